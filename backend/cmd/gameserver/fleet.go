@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"projectvelocity/backend/internal/shared/signing"
+	"projectvelocity/backend/internal/shared/types"
+)
+
+// fleetHeartbeatInterval keeps the server well inside the matchmaker's liveness window.
+const fleetHeartbeatInterval = 5 * time.Second
+
+// errNotRegistered means the matchmaker no longer knows this server and it must re-register.
+var errNotRegistered = errors.New("fleet: server not registered")
+
+// handleAllocate accepts a match the matchmaker reserved on this server and pre-creates
+// it with its roster so ticketed clients land in the right match. The endpoint shares the
+// public listener with the websocket, so the body must carry the matchmaker's signature
+// under the fleet key.
+func (s *server) handleAllocate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "bad_request"})
+		return
+	}
+	if err := signing.Verify(s.fleetKey, body, r.Header.Get(signing.Header)); err != nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_signature"})
+		return
+	}
+	var a types.MatchAllocation
+	if err := json.Unmarshal(body, &a); err != nil || a.MatchID == "" || len(a.Players) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "bad_request"})
+		return
+	}
+	m, err := s.matches.acquire(a.MatchID, a.Players)
+	if err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "server_full"})
+		return
+	}
+	m.configure(a)
+	s.log.Printf("allocated match %s (playlist=%s players=%d bots=%d)", a.MatchID, a.Playlist, len(a.Players), len(a.Bots))
+	writeJSON(w, http.StatusOK, map[string]string{"status": "allocated"})
+}
+
+// runFleetAgent registers with the matchmaker and heartbeats the hosted match list
+// until ctx is cancelled. Registration is retried whenever the matchmaker forgets us.
+func (s *server) runFleetAgent(ctx context.Context, matchmakerURL string, info types.GameServerRegistration) {
+	httpClient := &http.Client{Timeout: 5 * time.Second}
+	registered := false
+	ticker := time.NewTicker(fleetHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		if !registered {
+			if err := postFleet(ctx, httpClient, s.fleetKey, matchmakerURL+"/v1/fleet/register", info); err != nil {
+				s.log.Printf("fleet registration failed: %v", err)
+			} else {
+				registered = true
+				s.log.Printf("registered with matchmaker as %s (region=%s capacity=%d)", info.ServerID, info.Region, info.Capacity)
+			}
+		} else {
+			hb := types.GameServerHeartbeat{ServerID: info.ServerID}
+			for _, m := range s.matches.list() {
+				hb.Matches = append(hb.Matches, m.id)
+			}
+			err := postFleet(ctx, httpClient, s.fleetKey, matchmakerURL+"/v1/fleet/heartbeat", hb)
+			if errors.Is(err, errNotRegistered) {
+				registered = false
+				continue
+			}
+			if err != nil {
+				s.log.Printf("fleet heartbeat failed: %v", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// postFleet sends a registration or heartbeat signed with the fleet key, so only the
+// operator's servers can join the fleet and receive rosters.
+func postFleet(ctx context.Context, httpClient *http.Client, key []byte, url string, body interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(signing.Header, signing.Sign(key, payload))
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return errNotRegistered
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("matchmaker returned %d", resp.StatusCode)
+	}
+	return nil
+}
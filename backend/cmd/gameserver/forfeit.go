@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+
+	"projectvelocity/backend/internal/shared/types"
+)
+
+// forfeitVoteWindow is how long a team has to agree on a forfeit once a vote opens.
+const forfeitVoteWindow = 30 * time.Second
+
+// forfeitVote is one team's running vote to concede.
+type forfeitVote struct {
+	team     string
+	voters   []string
+	accepted map[string]bool
+	deadline time.Time
+}
+
+// voteForfeit records playerID's forfeit vote. Only a team that lost a player to an
+// abandon may vote, and the first acceptance opens a vote for every teammate connected
+// at that moment. It reports false when the player cannot vote. A passed vote ends the
+// match as the team's forfeit.
+func (m *match) voteForfeit(playerID string, accept bool, now time.Time) (types.ForfeitVote, bool) {
+	if m.world.Finished() {
+		return types.ForfeitVote{}, false
+	}
+	teams := m.carTeams()
+	team := teams[playerID]
+	if !m.deserted(team) {
+		return types.ForfeitVote{}, false
+	}
+
+	m.mu.Lock()
+	v, ok := m.votes[team]
+	if !ok || !now.Before(v.deadline) {
+		if !accept {
+			m.mu.Unlock()
+			return types.ForfeitVote{}, false
+		}
+		v = &forfeitVote{team: team, accepted: make(map[string]bool), deadline: now.Add(forfeitVoteWindow)}
+		for id := range m.clients {
+			if teams[id] == team {
+				v.voters = append(v.voters, id)
+			}
+		}
+		m.votes[team] = v
+	}
+	if !slices.Contains(v.voters, playerID) {
+		// A teammate who reconnected after the vote opened gets a say too.
+		v.voters = append(v.voters, playerID)
+	}
+	view := v.view()
+	switch {
+	case !accept:
+		delete(m.votes, team)
+		view.Status = "failed"
+	default:
+		v.accepted[playerID] = true
+		view = v.view()
+		if len(view.Accepted) == len(view.Voters) {
+			delete(m.votes, team)
+			m.forfeited = team
+			view.Status = "passed"
+		}
+	}
+	m.mu.Unlock()
+
+	if view.Status == "passed" {
+		m.world.Forfeit(team)
+	}
+	return view, true
+}
+
+// carTeams maps every player who has had a car in the match to their team.
+func (m *match) carTeams() map[string]string {
+	stats := m.world.Stats()
+	out := make(map[string]string, len(stats))
+	for _, p := range stats {
+		out[p.PlayerID] = p.Team
+	}
+	return out
+}
+
+// deserted reports whether a human on team has abandoned the match. Rostered teammates
+// who never connected count once a reconnect grace period has passed since the match
+// was created.
+func (m *match) deserted(team string) bool {
+	if team == "" {
+		return false
+	}
+	stats := m.world.Stats()
+	seen := make(map[string]bool, len(stats))
+	for _, p := range stats {
+		seen[p.PlayerID] = true
+		if p.Team == team && p.Abandoned && !p.IsBot {
+			return true
+		}
+	}
+	if time.Since(m.createdAt) < m.sessions.Grace() {
+		return false
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, id := range m.roster {
+		if !seen[id] && id != "bot" && m.teams[id] == team {
+			return true
+		}
+	}
+	return false
+}
+
+// broadcastForfeit sends a vote's state to everyone on the voting team.
+func (m *match) broadcastForfeit(vote types.ForfeitVote) {
+	payload, err := json.Marshal(types.ServerEnvelope{Type: "forfeit_vote", Forfeit: &vote, ServerMS: time.Now().UTC().UnixMilli()})
+	if err != nil {
+		return
+	}
+	teams := m.carTeams()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for id, c := range m.clients {
+		if teams[id] != vote.Team {
+			continue
+		}
+		select {
+		case c.send <- payload:
+		default:
+		}
+	}
+}
+
+func (v *forfeitVote) view() types.ForfeitVote {
+	out := types.ForfeitVote{
+		Team:       v.team,
+		Voters:     slices.Clone(v.voters),
+		Accepted:   []string{},
+		Status:     "open",
+		DeadlineMS: v.deadline.UnixMilli(),
+	}
+	for _, id := range v.voters {
+		if v.accepted[id] {
+			out.Accepted = append(out.Accepted, id)
+		}
+	}
+	return out
+}
@@ -1,45 +1,122 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
+	"slices"
 	"strconv"
-	"sync"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
 
+	"projectvelocity/backend/internal/clocksync"
+	"projectvelocity/backend/internal/matchauth"
+	"projectvelocity/backend/internal/replication"
+	"projectvelocity/backend/internal/session"
 	"projectvelocity/backend/internal/shared/logger"
 	"projectvelocity/backend/internal/shared/types"
-	"projectvelocity/backend/internal/simulation"
 )
 
+// closeSlowConsumer is sent when a client's send queue stays saturated for too long.
+const closeSlowConsumer = 4001
+
+// closeSuperseded is sent to an older socket when its player connects again.
+const closeSuperseded = 4002
+
+// rttProbeInterval doubles as the websocket keepalive and the RTT sample period.
+const rttProbeInterval = 2 * time.Second
+
+// maxPendingSyncs bounds the pong exchanges remembered while awaiting a client sync report.
+const maxPendingSyncs = 8
+
 type client struct {
 	playerID string
+	match    *match
 	conn     *websocket.Conn
 	send     chan []byte
+	pacer    *replication.Pacer
+	clock    *clocksync.Estimator
+	// pendingSync holds server timestamps of recent pongs keyed by client send time.
+	// It is only touched by the read pump.
+	pendingSync   map[int64]types.ClockSync
+	inputRejected bool
+	// ackTick is the newest snapshot tick the client has confirmed; 0 forces a full snapshot.
+	ackTick atomic.Uint64
+	closing atomic.Bool
+}
+
+// acknowledge advances the client's delta baseline, ignoring stale or reordered acks.
+func (c *client) acknowledge(tick uint64) {
+	for {
+		cur := c.ackTick.Load()
+		if tick <= cur || c.ackTick.CompareAndSwap(cur, tick) {
+			return
+		}
+	}
+}
+
+// devTicketKey must match the gateway's fallback so local runs work without configuration.
+const devTicketKey = "velocity-dev-match-ticket-key"
+
+// devFleetKey must match the matchmaker's fallback so local runs work without configuration.
+const devFleetKey = "velocity-dev-fleet-key"
+
+// serverMetrics are process-wide counters shared by every hosted match.
+type serverMetrics struct {
+	framesSent      atomic.Uint64
+	framesDropped   atomic.Uint64
+	slowDisconnects atomic.Uint64
+	inputsRejected  atomic.Uint64
+	matchesReaped   atomic.Uint64
 }
 
 type server struct {
-	log      *logger.Logger
-	world    *simulation.World
+	log       *logger.Logger
+	ticketKey []byte
+	// fleetKey authenticates allocations from the matchmaker and this server to it.
+	fleetKey []byte
+	matches  *registry
+	metrics  *serverMetrics
 	upgrader websocket.Upgrader
-
-	mu      sync.RWMutex
-	clients map[string]*client
 }
 
 func main() {
 	log := logger.New("gameserver")
 	addr := getEnv("GAME_ADDR", ":9003")
-	matchID := getEnv("MATCH_ID", fmt.Sprintf("local_%d", time.Now().UTC().Unix()))
 	durationSec := getEnvInt("MATCH_DURATION_SEC", 300)
+	graceSec := getEnvInt("RECONNECT_GRACE_SEC", int(session.DefaultGrace/time.Second))
+	maxMatches := getEnvInt("MAX_MATCHES", 64)
+	idleSec := getEnvInt("MATCH_IDLE_SEC", 60)
+	ticketKey := os.Getenv("MATCH_TICKET_KEY")
+	if ticketKey == "" {
+		ticketKey = devTicketKey
+		log.Printf("MATCH_TICKET_KEY not set; using insecure development key")
+	}
+	fleetKey := os.Getenv("FLEET_KEY")
+	if fleetKey == "" {
+		fleetKey = devFleetKey
+		log.Printf("FLEET_KEY not set; using insecure development key")
+	}
+	resultsKey := os.Getenv("RESULTS_KEY")
+	if resultsKey == "" {
+		resultsKey = devResultsKey
+		log.Printf("RESULTS_KEY not set; using insecure development key")
+	}
+	matchmakerURL := os.Getenv("MATCHMAKER_HTTP")
+	hostname, _ := os.Hostname()
+	serverID := getEnv("GAME_SERVER_ID", hostname+addr)
 
+	metrics := &serverMetrics{}
 	s := &server{
-		log:   log,
-		world: simulation.NewWorld(matchID, time.Duration(durationSec)*time.Second, nil),
+		log:       log,
+		ticketKey: []byte(ticketKey),
+		fleetKey:  []byte(fleetKey),
+		metrics:   metrics,
 		upgrader: websocket.Upgrader{
 			ReadBufferSize:  4096,
 			WriteBufferSize: 4096,
@@ -47,15 +124,40 @@ func main() {
 				return true
 			},
 		},
-		clients: make(map[string]*client),
 	}
+	reporter := &resultReporter{
+		log:      log,
+		key:      []byte(resultsKey),
+		serverID: serverID,
+		client:   &http.Client{Timeout: 5 * time.Second},
+	}
+	if matchmakerURL != "" {
+		reporter.url = matchmakerURL + "/v1/results"
+	}
+	s.matches = newRegistry(log, maxMatches, time.Duration(idleSec)*time.Second, func(id string, roster []string) *match {
+		m := newMatch(id, roster, time.Duration(durationSec)*time.Second, time.Duration(graceSec)*time.Second, log, metrics)
+		m.onEnd = reporter.report
+		return m
+	})
 
-	go s.runSimulationLoop()
-	go s.runReplicationLoop()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.matches.run(ctx, 5*time.Second)
+	if matchmakerURL != "" {
+		go s.runFleetAgent(ctx, matchmakerURL, types.GameServerRegistration{
+			ServerID:   serverID,
+			Region:     getEnv("GAME_REGION", "us-east"),
+			PublicAddr: getEnv("GAME_PUBLIC_ADDR", "ws://localhost:9003/ws"),
+			ControlURL: getEnv("GAME_CONTROL_URL", "http://localhost:9003"),
+			Capacity:   maxMatches,
+		})
+	}
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/health", s.handleHealth)
 	mux.HandleFunc("/ws", s.handleWS)
+	mux.HandleFunc("/metrics", s.handleMetrics)
+	mux.HandleFunc("/v1/matches", s.handleAllocate)
 
 	httpServer := &http.Server{
 		Addr:              addr,
@@ -63,25 +165,67 @@ func main() {
 		ReadHeaderTimeout: 5 * time.Second,
 	}
 
-	log.Printf("authoritative game server listening on %s (match=%s)", addr, matchID)
+	log.Printf("authoritative game server listening on %s (max_matches=%d)", addr, maxMatches)
 	if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		log.Fatalf("server failed: %v", err)
 	}
 }
 
 func (s *server) handleHealth(w http.ResponseWriter, _ *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	hosted, max := s.matches.capacity()
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": "ok", "matches": hosted, "max_matches": max})
 }
 
 func (s *server) handleWS(w http.ResponseWriter, r *http.Request) {
-	playerID := r.URL.Query().Get("player_id")
-	if playerID == "" {
-		playerID = fmt.Sprintf("guest_%d", time.Now().UTC().UnixNano())
-	}
-	displayName := r.URL.Query().Get("display_name")
-	if displayName == "" {
-		displayName = playerID
+	var m *match
+	var playerID, displayName string
+	if token := r.URL.Query().Get("resume"); token != "" {
+		hosted, ok := s.matches.get(r.URL.Query().Get("match_id"))
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "match_not_found"})
+			return
+		}
+		// The token is only redeemed once the upgrade succeeds; a failed upgrade must not
+		// cancel the grace period and strand the held car.
+		id, ok := hosted.sessions.Lookup(token)
+		if !ok {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_reconnect_token"})
+			return
+		}
+		m, playerID = hosted, id
+	} else {
+		// Identity comes only from the gateway-signed join ticket; query-string player ids are ignored.
+		claims, err := matchauth.Verify(s.ticketKey, r.URL.Query().Get("ticket"), time.Now().UTC())
+		if err != nil {
+			s.log.Printf("rejected connection remote=%s err=%v", r.RemoteAddr, err)
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_join_ticket"})
+			return
+		}
+		hosted, err := s.matches.acquire(claims.MatchID, claims.Players)
+		if err != nil {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "server_full"})
+			return
+		}
+		if !slices.Contains(hosted.roster, claims.PlayerID) {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "not_in_match"})
+			return
+		}
+		alloc := types.MatchAllocation{
+			Region:         claims.Region,
+			Playlist:       claims.Playlist,
+			Bots:           claims.Bots,
+			Private:        claims.Private,
+			Physics:        claims.Physics,
+			MatchLengthSec: claims.MatchLengthSec,
+		}
+		if claims.Team != "" {
+			alloc.Teams = map[string][]string{claims.Team: {claims.PlayerID}}
+		}
+		hosted.configure(alloc)
+		m, playerID, displayName = hosted, claims.PlayerID, claims.DisplayName
+		if displayName == "" {
+			displayName = playerID
+		}
 	}
 
 	conn, err := s.upgrader.Upgrade(w, r, nil)
@@ -89,18 +233,50 @@ func (s *server) handleWS(w http.ResponseWriter, r *http.Request) {
 		s.log.Printf("websocket upgrade error: %v", err)
 		return
 	}
+	if token := r.URL.Query().Get("resume"); token != "" {
+		if id, ok := m.sessions.Resume(token); !ok || id != playerID {
+			// Redeemed or revoked by a racing connection since the lookup.
+			msg := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "invalid_reconnect_token")
+			_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+			_ = conn.Close()
+			return
+		}
+	} else {
+		m.sessions.Reclaim(playerID)
+	}
 
-	team := s.world.EnsurePlayer(playerID, displayName)
-	s.maintainBotBalance(playerID)
-	c := &client{playerID: playerID, conn: conn, send: make(chan []byte, 64)}
-	s.register(c)
+	team := m.world.EnsurePlayerOnTeam(playerID, displayName, m.teamOf(playerID))
+	m.maintainBotBalance(playerID)
+	c := &client{
+		playerID: playerID,
+		match:    m,
+		conn:     conn,
+		send:     make(chan []byte, 64),
+		pacer:    replication.NewPacer(replication.DefaultPacerConfig()),
+		clock:    clocksync.NewEstimator(),
+
+		pendingSync: make(map[int64]types.ClockSync, maxPendingSyncs),
+	}
+	if prev := m.register(c); prev != nil {
+		closeClient(prev, closeSuperseded, "superseded")
+	}
+	reconnectToken, err := m.sessions.Issue(playerID)
+	if err != nil {
+		s.log.Printf("reconnect token failed player=%s err=%v", playerID, err)
+	}
 
-	s.log.Printf("client connected player=%s team=%s remote=%s", playerID, team, r.RemoteAddr)
+	s.log.Printf("client connected player=%s match=%s team=%s remote=%s", playerID, m.id, team, r.RemoteAddr)
+	// The welcome snapshot doubles as the catch-up frame for resumed players; a new
+	// client starts with no acknowledged baseline so replication stays on full snapshots
+	// until it acks one.
+	physics := m.world.Physics()
 	welcome := types.ServerEnvelope{
-		Type:     "welcome",
-		State:    ptrState(s.world.Snapshot()),
-		ServerMS: time.Now().UTC().UnixMilli(),
-		Message:  "connected",
+		Type:           "welcome",
+		State:          ptrState(m.world.Snapshot()),
+		ServerMS:       time.Now().UTC().UnixMilli(),
+		Message:        "connected",
+		Physics:        &physics,
+		ReconnectToken: reconnectToken,
 	}
 	if payload, err := json.Marshal(welcome); err == nil {
 		select {
@@ -115,18 +291,23 @@ func (s *server) handleWS(w http.ResponseWriter, r *http.Request) {
 
 func (s *server) readPump(c *client) {
 	defer func() {
-		s.unregister(c.playerID)
+		c.match.unregister(c)
 		_ = c.conn.Close()
 	}()
 
 	_ = c.conn.SetReadDeadline(time.Now().Add(90 * time.Second))
-	c.conn.SetPongHandler(func(string) error {
+	c.conn.SetPongHandler(func(appData string) error {
 		_ = c.conn.SetReadDeadline(time.Now().Add(90 * time.Second))
+		// Control-frame probes only drive pacing until the client completes a clock sync.
+		if sentNS, err := strconv.ParseInt(appData, 10, 64); err == nil && !c.clock.Ready() {
+			c.pacer.ObserveRTT(time.Since(time.Unix(0, sentNS)))
+		}
 		return nil
 	})
 
 	for {
 		_, msg, err := c.conn.ReadMessage()
+		recvMS := time.Now().UTC().UnixMilli()
 		if err != nil {
 			if websocket.IsCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
 				s.log.Printf("client disconnected player=%s", c.playerID)
@@ -141,6 +322,9 @@ func (s *server) readPump(c *client) {
 			s.sendError(c, "bad_payload")
 			continue
 		}
+		if in.AckTick > 0 {
+			c.acknowledge(in.AckTick)
+		}
 
 		switch in.Type {
 		case "input":
@@ -148,24 +332,96 @@ func (s *server) readPump(c *client) {
 				s.sendError(c, "missing_input")
 				continue
 			}
+			age, ok := c.clock.InputAgeMS(in.Input.ClientMS, recvMS)
+			if !ok {
+				s.metrics.inputsRejected.Add(1)
+				if !c.inputRejected {
+					c.inputRejected = true
+					s.sendError(c, "implausible_client_ms")
+				}
+				continue
+			}
+			c.inputRejected = false
 			in.Input.PlayerID = c.playerID
-			s.world.ApplyInput(*in.Input)
+			c.match.world.ApplyInputAt(*in.Input, age)
 		case "ping":
-			pong := types.ServerEnvelope{Type: "pong", ServerMS: time.Now().UTC().UnixMilli()}
-			if payload, err := json.Marshal(pong); err == nil {
-				select {
-				case c.send <- payload:
-				default:
-				}
+			s.sendPong(c, in.Sync, recvMS)
+		case "sync":
+			if in.Sync == nil {
+				s.sendError(c, "missing_sync")
+				continue
+			}
+			s.completeSync(c, *in.Sync, recvMS)
+		case "forfeit":
+			vote, ok := c.match.voteForfeit(c.playerID, in.Accept, time.Now().UTC())
+			if !ok {
+				s.sendError(c, "forfeit_unavailable")
+				continue
+			}
+			c.match.broadcastForfeit(vote)
+			if vote.Status == "passed" {
+				s.log.Printf("forfeit vote passed match=%s team=%s", c.match.id, vote.Team)
 			}
+		case "ack":
 		default:
 			s.sendError(c, "unsupported_message_type")
 		}
 	}
 }
 
+// sendPong answers a ping. When the client supplies its send time the pong carries the
+// server receive/send times so the client can derive RTT and offset NTP-style.
+func (s *server) sendPong(c *client, req *types.ClockSync, recvMS int64) {
+	pong := types.ServerEnvelope{Type: "pong"}
+	if req != nil && req.ClientSendMS > 0 {
+		pong.Sync = &types.ClockSync{
+			ClientSendMS: req.ClientSendMS,
+			ServerRecvMS: recvMS,
+			RTTMS:        c.clock.RTT().Milliseconds(),
+			OffsetMS:     c.clock.OffsetMS(),
+		}
+	}
+	pong.ServerMS = time.Now().UTC().UnixMilli()
+	if pong.Sync != nil {
+		pong.Sync.ServerSendMS = pong.ServerMS
+		if len(c.pendingSync) >= maxPendingSyncs {
+			clear(c.pendingSync)
+		}
+		c.pendingSync[pong.Sync.ClientSendMS] = *pong.Sync
+	}
+	if payload, err := json.Marshal(pong); err == nil {
+		select {
+		case c.send <- payload:
+		default:
+		}
+	}
+}
+
+// completeSync folds a client's report of a finished exchange into its estimates. Server
+// timestamps come from our own record, so the client only contributes its receive time.
+func (s *server) completeSync(c *client, report types.ClockSync, recvMS int64) {
+	exchange, ok := c.pendingSync[report.ClientSendMS]
+	if !ok {
+		return
+	}
+	delete(c.pendingSync, report.ClientSendMS)
+	exchange.ClientRecvMS = report.ClientRecvMS
+
+	sample, ok := clocksync.SampleFrom(exchange)
+	if !ok {
+		return
+	}
+	// The report trails the pong by a further one-way trip, so a genuine RTT can never
+	// exceed the server-observed time since the ping arrived.
+	if sample.RTTMS > float64(recvMS-exchange.ServerRecvMS) {
+		return
+	}
+	c.clock.Add(sample)
+	c.pacer.ObserveRTT(c.clock.RTT())
+}
+
 func (s *server) writePump(c *client) {
-	ticker := time.NewTicker(20 * time.Second)
+	ticker := time.NewTicker(rttProbeInterval)
 	defer func() {
 		ticker.Stop()
 		_ = c.conn.Close()
@@ -184,31 +440,14 @@ func (s *server) writePump(c *client) {
 			}
 		case <-ticker.C:
 			_ = c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			if err := c.conn.WriteMessage(websocket.PingMessage, []byte("keepalive")); err != nil {
+			probe := strconv.FormatInt(time.Now().UnixNano(), 10)
+			if err := c.conn.WriteMessage(websocket.PingMessage, []byte(probe)); err != nil {
 				return
 			}
 		}
 	}
 }
 
-func (s *server) register(c *client) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.clients[c.playerID] = c
-}
-
-func (s *server) unregister(playerID string) {
-	s.mu.Lock()
-	if c, ok := s.clients[playerID]; ok {
-		close(c.send)
-		delete(s.clients, playerID)
-	}
-	s.mu.Unlock()
-
-	s.world.RemovePlayer(playerID)
-	s.maintainBotBalance("")
-}
-
 func (s *server) sendError(c *client, message string) {
 	errPayload, _ := json.Marshal(types.ServerEnvelope{
 		Type:    "error",
@@ -220,61 +459,87 @@ func (s *server) sendError(c *client, message string) {
 	}
 }
 
-func (s *server) runSimulationLoop() {
-	ticker := time.NewTicker(time.Second / 120)
-	defer ticker.Stop()
-	dt := 1.0 / 120.0
-
-	for range ticker.C {
-		s.world.Tick(dt)
+// closeClient sends a close frame with a specific code and tears down the socket once.
+func closeClient(c *client, code int, reason string) bool {
+	if !c.closing.CompareAndSwap(false, true) {
+		return false
 	}
+	msg := websocket.FormatCloseMessage(code, reason)
+	_ = c.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+	_ = c.conn.Close()
+	return true
 }
 
-func (s *server) runReplicationLoop() {
-	ticker := time.NewTicker(time.Second / 60)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		state := s.world.Snapshot()
-		env := types.ServerEnvelope{
-			Type:     "state",
-			Tick:     state.Tick,
-			State:    &state,
-			ServerMS: time.Now().UTC().UnixMilli(),
-		}
-		payload, err := json.Marshal(env)
-		if err != nil {
-			s.log.Printf("marshal state failed: %v", err)
-			continue
-		}
-
-		s.mu.RLock()
-		for _, c := range s.clients {
-			select {
-			case c.send <- payload:
-			default:
-			}
+func (s *server) handleMetrics(w http.ResponseWriter, _ *http.Request) {
+	type clientStats struct {
+		matchID  string
+		playerID string
+		stats    replication.PacerStats
+		offsetMS int64
+	}
+	matches := s.matches.list()
+	var perClient []clientStats
+	for _, m := range matches {
+		m.mu.RLock()
+		for id, c := range m.clients {
+			perClient = append(perClient, clientStats{matchID: m.id, playerID: id, stats: c.pacer.Stats(), offsetMS: c.clock.OffsetMS()})
 		}
-		s.mu.RUnlock()
+		m.mu.RUnlock()
+	}
+	hosted, max := s.matches.capacity()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_, _ = fmt.Fprintln(w, "# HELP velocity_gameserver_matches Matches hosted by this process")
+	_, _ = fmt.Fprintln(w, "# TYPE velocity_gameserver_matches gauge")
+	_, _ = fmt.Fprintf(w, "velocity_gameserver_matches %d\n", hosted)
+	_, _ = fmt.Fprintln(w, "# HELP velocity_gameserver_max_matches Match capacity of this process")
+	_, _ = fmt.Fprintln(w, "# TYPE velocity_gameserver_max_matches gauge")
+	_, _ = fmt.Fprintf(w, "velocity_gameserver_max_matches %d\n", max)
+	_, _ = fmt.Fprintln(w, "# HELP velocity_gameserver_matches_reaped_total Idle or finished matches torn down")
+	_, _ = fmt.Fprintln(w, "# TYPE velocity_gameserver_matches_reaped_total counter")
+	_, _ = fmt.Fprintf(w, "velocity_gameserver_matches_reaped_total %d\n", s.metrics.matchesReaped.Load())
+	_, _ = fmt.Fprintln(w, "# HELP velocity_gameserver_clients Connected websocket clients")
+	_, _ = fmt.Fprintln(w, "# TYPE velocity_gameserver_clients gauge")
+	_, _ = fmt.Fprintf(w, "velocity_gameserver_clients %d\n", len(perClient))
+	_, _ = fmt.Fprintln(w, "# HELP velocity_gameserver_frames_sent_total Replication frames queued to clients")
+	_, _ = fmt.Fprintln(w, "# TYPE velocity_gameserver_frames_sent_total counter")
+	_, _ = fmt.Fprintf(w, "velocity_gameserver_frames_sent_total %d\n", s.metrics.framesSent.Load())
+	_, _ = fmt.Fprintln(w, "# HELP velocity_gameserver_frames_dropped_total Replication frames dropped on full send queues")
+	_, _ = fmt.Fprintln(w, "# TYPE velocity_gameserver_frames_dropped_total counter")
+	_, _ = fmt.Fprintf(w, "velocity_gameserver_frames_dropped_total %d\n", s.metrics.framesDropped.Load())
+	_, _ = fmt.Fprintln(w, "# HELP velocity_gameserver_slow_consumer_disconnects_total Clients closed for persistently full send queues")
+	_, _ = fmt.Fprintln(w, "# TYPE velocity_gameserver_slow_consumer_disconnects_total counter")
+	_, _ = fmt.Fprintf(w, "velocity_gameserver_slow_consumer_disconnects_total %d\n", s.metrics.slowDisconnects.Load())
+	_, _ = fmt.Fprintln(w, "# HELP velocity_gameserver_inputs_rejected_total Inputs dropped for implausible client timestamps")
+	_, _ = fmt.Fprintln(w, "# TYPE velocity_gameserver_inputs_rejected_total counter")
+	_, _ = fmt.Fprintf(w, "velocity_gameserver_inputs_rejected_total %d\n", s.metrics.inputsRejected.Load())
+	for _, m := range matches {
+		_, _ = fmt.Fprintf(w, "velocity_gameserver_match_clients{match_id=\"%s\"} %d\n", labelValue(m.id), m.clientCount())
+	}
+	for _, pc := range perClient {
+		labels := fmt.Sprintf("match_id=\"%s\",player_id=\"%s\"", labelValue(pc.matchID), labelValue(pc.playerID))
+		_, _ = fmt.Fprintf(w, "velocity_gameserver_client_send_rate_hz{%s} %d\n", labels, pc.stats.RateHz)
+		_, _ = fmt.Fprintf(w, "velocity_gameserver_client_rtt_ms{%s} %d\n", labels, pc.stats.RTT.Milliseconds())
+		_, _ = fmt.Fprintf(w, "velocity_gameserver_client_clock_offset_ms{%s} %d\n", labels, pc.offsetMS)
+		_, _ = fmt.Fprintf(w, "velocity_gameserver_client_queue_depth{%s} %d\n", labels, pc.stats.QueueDepth)
+		_, _ = fmt.Fprintf(w, "velocity_gameserver_client_frames_sent_total{%s} %d\n", labels, pc.stats.Sent)
+		_, _ = fmt.Fprintf(w, "velocity_gameserver_client_frames_dropped_total{%s} %d\n", labels, pc.stats.Dropped)
 	}
 }
 
-func (s *server) maintainBotBalance(preferredHuman string) {
-	humans := s.world.HumanCount()
-	switch {
-	case humans <= 0:
-		s.world.RemoveAllBots()
-	case humans == 1:
-		playerID := preferredHuman
-		if playerID == "" {
-			playerID = s.world.FirstHumanID()
-		}
-		if playerID != "" {
-			s.world.EnsureBotOpponent(playerID)
-		}
-	default:
-		s.world.RemoveAllBots()
-	}
+// labelEscaper escapes a Prometheus label value as the text exposition format requires.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labelValue escapes v for use inside a quoted label. Player and match ids come from
+// clients and tickets, so one stray quote or newline must not break the whole scrape.
+func labelValue(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
 }
 
 func getEnv(key, fallback string) string {
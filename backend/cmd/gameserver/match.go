@@ -0,0 +1,413 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"sync"
+	"time"
+
+	"projectvelocity/backend/internal/replication"
+	"projectvelocity/backend/internal/session"
+	"projectvelocity/backend/internal/shared/logger"
+	"projectvelocity/backend/internal/shared/types"
+	"projectvelocity/backend/internal/simulation"
+)
+
+// match is one isolated hosted game: its own world, tick loops, clients and reconnect sessions.
+type match struct {
+	id       string
+	log      *logger.Logger
+	metrics  *serverMetrics
+	world    *simulation.World
+	history  *replication.History
+	sessions *session.Manager
+	// roster is fixed by the first join ticket; later tickets must name a rostered player.
+	roster    []string
+	createdAt time.Time
+	// onEnd receives the final record exactly once, when the clock runs out or the
+	// match is torn down early.
+	onEnd   func(types.MatchResult)
+	endOnce sync.Once
+
+	mu      sync.RWMutex
+	clients map[string]*client
+	// region, playlist, teams and bots come from the matchmaker's allocation or join tickets.
+	region   string
+	playlist string
+	teams    map[string]string // player id -> orange|blue
+	bots     []types.BotSlot
+	// private lobby matches are reported as such so they are never rated.
+	private bool
+	// votes holds each team's open forfeit vote; forfeited is the team whose vote passed.
+	votes     map[string]*forfeitVote
+	forfeited string
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func newMatch(id string, roster []string, duration, grace time.Duration, log *logger.Logger, metrics *serverMetrics) *match {
+	m := &match{
+		id:        id,
+		log:       log,
+		metrics:   metrics,
+		world:     simulation.NewWorld(id, duration, nil),
+		history:   replication.NewHistory(replication.DefaultHistorySize),
+		roster:    append([]string(nil), roster...),
+		createdAt: time.Now().UTC(),
+		clients:   make(map[string]*client),
+		teams:     make(map[string]string),
+		votes:     make(map[string]*forfeitVote),
+		stop:      make(chan struct{}),
+	}
+	m.sessions = session.NewManager(grace, m.expirePlayer)
+	return m
+}
+
+func (m *match) start() {
+	go m.runSimulationLoop()
+	go m.runReplicationLoop()
+}
+
+// configure records the region, playlist and team assignments matchmaking chose. They
+// come from the fleet allocation or, for statically addressed servers, from join
+// tickets; the first non-empty value for each field or player wins. The physics preset
+// and match length arrive with the playlist and are applied once.
+func (m *match) configure(a types.MatchAllocation) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.region == "" {
+		m.region = a.Region
+	}
+	if m.playlist == "" {
+		m.playlist = a.Playlist
+		m.bots = slices.Clone(a.Bots)
+		m.private = a.Private
+		if p, ok := simulation.PhysicsPreset(a.Physics); ok {
+			m.world.SetPhysics(p)
+		}
+		if a.MatchLengthSec > 0 {
+			m.world.SetTimeRemaining(time.Duration(a.MatchLengthSec) * time.Second)
+		}
+	}
+	for team, players := range a.Teams {
+		for _, id := range players {
+			if _, ok := m.teams[id]; !ok && id != "bot" {
+				m.teams[id] = team
+			}
+		}
+	}
+}
+
+// teamOf returns the team matchmaking assigned the player to, or "".
+func (m *match) teamOf(playerID string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.teams[playerID]
+}
+
+// shutdown stops the tick loops and closes every connected client with code/reason.
+func (m *match) shutdown(code int, reason string) {
+	m.stopOnce.Do(func() { close(m.stop) })
+	m.end(false)
+	m.mu.RLock()
+	clients := make([]*client, 0, len(m.clients))
+	for _, c := range m.clients {
+		clients = append(clients, c)
+	}
+	m.mu.RUnlock()
+	for _, c := range clients {
+		closeClient(c, code, reason)
+	}
+}
+
+// idle reports whether nobody is connected and no car is held for a reconnect.
+func (m *match) idle() bool {
+	m.mu.RLock()
+	connected := len(m.clients)
+	m.mu.RUnlock()
+	return connected == 0 && m.world.HumanCount() == 0
+}
+
+func (m *match) clientCount() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.clients)
+}
+
+// register installs c as the player's live connection and returns any connection it replaced.
+func (m *match) register(c *client) *client {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	prev := m.clients[c.playerID]
+	m.clients[c.playerID] = c
+	return prev
+}
+
+// unregister detaches c. A dropped player keeps their car under autopilot for the
+// reconnect grace period; only players without a session are removed immediately.
+func (m *match) unregister(c *client) {
+	m.mu.Lock()
+	current, ok := m.clients[c.playerID]
+	if ok && current == c {
+		close(c.send)
+		delete(m.clients, c.playerID)
+	}
+	m.mu.Unlock()
+	if !ok || current != c {
+		// Superseded by a newer connection for the same player.
+		return
+	}
+
+	if m.sessions.Disconnected(c.playerID) && m.world.SetAutopilot(c.playerID, true) {
+		m.log.Printf("holding car match=%s player=%s grace=%s", m.id, c.playerID, m.sessions.Grace())
+		return
+	}
+	m.sessions.Forget(c.playerID)
+	m.world.RemovePlayer(c.playerID)
+	m.maintainBotBalance("")
+}
+
+// expirePlayer removes a held car once its reconnect grace period lapses.
+func (m *match) expirePlayer(playerID string) {
+	m.mu.RLock()
+	_, connected := m.clients[playerID]
+	m.mu.RUnlock()
+	if connected {
+		return
+	}
+	m.log.Printf("reconnect grace expired match=%s player=%s", m.id, playerID)
+	m.world.RemovePlayer(playerID)
+	m.maintainBotBalance("")
+}
+
+func (m *match) runSimulationLoop() {
+	ticker := time.NewTicker(time.Second / 120)
+	defer ticker.Stop()
+	dt := 1.0 / 120.0
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.world.Tick(dt)
+			if m.world.Finished() {
+				m.end(true)
+			}
+		}
+	}
+}
+
+func (m *match) runReplicationLoop() {
+	ticker := time.NewTicker(time.Second / 60)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.replicate()
+		}
+	}
+}
+
+func (m *match) replicate() {
+	state := m.world.Snapshot()
+	m.history.Push(state)
+	serverMS := time.Now().UTC().UnixMilli()
+
+	// Clients acknowledging the same baseline share one encoded payload.
+	var full []byte
+	deltas := make(map[uint64][]byte)
+
+	now := time.Now()
+	var slow []*client
+	m.mu.RLock()
+	for _, c := range m.clients {
+		c.pacer.ObserveQueue(len(c.send), cap(c.send))
+		if !c.pacer.Ready(now) {
+			continue
+		}
+		payload := m.encodeFor(c, state, serverMS, &full, deltas)
+		if payload == nil {
+			continue
+		}
+		select {
+		case c.send <- payload:
+			c.pacer.Delivered()
+			m.metrics.framesSent.Add(1)
+		default:
+			m.metrics.framesDropped.Add(1)
+			if c.pacer.Dropped(now) {
+				slow = append(slow, c)
+			}
+		}
+	}
+	m.mu.RUnlock()
+
+	for _, c := range slow {
+		m.disconnectSlowConsumer(c)
+	}
+}
+
+// disconnectSlowConsumer closes a client whose queue never drains. The read pump
+// observes the closed connection and performs the normal unregister path.
+func (m *match) disconnectSlowConsumer(c *client) {
+	stats := c.pacer.Stats()
+	if closeClient(c, closeSlowConsumer, "slow_consumer") {
+		m.metrics.slowDisconnects.Add(1)
+		m.log.Printf("disconnected slow consumer match=%s player=%s dropped=%d rtt=%s", m.id, c.playerID, stats.Dropped, stats.RTT)
+	}
+}
+
+// encodeFor picks a delta against the client's acknowledged baseline, falling back to a full
+// snapshot when the client has never acknowledged or its baseline has left the history window.
+func (m *match) encodeFor(c *client, state types.MatchState, serverMS int64, full *[]byte, deltas map[uint64][]byte) []byte {
+	ack := c.ackTick.Load()
+	if ack > 0 && ack <= state.Tick {
+		if payload, ok := deltas[ack]; ok {
+			return payload
+		}
+		if base, ok := m.history.Get(ack); ok {
+			delta := replication.Diff(base, state)
+			payload, err := json.Marshal(types.ServerEnvelope{
+				Type:     "delta",
+				Tick:     state.Tick,
+				Delta:    &delta,
+				ServerMS: serverMS,
+			})
+			if err != nil {
+				m.log.Printf("marshal delta failed: %v", err)
+				return nil
+			}
+			deltas[ack] = payload
+			return payload
+		}
+	}
+
+	if *full == nil {
+		payload, err := json.Marshal(types.ServerEnvelope{
+			Type:     "state",
+			Tick:     state.Tick,
+			State:    &state,
+			ServerMS: serverMS,
+		})
+		if err != nil {
+			m.log.Printf("marshal state failed: %v", err)
+			return nil
+		}
+		*full = payload
+	}
+	return *full
+}
+
+// end reports the final record once. completed is false when the match is torn down
+// before its clock ran out.
+func (m *match) end(completed bool) {
+	m.endOnce.Do(func() {
+		if m.onEnd != nil {
+			m.onEnd(m.result(time.Now().UTC(), completed))
+		}
+	})
+}
+
+// result builds the final match record. Rostered players who never connected are listed
+// as no-shows on the team they were assigned, and as abandoned too if another human
+// played. A passed forfeit vote, or an early end where one team's humans all abandoned,
+// is that team's forfeit.
+func (m *match) result(now time.Time, completed bool) types.MatchResult {
+	state := m.world.Snapshot()
+	players := m.world.Stats()
+	seen := make(map[string]bool, len(players))
+	played := false
+	for _, p := range players {
+		seen[p.PlayerID] = true
+		played = played || !p.IsBot
+	}
+
+	m.mu.RLock()
+	for _, id := range m.roster {
+		if !seen[id] && id != "bot" {
+			players = append(players, types.PlayerMatchStats{PlayerID: id, Team: m.teams[id], NoShow: true, Abandoned: played})
+		}
+	}
+	forfeited := m.forfeited
+	r := types.MatchResult{
+		MatchID:     m.id,
+		Region:      m.region,
+		Playlist:    m.playlist,
+		StartedAt:   m.createdAt.UnixMilli(),
+		EndedAt:     now.UnixMilli(),
+		DurationMS:  now.Sub(m.createdAt).Milliseconds(),
+		OrangeScore: state.Score.Orange,
+		BlueScore:   state.Score.Blue,
+		Private:     m.private,
+		Players:     players,
+	}
+	m.mu.RUnlock()
+
+	switch {
+	case r.OrangeScore > r.BlueScore:
+		r.Winner = "orange"
+	case r.BlueScore > r.OrangeScore:
+		r.Winner = "blue"
+	default:
+		r.Winner = "draw"
+	}
+	switch forfeited {
+	case "orange":
+		r.Forfeit, r.ForfeitTeam, r.Winner = true, "orange", "blue"
+	case "blue":
+		r.Forfeit, r.ForfeitTeam, r.Winner = true, "blue", "orange"
+	}
+	if !completed && !r.Forfeit {
+		humans := map[string]int{}
+		left := map[string]int{}
+		for _, p := range players {
+			if p.IsBot || p.Team == "" {
+				continue
+			}
+			humans[p.Team]++
+			if p.Abandoned {
+				left[p.Team]++
+			}
+		}
+		orangeGone := humans["orange"] > 0 && left["orange"] == humans["orange"]
+		blueGone := humans["blue"] > 0 && left["blue"] == humans["blue"]
+		switch {
+		case orangeGone && !blueGone:
+			r.Forfeit, r.ForfeitTeam, r.Winner = true, "orange", "blue"
+		case blueGone && !orangeGone:
+			r.Forfeit, r.ForfeitTeam, r.Winner = true, "blue", "orange"
+		}
+	}
+	return r
+}
+
+// maintainBotBalance spawns the bots matchmaking asked for. Matches started without a
+// playlist, such as direct dev connections, give a lone human one opponent instead.
+func (m *match) maintainBotBalance(preferredHuman string) {
+	m.mu.RLock()
+	configured, bots := m.playlist != "", m.bots
+	m.mu.RUnlock()
+
+	humans := m.world.HumanCount()
+	switch {
+	case humans <= 0:
+		m.world.RemoveAllBots()
+	case configured:
+		m.world.SetBots(bots)
+	case humans == 1:
+		playerID := preferredHuman
+		if playerID == "" {
+			playerID = m.world.FirstHumanID()
+		}
+		if playerID != "" {
+			m.world.EnsureBotOpponent(playerID)
+		}
+	default:
+		m.world.RemoveAllBots()
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"projectvelocity/backend/internal/shared/logger"
+)
+
+// closeMatchEnded is sent to clients still connected when a match is torn down.
+const closeMatchEnded = 4003
+
+var errAtCapacity = errors.New("gameserver at match capacity")
+
+// registry hosts many isolated matches in one process and tears down idle ones.
+type registry struct {
+	log         *logger.Logger
+	maxMatches  int
+	idleTimeout time.Duration
+	create      func(id string, roster []string) *match
+
+	mu        sync.Mutex
+	matches   map[string]*match
+	idleSince map[string]time.Time
+}
+
+func newRegistry(log *logger.Logger, maxMatches int, idleTimeout time.Duration, create func(id string, roster []string) *match) *registry {
+	if maxMatches <= 0 {
+		maxMatches = 1
+	}
+	return &registry{
+		log:         log,
+		maxMatches:  maxMatches,
+		idleTimeout: idleTimeout,
+		create:      create,
+		matches:     make(map[string]*match),
+		idleSince:   make(map[string]time.Time),
+	}
+}
+
+// acquire returns the match for id, starting it with roster if it is not hosted yet.
+func (r *registry) acquire(id string, roster []string) (*match, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if m, ok := r.matches[id]; ok {
+		// A fresh join restarts the idle window so the match is not reaped under the player.
+		delete(r.idleSince, id)
+		return m, nil
+	}
+	if len(r.matches) >= r.maxMatches {
+		return nil, errAtCapacity
+	}
+	m := r.create(id, roster)
+	r.matches[id] = m
+	m.start()
+	r.log.Printf("match started id=%s roster=%d hosted=%d/%d", id, len(roster), len(r.matches), r.maxMatches)
+	return m, nil
+}
+
+func (r *registry) get(id string) (*match, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.matches[id]
+	return m, ok
+}
+
+// list returns hosted matches ordered by id.
+func (r *registry) list() []*match {
+	r.mu.Lock()
+	out := make([]*match, 0, len(r.matches))
+	for _, m := range r.matches {
+		out = append(out, m)
+	}
+	r.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].id < out[j].id })
+	return out
+}
+
+func (r *registry) capacity() (hosted, max int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.matches), r.maxMatches
+}
+
+// run reaps idle and finished matches until ctx is cancelled.
+func (r *registry) run(ctx context.Context, cadence time.Duration) {
+	ticker := time.NewTicker(cadence)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.reap(time.Now().UTC())
+		}
+	}
+}
+
+// reap tears down matches that have been idle, or finished, for longer than idleTimeout.
+func (r *registry) reap(now time.Time) {
+	var doomed []*match
+	r.mu.Lock()
+	for id, m := range r.matches {
+		if !m.idle() && !m.world.Finished() {
+			delete(r.idleSince, id)
+			continue
+		}
+		since, ok := r.idleSince[id]
+		if !ok {
+			r.idleSince[id] = now
+			continue
+		}
+		if now.Sub(since) < r.idleTimeout {
+			continue
+		}
+		delete(r.matches, id)
+		delete(r.idleSince, id)
+		doomed = append(doomed, m)
+	}
+	r.mu.Unlock()
+
+	for _, m := range doomed {
+		m.shutdown(closeMatchEnded, "match_ended")
+		m.metrics.matchesReaped.Add(1)
+		r.log.Printf("match torn down id=%s age=%s", m.id, now.Sub(m.createdAt).Round(time.Second))
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"projectvelocity/backend/internal/shared/logger"
+	"projectvelocity/backend/internal/shared/signing"
+	"projectvelocity/backend/internal/shared/types"
+)
+
+// devResultsKey must match the matchmaker's fallback so local runs work without configuration.
+const devResultsKey = "velocity-dev-results-key"
+
+// resultSubmitAttempts bounds retries of a result the matchmaker could not accept.
+const resultSubmitAttempts = 5
+
+// errPermanent marks a rejection that retrying cannot fix.
+type errPermanent struct{ status int }
+
+func (e errPermanent) Error() string { return fmt.Sprintf("results endpoint returned %d", e.status) }
+
+// resultReporter posts signed final match records to the matchmaker's results endpoint.
+type resultReporter struct {
+	log      *logger.Logger
+	url      string
+	key      []byte
+	serverID string
+	client   *http.Client
+}
+
+// report submits r in the background, retrying transient failures with backoff. The
+// endpoint is idempotent by match id, so a retry after a lost response is harmless.
+func (rr *resultReporter) report(r types.MatchResult) {
+	r.ServerID = rr.serverID
+	rr.log.Printf("match ended id=%s score=%d-%d winner=%s forfeit=%v players=%d", r.MatchID, r.OrangeScore, r.BlueScore, r.Winner, r.Forfeit, len(r.Players))
+	if rr.url == "" {
+		return
+	}
+	body, err := json.Marshal(r)
+	if err != nil {
+		rr.log.Printf("marshal result failed match=%s: %v", r.MatchID, err)
+		return
+	}
+	go func() {
+		backoff := time.Second
+		for attempt := 1; attempt <= resultSubmitAttempts; attempt++ {
+			err := rr.submit(body)
+			if err == nil {
+				return
+			}
+			if _, ok := err.(errPermanent); ok || attempt == resultSubmitAttempts {
+				rr.log.Printf("result submission failed match=%s attempt=%d: %v", r.MatchID, attempt, err)
+				return
+			}
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}()
+}
+
+func (rr *resultReporter) submit(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, rr.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(signing.Header, signing.Sign(rr.key, body))
+	resp, err := rr.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode >= 500:
+		return fmt.Errorf("results endpoint returned %d", resp.StatusCode)
+	default:
+		return errPermanent{status: resp.StatusCode}
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"projectvelocity/backend/internal/account"
+	"projectvelocity/backend/internal/shared/types"
+)
+
+// handleGuestAuth signs a guest in. With a device id the device's account is reused, or
+// created on first sight, so the player keeps one id across sessions and restarts;
+// without one the guest lasts only as long as its session. It also serves
+// /v1/auth/device.
+func (g *gateway) handleGuestAuth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+		return
+	}
+	var req types.GuestAuthRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "bad_request"})
+		return
+	}
+	if req.DeviceID == "" && r.URL.Path == "/v1/auth/device" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "device_id_required"})
+		return
+	}
+
+	var a account.Account
+	var err error
+	if req.DeviceID != "" {
+		a, _, err = g.accounts.Device(req.DeviceID, req.DisplayName)
+	} else {
+		a, err = g.accounts.Guest(req.DisplayName)
+	}
+	if err != nil {
+		writeAccountError(w, err)
+		return
+	}
+	g.startSession(w, a)
+}
+
+// handleRegister creates a full account and signs it in.
+func (g *gateway) handleRegister(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeAccountRequest(w, r)
+	if !ok {
+		return
+	}
+	a, err := g.accounts.Register(req.Username, req.Password, req.DisplayName, req.DeviceID)
+	if err != nil {
+		writeAccountError(w, err)
+		return
+	}
+	g.startSession(w, a)
+}
+
+// handleLogin signs in with a username and password.
+func (g *gateway) handleLogin(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeAccountRequest(w, r)
+	if !ok {
+		return
+	}
+	a, err := g.accounts.Login(req.Username, req.Password, req.DeviceID)
+	if err != nil {
+		writeAccountError(w, err)
+		return
+	}
+	g.startSession(w, a)
+}
+
+// handleUpgrade turns the caller's guest account into a full one. The player id, and
+// with it every rating and match record, stays the same; the current session stays valid.
+func (g *gateway) handleUpgrade(w http.ResponseWriter, r *http.Request) {
+	session, ok := g.authedPost(w, r)
+	if !ok {
+		return
+	}
+	var req types.AccountAuthRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "bad_request"})
+		return
+	}
+	a, err := g.accounts.Upgrade(session.PlayerID, req.Username, req.Password, req.DisplayName)
+	if err != nil {
+		writeAccountError(w, err)
+		return
+	}
+	resp := authResponse(a)
+	resp.ExpiresAt = session.ExpiresAt
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleMe returns the caller's account.
+func (g *gateway) handleMe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+		return
+	}
+	session, ok := g.validateAuth(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_auth"})
+		return
+	}
+	a, err := g.accounts.Get(session.PlayerID)
+	if err != nil {
+		writeAccountError(w, err)
+		return
+	}
+	resp := authResponse(a)
+	resp.ExpiresAt = session.ExpiresAt
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleLogout ends the caller's session.
+func (g *gateway) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+		return
+	}
+	token, ok := bearerToken(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_auth"})
+		return
+	}
+	if err := g.accounts.EndSession(token); err != nil {
+		writeAccountError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "signed_out"})
+}
+
+func (g *gateway) startSession(w http.ResponseWriter, a account.Account) {
+	token, session, err := g.accounts.StartSession(a.PlayerID)
+	if err != nil {
+		g.log.Printf("start session player=%s err=%v", a.PlayerID, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "token_generation_failed"})
+		return
+	}
+	resp := authResponse(a)
+	resp.Token, resp.ExpiresAt = token, session.ExpiresAt
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func decodeAccountRequest(w http.ResponseWriter, r *http.Request) (types.AccountAuthRequest, bool) {
+	var req types.AccountAuthRequest
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+		return req, false
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "bad_request"})
+		return req, false
+	}
+	return req, true
+}
+
+func authResponse(a account.Account) types.AuthResponse {
+	return types.AuthResponse{
+		PlayerID:    a.PlayerID,
+		DisplayName: a.DisplayName,
+		Username:    a.Username,
+		Guest:       a.Guest(),
+		CreatedAt:   a.CreatedAt.Unix(),
+	}
+}
+
+// bearerToken returns the token from the Authorization header.
+func bearerToken(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return token, ok && token != ""
+}
+
+func writeAccountError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, account.ErrNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "account_not_found"})
+	case errors.Is(err, account.ErrBadCredentials):
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_credentials"})
+	case errors.Is(err, account.ErrUsernameTaken):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "username_taken"})
+	case errors.Is(err, account.ErrNotGuest):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "not_guest"})
+	case errors.Is(err, account.ErrBadUsername):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_username"})
+	case errors.Is(err, account.ErrWeakPassword):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "weak_password"})
+	case errors.Is(err, account.ErrBadDevice):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_device_id"})
+	default:
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "account_store_error"})
+	}
+}
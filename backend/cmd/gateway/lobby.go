@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"projectvelocity/backend/internal/shared/types"
+)
+
+// lobbyBody is what a client may send to a lobby route. Identity is never taken from
+// the client; the gateway adds it from the session.
+type lobbyBody struct {
+	Code     string              `json:"code,omitempty"`
+	TargetID string              `json:"target_id,omitempty"`
+	Team     string              `json:"team,omitempty"`
+	Settings types.LobbySettings `json:"settings"`
+}
+
+func (g *gateway) handleLobbyGet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+		return
+	}
+	session, ok := g.validateAuth(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_auth"})
+		return
+	}
+	query := url.Values{}
+	query.Set("player_id", session.PlayerID)
+	code, body, err := g.proxyRequest(http.MethodGet, g.matchmaker+"/v1/lobby?"+query.Encode(), nil)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": "matchmaker_unavailable"})
+		return
+	}
+	g.writeLobby(w, session, code, body)
+}
+
+func (g *gateway) handleLobbyPresets(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+		return
+	}
+	code, body, err := g.proxyRequest(http.MethodGet, g.matchmaker+"/v1/lobby/presets", nil)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": "matchmaker_unavailable"})
+		return
+	}
+	writeRawJSON(w, code, body)
+}
+
+// handleLobbyAction proxies one lobby mutation to the matchmaker on behalf of the
+// session's player.
+func (g *gateway) handleLobbyAction(action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, ok := g.authedPost(w, r)
+		if !ok {
+			return
+		}
+		var body lobbyBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "bad_request"})
+			return
+		}
+		buf, _ := json.Marshal(struct {
+			PlayerID    string `json:"player_id"`
+			DisplayName string `json:"display_name"`
+			lobbyBody
+		}{session.PlayerID, session.DisplayName, body})
+		code, out, err := g.proxyRequest(http.MethodPost, g.matchmaker+"/v1/lobby/"+action, bytes.NewReader(buf))
+		if err != nil {
+			writeJSON(w, http.StatusBadGateway, map[string]string{"error": "matchmaker_unavailable"})
+			return
+		}
+		if action == "leave" {
+			writeRawJSON(w, code, out)
+			return
+		}
+		g.writeLobby(w, session, code, out)
+	}
+}
+
+// writeLobby relays a lobby from the matchmaker, signing the caller a join ticket once
+// the lobby's match has started.
+func (g *gateway) writeLobby(w http.ResponseWriter, session authSession, code int, body []byte) {
+	if code != http.StatusOK {
+		writeRawJSON(w, code, body)
+		return
+	}
+	var l types.Lobby
+	if err := json.Unmarshal(body, &l); err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": "matchmaker_bad_response"})
+		return
+	}
+	if err := g.attachJoinTicket(session, l.Assignment); err != nil {
+		writeJoinTicketError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, l)
+}
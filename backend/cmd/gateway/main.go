@@ -2,20 +2,29 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
-	"encoding/base64"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
-	"sync"
+	"slices"
 	"time"
 
+	"projectvelocity/backend/internal/account"
+	"projectvelocity/backend/internal/matchauth"
+	"projectvelocity/backend/internal/party"
 	"projectvelocity/backend/internal/shared/logger"
 	"projectvelocity/backend/internal/shared/types"
 )
 
+// devTicketKey must match the game server's fallback so local runs work without configuration.
+const devTicketKey = "velocity-dev-match-ticket-key"
+
+var errTicketNotOwned = errors.New("gateway: ticket not owned")
+
 type authSession struct {
 	PlayerID    string
 	DisplayName string
@@ -23,31 +32,101 @@ type authSession struct {
 }
 
 type gateway struct {
-	log          *logger.Logger
-	matchmaker   string
-	httpClient   *http.Client
-	authMu       sync.RWMutex
-	authSessions map[string]authSession
+	log        *logger.Logger
+	matchmaker string
+	httpClient *http.Client
+	// streamClient has no overall timeout; its requests are bounded by the caller's context.
+	streamClient *http.Client
+	ticketKey    []byte
+	// streamKey signs stream tokens. It is made fresh at startup: tokens only live for
+	// streamTokenTTL, and a client whose token is refused falls back to long-polling.
+	streamKey []byte
+	accounts  *account.Manager
+	parties   *party.Manager
 }
 
 func main() {
 	log := logger.New("gateway")
 	addr := getenv("GATEWAY_ADDR", ":9000")
 	matchmakerURL := getenv("MATCHMAKER_HTTP", "http://localhost:9001")
+	ticketKey := os.Getenv("MATCH_TICKET_KEY")
+	if ticketKey == "" {
+		ticketKey = devTicketKey
+		log.Printf("MATCH_TICKET_KEY not set; using insecure development key")
+	}
+
+	var store account.Store = account.NewMemoryStore()
+	if dir := os.Getenv("GATEWAY_DATA_DIR"); dir != "" {
+		fs, err := account.OpenFileStore(dir)
+		if err != nil {
+			log.Fatalf("open account store: %v", err)
+		}
+		defer fs.Close()
+		store = fs
+	} else {
+		log.Printf("GATEWAY_DATA_DIR not set; accounts are kept in memory")
+	}
+	accounts, err := account.NewManager(store)
+	if err != nil {
+		log.Fatalf("load accounts: %v", err)
+	}
+	total, guests := accounts.Count()
+	log.Printf("loaded %d accounts (%d guests)", total, guests)
+	go func() {
+		for t := range time.Tick(time.Hour) {
+			accounts.Expire(t.UTC())
+		}
+	}()
+
+	streamKey := make([]byte, 32)
+	if _, err := rand.Read(streamKey); err != nil {
+		log.Fatalf("stream key: %v", err)
+	}
 
 	g := &gateway{
 		log:          log,
 		matchmaker:   matchmakerURL,
 		httpClient:   &http.Client{Timeout: 5 * time.Second},
-		authSessions: make(map[string]authSession),
+		streamClient: &http.Client{},
+		ticketKey:    []byte(ticketKey),
+		streamKey:    streamKey,
+		accounts:     accounts,
+		parties:      party.NewManager(),
 	}
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/health", g.handleHealth)
 	mux.HandleFunc("/v1/auth/guest", g.handleGuestAuth)
+	mux.HandleFunc("/v1/auth/device", g.handleGuestAuth)
+	mux.HandleFunc("/v1/auth/register", g.handleRegister)
+	mux.HandleFunc("/v1/auth/login", g.handleLogin)
+	mux.HandleFunc("/v1/auth/upgrade", g.handleUpgrade)
+	mux.HandleFunc("/v1/auth/me", g.handleMe)
+	mux.HandleFunc("/v1/auth/logout", g.handleLogout)
 	mux.HandleFunc("/v1/matchmaking/join", g.handleMatchJoin)
 	mux.HandleFunc("/v1/matchmaking/poll", g.handleMatchPoll)
+	mux.HandleFunc("/v1/matchmaking/stream", g.handleMatchStream)
+	mux.HandleFunc("/v1/matchmaking/stream-token", g.handleStreamToken)
 	mux.HandleFunc("/v1/matchmaking/leave", g.handleMatchLeave)
+	mux.HandleFunc("/v1/matchmaking/accept", g.handleMatchAccept)
+	mux.HandleFunc("/v1/matches/history", g.handleMatchHistory)
+	mux.HandleFunc("/v1/conduct", g.handleConduct)
+	mux.HandleFunc("/v1/party", g.handlePartyGet)
+	mux.HandleFunc("/v1/party/create", g.handlePartyCreate)
+	mux.HandleFunc("/v1/party/invite", g.handlePartyInvite)
+	mux.HandleFunc("/v1/party/accept", g.handlePartyAccept)
+	mux.HandleFunc("/v1/party/leave", g.handlePartyLeave)
+	mux.HandleFunc("/v1/party/disband", g.handlePartyDisband)
+	mux.HandleFunc("/v1/lobby", g.handleLobbyGet)
+	mux.HandleFunc("/v1/lobby/presets", g.handleLobbyPresets)
+	for _, action := range []string{"create", "join", "leave", "move", "settings", "start"} {
+		mux.HandleFunc("/v1/lobby/"+action, g.handleLobbyAction(action))
+	}
+	mux.HandleFunc("/v1/tournaments", g.handleTournamentList)
+	mux.HandleFunc("/v1/tournaments/match", g.handleTournamentMatch)
+	for _, action := range []string{"create", "register", "withdraw", "start"} {
+		mux.HandleFunc("/v1/tournaments/"+action, g.handleTournamentAction(action))
+	}
 
 	httpServer := &http.Server{
 		Addr:              addr,
@@ -65,42 +144,6 @@ func (g *gateway) handleHealth(w http.ResponseWriter, _ *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
 }
 
-func (g *gateway) handleGuestAuth(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
-		return
-	}
-
-	var req types.GuestAuthRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "bad_request"})
-		return
-	}
-	if req.DisplayName == "" {
-		req.DisplayName = "pilot"
-	}
-
-	playerID := fmt.Sprintf("player_%d", time.Now().UTC().UnixNano())
-	token, err := randomToken(32)
-	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "token_generation_failed"})
-		return
-	}
-	expiresAt := time.Now().UTC().Add(24 * time.Hour).Unix()
-
-	g.authMu.Lock()
-	g.authSessions[token] = authSession{PlayerID: playerID, DisplayName: req.DisplayName, ExpiresAt: expiresAt}
-	g.authMu.Unlock()
-
-	resp := types.GuestAuthResponse{
-		PlayerID:    playerID,
-		DisplayName: req.DisplayName,
-		Token:       token,
-		ExpiresAt:   expiresAt,
-	}
-	writeJSON(w, http.StatusOK, resp)
-}
-
 func (g *gateway) handleMatchJoin(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
@@ -126,8 +169,20 @@ func (g *gateway) handleMatchJoin(w http.ResponseWriter, r *http.Request) {
 	if req.Playlist == "" {
 		req.Playlist = "ranked-1v1"
 	}
-	if req.MMR <= 0 {
-		req.MMR = 1000
+	// Skill is owned by the matchmaker's rating service; never trust the client's value.
+	req.MMR = 0
+	req.PartyID, req.Members = "", nil
+	p, inParty := g.parties.Get(session.PlayerID)
+	if inParty {
+		if p.LeaderID != session.PlayerID {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "not_party_leader"})
+			return
+		}
+		if p.TicketID != "" {
+			g.cancelTicket(p.TicketID, session.PlayerID)
+		}
+		req.PartyID = p.PartyID
+		req.Members = p.Members
 	}
 
 	buf, _ := json.Marshal(req)
@@ -136,6 +191,12 @@ func (g *gateway) handleMatchJoin(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusBadGateway, map[string]string{"error": "matchmaker_unavailable"})
 		return
 	}
+	if inParty && code == http.StatusOK {
+		var resp types.QueueJoinResponse
+		if json.Unmarshal(body, &resp) == nil {
+			_ = g.parties.SetTicket(session.PlayerID, resp.TicketID)
+		}
+	}
 	writeRawJSON(w, code, body)
 }
 
@@ -144,7 +205,8 @@ func (g *gateway) handleMatchPoll(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
 		return
 	}
-	if _, ok := g.validateAuth(r); !ok {
+	session, ok := g.validateAuth(r)
+	if !ok {
 		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_auth"})
 		return
 	}
@@ -155,13 +217,73 @@ func (g *gateway) handleMatchPoll(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	url := g.matchmaker + "/v1/queue/poll?ticket_id=" + ticketID
-	code, body, err := g.proxyRequest(http.MethodGet, url, nil)
+	query := url.Values{}
+	query.Set("ticket_id", ticketID)
+	client := g.httpClient
+	if since := r.URL.Query().Get("since"); since != "" {
+		// Long-poll: the matchmaker holds the request for up to wait_ms.
+		query.Set("since", since)
+		query.Set("wait_ms", r.URL.Query().Get("wait_ms"))
+		client = g.streamClient
+	}
+	code, body, err := g.proxyRequestWith(r.Context(), client, http.MethodGet, g.matchmaker+"/v1/queue/poll?"+query.Encode(), nil)
 	if err != nil {
 		writeJSON(w, http.StatusBadGateway, map[string]string{"error": "matchmaker_unavailable"})
 		return
 	}
-	writeRawJSON(w, code, body)
+	if code != http.StatusOK {
+		writeRawJSON(w, code, body)
+		return
+	}
+
+	var poll types.QueuePollResponse
+	if err := json.Unmarshal(body, &poll); err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": "matchmaker_bad_response"})
+		return
+	}
+	if err := g.attachJoinTicket(session, poll.Assignment); err != nil {
+		writeJoinTicketError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, poll)
+}
+
+// attachJoinTicket signs a join ticket onto assignment, if there is one, after
+// checking that the session's player is part of it.
+func (g *gateway) attachJoinTicket(session authSession, assignment *types.MatchAssignment) error {
+	if assignment == nil {
+		return nil
+	}
+	if !slices.Contains(assignment.Players, session.PlayerID) {
+		return errTicketNotOwned
+	}
+	joinTicket, err := matchauth.Issue(g.ticketKey, matchauth.Claims{
+		PlayerID:       session.PlayerID,
+		DisplayName:    session.DisplayName,
+		MatchID:        assignment.MatchID,
+		Players:        assignment.Players,
+		Region:         assignment.Region,
+		Playlist:       assignment.Playlist,
+		Team:           teamOf(assignment.Teams, session.PlayerID),
+		Bots:           assignment.Bots,
+		Private:        assignment.Private,
+		Physics:        assignment.Physics,
+		MatchLengthSec: assignment.MatchLengthSec,
+		ExpiresAt:      time.Now().UTC().Add(matchauth.DefaultTTL).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+	assignment.JoinTicket = joinTicket
+	return nil
+}
+
+func writeJoinTicketError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errTicketNotOwned) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "ticket_not_owned"})
+		return
+	}
+	writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "join_ticket_failed"})
 }
 
 func (g *gateway) handleMatchLeave(w http.ResponseWriter, r *http.Request) {
@@ -169,17 +291,20 @@ func (g *gateway) handleMatchLeave(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
 		return
 	}
-	if _, ok := g.validateAuth(r); !ok {
+	session, ok := g.validateAuth(r)
+	if !ok {
 		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_auth"})
 		return
 	}
 
-	var body map[string]interface{}
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+	var req types.QueueLeaveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "bad_request"})
 		return
 	}
-	buf, _ := json.Marshal(body)
+	// Only a player on the ticket may pull it; leaving a found match counts against them.
+	req.PlayerID = session.PlayerID
+	buf, _ := json.Marshal(req)
 	code, out, err := g.proxyRequest(http.MethodPost, g.matchmaker+"/v1/queue/leave", bytes.NewReader(buf))
 	if err != nil {
 		writeJSON(w, http.StatusBadGateway, map[string]string{"error": "matchmaker_unavailable"})
@@ -188,38 +313,131 @@ func (g *gateway) handleMatchLeave(w http.ResponseWriter, r *http.Request) {
 	writeRawJSON(w, code, out)
 }
 
+func (g *gateway) handleMatchAccept(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+		return
+	}
+	session, ok := g.validateAuth(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_auth"})
+		return
+	}
+
+	var req types.QueueAcceptRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "bad_request"})
+		return
+	}
+	req.PlayerID = session.PlayerID
+	buf, _ := json.Marshal(req)
+	code, out, err := g.proxyRequest(http.MethodPost, g.matchmaker+"/v1/queue/accept", bytes.NewReader(buf))
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": "matchmaker_unavailable"})
+		return
+	}
+	writeRawJSON(w, code, out)
+}
+
+func (g *gateway) handleMatchHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+		return
+	}
+	session, ok := g.validateAuth(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_auth"})
+		return
+	}
+
+	query := url.Values{}
+	query.Set("player_id", session.PlayerID)
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		query.Set("limit", limit)
+	}
+	code, out, err := g.proxyRequest(http.MethodGet, g.matchmaker+"/v1/results/history?"+query.Encode(), nil)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": "matchmaker_unavailable"})
+		return
+	}
+	writeRawJSON(w, code, out)
+}
+
+// handleConduct returns the caller's abandon record and any matchmaking ban it earned.
+func (g *gateway) handleConduct(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+		return
+	}
+	session, ok := g.validateAuth(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_auth"})
+		return
+	}
+	query := url.Values{}
+	query.Set("player_id", session.PlayerID)
+	code, out, err := g.proxyRequest(http.MethodGet, g.matchmaker+"/v1/conduct?"+query.Encode(), nil)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": "matchmaker_unavailable"})
+		return
+	}
+	writeRawJSON(w, code, out)
+}
+
+// teamOf returns the team the assignment put playerID on, or "" when teams are unset.
+func teamOf(teams map[string][]string, playerID string) string {
+	for team, players := range teams {
+		if slices.Contains(players, playerID) {
+			return team
+		}
+	}
+	return ""
+}
+
 func (g *gateway) validateAuth(r *http.Request) (authSession, bool) {
-	token := r.Header.Get("Authorization")
-	if token == "" {
+	token, ok := bearerToken(r)
+	if !ok {
 		return authSession{}, false
 	}
-	const prefix = "Bearer "
-	if len(token) <= len(prefix) || token[:len(prefix)] != prefix {
+	return g.sessionFor(token)
+}
+
+func (g *gateway) sessionFor(token string) (authSession, bool) {
+	a, session, ok := g.accounts.Authenticate(token)
+	if !ok {
 		return authSession{}, false
 	}
-	token = token[len(prefix):]
+	return authSession{PlayerID: a.PlayerID, DisplayName: a.DisplayName, ExpiresAt: session.ExpiresAt}, true
+}
 
-	g.authMu.RLock()
-	defer g.authMu.RUnlock()
-	session, ok := g.authSessions[token]
-	if !ok {
+// authedPost rejects anything but a POST from a signed-in player and returns the
+// caller's session.
+func (g *gateway) authedPost(w http.ResponseWriter, r *http.Request) (authSession, bool) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
 		return authSession{}, false
 	}
-	if session.ExpiresAt < time.Now().UTC().Unix() {
+	session, ok := g.validateAuth(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_auth"})
 		return authSession{}, false
 	}
 	return session, true
 }
 
 func (g *gateway) proxyRequest(method, url string, body io.Reader) (int, []byte, error) {
-	req, err := http.NewRequest(method, url, body)
+	return g.proxyRequestWith(context.Background(), g.httpClient, method, url, body)
+}
+
+func (g *gateway) proxyRequestWith(ctx context.Context, client *http.Client, method, url string, body io.Reader) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return 0, nil, err
 	}
 	if body != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
-	resp, err := g.httpClient.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
 		return 0, nil, err
 	}
@@ -232,14 +450,6 @@ func (g *gateway) proxyRequest(method, url string, body io.Reader) (int, []byte,
 	return resp.StatusCode, payload, nil
 }
 
-func randomToken(size int) (string, error) {
-	buf := make([]byte, size)
-	if _, err := rand.Read(buf); err != nil {
-		return "", err
-	}
-	return base64.RawURLEncoding.EncodeToString(buf), nil
-}
-
 func withCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"projectvelocity/backend/internal/party"
+	"projectvelocity/backend/internal/shared/types"
+)
+
+func (g *gateway) handlePartyGet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+		return
+	}
+	session, ok := g.validateAuth(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_auth"})
+		return
+	}
+	p, ok := g.parties.Get(session.PlayerID)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not_in_party"})
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+func (g *gateway) handlePartyCreate(w http.ResponseWriter, r *http.Request) {
+	session, ok := g.authedPost(w, r)
+	if !ok {
+		return
+	}
+	p, err := g.parties.Create(types.PartyMember{PlayerID: session.PlayerID, DisplayName: session.DisplayName})
+	if err != nil {
+		writePartyError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+func (g *gateway) handlePartyInvite(w http.ResponseWriter, r *http.Request) {
+	session, ok := g.authedPost(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		PlayerID string `json:"player_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.PlayerID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "player_id_required"})
+		return
+	}
+	p, err := g.parties.Invite(session.PlayerID, body.PlayerID)
+	if err != nil {
+		writePartyError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+func (g *gateway) handlePartyAccept(w http.ResponseWriter, r *http.Request) {
+	session, ok := g.authedPost(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		PartyID string `json:"party_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.PartyID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "party_id_required"})
+		return
+	}
+	p, ticketID, err := g.parties.Accept(body.PartyID, types.PartyMember{PlayerID: session.PlayerID, DisplayName: session.DisplayName})
+	if err != nil {
+		writePartyError(w, err)
+		return
+	}
+	g.cancelTicket(ticketID, p.LeaderID)
+	writeJSON(w, http.StatusOK, p)
+}
+
+func (g *gateway) handlePartyLeave(w http.ResponseWriter, r *http.Request) {
+	session, ok := g.authedPost(w, r)
+	if !ok {
+		return
+	}
+	ticketID, err := g.parties.Leave(session.PlayerID)
+	if err != nil {
+		writePartyError(w, err)
+		return
+	}
+	g.cancelTicket(ticketID, session.PlayerID)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "left"})
+}
+
+func (g *gateway) handlePartyDisband(w http.ResponseWriter, r *http.Request) {
+	session, ok := g.authedPost(w, r)
+	if !ok {
+		return
+	}
+	ticketID, err := g.parties.Disband(session.PlayerID)
+	if err != nil {
+		writePartyError(w, err)
+		return
+	}
+	g.cancelTicket(ticketID, session.PlayerID)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "disbanded"})
+}
+
+// cancelTicket takes a party's ticket out of the queue after its roster changed. The
+// whole party leaves together because it shares the one ticket; playerID must be one of
+// the members it was queued with.
+func (g *gateway) cancelTicket(ticketID, playerID string) {
+	if ticketID == "" {
+		return
+	}
+	buf, _ := json.Marshal(types.QueueLeaveRequest{TicketID: ticketID, PlayerID: playerID})
+	if _, _, err := g.proxyRequest(http.MethodPost, g.matchmaker+"/v1/queue/leave", bytes.NewReader(buf)); err != nil {
+		g.log.Printf("cancel party ticket %s failed: %v", ticketID, err)
+	}
+}
+
+func writePartyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, party.ErrAlreadyInParty):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "already_in_party"})
+	case errors.Is(err, party.ErrNotInParty):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not_in_party"})
+	case errors.Is(err, party.ErrNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "party_not_found"})
+	case errors.Is(err, party.ErrNotLeader):
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "not_party_leader"})
+	case errors.Is(err, party.ErrNotInvited):
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "not_invited"})
+	case errors.Is(err, party.ErrFull):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "party_full"})
+	default:
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "party_error"})
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"projectvelocity/backend/internal/shared/signing"
+	"projectvelocity/backend/internal/shared/types"
+)
+
+// streamTokenTTL bounds how long a stream token can open a stream. It is only checked
+// when the stream opens, so it just has to cover the client's round trip.
+const streamTokenTTL = time.Minute
+
+// streamClaims bind a stream token to one player and ticket until ExpiresAt.
+type streamClaims struct {
+	PlayerID    string `json:"pid"`
+	DisplayName string `json:"name"`
+	TicketID    string `json:"tid"`
+	ExpiresAt   int64  `json:"exp"`
+}
+
+// handleStreamToken issues a stream token for one of the caller's tickets. Browsers'
+// EventSource cannot send headers, and a token in the URL ends up in proxy and access
+// logs, so the stream takes this instead of the session token.
+func (g *gateway) handleStreamToken(w http.ResponseWriter, r *http.Request) {
+	session, ok := g.authedPost(w, r)
+	if !ok {
+		return
+	}
+	var req types.StreamTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.TicketID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "ticket_id_required"})
+		return
+	}
+	expires := time.Now().UTC().Add(streamTokenTTL)
+	body, _ := json.Marshal(streamClaims{
+		PlayerID:    session.PlayerID,
+		DisplayName: session.DisplayName,
+		TicketID:    req.TicketID,
+		ExpiresAt:   expires.Unix(),
+	})
+	payload := base64.RawURLEncoding.EncodeToString(body)
+	writeJSON(w, http.StatusOK, types.StreamTokenResponse{
+		StreamToken: payload + "." + signing.Sign(g.streamKey, []byte(payload)),
+		ExpiresAt:   expires.Unix(),
+	})
+}
+
+// streamSession returns the session a stream token was issued to, if it is genuine,
+// unexpired and for ticketID.
+func (g *gateway) streamSession(token, ticketID string, now time.Time) (authSession, bool) {
+	payload, sig, ok := strings.Cut(token, ".")
+	if !ok || signing.Verify(g.streamKey, []byte(payload), sig) != nil {
+		return authSession{}, false
+	}
+	body, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return authSession{}, false
+	}
+	var c streamClaims
+	if err := json.Unmarshal(body, &c); err != nil || c.TicketID != ticketID || now.Unix() >= c.ExpiresAt {
+		return authSession{}, false
+	}
+	return authSession{PlayerID: c.PlayerID, DisplayName: c.DisplayName}, true
+}
+
+// handleMatchStream relays the matchmaker's ticket event stream to the client,
+// signing a join ticket onto the matched event just as poll does. Callers that can send
+// headers use their session token; browsers pass a stream token in the query.
+func (g *gateway) handleMatchStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+		return
+	}
+	ticketID := r.URL.Query().Get("ticket_id")
+	session, ok := g.validateAuth(r)
+	if !ok {
+		session, ok = g.streamSession(r.URL.Query().Get("stream_token"), ticketID, time.Now().UTC())
+	}
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_auth"})
+		return
+	}
+	if ticketID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "ticket_id_required"})
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "streaming_unsupported"})
+		return
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, g.matchmaker+"/v1/queue/stream?ticket_id="+url.QueryEscape(ticketID), nil)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "stream_failed"})
+		return
+	}
+	resp, err := g.streamClient.Do(req)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": "matchmaker_unavailable"})
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		writeJSON(w, resp.StatusCode, map[string]string{"error": "matchmaker_stream_rejected"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	scanner := bufio.NewScanner(resp.Body)
+	event := ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			var poll types.QueuePollResponse
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &poll); err != nil {
+				writeStreamEvent(w, "error", map[string]string{"error": "matchmaker_bad_response"})
+				flusher.Flush()
+				return
+			}
+			if err := g.attachJoinTicket(session, poll.Assignment); err != nil {
+				code := "join_ticket_failed"
+				if errors.Is(err, errTicketNotOwned) {
+					code = "ticket_not_owned"
+				}
+				writeStreamEvent(w, "error", map[string]string{"error": code})
+				flusher.Flush()
+				return
+			}
+			writeStreamEvent(w, event, poll)
+			flusher.Flush()
+			event = ""
+		}
+	}
+}
+
+func writeStreamEvent(w http.ResponseWriter, event string, v interface{}) {
+	data, _ := json.Marshal(v)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
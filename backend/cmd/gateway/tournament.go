@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"projectvelocity/backend/internal/shared/types"
+)
+
+func (g *gateway) handleTournamentList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+		return
+	}
+	target := g.matchmaker + "/v1/tournaments"
+	if id := r.URL.Query().Get("tournament_id"); id != "" {
+		target += "/bracket?" + url.Values{"tournament_id": {id}}.Encode()
+	}
+	code, body, err := g.proxyRequest(http.MethodGet, target, nil)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": "matchmaker_unavailable"})
+		return
+	}
+	writeRawJSON(w, code, body)
+}
+
+// handleTournamentMatch returns the caller's live tournament game with a join ticket.
+func (g *gateway) handleTournamentMatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+		return
+	}
+	session, ok := g.validateAuth(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_auth"})
+		return
+	}
+	query := url.Values{}
+	query.Set("player_id", session.PlayerID)
+	code, body, err := g.proxyRequest(http.MethodGet, g.matchmaker+"/v1/tournaments/match?"+query.Encode(), nil)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": "matchmaker_unavailable"})
+		return
+	}
+	if code != http.StatusOK {
+		writeRawJSON(w, code, body)
+		return
+	}
+	var a types.MatchAssignment
+	if err := json.Unmarshal(body, &a); err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": "matchmaker_bad_response"})
+		return
+	}
+	if err := g.attachJoinTicket(session, &a); err != nil {
+		writeJoinTicketError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, a)
+}
+
+// handleTournamentAction proxies a tournament mutation on behalf of the session's
+// player, who acts as organizer or team captain.
+func (g *gateway) handleTournamentAction(action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, ok := g.authedPost(w, r)
+		if !ok {
+			return
+		}
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "bad_request"})
+			return
+		}
+		body["player_id"] = session.PlayerID
+		buf, _ := json.Marshal(body)
+		code, out, err := g.proxyRequest(http.MethodPost, g.matchmaker+"/v1/tournaments/"+action, bytes.NewReader(buf))
+		if err != nil {
+			writeJSON(w, http.StatusBadGateway, map[string]string{"error": "matchmaker_unavailable"})
+			return
+		}
+		writeRawJSON(w, code, out)
+	}
+}
@@ -0,0 +1,615 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"projectvelocity/backend/internal/cluster"
+	"projectvelocity/backend/internal/conduct"
+	"projectvelocity/backend/internal/matchmaking"
+	"projectvelocity/backend/internal/rating"
+	"projectvelocity/backend/internal/shared/logger"
+	"projectvelocity/backend/internal/shared/signing"
+	"projectvelocity/backend/internal/shared/types"
+)
+
+// forwardedHeader marks a request one instance passed to another. The receiver always
+// serves it locally, so ownership changes mid-flight cannot bounce a request forever.
+const forwardedHeader = "X-Velocity-Forwarded-By"
+
+// primaryPaths are the routes over cluster-wide state. Only the primary instance holds
+// that state, so every other instance forwards these requests to it.
+var primaryPaths = []string{"/v1/results", "/v1/ratings", "/v1/conduct", "/v1/fleet/", "/v1/lobby", "/v1/tournaments"}
+
+// shard routes queue requests to the instance that owns their bucket, and everything
+// else to the primary. A nil shard means this matchmaker runs alone and serves
+// everything itself.
+type shard struct {
+	log     *logger.Logger
+	store   cluster.SharedStore
+	node    *cluster.Node
+	manager *matchmaking.QueueManager
+	// local reserves game servers on this instance's fleet, which only the primary's
+	// game servers register with.
+	local   matchmaking.Allocator
+	tickets *bucketStore
+	// hosted is the shared store when this instance serves it, closed on shutdown.
+	hosted *cluster.FileStore
+	lease  time.Duration
+	// key signs calls between instances; their cluster routes refuse anything else.
+	key    []byte
+	client *http.Client
+	done   chan struct{}
+}
+
+// newShard joins the cluster described by the environment, or returns nil when
+// clustering is off. CLUSTER_SERVE_STORE=1 hosts the shared store in this process at
+// /v1/cluster/store, kept in MATCHMAKER_DATA_DIR when that is set; other instances
+// point CLUSTER_STORE_URL at it. The queue saves its tickets to the shared store. Every
+// instance needs the same CLUSTER_KEY, which signs the calls they make to each other.
+func newShard(log *logger.Logger, manager *matchmaking.QueueManager, local matchmaking.Allocator, mux *http.ServeMux, addr string) (*shard, error) {
+	if os.Getenv("CLUSTER_SERVE_STORE") != "1" && os.Getenv("CLUSTER_STORE_URL") == "" {
+		return nil, nil
+	}
+	key := os.Getenv("CLUSTER_KEY")
+	if key == "" {
+		key = devClusterKey
+		log.Printf("CLUSTER_KEY not set; using insecure development key")
+	}
+	var store cluster.SharedStore
+	var hosted *cluster.FileStore
+	if os.Getenv("CLUSTER_SERVE_STORE") == "1" {
+		var served cluster.SharedStore = cluster.NewMemoryStore()
+		if dir := os.Getenv("MATCHMAKER_DATA_DIR"); dir != "" {
+			var err error
+			if hosted, err = cluster.OpenFileStore(dir); err != nil {
+				return nil, fmt.Errorf("open shared store: %w", err)
+			}
+			served = hosted
+		}
+		mux.Handle("/v1/cluster/store/", cluster.Handler("/v1/cluster/store", served, []byte(key)))
+		store = served
+	} else {
+		store = cluster.NewHTTPStore(strings.TrimSuffix(os.Getenv("CLUSTER_STORE_URL"), "/"), []byte(key), nil)
+	}
+
+	id := os.Getenv("CLUSTER_INSTANCE_ID")
+	if id == "" {
+		id, _ = os.Hostname()
+	}
+	lease := time.Duration(getenvInt("CLUSTER_LEASE_SEC", 15)) * time.Second
+	self := cluster.Member{ID: id, Addr: getenv("CLUSTER_ADVERTISE_URL", "http://localhost"+addr)}
+	s := &shard{
+		log:     log,
+		store:   store,
+		node:    cluster.NewNode(store, self, lease),
+		manager: manager,
+		local:   local,
+		tickets: &bucketStore{table: cluster.NewTable(store, "tickets"), buckets: make(map[string]string)},
+		hosted:  hosted,
+		key:     []byte(key),
+		client:  &http.Client{Timeout: 5 * time.Second},
+		done:    make(chan struct{}),
+	}
+	if err := manager.SetStore(s.tickets); err != nil {
+		return nil, err
+	}
+	// Every region of a playlist lands on one instance so cross-region search still
+	// sees all of the playlist's tickets.
+	s.node.SetPlacement(matchmaking.BucketPlaylist)
+	s.node.OnHandover(s.handover)
+	s.node.OnClaim(s.claimBucket)
+	if err := s.node.Join(); err != nil {
+		return nil, fmt.Errorf("join cluster: %w", err)
+	}
+	mux.HandleFunc("/v1/cluster/import", s.handleImport)
+	mux.HandleFunc("/v1/cluster/allocate", s.handleAllocate)
+	mux.HandleFunc("/v1/cluster/rollback", s.handleRollback)
+	mux.HandleFunc("/v1/cluster/members", func(w http.ResponseWriter, _ *http.Request) {
+		members, err := s.node.Members()
+		if err != nil {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "store_unavailable"})
+			return
+		}
+		primary, _ := s.node.Primary()
+		writeJSON(w, http.StatusOK, map[string]interface{}{"self": self.ID, "primary": primary.ID, "members": members, "owned": s.node.Owned()})
+	})
+	s.lease = lease
+	log.Printf("joined matchmaker cluster as %s (%s)", self.ID, self.Addr)
+	return s, nil
+}
+
+// start takes the primary role if it is free and keeps the membership alive until ctx
+// ends. Register every hook before calling it: the node may be promoted or claim
+// buckets as soon as it runs.
+func (s *shard) start(ctx context.Context) {
+	if s == nil {
+		return
+	}
+	if _, err := s.node.Primary(); err != nil {
+		s.log.Printf("cluster primary unavailable: %v", err)
+	}
+	go func() {
+		s.node.Run(ctx, s.lease/3)
+		close(s.done)
+	}()
+}
+
+// forwardJoin proxies a join to the owner of bucket. It reports false when this
+// instance should serve the request itself.
+func (s *shard) forwardJoin(w http.ResponseWriter, r *http.Request, bucket string, body []byte) bool {
+	if s == nil {
+		return false
+	}
+	// Resolve even for forwarded joins: that is how the chosen owner takes its lease.
+	owner, err := s.node.Owner(bucket)
+	if r.Header.Get(forwardedHeader) != "" {
+		return false
+	}
+	if err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "bucket_unavailable"})
+		return true
+	}
+	if owner.ID == s.node.Self().ID {
+		return false
+	}
+	s.forward(w, r, owner, body)
+	return true
+}
+
+// forwardTicket proxies a request about ticketID to the instance holding it. Tickets
+// the store has no record of are looked up locally.
+func (s *shard) forwardTicket(w http.ResponseWriter, r *http.Request, ticketID string, body []byte) bool {
+	if s == nil || r.Header.Get(forwardedHeader) != "" {
+		return false
+	}
+	holder, ok, err := s.node.Locate(ticketID)
+	if err != nil || !ok || holder.ID == s.node.Self().ID {
+		return false
+	}
+	s.forward(w, r, holder, body)
+	return true
+}
+
+// onPrimary forwards requests for primaryPaths to the primary and serves the rest, and
+// anything another instance forwarded, with next.
+func (s *shard) onPrimary(next http.Handler) http.Handler {
+	if s == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(forwardedHeader) != "" || !slices.ContainsFunc(primaryPaths, func(p string) bool {
+			return strings.HasPrefix(r.URL.Path, p)
+		}) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		primary, remote, err := s.primary()
+		if err != nil {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "primary_unavailable"})
+			return
+		}
+		if !remote {
+			next.ServeHTTP(w, r)
+			return
+		}
+		s.forward(w, r, primary, nil)
+	})
+}
+
+// primary returns the primary instance and whether it is another one.
+func (s *shard) primary() (cluster.Member, bool, error) {
+	p, err := s.node.Primary()
+	if err != nil {
+		return cluster.Member{}, false, err
+	}
+	return p, p.ID != s.node.Self().ID, nil
+}
+
+// ratings returns where joins read players' skill: local is only current on the
+// primary, which applies results.
+func (s *shard) ratings(local *rating.Service) matchmaking.RatingSource {
+	if s == nil {
+		return local
+	}
+	return rating.NewRemote(s.client, func() (string, bool) {
+		p, remote, err := s.primary()
+		if err != nil || !remote {
+			return "", false
+		}
+		return strings.TrimSuffix(p.Addr, "/") + "/v1/ratings", true
+	}, local)
+}
+
+// banSource answers whether a player is banned from queueing; see conduct.Tracker.Ban.
+type banSource interface {
+	Ban(playerID string, now time.Time) time.Time
+}
+
+// bans returns where joins check abandon bans: local is only current on the primary,
+// which records results.
+func (s *shard) bans(local *conduct.Tracker) banSource {
+	if s == nil {
+		return local
+	}
+	return primaryBans{shard: s, local: local}
+}
+
+type primaryBans struct {
+	shard *shard
+	local *conduct.Tracker
+}
+
+func (b primaryBans) Ban(playerID string, now time.Time) time.Time {
+	p, remote, err := b.shard.primary()
+	if err != nil || !remote {
+		return b.local.Ban(playerID, now)
+	}
+	var rec conduct.Record
+	target := strings.TrimSuffix(p.Addr, "/") + "/v1/conduct?" + url.Values{"player_id": {playerID}}.Encode()
+	if err := b.shard.getJSON(target, &rec); err != nil {
+		b.shard.log.Printf("ban lookup for %s on primary %s failed: %v", playerID, p.ID, err)
+		return b.local.Ban(playerID, now)
+	}
+	if until := time.UnixMilli(rec.BannedUntil); rec.BannedUntil > 0 && now.Before(until) {
+		return until
+	}
+	return time.Time{}
+}
+
+// allocator returns how matches formed here reserve a game server: from the fleet
+// directly on the primary, and through the primary everywhere else.
+func (s *shard) allocator(local matchmaking.Allocator) matchmaking.Allocator {
+	if s == nil {
+		return local
+	}
+	return primaryAllocator{s}
+}
+
+type primaryAllocator struct {
+	shard *shard
+}
+
+func (a primaryAllocator) Allocate(m types.MatchAllocation) (string, error) {
+	s := a.shard
+	p, remote, err := s.primary()
+	if err != nil {
+		return "", err
+	}
+	if !remote {
+		return s.local.Allocate(m)
+	}
+	// Record where the tickets are first, so a rollback on the primary finds them.
+	if err := s.node.PlaceMatch(m.MatchID); err != nil {
+		return "", err
+	}
+	var out struct {
+		ServerAddr string `json:"server_addr"`
+	}
+	if err := s.postJSON(strings.TrimSuffix(p.Addr, "/")+"/v1/cluster/allocate", m, &out); err != nil {
+		return "", err
+	}
+	return out.ServerAddr, nil
+}
+
+func (s *shard) handleAllocate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+		return
+	}
+	raw, ok := readSigned(w, r, s.key)
+	if !ok {
+		return
+	}
+	var m types.MatchAllocation
+	if err := json.Unmarshal(raw, &m); err != nil || m.MatchID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "bad_request"})
+		return
+	}
+	addr, err := s.local.Allocate(m)
+	if err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "no_capacity"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"server_addr": addr})
+}
+
+// rollback passes a lost reservation to the instance that formed the match, which holds
+// its tickets. It reports false when this instance should requeue them itself.
+func (s *shard) rollback(matchID string) bool {
+	if s == nil {
+		return false
+	}
+	holder, ok, err := s.node.LocateMatch(matchID)
+	if err != nil || !ok || holder.ID == s.node.Self().ID {
+		return false
+	}
+	var out map[string]int
+	if err := s.postJSON(strings.TrimSuffix(holder.Addr, "/")+"/v1/cluster/rollback", map[string]string{"match_id": matchID}, &out); err != nil {
+		s.log.Printf("rollback of match %s on %s failed: %v", matchID, holder.ID, err)
+		return true
+	}
+	s.log.Printf("rolled back match %s on %s, requeued %d tickets", matchID, holder.ID, out["requeued"])
+	return true
+}
+
+func (s *shard) handleRollback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+		return
+	}
+	raw, ok := readSigned(w, r, s.key)
+	if !ok {
+		return
+	}
+	var body struct {
+		MatchID string `json:"match_id"`
+	}
+	if err := json.Unmarshal(raw, &body); err != nil || body.MatchID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "match_id_required"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"requeued": s.manager.RollbackMatch(body.MatchID)})
+}
+
+// postJSON sends v, signed with the cluster key, to another instance and decodes its
+// reply into out.
+func (s *shard) postJSON(target string, v, out interface{}) error {
+	buf, _ := json.Marshal(v)
+	req, err := s.signedRequest(target, buf)
+	if err != nil {
+		return err
+	}
+	return s.do(req, out)
+}
+
+// signedRequest builds a POST of body to another instance's cluster route.
+func (s *shard) signedRequest(target string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(forwardedHeader, s.node.Self().ID)
+	req.Header.Set(signing.Header, signing.Sign(s.key, body))
+	return req, nil
+}
+
+// getJSON reads target from another instance into out.
+func (s *shard) getJSON(target string, out interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(forwardedHeader, s.node.Self().ID)
+	return s.do(req, out)
+}
+
+func (s *shard) do(req *http.Request, out interface{}) error {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d", req.URL.Path, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// drained blocks until the node has left the cluster after ctx was cancelled.
+func (s *shard) drained() {
+	if s != nil {
+		<-s.done
+	}
+}
+
+// close closes the shared store if this instance hosts it. Call it last: the other
+// instances lose the store with it.
+func (s *shard) close() error {
+	if s == nil || s.hosted == nil {
+		return nil
+	}
+	return s.hosted.Close()
+}
+
+// place records that this instance now holds ticketID.
+func (s *shard) place(ticketID string) {
+	if s == nil {
+		return
+	}
+	if err := s.node.Place(ticketID); err != nil {
+		s.log.Printf("record ticket %s location: %v", ticketID, err)
+	}
+}
+
+func (s *shard) forward(w http.ResponseWriter, r *http.Request, to cluster.Member, body []byte) {
+	target, err := url.Parse(to.Addr)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": "owner_unavailable"})
+		return
+	}
+	if body != nil {
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		r.ContentLength = int64(len(body))
+	}
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			pr.Out.Header.Set(forwardedHeader, s.node.Self().ID)
+		},
+		// Flush immediately so forwarded event streams are not buffered.
+		FlushInterval: -1,
+		ModifyResponse: func(resp *http.Response) error {
+			// withCORS on this instance already set these.
+			for key := range resp.Header {
+				if strings.HasPrefix(key, "Access-Control-") {
+					resp.Header.Del(key)
+				}
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, _ *http.Request, err error) {
+			s.log.Printf("forward to %s failed: %v", to.ID, err)
+			writeJSON(w, http.StatusBadGateway, map[string]string{"error": "owner_unavailable"})
+		},
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+// handover exports bucket's tickets to their new owner. If the owner cannot take
+// them they are put back, and the node retries on its next heartbeat. The node calls
+// this again after releasing the lease, so even an empty handover is sent: it is the
+// new owner's cue to claim the bucket. The tickets are sent along even though the
+// shared store has them, because their latest changes may not be written yet.
+func (s *shard) handover(bucket string, to cluster.Member) error {
+	records := s.manager.Export(bucket)
+	buf, _ := json.Marshal(map[string]interface{}{"bucket": bucket, "tickets": records})
+	req, err := s.signedRequest(strings.TrimSuffix(to.Addr, "/")+"/v1/cluster/import", buf)
+	if err == nil {
+		var resp *http.Response
+		if resp, err = s.client.Do(req); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				err = fmt.Errorf("import returned %d", resp.StatusCode)
+			}
+		}
+	}
+	if err != nil {
+		if len(records) > 0 {
+			s.manager.Import(records)
+		}
+		s.log.Printf("handover of %s to %s failed, keeping %d tickets: %v", bucket, to.ID, len(records), err)
+		return err
+	}
+	if len(records) > 0 {
+		s.log.Printf("handed %s to %s with %d tickets", bucket, to.ID, len(records))
+	}
+	return nil
+}
+
+func (s *shard) handleImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+		return
+	}
+	raw, ok := readSigned(w, r, s.key)
+	if !ok {
+		return
+	}
+	var body struct {
+		Bucket  string                     `json:"bucket"`
+		Tickets []matchmaking.TicketRecord `json:"tickets"`
+	}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "bad_request"})
+		return
+	}
+	if body.Bucket != "" {
+		// Claims the lease once the sender has released it.
+		_, _ = s.node.Owner(body.Bucket)
+	}
+	s.manager.Import(body.Tickets)
+	for _, t := range body.Tickets {
+		s.place(t.TicketID)
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"imported": len(body.Tickets)})
+}
+
+// claimBucket loads the tickets the bucket's previous owner saved, before this instance
+// serves the bucket. After a crash this is how they come back; after a handover it
+// finds nothing newer than what was sent.
+func (s *shard) claimBucket(bucket string) error {
+	records, err := s.tickets.load(bucket)
+	if err != nil {
+		s.log.Printf("load tickets of %s: %v", bucket, err)
+		return err
+	}
+	s.manager.Import(records)
+	for _, rec := range records {
+		s.place(rec.TicketID)
+	}
+	if len(records) > 0 {
+		s.log.Printf("claimed %s with %d saved tickets", bucket, len(records))
+	}
+	return nil
+}
+
+// bucketStore is the queue's store in a cluster. It saves tickets in the shared store
+// under their bucket, so whoever owns the bucket next can load them.
+type bucketStore struct {
+	table *cluster.Table
+
+	mu sync.Mutex
+	// buckets remembers each saved ticket's bucket, for deleting it.
+	buckets map[string]string
+}
+
+// Load returns nothing: an instance starts out owning no buckets, and loads each one's
+// tickets as it claims it.
+func (s *bucketStore) Load() ([]matchmaking.TicketRecord, error) {
+	return nil, nil
+}
+
+func (s *bucketStore) Write(changes []matchmaking.Change) error {
+	puts := make(map[string]interface{})
+	var deletes, forgotten []string
+	s.mu.Lock()
+	for _, c := range changes {
+		if c.Record != nil {
+			bucket := c.Record.Bucket()
+			s.buckets[c.TicketID] = bucket
+			puts[bucket+"/"+c.TicketID] = c.Record
+		} else if bucket, ok := s.buckets[c.TicketID]; ok {
+			deletes = append(deletes, bucket+"/"+c.TicketID)
+			forgotten = append(forgotten, c.TicketID)
+		}
+	}
+	s.mu.Unlock()
+	if err := s.table.Write(puts, deletes); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	for _, id := range forgotten {
+		delete(s.buckets, id)
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *bucketStore) Close() error {
+	return nil
+}
+
+// load returns the tickets saved for bucket.
+func (s *bucketStore) load(bucket string) ([]matchmaking.TicketRecord, error) {
+	var out []matchmaking.TicketRecord
+	err := s.table.Load(bucket+"/", func(data []byte) error {
+		var rec matchmaking.TicketRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return err
+		}
+		out = append(out, rec)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, rec := range out {
+		s.buckets[rec.TicketID] = bucket
+	}
+	return out, nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"projectvelocity/backend/internal/lobby"
+	"projectvelocity/backend/internal/shared/types"
+	"projectvelocity/backend/internal/simulation"
+)
+
+// lobbyRequest is the body of every lobby mutation. The gateway fills in PlayerID and
+// DisplayName from the caller's session; the other fields depend on the route.
+type lobbyRequest struct {
+	PlayerID    string              `json:"player_id"`
+	DisplayName string              `json:"display_name"`
+	Code        string              `json:"code,omitempty"`
+	TargetID    string              `json:"target_id,omitempty"`
+	Team        string              `json:"team,omitempty"`
+	Settings    types.LobbySettings `json:"settings"`
+}
+
+// registerLobbyRoutes serves private lobbies. In a cluster they live on the primary,
+// which every other instance forwards these routes to.
+func registerLobbyRoutes(mux *http.ServeMux, lobbies *lobby.Manager) {
+	mux.HandleFunc("/v1/lobby", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+			return
+		}
+		l, ok := lobbies.Get(r.URL.Query().Get("player_id"))
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "not_in_lobby"})
+			return
+		}
+		writeJSON(w, http.StatusOK, l)
+	})
+	mux.HandleFunc("/v1/lobby/presets", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"physics": simulation.PhysicsPresets()})
+	})
+	mux.HandleFunc("/v1/lobby/create", lobbyHandler(func(req lobbyRequest) (interface{}, error) {
+		return lobbies.Create(types.LobbyMember{PlayerID: req.PlayerID, DisplayName: req.DisplayName}, req.Settings)
+	}))
+	mux.HandleFunc("/v1/lobby/join", lobbyHandler(func(req lobbyRequest) (interface{}, error) {
+		return lobbies.Join(req.Code, types.LobbyMember{PlayerID: req.PlayerID, DisplayName: req.DisplayName})
+	}))
+	mux.HandleFunc("/v1/lobby/leave", lobbyHandler(func(req lobbyRequest) (interface{}, error) {
+		return map[string]string{"status": "left"}, lobbies.Leave(req.PlayerID)
+	}))
+	mux.HandleFunc("/v1/lobby/move", lobbyHandler(func(req lobbyRequest) (interface{}, error) {
+		return lobbies.Move(req.PlayerID, req.TargetID, req.Team)
+	}))
+	mux.HandleFunc("/v1/lobby/settings", lobbyHandler(func(req lobbyRequest) (interface{}, error) {
+		return lobbies.Update(req.PlayerID, req.Settings)
+	}))
+	mux.HandleFunc("/v1/lobby/start", lobbyHandler(func(req lobbyRequest) (interface{}, error) {
+		return lobbies.Start(req.PlayerID)
+	}))
+}
+
+// lobbyHandler decodes a lobby mutation, runs it and writes its result or error.
+func lobbyHandler(do func(lobbyRequest) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+			return
+		}
+		var req lobbyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "bad_request"})
+			return
+		}
+		if req.PlayerID == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "player_id_required"})
+			return
+		}
+		out, err := do(req)
+		if err != nil {
+			writeLobbyError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, out)
+	}
+}
+
+func writeLobbyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, lobby.ErrNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "lobby_not_found"})
+	case errors.Is(err, lobby.ErrAlreadyInLobby):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "already_in_lobby"})
+	case errors.Is(err, lobby.ErrNotInLobby):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not_in_lobby"})
+	case errors.Is(err, lobby.ErrNotHost):
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "not_lobby_host"})
+	case errors.Is(err, lobby.ErrFull):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "team_full"})
+	case errors.Is(err, lobby.ErrStarted):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "lobby_started"})
+	case errors.Is(err, lobby.ErrBadSettings):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_settings", "detail": err.Error()})
+	case errors.Is(err, lobby.ErrNotReady):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "teams_not_ready"})
+	case errors.Is(err, lobby.ErrNoServer):
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "no_server"})
+	default:
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "lobby_error"})
+	}
+}
@@ -3,37 +3,218 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"os/signal"
+	"slices"
 	"strconv"
+	"syscall"
 	"time"
 
+	"projectvelocity/backend/internal/conduct"
+	"projectvelocity/backend/internal/fleet"
+	"projectvelocity/backend/internal/lobby"
 	"projectvelocity/backend/internal/matchmaking"
+	"projectvelocity/backend/internal/rating"
+	"projectvelocity/backend/internal/results"
 	"projectvelocity/backend/internal/shared/logger"
+	"projectvelocity/backend/internal/shared/signing"
 	"projectvelocity/backend/internal/shared/types"
+	"projectvelocity/backend/internal/tournament"
 )
 
 func main() {
 	log := logger.New("matchmaker")
 	addr := getenv("MATCHMAKER_ADDR", ":9001")
 	serverAddr := getenv("GAME_WS_ADDR", "ws://localhost:9003/ws")
+	resultsKey := os.Getenv("RESULTS_KEY")
+	if resultsKey == "" {
+		resultsKey = devResultsKey
+		log.Printf("RESULTS_KEY not set; using insecure development key")
+	}
+	fleetKey := os.Getenv("FLEET_KEY")
+	if fleetKey == "" {
+		fleetKey = devFleetKey
+		log.Printf("FLEET_KEY not set; using insecure development key")
+	}
 
 	manager := matchmaking.NewQueueManager(serverAddr)
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	go manager.Run(ctx, time.Second, 2)
+	manager.SetReadyCheckTimeout(time.Duration(getenvInt("READY_CHECK_SEC", int(matchmaking.DefaultReadyCheckTimeout/time.Second))) * time.Second)
+	window := matchmaking.DefaultMMRWindow()
+	manager.SetMMRWindow(matchmaking.MMRWindow{
+		Base:      getenvInt("MMR_WINDOW_BASE", window.Base),
+		PerSecond: getenvFloat("MMR_WINDOW_PER_SEC", window.PerSecond),
+		MaxWiden:  getenvInt("MMR_WINDOW_MAX_WIDEN", window.MaxWiden),
+	})
+	servers := fleet.NewRegistry(fleet.HTTPDispatcher(&http.Client{Timeout: 5 * time.Second}, []byte(fleetKey)))
+	ratings := rating.NewService()
+	abandons := conduct.NewTracker()
+
+	lobbies := lobby.NewManager(manager.Playlists(), fleetAllocator{fleet: servers, fallback: serverAddr})
+	tournaments := tournament.NewManager(manager.Playlists(), ratings, fleetAllocator{fleet: servers, fallback: serverAddr})
+
+	store := results.NewStore()
+	store.OnRecorded(func(r types.MatchResult) {
+		// The match is over, so its slot no longer needs to be held or rolled back.
+		servers.Release(r.MatchID)
+		lobbies.Reopen(r.MatchID)
+		if tournaments.Record(r) {
+			log.Printf("recorded tournament game match=%s winner=%s", r.MatchID, r.Winner)
+		}
+		if updated := ratings.Apply(r); len(updated) > 0 {
+			log.Printf("updated %d ratings from match=%s playlist=%s", len(updated), r.MatchID, r.Playlist)
+		}
+		// Leavers are penalized after the match is rated, so the penalty comes on top of the loss.
+		playlist, _ := manager.Playlists().Get(r.Playlist)
+		for _, p := range abandons.Record(r, playlist.Ranked) {
+			ratings.Penalize(p.PlayerID, p.Playlist, p.RatingPenalty)
+			log.Printf("penalized abandon player=%s match=%s strike=%d banned_until=%s", p.PlayerID, p.MatchID, p.Strike, p.BannedUntil.Format(time.RFC3339))
+		}
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	manager.SetTTLs(matchmaking.TTLs{
+		Searching:  time.Duration(getenvInt("SEARCH_TTL_SEC", 600)) * time.Second,
+		Assignment: time.Duration(getenvInt("ASSIGNMENT_TTL_SEC", 900)) * time.Second,
+		Cancelled:  time.Duration(getenvInt("CANCELLED_TTL_SEC", 120)) * time.Second,
+	})
+	go manager.RunStore(ctx)
+	go manager.RunJanitor(ctx, time.Duration(getenvInt("QUEUE_JANITOR_SEC", 15))*time.Second)
+	lobbyIdle := time.Duration(getenvInt("LOBBY_IDLE_SEC", 1800)) * time.Second
+	go func() {
+		ticker := time.NewTicker(time.Duration(getenvInt("FLEET_REAP_SEC", 5)) * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case t := <-ticker.C:
+				servers.Reap()
+				lobbies.Expire(t.UTC(), lobbyIdle)
+				tournaments.Retry()
+				abandons.Expire(t.UTC())
+			}
+		}
+	}()
 
 	mux := http.NewServeMux()
+	shard, err := newShard(log, manager, fleetAllocator{fleet: servers, fallback: serverAddr}, mux, addr)
+	if err != nil {
+		log.Fatalf("cluster: %v", err)
+	}
+	// A cluster keeps results, ratings, conduct and the fleet in the shared store
+	// instead, so the next primary can carry on with them.
+	if shard != nil {
+		shard.keepPrimaryState(primaryState{
+			results:     store,
+			ratings:     ratings,
+			abandons:    abandons,
+			servers:     servers,
+			lobbies:     lobbies,
+			tournaments: tournaments,
+		})
+	} else if dir := os.Getenv("MATCHMAKER_DATA_DIR"); dir != "" {
+		ratingStore, err := rating.OpenFileStore(dir)
+		if err != nil {
+			log.Fatalf("open rating store: %v", err)
+		}
+		defer ratingStore.Close()
+		if err := ratings.SetStore(ratingStore); err != nil {
+			log.Fatalf("restore ratings: %v", err)
+		}
+		conductStore, err := conduct.OpenFileStore(dir)
+		if err != nil {
+			log.Fatalf("open conduct store: %v", err)
+		}
+		defer conductStore.Close()
+		if err := abandons.SetStore(conductStore); err != nil {
+			log.Fatalf("restore conduct: %v", err)
+		}
+		archive, err := results.OpenFileArchive(dir)
+		if err != nil {
+			log.Fatalf("open results archive: %v", err)
+		}
+		defer archive.Close()
+		if err := store.SetArchive(archive); err != nil {
+			log.Fatalf("restore results: %v", err)
+		}
+	}
+	// A clustered queue saves to the shared store instead, so another instance can take
+	// over its buckets.
+	if dir := os.Getenv("MATCHMAKER_DATA_DIR"); dir != "" && shard == nil {
+		store, err := matchmaking.OpenFileStore(dir)
+		if err != nil {
+			log.Fatalf("open queue store: %v", err)
+		}
+		defer store.Close()
+		if err := manager.SetStore(store); err != nil {
+			log.Fatalf("restore queue: %v", err)
+		}
+		stats := manager.Stats()
+		log.Printf("restored queue from %s (searching=%d matched=%d)", dir, stats.Searching, stats.Matched)
+	}
+	// Results, ratings, bans and the fleet live on the primary; on every other instance
+	// these read and allocate through it.
+	manager.SetRatingSource(shard.ratings(ratings))
+	manager.SetAllocator(shard.allocator(fleetAllocator{fleet: servers, fallback: serverAddr}))
+	bans := shard.bans(abandons)
+	go manager.Run(ctx, time.Second)
+	servers.OnRollback(func(matchID string) {
+		if lobbies.Reopen(matchID) {
+			log.Printf("rolled back lobby match %s", matchID)
+			return
+		}
+		if tournaments.Rollback(matchID) {
+			log.Printf("rolled back tournament match %s", matchID)
+			return
+		}
+		if shard.rollback(matchID) {
+			return
+		}
+		n := manager.RollbackMatch(matchID)
+		log.Printf("rolled back match %s, requeued %d tickets", matchID, n)
+	})
 	mux.HandleFunc("/health", func(w http.ResponseWriter, _ *http.Request) {
+		// A failing queue store means recent tickets would not survive a restart.
+		if err := manager.StoreErr(); err != nil {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "degraded", "error": "queue_store_failing"})
+			return
+		}
 		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
 	})
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, _ *http.Request) {
+		writeMetrics(w, manager.Stats(), manager.BucketStats(), abandons, ratings, lobbies, tournaments, servers)
+	})
+	mux.HandleFunc("/v1/queue/stats", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+			return
+		}
+		buckets := manager.BucketStats()
+		if playlist := r.URL.Query().Get("playlist"); playlist != "" {
+			buckets = slices.DeleteFunc(buckets, func(b matchmaking.BucketStats) bool { return b.Playlist != playlist })
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"window_sec": int(matchmaking.AnalyticsWindow / time.Second),
+			"buckets":    buckets,
+		})
+	})
 	mux.HandleFunc("/v1/queue/join", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
 			return
 		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "bad_request"})
+			return
+		}
 		var req types.QueueJoinRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if err := json.Unmarshal(body, &req); err != nil {
 			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "bad_request"})
 			return
 		}
@@ -47,13 +228,44 @@ func main() {
 		if req.Playlist == "" {
 			req.Playlist = "ranked-1v1"
 		}
-		if req.MMR <= 0 {
-			req.MMR = 1000
+		if shard.forwardJoin(w, r, matchmaking.BucketFor(req), body) {
+			return
+		}
+		if _, ok := manager.Playlists().Get(req.Playlist); !ok {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unknown_playlist"})
+			return
+		}
+		if len(req.Members) > 0 && !manager.Fits(req.Playlist, len(req.Members)) {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "party_too_large"})
+			return
+		}
+		queuers := []string{req.PlayerID}
+		for _, m := range req.Members {
+			queuers = append(queuers, m.PlayerID)
+		}
+		now := time.Now().UTC()
+		for _, id := range queuers {
+			if until := manager.Cooldown(id, now); !until.IsZero() {
+				writeJSON(w, http.StatusTooManyRequests, map[string]interface{}{"error": "queue_cooldown", "player_id": id, "until": until.Unix()})
+				return
+			}
+			if until := bans.Ban(id, now); !until.IsZero() {
+				writeJSON(w, http.StatusTooManyRequests, map[string]interface{}{"error": "abandon_ban", "player_id": id, "until": until.Unix()})
+				return
+			}
 		}
 
 		resp := manager.Join(req)
+		shard.place(resp.TicketID)
 		writeJSON(w, http.StatusOK, resp)
 	})
+	mux.HandleFunc("/v1/playlists", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"playlists": manager.Playlists().List()})
+	})
 	mux.HandleFunc("/v1/queue/poll", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
@@ -64,41 +276,274 @@ func main() {
 			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "ticket_id_required"})
 			return
 		}
-		writeJSON(w, http.StatusOK, manager.Poll(ticketID))
+		if shard.forwardTicket(w, r, ticketID, nil) {
+			return
+		}
+		poll := longPoll(manager, r, ticketID)
+		if poll.Status == "not_found" && shard.forwardTicket(w, r, ticketID, nil) {
+			// Handed over while the request waited.
+			return
+		}
+		writeJSON(w, http.StatusOK, poll)
+	})
+	stream := handleQueueStream(manager)
+	mux.HandleFunc("/v1/queue/stream", func(w http.ResponseWriter, r *http.Request) {
+		if shard.forwardTicket(w, r, r.URL.Query().Get("ticket_id"), nil) {
+			return
+		}
+		stream(w, r)
+	})
+	mux.HandleFunc("/v1/queue/accept", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+			return
+		}
+		raw, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "bad_request"})
+			return
+		}
+		var req types.QueueAcceptRequest
+		if err := json.Unmarshal(raw, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "bad_request"})
+			return
+		}
+		if req.TicketID == "" || req.PlayerID == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "ticket_id_and_player_id_required"})
+			return
+		}
+		if shard.forwardTicket(w, r, req.TicketID, raw) {
+			return
+		}
+		switch err := manager.Accept(req.TicketID, req.PlayerID, req.Accept); {
+		case errors.Is(err, matchmaking.ErrTicketNotFound):
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "ticket_not_found"})
+		case errors.Is(err, matchmaking.ErrNoReadyCheck):
+			writeJSON(w, http.StatusConflict, map[string]string{"error": "no_ready_check"})
+		case errors.Is(err, matchmaking.ErrNotOnTicket):
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "not_on_ticket"})
+		case err != nil:
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "accept_failed"})
+		default:
+			writeJSON(w, http.StatusOK, manager.Poll(req.TicketID))
+		}
 	})
 	mux.HandleFunc("/v1/queue/leave", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
 			return
 		}
-		var body struct {
-			TicketID string `json:"ticket_id"`
+		raw, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "bad_request"})
+			return
 		}
-		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		var body types.QueueLeaveRequest
+		if err := json.Unmarshal(raw, &body); err != nil {
 			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "bad_request"})
 			return
 		}
-		if body.TicketID == "" {
-			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "ticket_id_required"})
+		if body.TicketID == "" || body.PlayerID == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "ticket_id_and_player_id_required"})
+			return
+		}
+		if shard.forwardTicket(w, r, body.TicketID, raw) {
 			return
 		}
-		if !manager.Leave(body.TicketID) {
+		switch err := manager.Leave(body.TicketID, body.PlayerID); {
+		case errors.Is(err, matchmaking.ErrTicketNotFound):
 			writeJSON(w, http.StatusNotFound, map[string]string{"error": "ticket_not_found"})
+		case errors.Is(err, matchmaking.ErrNotOnTicket):
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "not_on_ticket"})
+		default:
+			writeJSON(w, http.StatusOK, map[string]string{"status": "left"})
+		}
+	})
+	registerLobbyRoutes(mux, lobbies)
+	registerTournamentRoutes(mux, tournaments)
+	mux.HandleFunc("/v1/fleet/register", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+			return
+		}
+		body, ok := readSigned(w, r, []byte(fleetKey))
+		if !ok {
+			return
+		}
+		var req types.GameServerRegistration
+		if err := json.Unmarshal(body, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "bad_request"})
+			return
+		}
+		if req.Region == "" {
+			req.Region = "us-east"
+		}
+		if err := servers.Register(req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_registration"})
 			return
 		}
-		writeJSON(w, http.StatusOK, map[string]string{"status": "left"})
+		log.Printf("game server %s registered (region=%s capacity=%d addr=%s)", req.ServerID, req.Region, req.Capacity, req.PublicAddr)
+		writeJSON(w, http.StatusOK, map[string]string{"status": "registered"})
+	})
+	mux.HandleFunc("/v1/fleet/heartbeat", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+			return
+		}
+		body, ok := readSigned(w, r, []byte(fleetKey))
+		if !ok {
+			return
+		}
+		var hb types.GameServerHeartbeat
+		if err := json.Unmarshal(body, &hb); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "bad_request"})
+			return
+		}
+		if err := servers.Heartbeat(hb); err != nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown_server"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("/v1/fleet/servers", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"servers": servers.Servers()})
+	})
+
+	mux.HandleFunc("/v1/results", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			res, ok := store.Get(r.URL.Query().Get("match_id"))
+			if !ok {
+				writeJSON(w, http.StatusNotFound, map[string]string{"error": "result_not_found"})
+				return
+			}
+			writeJSON(w, http.StatusOK, res)
+		case http.MethodPost:
+			body, ok := readSigned(w, r, []byte(resultsKey))
+			if !ok {
+				return
+			}
+			var res types.MatchResult
+			if err := json.Unmarshal(body, &res); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "bad_request"})
+				return
+			}
+			created, err := store.Record(res)
+			switch {
+			case errors.Is(err, results.ErrConflict):
+				writeJSON(w, http.StatusConflict, map[string]string{"error": "result_conflict"})
+			case err != nil && !errors.Is(err, results.ErrInvalid):
+				// The game server retries 5xx, so a result the archive could not save is
+				// submitted again rather than applied without a record.
+				log.Printf("archive result match=%s: %v", res.MatchID, err)
+				writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "store_unavailable"})
+			case err != nil:
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_result"})
+			case !created:
+				writeJSON(w, http.StatusOK, map[string]string{"status": "duplicate"})
+			default:
+				log.Printf("recorded result match=%s score=%d-%d winner=%s", res.MatchID, res.OrangeScore, res.BlueScore, res.Winner)
+				writeJSON(w, http.StatusOK, map[string]string{"status": "recorded"})
+			}
+		default:
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+		}
+	})
+	mux.Handle("/v1/ratings", rating.Handler(ratings))
+	mux.HandleFunc("/v1/conduct", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+			return
+		}
+		playerID := r.URL.Query().Get("player_id")
+		if playerID == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "player_id_required"})
+			return
+		}
+		writeJSON(w, http.StatusOK, abandons.Get(playerID, time.Now().UTC()))
+	})
+	mux.HandleFunc("/v1/results/history", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+			return
+		}
+		playerID := r.URL.Query().Get("player_id")
+		if playerID == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "player_id_required"})
+			return
+		}
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil || limit <= 0 {
+			limit = 20
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"matches": store.History(playerID, limit)})
 	})
 
 	httpServer := &http.Server{
 		Addr:              addr,
-		Handler:           withCORS(mux),
+		Handler:           withCORS(shard.onPrimary(mux)),
 		ReadHeaderTimeout: 5 * time.Second,
 	}
 
+	// Take part in the cluster only once every hook is in place, since the primary role
+	// and buckets can arrive as soon as it does.
+	shard.start(ctx)
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-ctx.Done()
+		// Hand owned buckets to the rest of the cluster before refusing requests.
+		shard.drained()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = httpServer.Shutdown(shutdown)
+	}()
+
 	log.Printf("matchmaker listening on %s (game server=%s)", addr, serverAddr)
 	if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		log.Fatalf("server failed: %v", err)
 	}
+	// Let in-flight requests finish, then write their ticket changes before the stores close.
+	<-stopped
+	if err := manager.Flush(); err != nil {
+		log.Printf("write queue on shutdown: %v", err)
+	}
+	if err := shard.close(); err != nil {
+		log.Printf("close shared store: %v", err)
+	}
+}
+
+// devResultsKey must match the game server's fallback so local runs work without configuration.
+const devResultsKey = "velocity-dev-results-key"
+
+// devFleetKey must match the game server's fallback; it signs match allocations.
+const devFleetKey = "velocity-dev-fleet-key"
+
+// devClusterKey lets a local cluster run without configuration; it signs the calls
+// matchmakers make to each other and to the shared store.
+const devClusterKey = "velocity-dev-cluster-key"
+
+// maxSignedBytes bounds a signed body, from a game server's match record to a bucket
+// of tickets handed over by another matchmaker.
+const maxSignedBytes = 4 << 20
+
+// fleetAllocator allocates through the fleet registry once any game server has
+// registered, and falls back to the static GAME_WS_ADDR for single-server setups.
+type fleetAllocator struct {
+	fleet    *fleet.Registry
+	fallback string
+}
+
+func (a fleetAllocator) Allocate(m types.MatchAllocation) (string, error) {
+	if len(a.fleet.Servers()) == 0 {
+		return a.fallback, nil
+	}
+	return a.fleet.Allocate(m)
 }
 
 func withCORS(next http.Handler) http.Handler {
@@ -114,6 +559,96 @@ func withCORS(next http.Handler) http.Handler {
 	})
 }
 
+func writeMetrics(w http.ResponseWriter, stats matchmaking.QueueStats, buckets []matchmaking.BucketStats, abandons *conduct.Tracker, ratings *rating.Service, lobbies *lobby.Manager, tournaments *tournament.Manager, servers *fleet.Registry) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_, _ = fmt.Fprintln(w, "# HELP velocity_matchmaker_tickets Tickets held by the queue, by status")
+	_, _ = fmt.Fprintln(w, "# TYPE velocity_matchmaker_tickets gauge")
+	_, _ = fmt.Fprintf(w, "velocity_matchmaker_tickets{status=\"searching\"} %d\n", stats.Searching)
+	_, _ = fmt.Fprintf(w, "velocity_matchmaker_tickets{status=\"accept_required\"} %d\n", stats.AcceptRequired)
+	_, _ = fmt.Fprintf(w, "velocity_matchmaker_tickets{status=\"matched\"} %d\n", stats.Matched)
+	_, _ = fmt.Fprintf(w, "velocity_matchmaker_tickets{status=\"cancelled\"} %d\n", stats.Cancelled)
+	_, _ = fmt.Fprintln(w, "# HELP velocity_matchmaker_cooldowns Players with ready-check strikes on record")
+	_, _ = fmt.Fprintln(w, "# TYPE velocity_matchmaker_cooldowns gauge")
+	_, _ = fmt.Fprintf(w, "velocity_matchmaker_cooldowns %d\n", stats.Cooldowns)
+	_, _ = fmt.Fprintln(w, "# HELP velocity_matchmaker_abandon_bans Players banned from matchmaking for abandoning ranked matches")
+	_, _ = fmt.Fprintln(w, "# TYPE velocity_matchmaker_abandon_bans gauge")
+	_, _ = fmt.Fprintf(w, "velocity_matchmaker_abandon_bans %d\n", abandons.Banned(time.Now().UTC()))
+	_, _ = fmt.Fprintln(w, "# HELP velocity_matchmaker_tickets_expired_total Tickets cancelled or forgotten by TTL")
+	_, _ = fmt.Fprintln(w, "# TYPE velocity_matchmaker_tickets_expired_total counter")
+	_, _ = fmt.Fprintf(w, "velocity_matchmaker_tickets_expired_total %d\n", stats.ExpiredTotal)
+	_, _ = fmt.Fprintln(w, "# HELP velocity_matchmaker_store_errors_total Failed writes to the queue store")
+	_, _ = fmt.Fprintln(w, "# TYPE velocity_matchmaker_store_errors_total counter")
+	_, _ = fmt.Fprintf(w, "velocity_matchmaker_store_errors_total %d\n", stats.StoreErrors)
+	_, _ = fmt.Fprintln(w, "# HELP velocity_matchmaker_conduct_store_errors_total Failed writes to the abandon history store")
+	_, _ = fmt.Fprintln(w, "# TYPE velocity_matchmaker_conduct_store_errors_total counter")
+	_, _ = fmt.Fprintf(w, "velocity_matchmaker_conduct_store_errors_total %d\n", abandons.StoreErrors())
+	_, _ = fmt.Fprintln(w, "# HELP velocity_matchmaker_rating_store_errors_total Failed writes to the rating store")
+	_, _ = fmt.Fprintln(w, "# TYPE velocity_matchmaker_rating_store_errors_total counter")
+	_, _ = fmt.Fprintf(w, "velocity_matchmaker_rating_store_errors_total %d\n", ratings.StoreErrors())
+	_, _ = fmt.Fprintln(w, "# HELP velocity_matchmaker_lobby_store_errors_total Failed writes to the lobby store")
+	_, _ = fmt.Fprintln(w, "# TYPE velocity_matchmaker_lobby_store_errors_total counter")
+	_, _ = fmt.Fprintf(w, "velocity_matchmaker_lobby_store_errors_total %d\n", lobbies.StoreErrors())
+	_, _ = fmt.Fprintln(w, "# HELP velocity_matchmaker_tournament_store_errors_total Failed writes to the tournament store")
+	_, _ = fmt.Fprintln(w, "# TYPE velocity_matchmaker_tournament_store_errors_total counter")
+	_, _ = fmt.Fprintf(w, "velocity_matchmaker_tournament_store_errors_total %d\n", tournaments.StoreErrors())
+	_, _ = fmt.Fprintln(w, "# HELP velocity_matchmaker_fleet_store_errors_total Failed writes to the fleet store")
+	_, _ = fmt.Fprintln(w, "# TYPE velocity_matchmaker_fleet_store_errors_total counter")
+	_, _ = fmt.Fprintf(w, "velocity_matchmaker_fleet_store_errors_total %d\n", servers.StoreErrors())
+
+	_, _ = fmt.Fprintln(w, "# HELP velocity_matchmaker_queue_players Players searching, by bucket")
+	_, _ = fmt.Fprintln(w, "# TYPE velocity_matchmaker_queue_players gauge")
+	for _, b := range buckets {
+		_, _ = fmt.Fprintf(w, "velocity_matchmaker_queue_players{region=%q,playlist=%q} %d\n", b.Region, b.Playlist, b.Players)
+	}
+	_, _ = fmt.Fprintln(w, "# HELP velocity_matchmaker_queue_wait_seconds Wait of recently matched tickets, by bucket")
+	_, _ = fmt.Fprintln(w, "# TYPE velocity_matchmaker_queue_wait_seconds gauge")
+	for _, b := range buckets {
+		for _, q := range []struct {
+			label string
+			value float64
+		}{{"0.5", b.WaitP50Sec}, {"0.9", b.WaitP90Sec}, {"0.99", b.WaitP99Sec}} {
+			_, _ = fmt.Fprintf(w, "velocity_matchmaker_queue_wait_seconds{region=%q,playlist=%q,quantile=%q} %g\n", b.Region, b.Playlist, q.label, q.value)
+		}
+	}
+	_, _ = fmt.Fprintln(w, "# HELP velocity_matchmaker_match_mmr_spread MMR gap between the best and worst player of recent matches")
+	_, _ = fmt.Fprintln(w, "# TYPE velocity_matchmaker_match_mmr_spread gauge")
+	for _, b := range buckets {
+		_, _ = fmt.Fprintf(w, "velocity_matchmaker_match_mmr_spread{region=%q,playlist=%q,quantile=\"0.5\"} %g\n", b.Region, b.Playlist, b.MMRSpreadP50)
+		_, _ = fmt.Fprintf(w, "velocity_matchmaker_match_mmr_spread{region=%q,playlist=%q,quantile=\"0.9\"} %g\n", b.Region, b.Playlist, b.MMRSpreadP90)
+		_, _ = fmt.Fprintf(w, "velocity_matchmaker_match_mmr_spread{region=%q,playlist=%q,quantile=\"1\"} %d\n", b.Region, b.Playlist, b.MMRSpreadMax)
+	}
+	_, _ = fmt.Fprintln(w, "# HELP velocity_matchmaker_bot_fill_ratio Share of recent matches that started with bots")
+	_, _ = fmt.Fprintln(w, "# TYPE velocity_matchmaker_bot_fill_ratio gauge")
+	for _, b := range buckets {
+		_, _ = fmt.Fprintf(w, "velocity_matchmaker_bot_fill_ratio{region=%q,playlist=%q} %g\n", b.Region, b.Playlist, b.BotFillRate)
+	}
+	_, _ = fmt.Fprintln(w, "# HELP velocity_matchmaker_matches_total Matches launched")
+	_, _ = fmt.Fprintln(w, "# TYPE velocity_matchmaker_matches_total counter")
+	for _, b := range buckets {
+		_, _ = fmt.Fprintf(w, "velocity_matchmaker_matches_total{region=%q,playlist=%q} %d\n", b.Region, b.Playlist, b.MatchesTotal)
+	}
+	_, _ = fmt.Fprintln(w, "# HELP velocity_matchmaker_bot_fills_total Matches launched with bots")
+	_, _ = fmt.Fprintln(w, "# TYPE velocity_matchmaker_bot_fills_total counter")
+	for _, b := range buckets {
+		_, _ = fmt.Fprintf(w, "velocity_matchmaker_bot_fills_total{region=%q,playlist=%q} %d\n", b.Region, b.Playlist, b.BotFillsTotal)
+	}
+}
+
+// readSigned reads a game server's request body and checks its signature under key. On
+// failure it has already written the error response.
+func readSigned(w http.ResponseWriter, r *http.Request, key []byte) ([]byte, bool) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxSignedBytes))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "bad_request"})
+		return nil, false
+	}
+	if err := signing.Verify(key, body, r.Header.Get(signing.Header)); err != nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "bad_signature"})
+		return nil, false
+	}
+	return body, true
+}
+
 func writeJSON(w http.ResponseWriter, status int, v interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -136,3 +671,13 @@ func getenvInt(key string, fallback int) int {
 	}
 	return fallback
 }
+
+func getenvFloat(key string, fallback float64) float64 {
+	if v := os.Getenv(key); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err == nil {
+			return f
+		}
+	}
+	return fallback
+}
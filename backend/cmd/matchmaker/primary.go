@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"projectvelocity/backend/internal/cluster"
+	"projectvelocity/backend/internal/conduct"
+	"projectvelocity/backend/internal/fleet"
+	"projectvelocity/backend/internal/lobby"
+	"projectvelocity/backend/internal/rating"
+	"projectvelocity/backend/internal/results"
+	"projectvelocity/backend/internal/shared/types"
+	"projectvelocity/backend/internal/tournament"
+)
+
+// errNotPrimary refuses a write from an instance that has lost the primary role, so it
+// cannot overwrite what its successor saved since.
+var errNotPrimary = errors.New("cluster: not the primary")
+
+// primaryState is the cluster-wide state the primary holds.
+type primaryState struct {
+	results     *results.Store
+	ratings     *rating.Service
+	abandons    *conduct.Tracker
+	servers     *fleet.Registry
+	lobbies     *lobby.Manager
+	tournaments *tournament.Manager
+}
+
+// keepPrimaryState saves st in the shared store while this instance is the primary, and
+// loads it whenever the instance becomes the primary, so a crashed primary's successor
+// carries on where it stopped. Call it before start.
+func (s *shard) keepPrimaryState(st primaryState) {
+	s.node.OnPromote(func() error {
+		if err := s.loadPrimaryState(st); err != nil {
+			s.log.Printf("load primary state: %v", err)
+			return err
+		}
+		s.log.Printf("became cluster primary")
+		return nil
+	})
+}
+
+func (s *shard) loadPrimaryState(st primaryState) error {
+	if err := st.results.SetArchive(sharedResults{newPrimaryTable[types.MatchResult](s, "results")}); err != nil {
+		return fmt.Errorf("results: %w", err)
+	}
+	if err := st.ratings.SetStore(sharedRatings{newPrimaryTable[rating.PlayerRating](s, "ratings")}); err != nil {
+		return fmt.Errorf("ratings: %w", err)
+	}
+	if err := st.abandons.SetStore(sharedConduct{newPrimaryTable[conduct.PlayerRecord](s, "conduct")}); err != nil {
+		return fmt.Errorf("conduct: %w", err)
+	}
+	fleetStore := sharedFleet{
+		servers:      newPrimaryTable[fleet.ServerRecord](s, "fleet-servers"),
+		reservations: newPrimaryTable[fleet.ReservationRecord](s, "fleet-reservations"),
+	}
+	if err := st.servers.SetStore(fleetStore); err != nil {
+		return fmt.Errorf("fleet: %w", err)
+	}
+	if err := st.lobbies.SetStore(sharedLobbies{newPrimaryTable[lobby.Record](s, "lobbies")}); err != nil {
+		return fmt.Errorf("lobbies: %w", err)
+	}
+	if err := st.tournaments.SetStore(sharedTournaments{newPrimaryTable[tournament.Record](s, "tournaments")}); err != nil {
+		return fmt.Errorf("tournaments: %w", err)
+	}
+	return nil
+}
+
+// primaryTable is a table of primary state in the shared store. Only the primary may
+// write it.
+type primaryTable[T any] struct {
+	table *cluster.Table
+	node  *cluster.Node
+}
+
+func newPrimaryTable[T any](s *shard, name string) primaryTable[T] {
+	return primaryTable[T]{table: cluster.NewTable(s.store, name), node: s.node}
+}
+
+func (t primaryTable[T]) Load() ([]T, error) {
+	var out []T
+	err := t.table.Load("", func(data []byte) error {
+		var v T
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		out = append(out, v)
+		return nil
+	})
+	return out, err
+}
+
+func (t primaryTable[T]) put(key string, v T) error {
+	if !t.node.IsPrimary() {
+		return errNotPrimary
+	}
+	return t.table.Put(key, v)
+}
+
+func (t primaryTable[T]) delete(key string) error {
+	if !t.node.IsPrimary() {
+		return errNotPrimary
+	}
+	return t.table.Delete(key)
+}
+
+// Close leaves the shared store open; the shard owns it.
+func (t primaryTable[T]) Close() error {
+	return nil
+}
+
+type sharedResults struct {
+	primaryTable[types.MatchResult]
+}
+
+func (s sharedResults) Put(r types.MatchResult) error { return s.put(r.MatchID, r) }
+
+type sharedRatings struct {
+	primaryTable[rating.PlayerRating]
+}
+
+func (s sharedRatings) Put(rec rating.PlayerRating) error {
+	return s.put(rec.Playlist+"/"+rec.PlayerID, rec)
+}
+
+type sharedConduct struct {
+	primaryTable[conduct.PlayerRecord]
+}
+
+func (s sharedConduct) Put(rec conduct.PlayerRecord) error { return s.put(rec.PlayerID, rec) }
+func (s sharedConduct) Delete(playerID string) error       { return s.delete(playerID) }
+
+type sharedLobbies struct{ primaryTable[lobby.Record] }
+
+func (s sharedLobbies) Put(rec lobby.Record) error  { return s.put(rec.Lobby.LobbyID, rec) }
+func (s sharedLobbies) Delete(lobbyID string) error { return s.delete(lobbyID) }
+
+type sharedTournaments struct {
+	primaryTable[tournament.Record]
+}
+
+func (s sharedTournaments) Put(rec tournament.Record) error {
+	return s.put(rec.Tournament.TournamentID, rec)
+}
+
+type sharedFleet struct {
+	servers      primaryTable[fleet.ServerRecord]
+	reservations primaryTable[fleet.ReservationRecord]
+}
+
+func (s sharedFleet) Load() ([]fleet.ServerRecord, []fleet.ReservationRecord, error) {
+	servers, err := s.servers.Load()
+	if err != nil {
+		return nil, nil, err
+	}
+	reservations, err := s.reservations.Load()
+	if err != nil {
+		return nil, nil, err
+	}
+	return servers, reservations, nil
+}
+
+func (s sharedFleet) PutServer(rec fleet.ServerRecord) error {
+	return s.servers.put(rec.Info.ServerID, rec)
+}
+
+func (s sharedFleet) DeleteServer(serverID string) error { return s.servers.delete(serverID) }
+
+func (s sharedFleet) PutReservation(rec fleet.ReservationRecord) error {
+	return s.reservations.put(rec.MatchID, rec)
+}
+
+func (s sharedFleet) DeleteReservation(matchID string) error {
+	return s.reservations.delete(matchID)
+}
+
+func (s sharedFleet) Close() error {
+	return nil
+}
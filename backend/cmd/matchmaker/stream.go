@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"projectvelocity/backend/internal/matchmaking"
+	"projectvelocity/backend/internal/shared/types"
+)
+
+const (
+	// streamWaitUpdate is how often a quiet stream sends wait_update; it doubles as a keep-alive.
+	streamWaitUpdate = 5 * time.Second
+	// maxLongPoll caps how long /v1/queue/poll may hold a request open.
+	maxLongPoll = 30 * time.Second
+)
+
+// handleQueueStream serves ticket status as server-sent events. Each status change is
+// an event named after the new status (searching, accept_required, matched,
+// cancelled, not_found); the stream ends once the ticket is matched or gone.
+func handleQueueStream(manager *matchmaking.QueueManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+			return
+		}
+		ticketID := r.URL.Query().Get("ticket_id")
+		if ticketID == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "ticket_id_required"})
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "streaming_unsupported"})
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		ticker := time.NewTicker(streamWaitUpdate)
+		defer ticker.Stop()
+		seen := false
+		for {
+			poll, changed := manager.Watch(ticketID)
+			if seen && poll.Status == "not_found" {
+				// The ticket was handed to another instance. Dropping the stream without a
+				// terminal event makes the client reconnect, which routes to the new holder.
+				return
+			}
+			seen = true
+			writeEvent(w, poll.Status, poll)
+			flusher.Flush()
+			if terminalStatus(poll.Status) {
+				return
+			}
+		idle:
+			for {
+				select {
+				case <-r.Context().Done():
+					return
+				case <-changed:
+					break idle
+				case <-ticker.C:
+					writeEvent(w, "wait_update", manager.Poll(ticketID))
+					flusher.Flush()
+				}
+			}
+		}
+	}
+}
+
+// longPoll answers /v1/queue/poll?since=&wait_ms=: it holds the request until the
+// ticket moves past version since or wait_ms elapses.
+func longPoll(manager *matchmaking.QueueManager, r *http.Request, ticketID string) types.QueuePollResponse {
+	query := r.URL.Query()
+	waitMS, err := strconv.Atoi(query.Get("wait_ms"))
+	if err != nil || waitMS <= 0 {
+		return manager.Poll(ticketID)
+	}
+	since, err := strconv.ParseUint(query.Get("since"), 10, 64)
+	if err != nil {
+		return manager.Poll(ticketID)
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), min(time.Duration(waitMS)*time.Millisecond, maxLongPoll))
+	defer cancel()
+	return manager.WaitForChange(ctx, ticketID, since)
+}
+
+func terminalStatus(status string) bool {
+	return status == "matched" || status == "cancelled" || status == "not_found"
+}
+
+func writeEvent(w http.ResponseWriter, event string, v interface{}) {
+	data, _ := json.Marshal(v)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
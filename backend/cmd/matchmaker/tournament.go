@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"projectvelocity/backend/internal/tournament"
+)
+
+// tournamentRequest is the body of every tournament mutation. The gateway fills in
+// PlayerID from the caller's session: the organizer for create and start, the captain
+// for register and withdraw.
+type tournamentRequest struct {
+	PlayerID     string            `json:"player_id"`
+	TournamentID string            `json:"tournament_id,omitempty"`
+	Config       tournament.Config `json:"config"`
+	TeamName     string            `json:"team_name,omitempty"`
+	Players      []string          `json:"players,omitempty"`
+}
+
+// registerTournamentRoutes serves tournament brackets. Like lobbies, tournaments live
+// on this instance only.
+func registerTournamentRoutes(mux *http.ServeMux, tournaments *tournament.Manager) {
+	mux.HandleFunc("/v1/tournaments", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"tournaments": tournaments.List()})
+	})
+	mux.HandleFunc("/v1/tournaments/bracket", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+			return
+		}
+		t, err := tournaments.Get(r.URL.Query().Get("tournament_id"))
+		if err != nil {
+			writeTournamentError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, t)
+	})
+	mux.HandleFunc("/v1/tournaments/match", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+			return
+		}
+		a, err := tournaments.Assignment(r.URL.Query().Get("player_id"))
+		if err != nil {
+			writeTournamentError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, a)
+	})
+	mux.HandleFunc("/v1/tournaments/create", tournamentHandler(func(req tournamentRequest) (interface{}, error) {
+		return tournaments.Create(req.PlayerID, req.Config)
+	}))
+	mux.HandleFunc("/v1/tournaments/register", tournamentHandler(func(req tournamentRequest) (interface{}, error) {
+		return tournaments.Register(req.TournamentID, req.PlayerID, req.TeamName, req.Players)
+	}))
+	mux.HandleFunc("/v1/tournaments/withdraw", tournamentHandler(func(req tournamentRequest) (interface{}, error) {
+		return tournaments.Withdraw(req.TournamentID, req.PlayerID)
+	}))
+	mux.HandleFunc("/v1/tournaments/start", tournamentHandler(func(req tournamentRequest) (interface{}, error) {
+		return tournaments.Start(req.TournamentID, req.PlayerID)
+	}))
+}
+
+// tournamentHandler decodes a tournament mutation, runs it and writes its result or error.
+func tournamentHandler(do func(tournamentRequest) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+			return
+		}
+		var req tournamentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "bad_request"})
+			return
+		}
+		if req.PlayerID == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "player_id_required"})
+			return
+		}
+		out, err := do(req)
+		if err != nil {
+			writeTournamentError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, out)
+	}
+}
+
+func writeTournamentError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, tournament.ErrNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "tournament_not_found"})
+	case errors.Is(err, tournament.ErrNoMatch):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "no_tournament_match"})
+	case errors.Is(err, tournament.ErrBadConfig):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_config", "detail": err.Error()})
+	case errors.Is(err, tournament.ErrTeamSize):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "wrong_team_size"})
+	case errors.Is(err, tournament.ErrNotOrganizer):
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "not_organizer"})
+	case errors.Is(err, tournament.ErrClosed):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "registration_closed"})
+	case errors.Is(err, tournament.ErrFull):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "tournament_full"})
+	case errors.Is(err, tournament.ErrAlreadyRegistered):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "already_registered"})
+	case errors.Is(err, tournament.ErrTooFewTeams):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "too_few_teams"})
+	default:
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "tournament_error"})
+	}
+}
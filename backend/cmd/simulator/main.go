@@ -0,0 +1,343 @@
+// Command simulator replays synthetic queue traffic through the matchmaker on a
+// virtual clock, so MMR window and bot-fill changes can be compared before rollout.
+//
+//	go run ./cmd/simulator -rate 3 -playlists ranked-2v2,casual-2v2 -mmr-per-sec 8
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"projectvelocity/backend/internal/matchmaking"
+	"projectvelocity/backend/internal/shared/logger"
+	"projectvelocity/backend/internal/shared/types"
+)
+
+// config is everything a run depends on; it is echoed in JSON reports.
+type config struct {
+	Duration   time.Duration         `json:"duration"`
+	Warmup     time.Duration         `json:"warmup"`
+	Tick       time.Duration         `json:"tick"`
+	Rate       float64               `json:"arrivals_per_sec"`
+	Playlists  []string              `json:"playlists"`
+	Regions    map[string]float64    `json:"regions"`
+	Pings      bool                  `json:"pings"`
+	MMRDist    string                `json:"mmr_dist"`
+	MMRMean    float64               `json:"mmr_mean"`
+	MMRStddev  float64               `json:"mmr_stddev"`
+	PartyRate  float64               `json:"party_rate"`
+	Patience   time.Duration         `json:"patience"`
+	SearchTTL  time.Duration         `json:"search_ttl"`
+	Window     matchmaking.MMRWindow `json:"mmr_window"`
+	BotWaitMS  int64                 `json:"bot_wait_ms"`
+	Seed       int64                 `json:"seed"`
+	JSONOutput bool                  `json:"-"`
+}
+
+func main() {
+	log := logger.New("simulator")
+	cfg, err := parseFlags(os.Args[1:])
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	rep, err := run(cfg)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if cfg.JSONOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(map[string]interface{}{"config": cfg, "report": rep})
+		return
+	}
+	rep.print(os.Stdout, cfg)
+}
+
+// MarshalJSON writes durations as strings like "1h0m0s" instead of nanoseconds.
+func (c config) MarshalJSON() ([]byte, error) {
+	type plain config
+	return json.Marshal(struct {
+		plain
+		Duration  string `json:"duration"`
+		Warmup    string `json:"warmup"`
+		Tick      string `json:"tick"`
+		Patience  string `json:"patience"`
+		SearchTTL string `json:"search_ttl"`
+	}{plain(c), c.Duration.String(), c.Warmup.String(), c.Tick.String(), c.Patience.String(), c.SearchTTL.String()})
+}
+
+func parseFlags(args []string) (config, error) {
+	window := matchmaking.DefaultMMRWindow()
+	cfg := config{}
+	fs := flag.NewFlagSet("simulator", flag.ContinueOnError)
+	fs.DurationVar(&cfg.Duration, "duration", time.Hour, "virtual time to simulate")
+	fs.DurationVar(&cfg.Warmup, "warmup", 5*time.Minute, "leading virtual time excluded from the report")
+	fs.DurationVar(&cfg.Tick, "tick", time.Second, "matchmaking pass interval")
+	fs.Float64Var(&cfg.Rate, "rate", 2, "mean arrivals (tickets) per second")
+	playlists := fs.String("playlists", "ranked-2v2", "comma-separated playlists, picked uniformly per arrival")
+	regions := fs.String("regions", "us-east=1", "comma-separated region=weight pairs for home regions")
+	fs.BoolVar(&cfg.Pings, "pings", true, "send measured pings so tickets can match across regions")
+	fs.StringVar(&cfg.MMRDist, "mmr-dist", "normal", "player MMR distribution: normal or uniform")
+	fs.Float64Var(&cfg.MMRMean, "mmr-mean", 1000, "mean player MMR")
+	fs.Float64Var(&cfg.MMRStddev, "mmr-stddev", 250, "player MMR standard deviation")
+	fs.Float64Var(&cfg.PartyRate, "party-rate", 0.2, "share of arrivals that are parties, where the playlist allows them")
+	fs.DurationVar(&cfg.Patience, "patience", 0, "mean time before a searching player gives up; 0 never")
+	fs.DurationVar(&cfg.SearchTTL, "search-ttl", matchmaking.DefaultTTLs().Searching, "searching ticket TTL")
+	fs.IntVar(&cfg.Window.Base, "mmr-base", window.Base, "MMR window at zero wait")
+	fs.Float64Var(&cfg.Window.PerSecond, "mmr-per-sec", window.PerSecond, "MMR window growth per second waited")
+	fs.IntVar(&cfg.Window.MaxWiden, "mmr-max-widen", window.MaxWiden, "cap on MMR window growth")
+	fs.Int64Var(&cfg.BotWaitMS, "bot-wait-ms", -1, "override every bot playlist's wait before bot fill; -1 keeps the defaults")
+	fs.Int64Var(&cfg.Seed, "seed", 1, "random seed")
+	fs.BoolVar(&cfg.JSONOutput, "json", false, "print the report as JSON")
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	for _, p := range strings.Split(*playlists, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			cfg.Playlists = append(cfg.Playlists, p)
+		}
+	}
+	cfg.Regions = make(map[string]float64)
+	for _, pair := range strings.Split(*regions, ",") {
+		name, weight, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			weight = "1"
+		}
+		w, err := strconv.ParseFloat(weight, 64)
+		if name == "" || err != nil || w <= 0 {
+			return cfg, fmt.Errorf("bad region weight %q", pair)
+		}
+		cfg.Regions[name] = w
+	}
+	switch {
+	case len(cfg.Playlists) == 0:
+		return cfg, fmt.Errorf("no playlists")
+	case cfg.Rate <= 0 || cfg.Tick <= 0 || cfg.Duration <= cfg.Warmup:
+		return cfg, fmt.Errorf("rate and tick must be positive and duration longer than warmup")
+	case cfg.MMRDist != "normal" && cfg.MMRDist != "uniform":
+		return cfg, fmt.Errorf("unknown MMR distribution %q", cfg.MMRDist)
+	}
+	return cfg, nil
+}
+
+// simTicket is what the simulator remembers about a ticket it queued.
+type simTicket struct {
+	id       string
+	leader   string
+	playlist string
+	joined   time.Time
+	giveUp   time.Time // zero when the players never give up
+}
+
+// simulation holds one run's state.
+type simulation struct {
+	cfg     config
+	rng     *rand.Rand
+	now     time.Time
+	start   time.Time
+	queue   *matchmaking.QueueManager
+	regions []string
+	players int
+	mmr     map[string]int // every simulated player's MMR
+	open    map[string]*simTicket
+	seen    map[string]bool // match IDs already counted
+	rep     *report
+}
+
+func run(cfg config) (*report, error) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := &simulation{
+		cfg:   cfg,
+		rng:   rand.New(rand.NewSource(cfg.Seed)),
+		now:   start,
+		start: start,
+		queue: matchmaking.NewQueueManager(""),
+		mmr:   make(map[string]int),
+		open:  make(map[string]*simTicket),
+		seen:  make(map[string]bool),
+		rep:   newReport(),
+	}
+	for name := range cfg.Regions {
+		s.regions = append(s.regions, name)
+	}
+	// Map order is random; sort so a seed always replays the same run.
+	sort.Strings(s.regions)
+
+	s.queue.SetClock(func() time.Time { return s.now })
+	s.queue.SetReadyCheckTimeout(0)
+	s.queue.SetMMRWindow(cfg.Window)
+	s.queue.SetTTLs(matchmaking.TTLs{Searching: cfg.SearchTTL, Assignment: time.Minute, Cancelled: time.Minute})
+	for _, name := range cfg.Playlists {
+		p, ok := s.queue.Playlists().Get(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown playlist %q", name)
+		}
+		if cfg.BotWaitMS >= 0 && p.Bots.Allowed {
+			p.Bots.WaitMS = cfg.BotWaitMS
+			if err := s.queue.Playlists().Register(p); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	end := start.Add(cfg.Duration)
+	nextArrival := start.Add(s.interarrival())
+	nextTick := start.Add(cfg.Tick)
+	for {
+		if nextArrival.Before(nextTick) {
+			if !nextArrival.Before(end) {
+				break
+			}
+			s.now = nextArrival
+			s.arrive()
+			nextArrival = s.now.Add(s.interarrival())
+			continue
+		}
+		if nextTick.After(end) {
+			break
+		}
+		s.now = nextTick
+		s.queue.Process()
+		s.collect()
+		s.queue.Expire(s.now)
+		nextTick = s.now.Add(cfg.Tick)
+	}
+	for _, t := range s.open {
+		if t.joined.Sub(s.start) >= cfg.Warmup {
+			s.rep.playlist(t.playlist).StillSearching++
+		}
+	}
+	s.rep.finish()
+	return s.rep, nil
+}
+
+// interarrival draws the gap to the next ticket of a Poisson arrival stream.
+func (s *simulation) interarrival() time.Duration {
+	return time.Duration(s.rng.ExpFloat64() / s.cfg.Rate * float64(time.Second))
+}
+
+// arrive queues a new solo player or party.
+func (s *simulation) arrive() {
+	name := s.cfg.Playlists[s.rng.Intn(len(s.cfg.Playlists))]
+	playlist, _ := s.queue.Playlists().Get(name)
+	size := 1
+	if playlist.TeamSize > 1 && s.rng.Float64() < s.cfg.PartyRate {
+		size = 2 + s.rng.Intn(playlist.TeamSize-1)
+	}
+
+	// Friends queue together at broadly similar skill.
+	base := s.drawMMR()
+	members := make([]types.PartyMember, size)
+	for i := range members {
+		s.players++
+		id := fmt.Sprintf("sim_%d", s.players)
+		mmr := base
+		if i > 0 {
+			mmr = max(0, base+int(s.rng.NormFloat64()*100))
+		}
+		s.mmr[id] = mmr
+		members[i] = types.PartyMember{PlayerID: id, MMR: mmr}
+	}
+	home := s.pickRegion()
+	req := types.QueueJoinRequest{
+		PlayerID: members[0].PlayerID,
+		MMR:      members[0].MMR,
+		Region:   home,
+		Playlist: name,
+		Pings:    s.drawPings(home),
+	}
+	if size > 1 {
+		req.PartyID = "party_" + members[0].PlayerID
+		req.Members = members
+	}
+	resp := s.queue.Join(req)
+	t := &simTicket{id: resp.TicketID, leader: req.PlayerID, playlist: name, joined: s.now}
+	if s.cfg.Patience > 0 {
+		t.giveUp = s.now.Add(time.Duration(s.rng.ExpFloat64() * float64(s.cfg.Patience)))
+	}
+	s.open[t.id] = t
+}
+
+func (s *simulation) drawMMR() int {
+	var v float64
+	if s.cfg.MMRDist == "uniform" {
+		// Same mean and spread as the normal distribution.
+		half := s.cfg.MMRStddev * math.Sqrt(3)
+		v = s.cfg.MMRMean - half + s.rng.Float64()*2*half
+	} else {
+		v = s.cfg.MMRMean + s.rng.NormFloat64()*s.cfg.MMRStddev
+	}
+	return max(0, int(math.Round(v)))
+}
+
+func (s *simulation) pickRegion() string {
+	total := 0.0
+	for _, w := range s.cfg.Regions {
+		total += w
+	}
+	pick := s.rng.Float64() * total
+	for _, name := range s.regions {
+		if pick -= s.cfg.Regions[name]; pick < 0 {
+			return name
+		}
+	}
+	return s.regions[len(s.regions)-1]
+}
+
+// drawPings models a player close to their home region and far from the rest.
+func (s *simulation) drawPings(home string) map[string]int {
+	if !s.cfg.Pings || len(s.regions) < 2 {
+		return nil
+	}
+	pings := make(map[string]int, len(s.regions))
+	for _, name := range s.regions {
+		if name == home {
+			pings[name] = 15 + s.rng.Intn(30)
+		} else {
+			pings[name] = 60 + s.rng.Intn(120)
+		}
+	}
+	return pings
+}
+
+// collect records tickets that matched this tick and walks away impatient players.
+func (s *simulation) collect() {
+	for id, t := range s.open {
+		poll := s.queue.Poll(id)
+		switch poll.Status {
+		case "matched":
+			delete(s.open, id)
+			if t.joined.Sub(s.start) >= s.cfg.Warmup {
+				s.rep.playlist(t.playlist).addWait(s.now.Sub(t.joined))
+			}
+			if a := poll.Assignment; a != nil && !s.seen[a.MatchID] {
+				s.seen[a.MatchID] = true
+				if s.now.Sub(s.start) >= s.cfg.Warmup {
+					s.rep.playlist(t.playlist).addMatch(a, s.mmr)
+				}
+			}
+		case "cancelled", "not_found":
+			delete(s.open, id)
+			if t.joined.Sub(s.start) >= s.cfg.Warmup {
+				s.rep.playlist(t.playlist).TimedOut++
+			}
+		case "searching":
+			if !t.giveUp.IsZero() && !s.now.Before(t.giveUp) {
+				s.queue.Leave(id, t.leader)
+				delete(s.open, id)
+				if t.joined.Sub(s.start) >= s.cfg.Warmup {
+					s.rep.playlist(t.playlist).GaveUp++
+				}
+			}
+		}
+	}
+}
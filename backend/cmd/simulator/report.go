@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"projectvelocity/backend/internal/shared/types"
+)
+
+// report collects outcomes per playlist. Tickets that joined during the warmup and
+// matches made during it are left out.
+type report struct {
+	Playlists map[string]*playlistReport `json:"playlists"`
+	Overall   *playlistReport            `json:"overall"`
+}
+
+type playlistReport struct {
+	// Matched counts tickets that found a match; Wait is their time in queue.
+	Matched int     `json:"matched"`
+	Wait    summary `json:"wait_sec"`
+	Matches int     `json:"matches"`
+	// MMRSpread is the gap between a match's best and worst human player.
+	MMRSpread summary `json:"mmr_spread"`
+	// TeamGap is the difference between the two teams' mean human MMR, for matches
+	// with humans on both sides.
+	TeamGap     summary `json:"team_gap"`
+	BotFilled   int     `json:"bot_filled"`
+	BotSlots    int     `json:"bot_slots"`
+	BotFillRate float64 `json:"bot_fill_rate"`
+	// GaveUp left on their own, TimedOut hit the searching TTL and StillSearching were
+	// queued when the run ended.
+	GaveUp         int `json:"gave_up"`
+	TimedOut       int `json:"timed_out"`
+	StillSearching int `json:"still_searching"`
+
+	waits, spreads, gaps []float64
+}
+
+// summary describes a distribution.
+type summary struct {
+	N    int     `json:"n"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+func newReport() *report {
+	return &report{Playlists: make(map[string]*playlistReport)}
+}
+
+func (r *report) playlist(name string) *playlistReport {
+	p, ok := r.Playlists[name]
+	if !ok {
+		p = &playlistReport{}
+		r.Playlists[name] = p
+	}
+	return p
+}
+
+func (p *playlistReport) addWait(d time.Duration) {
+	p.Matched++
+	p.waits = append(p.waits, d.Seconds())
+}
+
+func (p *playlistReport) addMatch(a *types.MatchAssignment, mmr map[string]int) {
+	p.Matches++
+	if a.BotFill {
+		p.BotFilled++
+		p.BotSlots += len(a.Bots)
+	}
+	lo, hi := math.MaxInt, math.MinInt
+	var means []float64
+	for _, team := range []string{"orange", "blue"} {
+		sum, n := 0, 0
+		for _, id := range a.Teams[team] {
+			v, ok := mmr[id]
+			if !ok {
+				continue // a bot
+			}
+			lo, hi = min(lo, v), max(hi, v)
+			sum += v
+			n++
+		}
+		if n > 0 {
+			means = append(means, float64(sum)/float64(n))
+		}
+	}
+	if hi >= lo {
+		p.spreads = append(p.spreads, float64(hi-lo))
+	}
+	if len(means) == 2 {
+		p.gaps = append(p.gaps, math.Abs(means[0]-means[1]))
+	}
+}
+
+// finish computes every summary and the overall totals.
+func (r *report) finish() {
+	overall := &playlistReport{}
+	for _, p := range r.Playlists {
+		p.summarise()
+		overall.Matched += p.Matched
+		overall.Matches += p.Matches
+		overall.BotFilled += p.BotFilled
+		overall.BotSlots += p.BotSlots
+		overall.GaveUp += p.GaveUp
+		overall.TimedOut += p.TimedOut
+		overall.StillSearching += p.StillSearching
+		overall.waits = append(overall.waits, p.waits...)
+		overall.spreads = append(overall.spreads, p.spreads...)
+		overall.gaps = append(overall.gaps, p.gaps...)
+	}
+	overall.summarise()
+	r.Overall = overall
+}
+
+func (p *playlistReport) summarise() {
+	p.Wait = summarise(p.waits)
+	p.MMRSpread = summarise(p.spreads)
+	p.TeamGap = summarise(p.gaps)
+	if p.Matches > 0 {
+		p.BotFillRate = float64(p.BotFilled) / float64(p.Matches)
+	}
+}
+
+func summarise(values []float64) summary {
+	if len(values) == 0 {
+		return summary{}
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	sum := 0.0
+	for _, v := range sorted {
+		sum += v
+	}
+	rank := func(q float64) float64 {
+		return sorted[max(int(math.Ceil(q*float64(len(sorted))))-1, 0)]
+	}
+	return summary{
+		N:    len(sorted),
+		Mean: sum / float64(len(sorted)),
+		P50:  rank(0.5),
+		P90:  rank(0.9),
+		P99:  rank(0.99),
+		Max:  sorted[len(sorted)-1],
+	}
+}
+
+func (r *report) print(w io.Writer, cfg config) {
+	fmt.Fprintf(w, "simulated %s (after %s warmup) at %.2f tickets/s, seed %d\n", cfg.Duration-cfg.Warmup, cfg.Warmup, cfg.Rate, cfg.Seed)
+	fmt.Fprintf(w, "mmr window: base %d, +%g/s, widen cap %d\n\n", cfg.Window.Base, cfg.Window.PerSecond, cfg.Window.MaxWiden)
+
+	names := make([]string, 0, len(r.Playlists))
+	for name := range r.Playlists {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "playlist\tmetric\tn\tmean\tp50\tp90\tp99\tmax\t")
+	row := func(name, metric string, s summary) {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t\n", name, metric, s.N, s.Mean, s.P50, s.P90, s.P99, s.Max)
+	}
+	for _, name := range append(names, "overall") {
+		p := r.Overall
+		if name != "overall" {
+			p = r.Playlists[name]
+		}
+		row(name, "wait (s)", p.Wait)
+		row("", "mmr spread", p.MMRSpread)
+		row("", "team gap", p.TeamGap)
+	}
+	_ = tw.Flush()
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "playlist\tmatches\tbot fill\tbot slots\tgave up\ttimed out\tstill searching\t")
+	for _, name := range append(names, "overall") {
+		p := r.Overall
+		if name != "overall" {
+			p = r.Playlists[name]
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%.1f%%\t%d\t%d\t%d\t%d\t\n", name, p.Matches, 100*p.BotFillRate, p.BotSlots, p.GaveUp, p.TimedOut, p.StillSearching)
+	}
+	_ = tw.Flush()
+}
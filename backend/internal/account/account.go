@@ -0,0 +1,524 @@
+// Package account keeps players' persistent identities: a stable player id, display
+// name, creation date and the credentials they sign in with.
+package account
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// SessionTTL is how long a sign-in lasts.
+	SessionTTL = 24 * time.Hour
+	// MinPasswordLength is the shortest password an account may have.
+	MinPasswordLength = 8
+	// MaxDisplayNameLength caps display names, matching what the client lets players type.
+	MaxDisplayNameLength = 24
+	// passwordIterations is the PBKDF2-SHA256 work factor for new password hashes.
+	passwordIterations = 600_000
+)
+
+var (
+	ErrNotFound       = errors.New("account: not found")
+	ErrBadCredentials = errors.New("account: wrong username or password")
+	ErrUsernameTaken  = errors.New("account: username taken")
+	ErrBadUsername    = errors.New("account: usernames are 3-20 letters, digits or underscores")
+	ErrWeakPassword   = errors.New("account: password too short")
+	ErrBadDevice      = errors.New("account: device id must be 8-128 characters")
+	ErrNotGuest       = errors.New("account: account already has a username")
+)
+
+// Credential is a salted PBKDF2-SHA256 password hash.
+type Credential struct {
+	Salt       []byte `json:"salt"`
+	Iterations int    `json:"iterations"`
+	Hash       []byte `json:"hash"`
+}
+
+// Account is a player's persistent identity. Guests have no username or password and
+// sign back in with their device id. Upgrading a guest adds credentials but keeps the
+// player id, so ratings, match history and everything else keyed by it carry over.
+type Account struct {
+	PlayerID    string      `json:"player_id"`
+	DisplayName string      `json:"display_name"`
+	CreatedAt   time.Time   `json:"created_at"`
+	Username    string      `json:"username,omitempty"`
+	Password    *Credential `json:"password,omitempty"`
+	// Devices are hashes of the device ids that sign in to this account.
+	Devices    []string  `json:"devices,omitempty"`
+	UpgradedAt time.Time `json:"upgraded_at,omitzero"`
+}
+
+// Guest reports whether the account has no username and password yet.
+func (a Account) Guest() bool {
+	return a.Username == ""
+}
+
+// Session is one sign-in. Only a hash of its bearer token is kept.
+type Session struct {
+	TokenHash string `json:"token_hash"`
+	PlayerID  string `json:"player_id"`
+	ExpiresAt int64  `json:"expires_at"` // unix seconds
+}
+
+// Manager signs players in and keeps their accounts and sessions, writing every change
+// through to its Store.
+type Manager struct {
+	mu         sync.RWMutex
+	store      Store
+	accounts   map[string]Account
+	byUsername map[string]string
+	byDevice   map[string]string
+	sessions   map[string]Session
+	// ephemeral marks guests with no device to sign back in with. They are never written
+	// to the store and are forgotten when their last session ends, unless upgraded.
+	ephemeral map[string]bool
+}
+
+// NewManager loads every account and live session from store.
+func NewManager(store Store) (*Manager, error) {
+	accounts, sessions, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	m := &Manager{
+		store:      store,
+		accounts:   make(map[string]Account, len(accounts)),
+		byUsername: make(map[string]string),
+		byDevice:   make(map[string]string),
+		sessions:   make(map[string]Session, len(sessions)),
+		ephemeral:  make(map[string]bool),
+	}
+	for _, a := range accounts {
+		m.index(a)
+	}
+	for _, s := range sessions {
+		m.sessions[s.TokenHash] = s
+	}
+	return m, nil
+}
+
+// Get returns the account for playerID.
+func (m *Manager) Get(playerID string) (Account, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	a, ok := m.accounts[playerID]
+	if !ok {
+		return Account{}, ErrNotFound
+	}
+	return a, nil
+}
+
+// Count returns how many accounts exist and how many of them are guests.
+func (m *Manager) Count() (total, guests int) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, a := range m.accounts {
+		if a.Guest() {
+			guests++
+		}
+	}
+	return len(m.accounts), guests
+}
+
+// Guest creates a guest account that is not bound to any device. Nothing could sign
+// back in to it, so it lives in memory only and is forgotten with its last session;
+// upgrading it makes it permanent.
+func (m *Manager) Guest(displayName string) (Account, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, err := m.newAccount(displayName)
+	if err != nil {
+		return Account{}, err
+	}
+	m.index(a)
+	m.ephemeral[a.PlayerID] = true
+	return a, nil
+}
+
+// Device signs in with a device id, creating a guest account bound to it the first time
+// the device is seen. A non-empty displayName renames the account. It reports whether
+// the account was created.
+func (m *Manager) Device(deviceID, displayName string) (Account, bool, error) {
+	device, err := deviceHash(deviceID)
+	if err != nil {
+		return Account{}, false, err
+	}
+	displayName = cleanDisplayName(displayName)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id, ok := m.byDevice[device]; ok {
+		a := m.accounts[id]
+		if displayName == "" || displayName == a.DisplayName {
+			return a, false, nil
+		}
+		a.DisplayName = displayName
+		return a, false, m.save(a)
+	}
+	a, err := m.newAccount(displayName)
+	if err != nil {
+		return Account{}, false, err
+	}
+	a.Devices = []string{device}
+	return a, true, m.save(a)
+}
+
+// Register creates a full account with a username and password, bound to deviceID when
+// one is given.
+func (m *Manager) Register(username, password, displayName, deviceID string) (Account, error) {
+	username, cred, device, err := prepareCredentials(username, password, deviceID)
+	if err != nil {
+		return Account{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, taken := m.byUsername[username]; taken {
+		return Account{}, ErrUsernameTaken
+	}
+	a, err := m.newAccount(displayName)
+	if err != nil {
+		return Account{}, err
+	}
+	a.Username, a.Password = username, cred
+	return a, m.saveWithDevice(a, device)
+}
+
+// Login checks a username and password, and binds deviceID to the account when one is
+// given so the device can sign in on its own from then on.
+func (m *Manager) Login(username, password, deviceID string) (Account, error) {
+	username = normalizeUsername(username)
+	var device string
+	if deviceID != "" {
+		var err error
+		if device, err = deviceHash(deviceID); err != nil {
+			return Account{}, err
+		}
+	}
+
+	m.mu.RLock()
+	a, ok := m.accounts[m.byUsername[username]]
+	m.mu.RUnlock()
+	// Hash either way so an unknown username takes as long as a wrong password.
+	cred := a.Password
+	if !ok || cred == nil {
+		cred = &Credential{Salt: make([]byte, 16), Iterations: passwordIterations}
+	}
+	if !cred.matches(password) || !ok {
+		return Account{}, ErrBadCredentials
+	}
+	if device == "" {
+		return a, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a = m.accounts[a.PlayerID]
+	if slices.Contains(a.Devices, device) {
+		return a, nil
+	}
+	return a, m.saveWithDevice(a, device)
+}
+
+// Upgrade gives the guest playerID a username and password. The player id is kept, so
+// the player's progress stays with the account. A non-empty displayName renames it.
+func (m *Manager) Upgrade(playerID, username, password, displayName string) (Account, error) {
+	username, cred, _, err := prepareCredentials(username, password, "")
+	if err != nil {
+		return Account{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.accounts[playerID]
+	if !ok {
+		return Account{}, ErrNotFound
+	}
+	if !a.Guest() {
+		return Account{}, ErrNotGuest
+	}
+	if _, taken := m.byUsername[username]; taken {
+		return Account{}, ErrUsernameTaken
+	}
+	a.Username, a.Password, a.UpgradedAt = username, cred, time.Now().UTC()
+	if name := cleanDisplayName(displayName); name != "" {
+		a.DisplayName = name
+	}
+	if err := m.save(a); err != nil {
+		return Account{}, err
+	}
+	if m.ephemeral[playerID] {
+		// The account is durable now, so its live sessions must be too.
+		for _, sess := range m.sessions {
+			if sess.PlayerID != playerID {
+				continue
+			}
+			if err := m.store.PutSession(sess); err != nil {
+				return Account{}, err
+			}
+		}
+		delete(m.ephemeral, playerID)
+	}
+	return a, nil
+}
+
+// StartSession signs playerID in for SessionTTL and returns the bearer token.
+func (m *Manager) StartSession(playerID string) (string, Session, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", Session{}, err
+	}
+	s := Session{
+		TokenHash: tokenHash(token),
+		PlayerID:  playerID,
+		ExpiresAt: time.Now().UTC().Add(SessionTTL).Unix(),
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.accounts[playerID]; !ok {
+		return "", Session{}, ErrNotFound
+	}
+	if !m.ephemeral[playerID] {
+		if err := m.store.PutSession(s); err != nil {
+			return "", Session{}, err
+		}
+	}
+	m.sessions[s.TokenHash] = s
+	return token, s, nil
+}
+
+// Authenticate returns the account and session a bearer token signs in, if the session
+// is still live.
+func (m *Manager) Authenticate(token string) (Account, Session, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.sessions[tokenHash(token)]
+	if !ok || s.ExpiresAt < time.Now().UTC().Unix() {
+		return Account{}, Session{}, false
+	}
+	a, ok := m.accounts[s.PlayerID]
+	if !ok {
+		return Account{}, Session{}, false
+	}
+	return a, s, true
+}
+
+// EndSession signs a bearer token out.
+func (m *Manager) EndSession(token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h := tokenHash(token)
+	s, ok := m.sessions[h]
+	if !ok {
+		return nil
+	}
+	return m.dropSession(h, s)
+}
+
+// Expire forgets sessions that ran out before now and returns how many were dropped.
+func (m *Manager) Expire(now time.Time) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for h, s := range m.sessions {
+		if s.ExpiresAt >= now.Unix() {
+			continue
+		}
+		if err := m.dropSession(h, s); err != nil {
+			continue
+		}
+		n++
+	}
+	return n
+}
+
+// dropSession deletes a session and forgets its account if that was a device-less
+// guest's last one. Callers hold m.mu.
+func (m *Manager) dropSession(h string, s Session) error {
+	if !m.ephemeral[s.PlayerID] {
+		if err := m.store.DeleteSession(h); err != nil {
+			return err
+		}
+	}
+	delete(m.sessions, h)
+	if !m.ephemeral[s.PlayerID] {
+		return nil
+	}
+	for _, other := range m.sessions {
+		if other.PlayerID == s.PlayerID {
+			return nil
+		}
+	}
+	delete(m.accounts, s.PlayerID)
+	delete(m.ephemeral, s.PlayerID)
+	return nil
+}
+
+// newAccount makes an unsaved account with a fresh player id. Callers hold m.mu.
+func (m *Manager) newAccount(displayName string) (Account, error) {
+	for {
+		suffix, err := randomHex(8)
+		if err != nil {
+			return Account{}, err
+		}
+		id := "player_" + suffix
+		if _, taken := m.accounts[id]; taken {
+			continue
+		}
+		name := cleanDisplayName(displayName)
+		if name == "" {
+			name = "pilot"
+		}
+		return Account{PlayerID: id, DisplayName: name, CreatedAt: time.Now().UTC()}, nil
+	}
+}
+
+// saveWithDevice binds device to a, moving it off any account it signed in to before, and
+// saves every account that changed. Callers hold m.mu.
+func (m *Manager) saveWithDevice(a Account, device string) error {
+	if device == "" {
+		return m.save(a)
+	}
+	if id, ok := m.byDevice[device]; ok && id != a.PlayerID {
+		prev := m.accounts[id]
+		prev.Devices = slices.DeleteFunc(slices.Clone(prev.Devices), func(d string) bool { return d == device })
+		if err := m.save(prev); err != nil {
+			return err
+		}
+	}
+	a.Devices = append(slices.Clone(a.Devices), device)
+	return m.save(a)
+}
+
+// save writes a through to the store and, once it is durable, indexes it. Callers hold
+// m.mu.
+func (m *Manager) save(a Account) error {
+	if err := m.store.PutAccount(a); err != nil {
+		return err
+	}
+	if prev, ok := m.accounts[a.PlayerID]; ok {
+		for _, d := range prev.Devices {
+			delete(m.byDevice, d)
+		}
+	}
+	m.index(a)
+	return nil
+}
+
+func (m *Manager) index(a Account) {
+	m.accounts[a.PlayerID] = a
+	if a.Username != "" {
+		m.byUsername[a.Username] = a.PlayerID
+	}
+	for _, d := range a.Devices {
+		m.byDevice[d] = a.PlayerID
+	}
+}
+
+// prepareCredentials validates and normalizes new credentials and hashes the password.
+// The slow hash runs before the caller takes its lock.
+func prepareCredentials(username, password, deviceID string) (string, *Credential, string, error) {
+	username = normalizeUsername(username)
+	if !validUsername(username) {
+		return "", nil, "", ErrBadUsername
+	}
+	if len(password) < MinPasswordLength {
+		return "", nil, "", ErrWeakPassword
+	}
+	var device string
+	if deviceID != "" {
+		var err error
+		if device, err = deviceHash(deviceID); err != nil {
+			return "", nil, "", err
+		}
+	}
+	cred, err := hashPassword(password)
+	if err != nil {
+		return "", nil, "", err
+	}
+	return username, cred, device, nil
+}
+
+func normalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+func validUsername(username string) bool {
+	if len(username) < 3 || len(username) > 20 {
+		return false
+	}
+	for _, r := range username {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+func cleanDisplayName(name string) string {
+	name = strings.TrimSpace(name)
+	if r := []rune(name); len(r) > MaxDisplayNameLength {
+		name = string(r[:MaxDisplayNameLength])
+	}
+	return name
+}
+
+// deviceHash validates a device id and returns the hash accounts are bound by.
+func deviceHash(deviceID string) (string, error) {
+	if len(deviceID) < 8 || len(deviceID) > 128 {
+		return "", ErrBadDevice
+	}
+	return tokenHash("device:" + deviceID), nil
+}
+
+func hashPassword(password string) (*Credential, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	hash, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, sha256.Size)
+	if err != nil {
+		return nil, err
+	}
+	return &Credential{Salt: salt, Iterations: passwordIterations, Hash: hash}, nil
+}
+
+func (c *Credential) matches(password string) bool {
+	hash, err := pbkdf2.Key(sha256.New, password, c.Salt, c.Iterations, sha256.Size)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(hash, c.Hash) == 1
+}
+
+func tokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomToken(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func randomHex(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,167 @@
+package account
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDeviceLoginKeepsPlayerIDAndUpgradeKeepsIt(t *testing.T) {
+	m, err := NewManager(NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	guest, created, err := m.Device("device-0001", "Rookie")
+	if err != nil || !created || !guest.Guest() {
+		t.Fatalf("expected a new guest: %+v created=%v err=%v", guest, created, err)
+	}
+	again, created, err := m.Device("device-0001", "")
+	if err != nil || created || again.PlayerID != guest.PlayerID || again.DisplayName != "Rookie" {
+		t.Fatalf("expected the same guest back: %+v created=%v err=%v", again, created, err)
+	}
+
+	full, err := m.Upgrade(guest.PlayerID, "  Rookie_1 ", "hunter22!", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if full.PlayerID != guest.PlayerID || full.Guest() || full.Username != "rookie_1" || !full.CreatedAt.Equal(guest.CreatedAt) {
+		t.Fatalf("expected the upgrade to keep the identity: %+v", full)
+	}
+	if _, err := m.Upgrade(guest.PlayerID, "other", "hunter22!", ""); !errors.Is(err, ErrNotGuest) {
+		t.Fatalf("expected a second upgrade to fail, got %v", err)
+	}
+
+	if _, err := m.Login("rookie_1", "wrong-password", ""); !errors.Is(err, ErrBadCredentials) {
+		t.Fatalf("expected a wrong password to fail, got %v", err)
+	}
+	viaPassword, err := m.Login("ROOKIE_1", "hunter22!", "")
+	if err != nil || viaPassword.PlayerID != guest.PlayerID {
+		t.Fatalf("expected password login to reach the upgraded account: %+v err=%v", viaPassword, err)
+	}
+	viaDevice, _, err := m.Device("device-0001", "")
+	if err != nil || viaDevice.PlayerID != guest.PlayerID || viaDevice.Guest() {
+		t.Fatalf("expected the device to stay bound after the upgrade: %+v err=%v", viaDevice, err)
+	}
+}
+
+func TestRegisterValidatesAndRejectsTakenUsernames(t *testing.T) {
+	m, _ := NewManager(NewMemoryStore())
+	if _, err := m.Register("no spaces", "hunter22!", "", ""); !errors.Is(err, ErrBadUsername) {
+		t.Fatalf("expected a bad username, got %v", err)
+	}
+	if _, err := m.Register("ace", "short", "", ""); !errors.Is(err, ErrWeakPassword) {
+		t.Fatalf("expected a weak password, got %v", err)
+	}
+	if _, err := m.Register("ace", "hunter22!", "Ace", "dev"); !errors.Is(err, ErrBadDevice) {
+		t.Fatalf("expected a bad device id, got %v", err)
+	}
+	ace, err := m.Register("ace", "hunter22!", "Ace", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Register("ACE", "hunter22!", "", ""); !errors.Is(err, ErrUsernameTaken) {
+		t.Fatalf("expected the username to be taken, got %v", err)
+	}
+	guest, _ := m.Guest("")
+	if _, err := m.Upgrade(guest.PlayerID, "ace", "hunter22!", ""); !errors.Is(err, ErrUsernameTaken) {
+		t.Fatalf("expected the upgrade to hit the taken username, got %v", err)
+	}
+	if guest.PlayerID == ace.PlayerID || guest.DisplayName != "pilot" {
+		t.Fatalf("unexpected guest: %+v", guest)
+	}
+}
+
+func TestFileStoreKeepsAccountsAndSessionsAcrossRestart(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, _ := NewManager(store)
+	a, _, err := m.Device("device-0002", "Keeper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := m.StartSession(a.PlayerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ended, _, _ := m.StartSession(a.PlayerID)
+	if err := m.EndSession(ended); err != nil {
+		t.Fatal(err)
+	}
+	// Simulate a crash: leave the log unsnapshotted and reopen.
+	store.wal.Close()
+
+	reopened, err := OpenFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	m2, err := NewManager(reopened)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, session, ok := m2.Authenticate(token)
+	if !ok || got.PlayerID != a.PlayerID || got.DisplayName != "Keeper" || session.PlayerID != a.PlayerID {
+		t.Fatalf("expected the session to survive a restart: %+v ok=%v", got, ok)
+	}
+	if _, _, ok := m2.Authenticate(ended); ok {
+		t.Fatal("expected the ended session to stay signed out")
+	}
+	again, created, _ := m2.Device("device-0002", "")
+	if created || again.PlayerID != a.PlayerID {
+		t.Fatalf("expected the device to sign back in to the same account: %+v", again)
+	}
+	if n := m2.Expire(time.Now().Add(SessionTTL + time.Minute)); n != 1 {
+		t.Fatalf("expected the live session to expire, dropped=%d", n)
+	}
+}
+
+func TestDevicelessGuestLastsOnlyAsLongAsItsSession(t *testing.T) {
+	store := NewMemoryStore()
+	m, _ := NewManager(store)
+	guest, err := m.Guest("Drifter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := m.StartSession(guest.PlayerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accounts, sessions, _ := store.Load(); len(accounts) != 0 || len(sessions) != 0 {
+		t.Fatalf("expected nothing persisted for a device-less guest: %d accounts %d sessions", len(accounts), len(sessions))
+	}
+	if got, _, ok := m.Authenticate(token); !ok || got.PlayerID != guest.PlayerID {
+		t.Fatalf("expected the session to sign the guest in: %+v ok=%v", got, ok)
+	}
+	if err := m.EndSession(token); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Get(guest.PlayerID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected the guest to be forgotten with its session, got %v", err)
+	}
+
+	expiring, _ := m.Guest("")
+	m.StartSession(expiring.PlayerID)
+	if n := m.Expire(time.Now().Add(SessionTTL + time.Minute)); n != 1 {
+		t.Fatalf("expected the guest's session to expire, dropped=%d", n)
+	}
+	if total, _ := m.Count(); total != 0 {
+		t.Fatalf("expected expired guests to be forgotten, total=%d", total)
+	}
+
+	kept, _ := m.Guest("Keeper")
+	keptToken, _, _ := m.StartSession(kept.PlayerID)
+	if _, err := m.Upgrade(kept.PlayerID, "keeper", "hunter22!", ""); err != nil {
+		t.Fatal(err)
+	}
+	accounts, sessions, _ := store.Load()
+	if len(accounts) != 1 || accounts[0].PlayerID != kept.PlayerID || len(sessions) != 1 {
+		t.Fatalf("expected the upgrade to persist the account and its session: %+v %+v", accounts, sessions)
+	}
+	m.EndSession(keptToken)
+	if _, err := m.Get(kept.PlayerID); err != nil {
+		t.Fatalf("expected the upgraded account to outlive its session: %v", err)
+	}
+}
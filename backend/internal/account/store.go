@@ -0,0 +1,65 @@
+package account
+
+import (
+	"maps"
+	"slices"
+	"sync"
+)
+
+// Store persists accounts and sign-in sessions so players keep their identity across
+// gateway restarts. Manager writes through on every change while holding its lock, so
+// implementations must not call back into it.
+type Store interface {
+	// Load returns every saved account and session.
+	Load() ([]Account, []Session, error)
+	// PutAccount saves a, replacing any earlier record for the same player.
+	PutAccount(a Account) error
+	// PutSession saves s, replacing any earlier record for the same token.
+	PutSession(s Session) error
+	// DeleteSession forgets a session; deleting an unknown session is not an error.
+	DeleteSession(tokenHash string) error
+	Close() error
+}
+
+// MemoryStore keeps records in process. It is the default store, so accounts last only
+// as long as the gateway does.
+type MemoryStore struct {
+	mu       sync.Mutex
+	accounts map[string]Account
+	sessions map[string]Session
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{accounts: make(map[string]Account), sessions: make(map[string]Session)}
+}
+
+func (s *MemoryStore) Load() ([]Account, []Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Collect(maps.Values(s.accounts)), slices.Collect(maps.Values(s.sessions)), nil
+}
+
+func (s *MemoryStore) PutAccount(a Account) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accounts[a.PlayerID] = a
+	return nil
+}
+
+func (s *MemoryStore) PutSession(sess Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[sess.TokenHash] = sess
+	return nil
+}
+
+func (s *MemoryStore) DeleteSession(tokenHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, tokenHash)
+	return nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,126 @@
+package clocksync
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"projectvelocity/backend/internal/shared/types"
+)
+
+const (
+	// rttGain and varGain follow the classic TCP SRTT/RTTVAR smoothing constants.
+	rttGain = 1.0 / 8.0
+	varGain = 1.0 / 4.0
+	// offsetGain is applied to offset samples taken from non-outlier exchanges.
+	offsetGain = 1.0 / 8.0
+
+	// FutureToleranceMS is how far ahead of server time a client timestamp may land.
+	FutureToleranceMS = 50
+	// MaxInputAgeMS bounds how stale a client input may be beyond one-way latency.
+	MaxInputAgeMS = 500
+)
+
+// Sample is one completed NTP-style exchange expressed in milliseconds.
+type Sample struct {
+	RTTMS    float64
+	OffsetMS float64
+}
+
+// SampleFrom derives round-trip time and clock offset (server minus client) from
+// the four exchange timestamps. It reports false for inconsistent timestamps.
+func SampleFrom(s types.ClockSync) (Sample, bool) {
+	if s.ClientSendMS <= 0 || s.ClientRecvMS < s.ClientSendMS || s.ServerSendMS < s.ServerRecvMS {
+		return Sample{}, false
+	}
+	rtt := float64((s.ClientRecvMS - s.ClientSendMS) - (s.ServerSendMS - s.ServerRecvMS))
+	if rtt < 0 {
+		return Sample{}, false
+	}
+	offset := float64((s.ServerRecvMS-s.ClientSendMS)+(s.ServerSendMS-s.ClientRecvMS)) / 2
+	return Sample{RTTMS: rtt, OffsetMS: offset}, true
+}
+
+// Estimator keeps smoothed RTT and clock offset estimates for one client.
+type Estimator struct {
+	mu      sync.RWMutex
+	samples int
+	srtt    float64
+	rttvar  float64
+	offset  float64
+}
+
+// NewEstimator returns an estimator with no samples.
+func NewEstimator() *Estimator {
+	return &Estimator{}
+}
+
+// Add folds a sample into the estimates. Offset is only updated from samples whose
+// RTT is not an outlier, since asymmetric delay on slow exchanges skews the offset.
+func (e *Estimator) Add(s Sample) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.samples == 0 {
+		e.srtt = s.RTTMS
+		e.rttvar = s.RTTMS / 2
+		e.offset = s.OffsetMS
+		e.samples = 1
+		return
+	}
+
+	outlier := s.RTTMS > e.srtt+4*e.rttvar
+	e.rttvar += varGain * (math.Abs(e.srtt-s.RTTMS) - e.rttvar)
+	e.srtt += rttGain * (s.RTTMS - e.srtt)
+	if !outlier {
+		e.offset += offsetGain * (s.OffsetMS - e.offset)
+	}
+	e.samples++
+}
+
+// Ready reports whether at least one sample has been recorded.
+func (e *Estimator) Ready() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.samples > 0
+}
+
+// RTT returns the smoothed round-trip time.
+func (e *Estimator) RTT() time.Duration {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return time.Duration(e.srtt * float64(time.Millisecond))
+}
+
+// OffsetMS returns the smoothed server-minus-client clock offset.
+func (e *Estimator) OffsetMS() int64 {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return int64(math.Round(e.offset))
+}
+
+// InputAgeMS returns how long ago, in server time, a client stamped an input and
+// whether that age is plausible given the current estimates. Without samples every
+// timestamp is accepted with an age of zero.
+func (e *Estimator) InputAgeMS(clientMS, serverNowMS int64) (int64, bool) {
+	e.mu.RLock()
+	ready := e.samples > 0
+	offset := int64(math.Round(e.offset))
+	oneWay := int64(e.srtt / 2)
+	e.mu.RUnlock()
+
+	if !ready || clientMS <= 0 {
+		return 0, true
+	}
+	age := serverNowMS - (clientMS + offset)
+	if age < -FutureToleranceMS {
+		return age, false
+	}
+	if age > oneWay+MaxInputAgeMS {
+		return age, false
+	}
+	if age < 0 {
+		age = 0
+	}
+	return age, true
+}
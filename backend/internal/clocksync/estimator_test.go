@@ -0,0 +1,68 @@
+package clocksync
+
+import (
+	"testing"
+
+	"projectvelocity/backend/internal/shared/types"
+)
+
+func TestSampleFromSymmetricExchange(t *testing.T) {
+	// Client clock runs 1000ms behind the server; 20ms each way, 2ms processing.
+	s, ok := SampleFrom(types.ClockSync{
+		ClientSendMS: 10_000,
+		ServerRecvMS: 11_020,
+		ServerSendMS: 11_022,
+		ClientRecvMS: 10_042,
+	})
+	if !ok {
+		t.Fatal("expected valid sample")
+	}
+	if s.RTTMS != 40 {
+		t.Fatalf("expected rtt=40, got=%f", s.RTTMS)
+	}
+	if s.OffsetMS != 1000 {
+		t.Fatalf("expected offset=1000, got=%f", s.OffsetMS)
+	}
+}
+
+func TestSampleFromRejectsInconsistentTimestamps(t *testing.T) {
+	if _, ok := SampleFrom(types.ClockSync{ClientSendMS: 100, ClientRecvMS: 90, ServerRecvMS: 5, ServerSendMS: 6}); ok {
+		t.Fatal("expected client receive before send to be rejected")
+	}
+	if _, ok := SampleFrom(types.ClockSync{ClientSendMS: 100, ClientRecvMS: 110, ServerRecvMS: 5, ServerSendMS: 50}); ok {
+		t.Fatal("expected server hold longer than round trip to be rejected")
+	}
+}
+
+func TestEstimatorSmoothsAndIgnoresOutlierOffsets(t *testing.T) {
+	e := NewEstimator()
+	for range 20 {
+		e.Add(Sample{RTTMS: 40, OffsetMS: 1000})
+	}
+	e.Add(Sample{RTTMS: 900, OffsetMS: 1400})
+	if got := e.OffsetMS(); got != 1000 {
+		t.Fatalf("expected outlier offset ignored, got=%d", got)
+	}
+	if rtt := e.RTT().Milliseconds(); rtt <= 40 || rtt >= 900 {
+		t.Fatalf("expected smoothed rtt between samples, got=%d", rtt)
+	}
+}
+
+func TestInputAgePlausibility(t *testing.T) {
+	e := NewEstimator()
+	if _, ok := e.InputAgeMS(1, 999_999); !ok {
+		t.Fatal("expected all timestamps accepted before first sample")
+	}
+	e.Add(Sample{RTTMS: 40, OffsetMS: 1000})
+
+	now := int64(50_000)
+	if age, ok := e.InputAgeMS(now-1000-20, now); !ok || age != 20 {
+		t.Fatalf("expected 20ms plausible age, got=%d ok=%v", age, ok)
+	}
+	if _, ok := e.InputAgeMS(now, now); ok {
+		t.Fatal("expected input stamped in the server future to be rejected")
+	}
+	if _, ok := e.InputAgeMS(now-1000-5000, now); ok {
+		t.Fatal("expected very stale input to be rejected")
+	}
+}
@@ -0,0 +1,223 @@
+package cluster
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	snapshotFile = "cluster.snapshot.json"
+	walFile      = "cluster.wal"
+	// DefaultCompactEvery is how many log entries FileStore appends before it folds them
+	// into a fresh snapshot.
+	DefaultCompactEvery = 1000
+)
+
+var ErrStoreClosed = errors.New("cluster: store closed")
+
+// FileStore is a MemoryStore that keeps the values written with Write on disk, so the
+// process hosting the shared store can restart without losing the tickets and primary
+// state kept there. Writes are appended to a log and synced before they apply, and the
+// log is periodically compacted into a snapshot. Leases and Set values are not kept:
+// members renew their leases within a heartbeat, and lost locations are written again
+// when buckets are reclaimed.
+type FileStore struct {
+	*MemoryStore
+
+	fileMu       sync.Mutex
+	dir          string
+	wal          *os.File
+	logged       int
+	compactEvery int
+}
+
+// OpenFileStore opens or creates a store in dir. Its files do not clash with the other
+// stores', so it can share the matchmaker's data directory.
+func OpenFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	s := &FileStore{MemoryStore: NewMemoryStore(), dir: dir, compactEvery: DefaultCompactEvery}
+	if err := s.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	torn, err := s.replay()
+	if err != nil {
+		return nil, err
+	}
+	wal, err := os.OpenFile(filepath.Join(dir, walFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	s.wal = wal
+	if torn {
+		// Appending after a torn line would hide the new entries from the next replay.
+		if err := s.compact(); err != nil {
+			wal.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// SetCompactEvery changes how many log entries trigger a snapshot.
+func (s *FileStore) SetCompactEvery(n int) {
+	s.fileMu.Lock()
+	defer s.fileMu.Unlock()
+	s.compactEvery = n
+}
+
+func (s *FileStore) Write(records []Record) error {
+	s.fileMu.Lock()
+	defer s.fileMu.Unlock()
+	// The values change only once the log has them, so a failed write can be retried.
+	if err := s.append(records); err != nil {
+		return err
+	}
+	if err := s.MemoryStore.Write(records); err != nil {
+		return err
+	}
+	if s.compactEvery > 0 && s.logged >= s.compactEvery {
+		return s.compact()
+	}
+	return nil
+}
+
+// Close writes a final snapshot so the next open has no log to replay.
+func (s *FileStore) Close() error {
+	s.fileMu.Lock()
+	defer s.fileMu.Unlock()
+	if s.wal == nil {
+		return nil
+	}
+	err := s.compact()
+	if cerr := s.wal.Close(); err == nil {
+		err = cerr
+	}
+	s.wal = nil
+	return err
+}
+
+// append logs records and syncs them to disk together. Callers hold s.fileMu.
+func (s *FileStore) append(records []Record) error {
+	if s.wal == nil {
+		return ErrStoreClosed
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	var buf []byte
+	for _, r := range records {
+		line, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+	info, err := s.wal.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := s.wal.Write(buf); err != nil {
+		// Cut off a partial batch so entries appended on retry stay replayable.
+		_ = s.wal.Truncate(info.Size())
+		return err
+	}
+	if err := s.wal.Sync(); err != nil {
+		return err
+	}
+	s.logged += len(records)
+	return nil
+}
+
+// compact writes every durable value to a new snapshot, swaps it in atomically and
+// empties the log. Callers hold s.fileMu.
+func (s *FileStore) compact() error {
+	s.MemoryStore.mu.Lock()
+	var records []Record
+	for key, l := range s.MemoryStore.entries {
+		if l.ExpiresAt.IsZero() {
+			records = append(records, Record{Key: key, Value: l.Value})
+		}
+	}
+	s.MemoryStore.mu.Unlock()
+	body, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(s.dir, snapshotFile+".tmp")
+	if err := writeSynced(tmp, body); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, snapshotFile)); err != nil {
+		return err
+	}
+	if err := s.wal.Truncate(0); err != nil {
+		return err
+	}
+	s.logged = 0
+	return nil
+}
+
+func (s *FileStore) loadSnapshot() error {
+	body, err := os.ReadFile(filepath.Join(s.dir, snapshotFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var records []Record
+	if err := json.Unmarshal(body, &records); err != nil {
+		return fmt.Errorf("cluster: corrupt snapshot: %w", err)
+	}
+	s.MemoryStore.applyLocked(records)
+	return nil
+}
+
+// replay applies the log on top of the snapshot and reports whether it ended in a torn
+// line.
+func (s *FileStore) replay() (bool, error) {
+	f, err := os.Open(filepath.Join(s.dir, walFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			// Only the last write can be torn; everything before it was synced whole.
+			return true, nil
+		}
+		s.MemoryStore.applyLocked([]Record{r})
+		s.logged++
+	}
+	return false, scanner.Err()
+}
+
+func writeSynced(path string, body []byte) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(body); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
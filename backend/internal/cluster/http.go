@@ -0,0 +1,244 @@
+package cluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"projectvelocity/backend/internal/shared/signing"
+)
+
+// maxRequestBytes bounds a store request body; a batch of ticket writes is the largest.
+const maxRequestBytes = 4 << 20
+
+type storeRequest struct {
+	Key   string `json:"key"`
+	Owner string `json:"owner,omitempty"`
+	Value string `json:"value,omitempty"`
+	TTLMS int64  `json:"ttl_ms,omitempty"`
+}
+
+// Handler serves store to HTTPStore clients under prefix, letting one process host the
+// shared store for a small cluster. Every request must be signed with key: the body of
+// a POST, or the raw query of a GET.
+func Handler(prefix string, store SharedStore, key []byte) http.Handler {
+	mux := http.NewServeMux()
+	read := func(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+			return nil, false
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBytes))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "bad_request"})
+			return nil, false
+		}
+		if err := signing.Verify(key, body, r.Header.Get(signing.Header)); err != nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "bad_signature"})
+			return nil, false
+		}
+		return body, true
+	}
+	decode := func(w http.ResponseWriter, r *http.Request) (storeRequest, bool) {
+		var req storeRequest
+		body, ok := read(w, r)
+		if !ok {
+			return req, false
+		}
+		if err := json.Unmarshal(body, &req); err != nil || req.Key == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "bad_request"})
+			return req, false
+		}
+		return req, true
+	}
+	query := func(w http.ResponseWriter, r *http.Request) (url.Values, bool) {
+		if err := signing.Verify(key, []byte(r.URL.RawQuery), r.Header.Get(signing.Header)); err != nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "bad_signature"})
+			return nil, false
+		}
+		return r.URL.Query(), true
+	}
+	mux.HandleFunc(prefix+"/acquire", func(w http.ResponseWriter, r *http.Request) {
+		req, ok := decode(w, r)
+		if !ok {
+			return
+		}
+		l, err := store.Acquire(req.Key, req.Owner, req.Value, time.Duration(req.TTLMS)*time.Millisecond)
+		switch err {
+		case nil:
+			writeJSON(w, http.StatusOK, l)
+		case ErrLeaseHeld:
+			writeJSON(w, http.StatusConflict, l)
+		default:
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+	})
+	mux.HandleFunc(prefix+"/release", func(w http.ResponseWriter, r *http.Request) {
+		if req, ok := decode(w, r); ok {
+			_ = store.Release(req.Key, req.Owner)
+			writeJSON(w, http.StatusOK, map[string]string{"status": "released"})
+		}
+	})
+	mux.HandleFunc(prefix+"/set", func(w http.ResponseWriter, r *http.Request) {
+		if req, ok := decode(w, r); ok {
+			_ = store.Set(req.Key, req.Value, time.Duration(req.TTLMS)*time.Millisecond)
+			writeJSON(w, http.StatusOK, map[string]string{"status": "set"})
+		}
+	})
+	mux.HandleFunc(prefix+"/write", func(w http.ResponseWriter, r *http.Request) {
+		raw, ok := read(w, r)
+		if !ok {
+			return
+		}
+		var body struct {
+			Records []Record `json:"records"`
+		}
+		if err := json.Unmarshal(raw, &body); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "bad_request"})
+			return
+		}
+		if err := store.Write(body.Records); err != nil {
+			// The records are not durable, so the writer must retry them.
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "write_failed"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]int{"written": len(body.Records)})
+	})
+	mux.HandleFunc(prefix+"/get", func(w http.ResponseWriter, r *http.Request) {
+		q, ok := query(w, r)
+		if !ok {
+			return
+		}
+		l, ok, _ := store.Get(q.Get("key"))
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "not_found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, l)
+	})
+	mux.HandleFunc(prefix+"/list", func(w http.ResponseWriter, r *http.Request) {
+		q, ok := query(w, r)
+		if !ok {
+			return
+		}
+		leases, _ := store.List(q.Get("prefix"))
+		writeJSON(w, http.StatusOK, map[string]interface{}{"leases": leases})
+	})
+	return mux
+}
+
+// HTTPStore is a SharedStore client for a store served by Handler.
+type HTTPStore struct {
+	base   string
+	key    []byte
+	client *http.Client
+}
+
+// NewHTTPStore talks to the Handler mounted at base, e.g.
+// "http://matchmaker-1:9001/v1/cluster/store", signing its requests with key.
+func NewHTTPStore(base string, key []byte, client *http.Client) *HTTPStore {
+	if client == nil {
+		client = &http.Client{Timeout: 2 * time.Second}
+	}
+	return &HTTPStore{base: base, key: key, client: client}
+}
+
+func (s *HTTPStore) Acquire(key, owner, value string, ttl time.Duration) (Lease, error) {
+	var l Lease
+	code, err := s.post("/acquire", storeRequest{Key: key, Owner: owner, Value: value, TTLMS: ttl.Milliseconds()}, &l)
+	if err != nil {
+		return Lease{}, err
+	}
+	if code == http.StatusConflict {
+		return l, ErrLeaseHeld
+	}
+	return l, nil
+}
+
+func (s *HTTPStore) Release(key, owner string) error {
+	_, err := s.post("/release", storeRequest{Key: key, Owner: owner}, nil)
+	return err
+}
+
+func (s *HTTPStore) Set(key, value string, ttl time.Duration) error {
+	_, err := s.post("/set", storeRequest{Key: key, Value: value, TTLMS: ttl.Milliseconds()}, nil)
+	return err
+}
+
+func (s *HTTPStore) Get(key string) (Lease, bool, error) {
+	var l Lease
+	code, err := s.get("/get", url.Values{"key": {key}}, &l)
+	if err != nil || code == http.StatusNotFound {
+		return Lease{}, false, err
+	}
+	return l, true, nil
+}
+
+func (s *HTTPStore) List(prefix string) ([]Lease, error) {
+	var out struct {
+		Leases []Lease `json:"leases"`
+	}
+	_, err := s.get("/list", url.Values{"prefix": {prefix}}, &out)
+	return out.Leases, err
+}
+
+func (s *HTTPStore) Write(records []Record) error {
+	_, err := s.post("/write", map[string][]Record{"records": records}, nil)
+	return err
+}
+
+func (s *HTTPStore) post(path string, body interface{}, out interface{}) (int, error) {
+	buf, _ := json.Marshal(body)
+	req, err := http.NewRequest(http.MethodPost, s.base+path, bytes.NewReader(buf))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(signing.Header, signing.Sign(s.key, buf))
+	return s.do(req, out)
+}
+
+func (s *HTTPStore) get(path string, query url.Values, out interface{}) (int, error) {
+	raw := query.Encode()
+	req, err := http.NewRequest(http.MethodGet, s.base+path+"?"+raw, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set(signing.Header, signing.Sign(s.key, []byte(raw)))
+	return s.do(req, out)
+}
+
+func (s *HTTPStore) do(req *http.Request, out interface{}) (int, error) {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	return s.read(resp, out)
+}
+
+func (s *HTTPStore) read(resp *http.Response, out interface{}) (int, error) {
+	switch {
+	case resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusConflict:
+		if out != nil {
+			if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+				return resp.StatusCode, err
+			}
+		}
+		return resp.StatusCode, nil
+	case resp.StatusCode == http.StatusNotFound:
+		return resp.StatusCode, nil
+	default:
+		return resp.StatusCode, fmt.Errorf("cluster: store returned %d", resp.StatusCode)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
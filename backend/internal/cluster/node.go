@@ -0,0 +1,436 @@
+package cluster
+
+import (
+	"context"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	memberPrefix = "member/"
+	bucketPrefix = "bucket/"
+	// knownPrefix marks every bucket that has ever been claimed, so an orphaned one is
+	// found and reclaimed even when nobody asks for it.
+	knownPrefix  = "buckets/"
+	ticketPrefix = "ticket/"
+	matchPrefix  = "match/"
+	primaryKey   = "primary"
+	// TicketLocationTTL is how long the store remembers which instance holds a ticket,
+	// or formed a match.
+	TicketLocationTTL = 30 * time.Minute
+)
+
+// Member is one matchmaker instance.
+type Member struct {
+	ID string `json:"id"`
+	// Addr is the base URL other instances forward requests to.
+	Addr string `json:"addr"`
+}
+
+// HandoverFunc moves a bucket's state to its new owner. An error keeps the bucket
+// where it is until the next heartbeat.
+type HandoverFunc func(bucket string, to Member) error
+
+// PromoteFunc loads the cluster-wide state when this instance becomes the primary. An
+// error gives the role back, so it is taken again later.
+type PromoteFunc func() error
+
+// ClaimFunc loads a bucket's state when this instance takes the bucket over. An error
+// gives the lease back, so the bucket is claimed again later.
+type ClaimFunc func(bucket string) error
+
+// Node is this instance's view of the cluster. Buckets are placed by rendezvous hashing
+// over live members and claimed lazily: the preferred member takes the lease the first
+// time it is asked to serve the bucket.
+//
+// A bucket's owner serves its tickets from memory and saves them to the shared store.
+// They move on a graceful handover; when the owner crashes, the preferred live member
+// claims the bucket at its first heartbeat after the lease lapses and loads the saved
+// tickets through OnClaim.
+type Node struct {
+	store SharedStore
+	self  Member
+	ttl   time.Duration
+
+	// claimMu keeps two requests from loading the same bucket, or the primary's
+	// state, at once.
+	claimMu   sync.Mutex
+	mu        sync.Mutex
+	owned     map[string]bool
+	primary   bool
+	handover  HandoverFunc
+	onClaim   ClaimFunc
+	onPromote PromoteFunc
+	placement func(bucket string) string
+}
+
+// NewNode creates a node for self. Leases last ttl and must be renewed by Heartbeat
+// well within it.
+func NewNode(store SharedStore, self Member, ttl time.Duration) *Node {
+	return &Node{
+		store:     store,
+		self:      self,
+		ttl:       ttl,
+		owned:     make(map[string]bool),
+		placement: func(bucket string) string { return bucket },
+	}
+}
+
+// Self returns this instance.
+func (n *Node) Self() Member {
+	return n.self
+}
+
+// OnHandover sets how buckets move when ownership changes.
+func (n *Node) OnHandover(fn HandoverFunc) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.handover = fn
+}
+
+// OnClaim sets how a bucket's state is loaded when this instance takes it over.
+func (n *Node) OnClaim(fn ClaimFunc) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.onClaim = fn
+}
+
+// OnPromote sets how the cluster-wide state is loaded when this instance becomes the
+// primary.
+func (n *Node) OnPromote(fn PromoteFunc) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.onPromote = fn
+}
+
+// IsPrimary reports whether this instance holds the primary role and has loaded its
+// state.
+func (n *Node) IsPrimary() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.primary
+}
+
+// SetPlacement makes buckets with the same placement key hash to the same member, so
+// related buckets stay together.
+func (n *Node) SetPlacement(fn func(bucket string) string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.placement = fn
+}
+
+// Join announces this instance to the cluster.
+func (n *Node) Join() error {
+	_, err := n.store.Acquire(memberPrefix+n.self.ID, n.self.ID, n.self.Addr, n.ttl)
+	return err
+}
+
+// Members returns every live instance ordered by ID.
+func (n *Node) Members() ([]Member, error) {
+	leases, err := n.store.List(memberPrefix)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Member, 0, len(leases))
+	for _, l := range leases {
+		out = append(out, Member{ID: l.Owner, Addr: l.Value})
+	}
+	return out, nil
+}
+
+// Owned returns the buckets this instance currently holds.
+func (n *Node) Owned() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	out := make([]string, 0, len(n.owned))
+	for b := range n.owned {
+		out = append(out, b)
+	}
+	return out
+}
+
+// Owner returns the instance that serves bucket, taking the lease when this instance
+// is the preferred owner and nobody holds it.
+func (n *Node) Owner(bucket string) (Member, error) {
+	if l, ok, err := n.store.Get(bucketPrefix + bucket); err != nil {
+		return Member{}, err
+	} else if ok {
+		if l.Owner == n.self.ID {
+			return n.self, nil
+		}
+		if m, ok, err := n.member(l.Owner); err != nil || ok {
+			return m, err
+		}
+	}
+	members, err := n.Members()
+	if err != nil {
+		return Member{}, err
+	}
+	pick, ok := n.preferred(bucket, members, "")
+	if !ok || pick.ID == n.self.ID {
+		return n.claim(bucket)
+	}
+	return pick, nil
+}
+
+// Primary returns the instance that holds the cluster-wide state: results, ratings,
+// conduct, the game server fleet, lobbies and tournaments. The first instance to ask
+// takes the role and keeps it through Heartbeat. If it dies another takes over once its
+// lease runs out, loading the state the old primary saved through OnPromote.
+func (n *Node) Primary() (Member, error) {
+	l, ok, err := n.store.Get(primaryKey)
+	if err != nil {
+		return Member{}, err
+	}
+	if !ok || l.Owner == n.self.ID {
+		if l, err = n.promote(); err == nil {
+			return n.self, nil
+		}
+		if err != ErrLeaseHeld {
+			return Member{}, err
+		}
+	}
+	if m, ok, err := n.member(l.Owner); err != nil || ok {
+		return m, err
+	}
+	// The primary died with its lease still live; wait for the lease to run out.
+	return Member{}, ErrOwnerUnavailable
+}
+
+// promote takes or renews the primary lease, loading the primary's state the first
+// time.
+func (n *Node) promote() (Lease, error) {
+	n.claimMu.Lock()
+	defer n.claimMu.Unlock()
+	l, err := n.store.Acquire(primaryKey, n.self.ID, n.self.Addr, n.ttl)
+	if err != nil {
+		return l, err
+	}
+	n.mu.Lock()
+	primary, onPromote := n.primary, n.onPromote
+	n.mu.Unlock()
+	if !primary && onPromote != nil {
+		if err := onPromote(); err != nil {
+			_ = n.store.Release(primaryKey, n.self.ID)
+			return Lease{}, err
+		}
+	}
+	n.mu.Lock()
+	n.primary = true
+	n.mu.Unlock()
+	return l, nil
+}
+
+// claim takes bucket's lease and loads its state, or reports whoever beat us to it.
+func (n *Node) claim(bucket string) (Member, error) {
+	n.claimMu.Lock()
+	defer n.claimMu.Unlock()
+	l, err := n.store.Acquire(bucketPrefix+bucket, n.self.ID, "", n.ttl)
+	if err == ErrLeaseHeld {
+		if m, ok, err := n.member(l.Owner); err != nil || ok {
+			return m, err
+		}
+		// The holder died with its lease still live; wait for the lease to run out.
+		return Member{}, ErrOwnerUnavailable
+	}
+	if err != nil {
+		return Member{}, err
+	}
+	n.mu.Lock()
+	owned, onClaim := n.owned[bucket], n.onClaim
+	n.mu.Unlock()
+	if !owned {
+		err := n.store.Write([]Record{{Key: knownPrefix + bucket, Value: bucket}})
+		if err == nil && onClaim != nil {
+			err = onClaim(bucket)
+		}
+		if err != nil {
+			_ = n.store.Release(bucketPrefix+bucket, n.self.ID)
+			return Member{}, err
+		}
+	}
+	n.mu.Lock()
+	n.owned[bucket] = true
+	n.mu.Unlock()
+	return n.self, nil
+}
+
+// Heartbeat renews this instance's membership and bucket leases. Buckets that now
+// hash to another live member are handed over and released, and buckets whose owner
+// died are claimed by their preferred member. The primary role is taken when nobody
+// holds it, so the cluster-wide state comes back without waiting for a request.
+func (n *Node) Heartbeat() error {
+	if err := n.Join(); err != nil {
+		return err
+	}
+	if n.IsPrimary() {
+		if _, err := n.store.Acquire(primaryKey, n.self.ID, n.self.Addr, n.ttl); err != nil {
+			n.mu.Lock()
+			n.primary = false
+			n.mu.Unlock()
+		}
+	} else if _, held, err := n.store.Get(primaryKey); err == nil && !held {
+		_, _ = n.promote()
+	}
+	members, err := n.Members()
+	if err != nil {
+		return err
+	}
+	for _, bucket := range n.Owned() {
+		if pick, ok := n.preferred(bucket, members, ""); ok && pick.ID != n.self.ID {
+			n.giveAway(bucket, pick)
+			continue
+		}
+		if _, err := n.store.Acquire(bucketPrefix+bucket, n.self.ID, "", n.ttl); err != nil {
+			// Someone took it after our lease lapsed; their copy wins.
+			n.mu.Lock()
+			delete(n.owned, bucket)
+			n.mu.Unlock()
+		}
+	}
+	return n.adopt(members)
+}
+
+// adopt claims every known bucket that nobody holds and that hashes to this instance.
+func (n *Node) adopt(members []Member) error {
+	known, err := n.store.List(knownPrefix)
+	if err != nil {
+		return err
+	}
+	for _, k := range known {
+		bucket := k.Value
+		n.mu.Lock()
+		owned := n.owned[bucket]
+		n.mu.Unlock()
+		if owned {
+			continue
+		}
+		if _, held, err := n.store.Get(bucketPrefix + bucket); err != nil || held {
+			continue
+		}
+		if pick, ok := n.preferred(bucket, members, ""); ok && pick.ID == n.self.ID {
+			_, _ = n.claim(bucket)
+		}
+	}
+	return nil
+}
+
+// Run heartbeats every interval until ctx ends, then leaves the cluster.
+func (n *Node) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			n.Leave()
+			return
+		case <-ticker.C:
+			_ = n.Heartbeat()
+		}
+	}
+}
+
+// Leave hands every owned bucket to the next preferred member and withdraws this
+// instance from the cluster.
+func (n *Node) Leave() {
+	members, err := n.Members()
+	// Withdraw first so the receivers see themselves as preferred and claim the
+	// buckets as soon as they are released.
+	_ = n.store.Release(memberPrefix+n.self.ID, n.self.ID)
+	if err == nil {
+		for _, bucket := range n.Owned() {
+			if pick, ok := n.preferred(bucket, members, n.self.ID); ok {
+				n.giveAway(bucket, pick)
+			}
+		}
+	}
+	for _, bucket := range n.Owned() {
+		_ = n.store.Release(bucketPrefix+bucket, n.self.ID)
+	}
+	_ = n.store.Release(primaryKey, n.self.ID)
+}
+
+// giveAway releases bucket so requests start going to pick, then hands its tickets
+// over. A failed handover keeps the lease so the next heartbeat can retry.
+func (n *Node) giveAway(bucket string, pick Member) {
+	n.mu.Lock()
+	handover := n.handover
+	n.mu.Unlock()
+	if handover != nil {
+		if err := handover(bucket, pick); err != nil {
+			return
+		}
+	}
+	_ = n.store.Release(bucketPrefix+bucket, n.self.ID)
+	n.mu.Lock()
+	delete(n.owned, bucket)
+	n.mu.Unlock()
+	if handover != nil {
+		// Catch anything that joined between the handover and the release.
+		_ = handover(bucket, pick)
+	}
+}
+
+// Place records that this instance holds ticketID.
+func (n *Node) Place(ticketID string) error {
+	return n.store.Set(ticketPrefix+ticketID, n.self.ID, TicketLocationTTL)
+}
+
+// Locate returns the instance holding ticketID. It reports false when the store has no
+// record, in which case callers should look locally.
+func (n *Node) Locate(ticketID string) (Member, bool, error) {
+	return n.locate(ticketPrefix + ticketID)
+}
+
+// PlaceMatch records that this instance formed matchID and holds its tickets.
+func (n *Node) PlaceMatch(matchID string) error {
+	return n.store.Set(matchPrefix+matchID, n.self.ID, TicketLocationTTL)
+}
+
+// LocateMatch returns the instance that formed matchID, reporting false like Locate.
+func (n *Node) LocateMatch(matchID string) (Member, bool, error) {
+	return n.locate(matchPrefix + matchID)
+}
+
+func (n *Node) locate(key string) (Member, bool, error) {
+	l, ok, err := n.store.Get(key)
+	if err != nil || !ok {
+		return Member{}, false, err
+	}
+	if l.Value == n.self.ID {
+		return n.self, true, nil
+	}
+	return n.member(l.Value)
+}
+
+func (n *Node) member(id string) (Member, bool, error) {
+	l, ok, err := n.store.Get(memberPrefix + id)
+	if err != nil || !ok {
+		return Member{}, false, err
+	}
+	return Member{ID: l.Owner, Addr: l.Value}, true, nil
+}
+
+// preferred picks bucket's owner among members by rendezvous hashing, skipping
+// excluded.
+func (n *Node) preferred(bucket string, members []Member, excluded string) (Member, bool) {
+	n.mu.Lock()
+	key := n.placement(bucket)
+	n.mu.Unlock()
+	var best Member
+	var bestScore uint64
+	found := false
+	for _, m := range members {
+		if m.ID == excluded {
+			continue
+		}
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(strings.Join([]string{key, m.ID}, "\x00")))
+		if score := h.Sum64(); !found || score > bestScore {
+			best, bestScore, found = m, score, true
+		}
+	}
+	return best, found
+}
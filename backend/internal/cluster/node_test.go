@@ -0,0 +1,313 @@
+package cluster
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type handoverCall struct {
+	bucket string
+	to     string
+}
+
+func newTestNodes(t *testing.T, store *MemoryStore, ids ...string) []*Node {
+	t.Helper()
+	var nodes []*Node
+	for _, id := range ids {
+		n := NewNode(store, Member{ID: id, Addr: "http://" + id}, 10*time.Second)
+		if err := n.Join(); err != nil {
+			t.Fatal(err)
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+// bucketPreferring finds a bucket that rendezvous hashing gives to want.
+func bucketPreferring(t *testing.T, n *Node, members []Member, want string) string {
+	t.Helper()
+	for i := 0; i < 1000; i++ {
+		bucket := fmt.Sprintf("us-east|playlist-%d", i)
+		if pick, _ := n.preferred(bucket, members, ""); pick.ID == want {
+			return bucket
+		}
+	}
+	t.Fatalf("no bucket prefers %s", want)
+	return ""
+}
+
+func TestNodeHandsBucketToNewPreferredMember(t *testing.T) {
+	store := NewMemoryStore()
+	a := newTestNodes(t, store, "a")[0]
+	var calls []handoverCall
+	a.OnHandover(func(bucket string, to Member) error {
+		calls = append(calls, handoverCall{bucket, to.ID})
+		return nil
+	})
+
+	bucket := bucketPreferring(t, a, []Member{{ID: "a"}, {ID: "b"}}, "b")
+	if owner, err := a.Owner(bucket); err != nil || owner.ID != "a" {
+		t.Fatalf("expected sole member to claim bucket, got=%+v err=%v", owner, err)
+	}
+
+	b := newTestNodes(t, store, "b")[0]
+	if owner, _ := b.Owner(bucket); owner.ID != "a" {
+		t.Fatalf("expected live lease to keep bucket with a, got=%+v", owner)
+	}
+	if err := a.Heartbeat(); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) == 0 || calls[0] != (handoverCall{bucket, "b"}) {
+		t.Fatalf("expected handover to b, got=%+v", calls)
+	}
+	if owner, _ := a.Owner(bucket); owner.ID != "b" || owner.Addr != "http://b" {
+		t.Fatalf("expected a to forward to b, got=%+v", owner)
+	}
+	if owner, _ := b.Owner(bucket); owner.ID != "b" {
+		t.Fatalf("expected b to claim bucket, got=%+v", owner)
+	}
+	if owned := b.Owned(); len(owned) != 1 || owned[0] != bucket {
+		t.Fatalf("expected b to hold the lease, got=%v", owned)
+	}
+}
+
+func TestNodeLeavesAndLeasesExpire(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now().UTC()
+	store.now = func() time.Time { return now }
+	nodes := newTestNodes(t, store, "a", "b", "c")
+	a, b := nodes[0], nodes[1]
+
+	members, _ := a.Members()
+	bucket := bucketPreferring(t, a, members, "a")
+	if owner, _ := a.Owner(bucket); owner.ID != "a" {
+		t.Fatalf("expected a to claim, got=%+v", owner)
+	}
+	var got []handoverCall
+	a.OnHandover(func(bucket string, to Member) error {
+		got = append(got, handoverCall{bucket, to.ID})
+		return nil
+	})
+	a.Leave()
+	if len(got) == 0 || got[0].to == "a" {
+		t.Fatalf("expected graceful leave to hand the bucket to another member, got=%+v", got)
+	}
+	if members, _ := b.Members(); len(members) != 2 {
+		t.Fatalf("expected a gone from members, got=%+v", members)
+	}
+
+	owner, err := b.Owner(bucket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	holder := map[string]*Node{"b": b, "c": nodes[2]}[owner.ID]
+	if _, err := holder.Owner(bucket); err != nil {
+		t.Fatal(err)
+	}
+
+	// The holder crashes: once its leases lapse the survivor takes over.
+	survivor := b
+	if holder == b {
+		survivor = nodes[2]
+	}
+	now = now.Add(5 * time.Second)
+	_ = survivor.Heartbeat()
+	if _, err := survivor.Owner(bucket); err != nil && err != ErrOwnerUnavailable {
+		t.Fatal(err)
+	}
+	now = now.Add(6 * time.Second)
+	if owner, err := survivor.Owner(bucket); err != nil || owner.ID != survivor.Self().ID {
+		t.Fatalf("expected survivor to take over after lease expiry, got=%+v err=%v", owner, err)
+	}
+}
+
+func TestHTTPStoreMatchesMemoryStore(t *testing.T) {
+	key := []byte("cluster-key")
+	srv := httptest.NewServer(Handler("/store", NewMemoryStore(), key))
+	defer srv.Close()
+	s := NewHTTPStore(srv.URL+"/store", key, nil)
+
+	if _, err := s.Acquire("bucket/x", "a", "", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	held, err := s.Acquire("bucket/x", "b", "", time.Minute)
+	if err != ErrLeaseHeld || held.Owner != "a" {
+		t.Fatalf("expected lease held by a, got=%+v err=%v", held, err)
+	}
+	if err := s.Set("ticket/t1", "a", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if l, ok, err := s.Get("ticket/t1"); err != nil || !ok || l.Value != "a" {
+		t.Fatalf("expected stored value, got=%+v ok=%v err=%v", l, ok, err)
+	}
+	if _, ok, _ := s.Get("ticket/missing"); ok {
+		t.Fatal("expected missing key")
+	}
+	_ = s.Release("bucket/x", "a")
+	if leases, _ := s.List("bucket/"); len(leases) != 0 {
+		t.Fatalf("expected released lease gone, got=%+v", leases)
+	}
+	if err := s.Write([]Record{{Key: "table/x/1", Value: "kept"}, {Key: "table/x/2", Value: "gone"}, {Key: "table/x/2", Delete: true}}); err != nil {
+		t.Fatal(err)
+	}
+	if values, _ := s.List("table/x/"); len(values) != 1 || values[0].Value != "kept" || !values[0].ExpiresAt.IsZero() {
+		t.Fatalf("expected one durable value, got=%+v", values)
+	}
+
+	stranger := NewHTTPStore(srv.URL+"/store", []byte("other-key"), nil)
+	if _, err := stranger.Acquire("bucket/y", "c", "", time.Minute); err == nil {
+		t.Fatal("expected an unsigned acquire to be refused")
+	}
+	if err := stranger.Write([]Record{{Key: "table/x/1", Delete: true}}); err == nil {
+		t.Fatal("expected an unsigned write to be refused")
+	}
+	if _, err := stranger.List("table/x/"); err == nil {
+		t.Fatal("expected an unsigned list to be refused")
+	}
+	if values, _ := s.List("table/x/"); len(values) != 1 {
+		t.Fatalf("expected refused writes to change nothing, got=%+v", values)
+	}
+}
+
+func TestPrimaryIsSharedAndMovesWhenItsHolderDies(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now().UTC()
+	store.now = func() time.Time { return now }
+	nodes := newTestNodes(t, store, "a", "b")
+	a, b := nodes[0], nodes[1]
+	promotions := 0
+	loadErr := errors.New("store unavailable")
+	b.OnPromote(func() error {
+		promotions++
+		return loadErr
+	})
+
+	if p, err := a.Primary(); err != nil || p.ID != "a" {
+		t.Fatalf("expected the first to ask to become primary, got=%+v err=%v", p, err)
+	}
+	if p, err := b.Primary(); err != nil || p.ID != "a" || p.Addr != "http://a" {
+		t.Fatalf("expected b to see a as primary, got=%+v err=%v", p, err)
+	}
+	now = now.Add(6 * time.Second)
+	_ = a.Heartbeat()
+	_ = b.Heartbeat()
+	now = now.Add(6 * time.Second)
+	if p, _ := b.Primary(); p.ID != "a" {
+		t.Fatalf("expected heartbeats to keep a primary, got=%+v", p)
+	}
+
+	// a crashes: once its lease runs out b takes over, but only after loading the state
+	// a saved.
+	now = now.Add(5 * time.Second)
+	if _, err := b.Primary(); err != loadErr || b.IsPrimary() {
+		t.Fatalf("expected a failed load to give the role back, err=%v", err)
+	}
+	loadErr = nil
+	_ = b.Heartbeat()
+	if promotions != 2 || !b.IsPrimary() {
+		t.Fatalf("expected b's heartbeat to take over and load the state, promotions=%d", promotions)
+	}
+	if p, err := b.Primary(); err != nil || p.ID != "b" || promotions != 2 {
+		t.Fatalf("expected b to stay primary without loading again, got=%+v err=%v promotions=%d", p, err, promotions)
+	}
+
+	c := newTestNodes(t, store, "c")[0]
+	b.Leave()
+	if p, err := c.Primary(); err != nil || p.ID != "c" {
+		t.Fatalf("expected a graceful leave to free the role at once, got=%+v err=%v", p, err)
+	}
+}
+
+func TestCrashedOwnersTicketsSurviveOnTheNewOwner(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now().UTC()
+	store.now = func() time.Time { return now }
+	nodes := newTestNodes(t, store, "a", "b")
+	a, b := nodes[0], nodes[1]
+	tickets := NewTable(store, "tickets")
+	var handovers []handoverCall
+	loaded := map[string][]string{}
+	for _, n := range nodes {
+		n.OnHandover(func(bucket string, to Member) error {
+			handovers = append(handovers, handoverCall{bucket, to.ID})
+			return nil
+		})
+		n.OnClaim(func(bucket string) error {
+			return tickets.Load(bucket+"/", func(data []byte) error {
+				var id string
+				if err := json.Unmarshal(data, &id); err != nil {
+					return err
+				}
+				loaded[n.Self().ID] = append(loaded[n.Self().ID], id)
+				return n.Place(id)
+			})
+		})
+	}
+
+	members, _ := a.Members()
+	bucket := bucketPreferring(t, a, members, "a")
+	if owner, _ := a.Owner(bucket); owner.ID != "a" {
+		t.Fatalf("expected a to claim, got=%+v", owner)
+	}
+	if err := tickets.Put(bucket+"/t1", "t1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Place("t1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// a crashes without leaving: nothing hands its bucket over, and nobody asks for it.
+	now = now.Add(11 * time.Second)
+	if err := b.Heartbeat(); err != nil {
+		t.Fatal(err)
+	}
+	if len(handovers) != 0 {
+		t.Fatalf("expected no handover from a crashed owner, got=%+v", handovers)
+	}
+	if owned := b.Owned(); len(owned) != 1 || owned[0] != bucket {
+		t.Fatalf("expected b to reclaim the orphaned bucket on its heartbeat, got=%v", owned)
+	}
+	if got := loaded["b"]; len(got) != 1 || got[0] != "t1" {
+		t.Fatalf("expected b to load the saved ticket, got=%v", got)
+	}
+	if holder, ok, err := a.Locate("t1"); err != nil || !ok || holder.ID != "b" {
+		t.Fatalf("expected the ticket to be found on b, got=%+v ok=%v err=%v", holder, ok, err)
+	}
+}
+
+func TestFileStoreKeepsWrittenValuesAcrossRestart(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetCompactEvery(2)
+	if err := s.Write([]Record{{Key: "table/x/1", Value: "one"}, {Key: "table/x/2", Value: "two"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write([]Record{{Key: "table/x/1", Delete: true}, {Key: "table/x/3", Value: "three"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Acquire("bucket/x", "a", "", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	// Crash without Close: the last write is only in the log.
+	s.wal.Close()
+
+	reopened, err := OpenFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	values, _ := reopened.List("table/x/")
+	if len(values) != 2 || values[0].Value != "two" || values[1].Value != "three" {
+		t.Fatalf("expected the written values back, got=%+v", values)
+	}
+	if _, ok, _ := reopened.Get("bucket/x"); ok {
+		t.Fatal("expected leases to be renewed by their owners, not restored")
+	}
+}
@@ -0,0 +1,149 @@
+// Package cluster lets several matchmaker instances share the queue: each owns a set of
+// buckets through leases in a shared store and forwards everything else to the owner.
+// State that is not per bucket, such as results, ratings, abandon bans and the game
+// server fleet, lives on one primary instance (see Node.Primary) that the rest defer to.
+package cluster
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrLeaseHeld = errors.New("cluster: lease held by another owner")
+	ErrBadLease  = errors.New("cluster: lease needs a key, an owner and a positive ttl")
+	// ErrOwnerUnavailable means a bucket's lease outlived its owner and cannot be taken yet.
+	ErrOwnerUnavailable = errors.New("cluster: bucket owner unavailable")
+)
+
+// Lease is a key held by one owner until ExpiresAt. Plain values written with Set have
+// no owner, and values written with Write also have no ExpiresAt.
+type Lease struct {
+	Key       string    `json:"key"`
+	Owner     string    `json:"owner,omitempty"`
+	Value     string    `json:"value,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Record is one durable change made with Write: Value is kept at Key, or Key is
+// removed when Delete is set.
+type Record struct {
+	Key    string `json:"key"`
+	Value  string `json:"value,omitempty"`
+	Delete bool   `json:"delete,omitempty"`
+}
+
+// SharedStore is the coordination state every matchmaker instance sees. Production
+// deployments would back it with etcd, Consul or Redis; MemoryStore is the in-process
+// stand-in, which Handler can also serve to other processes over HTTP.
+type SharedStore interface {
+	// Acquire takes key for owner, or renews owner's lease on it, until ttl from now.
+	// It fails with ErrLeaseHeld while another owner's lease is live.
+	Acquire(key, owner, value string, ttl time.Duration) (Lease, error)
+	// Release drops owner's lease on key. Releasing someone else's lease does nothing.
+	Release(key, owner string) error
+	// Set writes an unowned value that lives for ttl.
+	Set(key, value string, ttl time.Duration) error
+	// Get returns the live lease or value at key.
+	Get(key string) (Lease, bool, error)
+	// List returns every live entry whose key starts with prefix, ordered by key.
+	List(prefix string) ([]Lease, error)
+	// Write applies records in order. Their values never expire, so state written this
+	// way outlives the instance that wrote it.
+	Write(records []Record) error
+}
+
+// MemoryStore is a SharedStore held in process.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]Lease
+	now     func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]Lease), now: func() time.Time { return time.Now().UTC() }}
+}
+
+func (s *MemoryStore) Acquire(key, owner, value string, ttl time.Duration) (Lease, error) {
+	if key == "" || owner == "" || ttl <= 0 {
+		return Lease{}, ErrBadLease
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if cur, ok := s.entries[key]; ok && live(cur, now) && cur.Owner != owner {
+		return cur, ErrLeaseHeld
+	}
+	l := Lease{Key: key, Owner: owner, Value: value, ExpiresAt: now.Add(ttl)}
+	s.entries[key] = l
+	return l, nil
+}
+
+func (s *MemoryStore) Release(key, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cur, ok := s.entries[key]; ok && cur.Owner == owner {
+		delete(s.entries, key)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Set(key, value string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = Lease{Key: key, Value: value, ExpiresAt: s.now().Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStore) Get(key string) (Lease, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, ok := s.entries[key]
+	if !ok || !live(l, s.now()) {
+		return Lease{}, false, nil
+	}
+	return l, true, nil
+}
+
+func (s *MemoryStore) List(prefix string) ([]Lease, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	var out []Lease
+	for key, l := range s.entries {
+		if !live(l, now) {
+			delete(s.entries, key)
+			continue
+		}
+		if strings.HasPrefix(key, prefix) {
+			out = append(out, l)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out, nil
+}
+
+func (s *MemoryStore) Write(records []Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.applyLocked(records)
+	return nil
+}
+
+func (s *MemoryStore) applyLocked(records []Record) {
+	for _, r := range records {
+		if r.Delete {
+			delete(s.entries, r.Key)
+			continue
+		}
+		s.entries[r.Key] = Lease{Key: r.Key, Value: r.Value}
+	}
+}
+
+// live reports whether l has not run out by now. Values written with Write never do.
+func live(l Lease, now time.Time) bool {
+	return l.ExpiresAt.IsZero() || now.Before(l.ExpiresAt)
+}
@@ -0,0 +1,64 @@
+package cluster
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+const tablePrefix = "table/"
+
+// Table is a durable collection of JSON values in the shared store, for state that
+// must outlive the instance holding it: a bucket's tickets, or the primary's ratings.
+type Table struct {
+	store  SharedStore
+	prefix string
+}
+
+// NewTable returns the table called name in store.
+func NewTable(store SharedStore, name string) *Table {
+	return &Table{store: store, prefix: tablePrefix + name + "/"}
+}
+
+// Load passes every value whose key starts with prefix to decode, in key order.
+func (t *Table) Load(prefix string, decode func(data []byte) error) error {
+	values, err := t.store.List(t.prefix + prefix)
+	if err != nil {
+		return err
+	}
+	for _, v := range values {
+		if err := decode([]byte(v.Value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write saves puts and removes deletes in one write to the store.
+func (t *Table) Write(puts map[string]interface{}, deletes []string) error {
+	records := make([]Record, 0, len(puts)+len(deletes))
+	for key, v := range puts {
+		buf, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		records = append(records, Record{Key: t.prefix + key, Value: string(buf)})
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Key < records[j].Key })
+	for _, key := range deletes {
+		records = append(records, Record{Key: t.prefix + key, Delete: true})
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	return t.store.Write(records)
+}
+
+// Put saves v at key.
+func (t *Table) Put(key string, v interface{}) error {
+	return t.Write(map[string]interface{}{key: v}, nil)
+}
+
+// Delete removes key. Deleting a missing key is not an error.
+func (t *Table) Delete(key string) error {
+	return t.Write(nil, []string{key})
+}
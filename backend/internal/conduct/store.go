@@ -0,0 +1,66 @@
+package conduct
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Store persists abandon histories so bans and strikes survive a matchmaker restart.
+// Tracker writes through on every change while holding its lock, so implementations
+// must not call back into it.
+type Store interface {
+	// Load returns every saved history.
+	Load() ([]PlayerRecord, error)
+	// Put saves rec, replacing any earlier record for the same player.
+	Put(rec PlayerRecord) error
+	// Delete forgets a player; deleting an unknown player is not an error.
+	Delete(playerID string) error
+	Close() error
+}
+
+// PlayerRecord is the durable form of one player's abandon history.
+type PlayerRecord struct {
+	PlayerID    string    `json:"player_id"`
+	Abandons    int       `json:"abandons"`
+	Strikes     int       `json:"strikes"`
+	LastAbandon time.Time `json:"last_abandon"`
+	LastStrike  time.Time `json:"last_strike,omitzero"`
+	BannedUntil time.Time `json:"banned_until,omitzero"`
+}
+
+// MemoryStore keeps records in process. It is the default store, so histories last only
+// as long as the matchmaker does.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]PlayerRecord
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]PlayerRecord)}
+}
+
+func (s *MemoryStore) Load() ([]PlayerRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Collect(maps.Values(s.records)), nil
+}
+
+func (s *MemoryStore) Put(rec PlayerRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[rec.PlayerID] = rec
+	return nil
+}
+
+func (s *MemoryStore) Delete(playerID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, playerID)
+	return nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,226 @@
+// Package conduct tracks players who abandon matches and the matchmaking bans and
+// rating penalties that follow.
+package conduct
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"projectvelocity/backend/internal/shared/types"
+)
+
+// banSteps escalate with each ranked abandon; the last step repeats.
+var banSteps = []time.Duration{5 * time.Minute, 15 * time.Minute, time.Hour, 4 * time.Hour, 24 * time.Hour}
+
+// penaltySteps are the rating points taken from a ranked abandoner, escalating alongside
+// banSteps.
+var penaltySteps = []float64{10, 15, 25, 40, 50}
+
+// Memory is how long a ranked abandon keeps counting toward escalation.
+const Memory = 7 * 24 * time.Hour
+
+// Record is one player's abandon history as far as the tracker remembers it.
+type Record struct {
+	PlayerID string `json:"player_id"`
+	// Abandons counts every public match the player left early; Strikes only the ranked
+	// ones still within Memory, which set the next ban and penalty.
+	Abandons    int   `json:"abandons"`
+	Strikes     int   `json:"strikes"`
+	LastAbandon int64 `json:"last_abandon,omitempty"` // unix ms
+	BannedUntil int64 `json:"banned_until,omitempty"` // unix ms
+}
+
+// Penalty is what one ranked abandon cost a player.
+type Penalty struct {
+	PlayerID      string    `json:"player_id"`
+	MatchID       string    `json:"match_id"`
+	Playlist      string    `json:"playlist"`
+	Strike        int       `json:"strike"`
+	BannedUntil   time.Time `json:"banned_until"`
+	RatingPenalty float64   `json:"rating_penalty"`
+}
+
+type record struct {
+	abandons    int
+	strikes     int
+	lastAbandon time.Time
+	lastStrike  time.Time
+	until       time.Time
+}
+
+// Tracker counts the matches each player abandoned, from the results game servers
+// report, and bans ranked leavers from matchmaking for escalating spells.
+type Tracker struct {
+	mu      sync.RWMutex
+	players map[string]*record
+	store   Store
+	// storeErrors counts failed writes to store.
+	storeErrors uint64
+}
+
+// NewTracker creates an empty tracker backed by a MemoryStore.
+func NewTracker() *Tracker {
+	return &Tracker{players: make(map[string]*record), store: NewMemoryStore()}
+}
+
+// SetStore restores every history saved in s and makes s the write-through store for
+// later changes.
+func (t *Tracker) SetStore(s Store) error {
+	records, err := s.Load()
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.store = s
+	for _, rec := range records {
+		t.players[rec.PlayerID] = &record{
+			abandons:    rec.Abandons,
+			strikes:     rec.Strikes,
+			lastAbandon: rec.LastAbandon,
+			lastStrike:  rec.LastStrike,
+			until:       rec.BannedUntil,
+		}
+	}
+	return nil
+}
+
+// StoreErrors returns how many writes to the store have failed.
+func (t *Tracker) StoreErrors() uint64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.storeErrors
+}
+
+// Record notes every human r lists as abandoned. Private matches are ignored. In a
+// ranked match each abandoner also takes a strike and is returned with the ban and
+// rating penalty it earned; the caller applies the penalty to their rating. Time is
+// taken from the match's end so a late report does not shorten the ban.
+//
+// Only players who had a car can abandon: no-shows are skipped, and so is a match no
+// human ever connected to, since that points at the allocation or the server rather
+// than the players.
+func (t *Tracker) Record(r types.MatchResult, ranked bool) []Penalty {
+	if r.Private || !r.Connected() {
+		return nil
+	}
+	ended := time.UnixMilli(r.EndedAt).UTC()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var out []Penalty
+	for _, p := range r.Players {
+		if p.IsBot || !p.Abandoned || p.NoShow {
+			continue
+		}
+		rec, ok := t.players[p.PlayerID]
+		if !ok {
+			rec = &record{}
+			t.players[p.PlayerID] = rec
+		}
+		rec.abandons++
+		rec.lastAbandon = ended
+		if !ranked {
+			t.save(p.PlayerID, rec)
+			continue
+		}
+		if ended.Sub(rec.lastStrike) > Memory {
+			rec.strikes = 0
+		}
+		step := min(rec.strikes, len(banSteps)-1)
+		rec.strikes++
+		rec.lastStrike = ended
+		if until := ended.Add(banSteps[step]); until.After(rec.until) {
+			rec.until = until
+		}
+		t.save(p.PlayerID, rec)
+		out = append(out, Penalty{
+			PlayerID:      p.PlayerID,
+			MatchID:       r.MatchID,
+			Playlist:      r.Playlist,
+			Strike:        rec.strikes,
+			BannedUntil:   rec.until,
+			RatingPenalty: penaltySteps[step],
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].PlayerID < out[j].PlayerID })
+	return out
+}
+
+// Ban returns when playerID may queue again after abandoning ranked matches. The zero
+// time means no ban is active.
+func (t *Tracker) Ban(playerID string, now time.Time) time.Time {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if rec, ok := t.players[playerID]; ok && now.Before(rec.until) {
+		return rec.until
+	}
+	return time.Time{}
+}
+
+// Get returns playerID's record. Strikes older than Memory no longer count.
+func (t *Tracker) Get(playerID string, now time.Time) Record {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	out := Record{PlayerID: playerID}
+	rec, ok := t.players[playerID]
+	if !ok {
+		return out
+	}
+	out.Abandons = rec.abandons
+	out.LastAbandon = rec.lastAbandon.UnixMilli()
+	if now.Sub(rec.lastStrike) <= Memory {
+		out.Strikes = rec.strikes
+	}
+	if now.Before(rec.until) {
+		out.BannedUntil = rec.until.UnixMilli()
+	}
+	return out
+}
+
+// Banned reports how many players are serving a ban at now.
+func (t *Tracker) Banned(now time.Time) int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	n := 0
+	for _, rec := range t.players {
+		if now.Before(rec.until) {
+			n++
+		}
+	}
+	return n
+}
+
+// Expire forgets players who have served any ban and not abandoned a match within
+// Memory, and returns how many were dropped.
+func (t *Tracker) Expire(now time.Time) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := 0
+	for id, rec := range t.players {
+		if now.Sub(rec.lastAbandon) > Memory && !now.Before(rec.until) {
+			delete(t.players, id)
+			if err := t.store.Delete(id); err != nil {
+				t.storeErrors++
+			}
+			n++
+		}
+	}
+	return n
+}
+
+// save writes rec through to the store. Callers hold t.mu.
+func (t *Tracker) save(playerID string, rec *record) {
+	err := t.store.Put(PlayerRecord{
+		PlayerID:    playerID,
+		Abandons:    rec.abandons,
+		Strikes:     rec.strikes,
+		LastAbandon: rec.lastAbandon,
+		LastStrike:  rec.lastStrike,
+		BannedUntil: rec.until,
+	})
+	if err != nil {
+		t.storeErrors++
+	}
+}
@@ -0,0 +1,162 @@
+package conduct
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"projectvelocity/backend/internal/shared/types"
+)
+
+func abandonedBy(matchID string, ended time.Time, leaver string) types.MatchResult {
+	return types.MatchResult{
+		MatchID:  matchID,
+		Playlist: "ranked-2v2",
+		EndedAt:  ended.UnixMilli(),
+		Players: []types.PlayerMatchStats{
+			{PlayerID: leaver, Team: "orange", Abandoned: true},
+			{PlayerID: "mate", Team: "orange"},
+			{PlayerID: "bot_blue", Team: "blue", IsBot: true, Abandoned: true},
+		},
+	}
+}
+
+func TestRankedAbandonsEscalateBansAndPenalties(t *testing.T) {
+	tr := NewTracker()
+	now := time.Unix(1_700_000_000, 0).UTC()
+
+	first := tr.Record(abandonedBy("m1", now, "a"), true)
+	if len(first) != 1 || first[0].PlayerID != "a" || first[0].Strike != 1 {
+		t.Fatalf("expected one strike for the human leaver only: %+v", first)
+	}
+	if !first[0].BannedUntil.Equal(now.Add(banSteps[0])) || first[0].RatingPenalty != penaltySteps[0] {
+		t.Fatalf("unexpected first penalty: %+v", first[0])
+	}
+	if tr.Ban("a", now.Add(time.Minute)).IsZero() || !tr.Ban("mate", now).IsZero() {
+		t.Fatal("expected only the leaver to be banned")
+	}
+	if !tr.Ban("a", now.Add(banSteps[0])).IsZero() {
+		t.Fatal("expected the ban to lapse")
+	}
+
+	later := now.Add(time.Hour)
+	second := tr.Record(abandonedBy("m2", later, "a"), true)
+	if second[0].Strike != 2 || !second[0].BannedUntil.Equal(later.Add(banSteps[1])) || second[0].RatingPenalty != penaltySteps[1] {
+		t.Fatalf("expected the second abandon to escalate: %+v", second[0])
+	}
+
+	// A clean week resets the escalation.
+	clean := later.Add(Memory + time.Hour)
+	third := tr.Record(abandonedBy("m3", clean, "a"), true)
+	if third[0].Strike != 1 || third[0].RatingPenalty != penaltySteps[0] {
+		t.Fatalf("expected strikes to reset after Memory: %+v", third[0])
+	}
+	if rec := tr.Get("a", clean); rec.Abandons != 3 || rec.Strikes != 1 || rec.BannedUntil == 0 {
+		t.Fatalf("unexpected record: %+v", rec)
+	}
+}
+
+func TestUnrankedAndPrivateAbandonsAreNotPenalized(t *testing.T) {
+	tr := NewTracker()
+	now := time.Unix(1_700_000_000, 0).UTC()
+
+	if out := tr.Record(abandonedBy("m1", now, "a"), false); len(out) != 0 {
+		t.Fatalf("expected no penalty outside ranked: %+v", out)
+	}
+	if !tr.Ban("a", now).IsZero() || tr.Get("a", now).Abandons != 1 {
+		t.Fatal("expected a casual abandon to be counted but not banned")
+	}
+
+	private := abandonedBy("m2", now, "b")
+	private.Private = true
+	if out := tr.Record(private, true); len(out) != 0 || tr.Get("b", now).Abandons != 0 {
+		t.Fatal("expected private matches to be ignored")
+	}
+
+	if n := tr.Expire(now.Add(Memory + time.Minute)); n != 1 || tr.Get("a", now).Abandons != 0 {
+		t.Fatalf("expected the stale record to be dropped, dropped=%d", n)
+	}
+}
+
+func TestFileStoreKeepsBansAcrossRestart(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	tr := NewTracker()
+	if err := tr.SetStore(store); err != nil {
+		t.Fatal(err)
+	}
+	tr.Record(abandonedBy("m1", start, "quitter"), true)
+	tr.Record(abandonedBy("m2", start.Add(time.Minute), "quitter"), true)
+	tr.Record(abandonedBy("m3", start, "old"), true)
+	tr.Expire(start.Add(Memory + time.Hour))
+	tr.Record(abandonedBy("m4", start.Add(Memory+2*time.Hour), "quitter"), true)
+	want := tr.Get("quitter", start.Add(Memory+2*time.Hour))
+
+	// Simulate a crash: no Close, and a half-written final log line.
+	wal, err := os.OpenFile(filepath.Join(dir, walFile), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = wal.WriteString(`{"op":"put","record":{"player_id":`)
+	wal.Close()
+	store.wal.Close()
+
+	reopened, err := OpenFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	restored := NewTracker()
+	if err := restored.SetStore(reopened); err != nil {
+		t.Fatal(err)
+	}
+	now := start.Add(Memory + 2*time.Hour)
+	if got := restored.Get("quitter", now); got != want || got.BannedUntil == 0 {
+		t.Fatalf("expected the ban to survive a restart: want %+v got %+v", want, got)
+	}
+	if got := restored.Get("old", now); got.Abandons != 0 {
+		t.Fatalf("expected the expired player to stay forgotten: %+v", got)
+	}
+	if restored.StoreErrors() != 0 || tr.StoreErrors() != 0 {
+		t.Fatalf("unexpected store errors: %d %d", tr.StoreErrors(), restored.StoreErrors())
+	}
+}
+
+func TestRankedRosterThatNeverConnectedIsNotPenalized(t *testing.T) {
+	tr := NewTracker()
+	now := time.Unix(1_700_000_000, 0).UTC()
+	// An allocation that never started: every rostered player is a no-show, and a game
+	// server that still flags them as abandoned must not get them banned.
+	empty := types.MatchResult{
+		MatchID:  "m1",
+		Playlist: "ranked-2v2",
+		EndedAt:  now.UnixMilli(),
+		Players: []types.PlayerMatchStats{
+			{PlayerID: "a", Team: "orange", NoShow: true, Abandoned: true},
+			{PlayerID: "b", Team: "orange", NoShow: true, Abandoned: true},
+			{PlayerID: "c", Team: "blue", NoShow: true, Abandoned: true},
+			{PlayerID: "d", Team: "blue", NoShow: true, Abandoned: true},
+		},
+	}
+	if out := tr.Record(empty, true); len(out) != 0 {
+		t.Fatalf("expected no penalties for a match nobody joined: %+v", out)
+	}
+	for _, id := range []string{"a", "b", "c", "d"} {
+		if !tr.Ban(id, now).IsZero() || tr.Get(id, now).Abandons != 0 {
+			t.Fatalf("expected %s not to be banned or counted", id)
+		}
+	}
+
+	// Once someone played, a no-show still never had a car to abandon.
+	played := abandonedBy("m2", now, "leaver")
+	played.Players = append(played.Players, types.PlayerMatchStats{PlayerID: "absent", Team: "blue", NoShow: true, Abandoned: true})
+	out := tr.Record(played, true)
+	if len(out) != 1 || out[0].PlayerID != "leaver" || !tr.Ban("absent", now).IsZero() {
+		t.Fatalf("expected only the player who left their car to be penalized: %+v", out)
+	}
+}
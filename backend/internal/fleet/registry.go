@@ -0,0 +1,371 @@
+package fleet
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"projectvelocity/backend/internal/shared/signing"
+	"projectvelocity/backend/internal/shared/types"
+)
+
+const (
+	// DefaultServerTTL is how long a server may go without a heartbeat before it is presumed dead.
+	DefaultServerTTL = 15 * time.Second
+	// DefaultReservationTTL is how long a reservation may wait for the server to report the match.
+	DefaultReservationTTL = 30 * time.Second
+)
+
+var (
+	ErrNoCapacity    = errors.New("fleet: no healthy server with free capacity")
+	ErrUnknownServer = errors.New("fleet: unknown server")
+)
+
+// Dispatcher delivers an allocation to the chosen game server.
+type Dispatcher func(server types.GameServerRegistration, a types.MatchAllocation) error
+
+type reservation struct {
+	serverID   string
+	allocation types.MatchAllocation
+	reservedAt time.Time
+	// confirmed is set once the server reports the match in a heartbeat.
+	confirmed bool
+}
+
+type server struct {
+	info          types.GameServerRegistration
+	lastHeartbeat time.Time
+	hosted        map[string]bool
+}
+
+// Registry tracks live game servers and the match slots reserved on them, writing every
+// change through to its store. Reservations are rolled back when dispatch fails, the
+// server dies, or the match never shows up.
+type Registry struct {
+	mu             sync.Mutex
+	servers        map[string]*server
+	reservations   map[string]*reservation
+	serverTTL      time.Duration
+	reservationTTL time.Duration
+	dispatch       Dispatcher
+	onRollback     func(matchID string)
+	now            func() time.Time
+	store          Store
+	// storeErrors counts failed writes to store.
+	storeErrors uint64
+}
+
+// NewRegistry creates an empty fleet registry backed by a MemoryStore. dispatch may be
+// nil to skip delivery.
+func NewRegistry(dispatch Dispatcher) *Registry {
+	return &Registry{
+		servers:        make(map[string]*server),
+		reservations:   make(map[string]*reservation),
+		serverTTL:      DefaultServerTTL,
+		reservationTTL: DefaultReservationTTL,
+		dispatch:       dispatch,
+		now:            func() time.Time { return time.Now().UTC() },
+		store:          NewMemoryStore(),
+	}
+}
+
+// SetStore replaces the fleet with the servers and reservations saved in s and makes s
+// the write-through store for later changes. Restored servers get a full heartbeat
+// window to check in before they, and the matches reserved on them, are given up.
+func (r *Registry) SetStore(s Store) error {
+	servers, reservations, err := s.Load()
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.store = s
+	now := r.now()
+	clear(r.servers)
+	clear(r.reservations)
+	for _, rec := range servers {
+		sv := &server{info: rec.Info, lastHeartbeat: now, hosted: make(map[string]bool, len(rec.Hosted))}
+		for _, id := range rec.Hosted {
+			sv.hosted[id] = true
+		}
+		r.servers[rec.Info.ServerID] = sv
+	}
+	for _, rec := range reservations {
+		r.reservations[rec.MatchID] = &reservation{
+			serverID:   rec.ServerID,
+			allocation: rec.Allocation,
+			reservedAt: rec.ReservedAt,
+			confirmed:  rec.Confirmed,
+		}
+	}
+	return nil
+}
+
+// StoreErrors returns how many writes to the store have failed.
+func (r *Registry) StoreErrors() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.storeErrors
+}
+
+// OnRollback sets the callback invoked, outside the registry lock, for each rolled back match.
+func (r *Registry) OnRollback(fn func(matchID string)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onRollback = fn
+}
+
+// Register adds or refreshes a game server.
+func (r *Registry) Register(info types.GameServerRegistration) error {
+	if info.ServerID == "" || info.PublicAddr == "" {
+		return fmt.Errorf("fleet: server_id and public_addr are required")
+	}
+	if info.Capacity <= 0 {
+		info.Capacity = 1
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.servers[info.ServerID]
+	if !ok {
+		s = &server{hosted: make(map[string]bool)}
+		r.servers[info.ServerID] = s
+	}
+	s.info = info
+	s.lastHeartbeat = r.now()
+	r.saveServerLocked(s)
+	return nil
+}
+
+// Heartbeat records liveness and confirms reservations the server now hosts.
+func (r *Registry) Heartbeat(hb types.GameServerHeartbeat) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.servers[hb.ServerID]
+	if !ok {
+		return ErrUnknownServer
+	}
+	s.lastHeartbeat = r.now()
+	hosted := make(map[string]bool, len(hb.Matches))
+	for _, id := range hb.Matches {
+		hosted[id] = true
+		if res, ok := r.reservations[id]; ok && res.serverID == hb.ServerID && !res.confirmed {
+			res.confirmed = true
+			r.saveReservationLocked(id, res)
+		}
+	}
+	// Heartbeats are frequent, so the server is only saved when its matches change.
+	if !maps.Equal(hosted, s.hosted) {
+		s.hosted = hosted
+		r.saveServerLocked(s)
+	}
+	return nil
+}
+
+// Allocate reserves a slot for the match and dispatches the roster asynchronously. Servers
+// in the match's region are preferred; any healthy server is used as a fallback.
+func (r *Registry) Allocate(a types.MatchAllocation) (string, error) {
+	r.mu.Lock()
+	chosen := r.pickLocked(a.Region)
+	if chosen == nil {
+		r.mu.Unlock()
+		return "", ErrNoCapacity
+	}
+	res := &reservation{
+		serverID:   chosen.info.ServerID,
+		allocation: a,
+		reservedAt: r.now(),
+	}
+	r.reservations[a.MatchID] = res
+	r.saveReservationLocked(a.MatchID, res)
+	info := chosen.info
+	dispatch := r.dispatch
+	r.mu.Unlock()
+
+	if dispatch != nil {
+		go func() {
+			if err := dispatch(info, a); err != nil {
+				r.rollback(a.MatchID)
+			}
+		}()
+	}
+	return info.PublicAddr, nil
+}
+
+// Release frees a match's reservation without triggering the rollback callback.
+func (r *Registry) Release(matchID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dropReservationLocked(matchID)
+}
+
+// Reap drops servers that missed their heartbeat window and frees reservations that
+// are stale. Reservations on dead servers, or never confirmed, are rolled back.
+func (r *Registry) Reap() {
+	now := r.now()
+	var rolledBack []string
+
+	r.mu.Lock()
+	for id, s := range r.servers {
+		if now.Sub(s.lastHeartbeat) > r.serverTTL {
+			delete(r.servers, id)
+			if err := r.store.DeleteServer(id); err != nil {
+				r.storeErrors++
+			}
+		}
+	}
+	for matchID, res := range r.reservations {
+		s, alive := r.servers[res.serverID]
+		switch {
+		case !alive:
+			r.dropReservationLocked(matchID)
+			rolledBack = append(rolledBack, matchID)
+		case res.confirmed && !s.hosted[matchID]:
+			// The match ran and has since been torn down by the server.
+			r.dropReservationLocked(matchID)
+		case !res.confirmed && now.Sub(res.reservedAt) > r.reservationTTL:
+			r.dropReservationLocked(matchID)
+			rolledBack = append(rolledBack, matchID)
+		}
+	}
+	fn := r.onRollback
+	r.mu.Unlock()
+
+	if fn != nil {
+		for _, id := range rolledBack {
+			fn(id)
+		}
+	}
+}
+
+// Servers returns the current fleet view ordered by server id.
+func (r *Registry) Servers() []types.GameServerStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]types.GameServerStatus, 0, len(r.servers))
+	for id, s := range r.servers {
+		out = append(out, types.GameServerStatus{
+			GameServerRegistration: s.info,
+			Reserved:               r.reservedLocked(id),
+			Hosted:                 len(s.hosted),
+			LastHeartbeatAt:        s.lastHeartbeat.Unix(),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ServerID < out[j].ServerID })
+	return out
+}
+
+func (r *Registry) rollback(matchID string) {
+	r.mu.Lock()
+	ok := r.dropReservationLocked(matchID)
+	fn := r.onRollback
+	r.mu.Unlock()
+	if ok && fn != nil {
+		fn(matchID)
+	}
+}
+
+// saveServerLocked writes s through to the store. Callers hold r.mu.
+func (r *Registry) saveServerLocked(s *server) {
+	rec := ServerRecord{Info: s.info, Hosted: slices.Sorted(maps.Keys(s.hosted))}
+	if err := r.store.PutServer(rec); err != nil {
+		r.storeErrors++
+	}
+}
+
+// saveReservationLocked writes res through to the store. Callers hold r.mu.
+func (r *Registry) saveReservationLocked(matchID string, res *reservation) {
+	rec := ReservationRecord{
+		MatchID:    matchID,
+		ServerID:   res.serverID,
+		Allocation: res.allocation,
+		ReservedAt: res.reservedAt,
+		Confirmed:  res.confirmed,
+	}
+	if err := r.store.PutReservation(rec); err != nil {
+		r.storeErrors++
+	}
+}
+
+// dropReservationLocked frees matchID's reservation and reports whether it had one.
+// Callers hold r.mu.
+func (r *Registry) dropReservationLocked(matchID string) bool {
+	if _, ok := r.reservations[matchID]; !ok {
+		return false
+	}
+	delete(r.reservations, matchID)
+	if err := r.store.DeleteReservation(matchID); err != nil {
+		r.storeErrors++
+	}
+	return true
+}
+
+func (r *Registry) pickLocked(region string) *server {
+	now := r.now()
+	var best, fallback *server
+	bestFree, fallbackFree := 0, 0
+	for id, s := range r.servers {
+		if now.Sub(s.lastHeartbeat) > r.serverTTL {
+			continue
+		}
+		free := s.info.Capacity - r.reservedLocked(id)
+		if free <= 0 {
+			continue
+		}
+		if s.info.Region == region {
+			if free > bestFree || (free == bestFree && best != nil && id < best.info.ServerID) {
+				best, bestFree = s, free
+			}
+			continue
+		}
+		if free > fallbackFree || (free == fallbackFree && fallback != nil && id < fallback.info.ServerID) {
+			fallback, fallbackFree = s, free
+		}
+	}
+	if best != nil {
+		return best
+	}
+	return fallback
+}
+
+func (r *Registry) reservedLocked(serverID string) int {
+	n := 0
+	for _, res := range r.reservations {
+		if res.serverID == serverID {
+			n++
+		}
+	}
+	return n
+}
+
+// HTTPDispatcher posts allocations to the game server's control endpoint, signed with the
+// fleet key so only the matchmaker can create matches there.
+func HTTPDispatcher(client *http.Client, key []byte) Dispatcher {
+	return func(server types.GameServerRegistration, a types.MatchAllocation) error {
+		buf, err := json.Marshal(a)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequest(http.MethodPost, server.ControlURL+"/v1/matches", bytes.NewReader(buf))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(signing.Header, signing.Sign(key, buf))
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("fleet: allocation rejected by %s: status %d", server.ServerID, resp.StatusCode)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,198 @@
+package fleet
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"projectvelocity/backend/internal/shared/signing"
+	"projectvelocity/backend/internal/shared/types"
+)
+
+type clock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *clock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *clock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+func newTestRegistry(dispatch Dispatcher) (*Registry, *clock) {
+	c := &clock{t: time.Unix(1_700_000_000, 0)}
+	r := NewRegistry(dispatch)
+	r.now = c.now
+	return r, c
+}
+
+func TestAllocatePrefersRegionAndRespectsCapacity(t *testing.T) {
+	r, _ := newTestRegistry(nil)
+	_ = r.Register(types.GameServerRegistration{ServerID: "eu-1", Region: "eu-west", PublicAddr: "ws://eu/ws", Capacity: 4})
+	_ = r.Register(types.GameServerRegistration{ServerID: "us-1", Region: "us-east", PublicAddr: "ws://us/ws", Capacity: 1})
+
+	addr, err := r.Allocate(types.MatchAllocation{MatchID: "m1", Region: "us-east"})
+	if err != nil || addr != "ws://us/ws" {
+		t.Fatalf("expected in-region server, got=%q err=%v", addr, err)
+	}
+	addr, err = r.Allocate(types.MatchAllocation{MatchID: "m2", Region: "us-east"})
+	if err != nil || addr != "ws://eu/ws" {
+		t.Fatalf("expected fallback once region is full, got=%q err=%v", addr, err)
+	}
+	for i := range 3 {
+		if _, err := r.Allocate(types.MatchAllocation{MatchID: "x" + string(rune('a'+i)), Region: "eu-west"}); err != nil {
+			t.Fatalf("unexpected allocation failure: %v", err)
+		}
+	}
+	if _, err := r.Allocate(types.MatchAllocation{MatchID: "m9", Region: "eu-west"}); !errors.Is(err, ErrNoCapacity) {
+		t.Fatalf("expected no capacity, got=%v", err)
+	}
+}
+
+func TestDeadServerRollsBackReservations(t *testing.T) {
+	r, c := newTestRegistry(nil)
+	var rolled []string
+	r.OnRollback(func(matchID string) { rolled = append(rolled, matchID) })
+	_ = r.Register(types.GameServerRegistration{ServerID: "gs-1", Region: "us-east", PublicAddr: "ws://gs1/ws", Capacity: 2})
+	if _, err := r.Allocate(types.MatchAllocation{MatchID: "m1", Region: "us-east"}); err != nil {
+		t.Fatalf("allocate failed: %v", err)
+	}
+
+	c.advance(DefaultServerTTL + time.Second)
+	r.Reap()
+	if len(rolled) != 1 || rolled[0] != "m1" {
+		t.Fatalf("expected m1 rolled back, got=%v", rolled)
+	}
+	if len(r.Servers()) != 0 {
+		t.Fatal("expected dead server removed")
+	}
+}
+
+func TestHeartbeatConfirmsAndReleasesFinishedMatches(t *testing.T) {
+	r, c := newTestRegistry(nil)
+	var rolled []string
+	r.OnRollback(func(matchID string) { rolled = append(rolled, matchID) })
+	_ = r.Register(types.GameServerRegistration{ServerID: "gs-1", Region: "us-east", PublicAddr: "ws://gs1/ws", Capacity: 1})
+	_, _ = r.Allocate(types.MatchAllocation{MatchID: "m1", Region: "us-east"})
+	_ = r.Heartbeat(types.GameServerHeartbeat{ServerID: "gs-1", Matches: []string{"m1"}})
+
+	c.advance(DefaultReservationTTL + time.Second)
+	_ = r.Heartbeat(types.GameServerHeartbeat{ServerID: "gs-1", Matches: []string{"m1"}})
+	r.Reap()
+	if got := r.Servers()[0].Reserved; got != 1 {
+		t.Fatalf("expected confirmed reservation kept, got=%d", got)
+	}
+
+	_ = r.Heartbeat(types.GameServerHeartbeat{ServerID: "gs-1"})
+	r.Reap()
+	if got := r.Servers()[0].Reserved; got != 0 {
+		t.Fatalf("expected finished match released, got=%d", got)
+	}
+	if len(rolled) != 0 {
+		t.Fatalf("expected no rollback for finished match, got=%v", rolled)
+	}
+}
+
+func TestAnotherRegistryResumesReservationsFromTheStore(t *testing.T) {
+	store := NewMemoryStore()
+	r, c := newTestRegistry(nil)
+	if err := r.SetStore(store); err != nil {
+		t.Fatal(err)
+	}
+	_ = r.Register(types.GameServerRegistration{ServerID: "gs-1", Region: "us-east", PublicAddr: "ws://gs1/ws", Capacity: 2})
+	_, _ = r.Allocate(types.MatchAllocation{MatchID: "m1", Region: "us-east"})
+	_, _ = r.Allocate(types.MatchAllocation{MatchID: "m2", Region: "us-east"})
+	_ = r.Heartbeat(types.GameServerHeartbeat{ServerID: "gs-1", Matches: []string{"m1"}})
+
+	// The holder dies well after the server's last heartbeat; its successor gives the
+	// server a fresh window to check in rather than rolling everything back.
+	c.advance(DefaultServerTTL - time.Second)
+	next, _ := newTestRegistry(nil)
+	next.now = c.now
+	var rolled []string
+	next.OnRollback(func(matchID string) { rolled = append(rolled, matchID) })
+	if err := next.SetStore(store); err != nil {
+		t.Fatal(err)
+	}
+	c.advance(2 * time.Second)
+	next.Reap()
+	servers := next.Servers()
+	if len(servers) != 1 || servers[0].Reserved != 2 || servers[0].Hosted != 1 || len(rolled) != 0 {
+		t.Fatalf("expected the server and both reservations back, got=%+v rolled=%v", servers, rolled)
+	}
+	c.advance(DefaultReservationTTL)
+	if err := next.Heartbeat(types.GameServerHeartbeat{ServerID: "gs-1", Matches: []string{"m1"}}); err != nil {
+		t.Fatalf("expected the restored server to be known, got=%v", err)
+	}
+	next.Reap()
+	if len(rolled) != 1 || rolled[0] != "m2" {
+		t.Fatalf("expected only the match that never showed up rolled back, got=%v", rolled)
+	}
+	next.Release("m1")
+	if _, reservations, _ := store.Load(); len(reservations) != 0 {
+		t.Fatalf("expected released reservations gone from the store, got=%+v", reservations)
+	}
+}
+
+func TestFailedDispatchRollsBack(t *testing.T) {
+	done := make(chan string, 1)
+	r, _ := newTestRegistry(func(types.GameServerRegistration, types.MatchAllocation) error {
+		return errors.New("connection refused")
+	})
+	r.OnRollback(func(matchID string) { done <- matchID })
+	_ = r.Register(types.GameServerRegistration{ServerID: "gs-1", Region: "us-east", PublicAddr: "ws://gs1/ws", Capacity: 1})
+	if _, err := r.Allocate(types.MatchAllocation{MatchID: "m1", Region: "us-east"}); err != nil {
+		t.Fatalf("allocate failed: %v", err)
+	}
+	select {
+	case id := <-done:
+		if id != "m1" {
+			t.Fatalf("unexpected rollback id=%s", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected rollback after failed dispatch")
+	}
+	if got := r.Servers()[0].Reserved; got != 0 {
+		t.Fatalf("expected slot freed, got=%d", got)
+	}
+}
+
+func TestHeartbeatFromUnknownServer(t *testing.T) {
+	r, _ := newTestRegistry(nil)
+	if err := r.Heartbeat(types.GameServerHeartbeat{ServerID: "ghost"}); !errors.Is(err, ErrUnknownServer) {
+		t.Fatalf("expected unknown server, got=%v", err)
+	}
+}
+
+func TestHTTPDispatcherSignsAllocations(t *testing.T) {
+	key := []byte("fleet-test-key")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := signing.Verify(key, body, r.Header.Get(signing.Header)); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	gs := types.GameServerRegistration{ServerID: "gs-1", ControlURL: srv.URL}
+	a := types.MatchAllocation{MatchID: "m1", Players: []string{"p1"}}
+	if err := HTTPDispatcher(srv.Client(), key)(gs, a); err != nil {
+		t.Fatalf("expected the signed allocation to be accepted: %v", err)
+	}
+	if err := HTTPDispatcher(srv.Client(), []byte("other-key"))(gs, a); err == nil {
+		t.Fatal("expected an allocation signed with the wrong key to be rejected")
+	}
+}
@@ -0,0 +1,91 @@
+package fleet
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"projectvelocity/backend/internal/shared/types"
+)
+
+// Store persists the fleet so reservations outlive the matchmaker holding them.
+// Registry writes through on every change while holding its lock, so implementations
+// must not call back into it.
+type Store interface {
+	// Load returns every saved server and reservation.
+	Load() ([]ServerRecord, []ReservationRecord, error)
+	// PutServer saves rec, replacing any earlier record for the same server.
+	PutServer(rec ServerRecord) error
+	DeleteServer(serverID string) error
+	// PutReservation saves rec, replacing any earlier record for the same match.
+	PutReservation(rec ReservationRecord) error
+	DeleteReservation(matchID string) error
+	Close() error
+}
+
+// ServerRecord is the durable form of a registered game server and the matches it last
+// reported.
+type ServerRecord struct {
+	Info   types.GameServerRegistration `json:"info"`
+	Hosted []string                     `json:"hosted,omitempty"`
+}
+
+// ReservationRecord is the durable form of a match slot reserved on a server.
+type ReservationRecord struct {
+	MatchID    string                `json:"match_id"`
+	ServerID   string                `json:"server_id"`
+	Allocation types.MatchAllocation `json:"allocation"`
+	ReservedAt time.Time             `json:"reserved_at"`
+	Confirmed  bool                  `json:"confirmed,omitempty"`
+}
+
+// MemoryStore keeps records in process. It is the default store, so the fleet lasts
+// only as long as the matchmaker does.
+type MemoryStore struct {
+	mu           sync.Mutex
+	servers      map[string]ServerRecord
+	reservations map[string]ReservationRecord
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{servers: make(map[string]ServerRecord), reservations: make(map[string]ReservationRecord)}
+}
+
+func (s *MemoryStore) Load() ([]ServerRecord, []ReservationRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Collect(maps.Values(s.servers)), slices.Collect(maps.Values(s.reservations)), nil
+}
+
+func (s *MemoryStore) PutServer(rec ServerRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.servers[rec.Info.ServerID] = rec
+	return nil
+}
+
+func (s *MemoryStore) DeleteServer(serverID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.servers, serverID)
+	return nil
+}
+
+func (s *MemoryStore) PutReservation(rec ReservationRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reservations[rec.MatchID] = rec
+	return nil
+}
+
+func (s *MemoryStore) DeleteReservation(matchID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.reservations, matchID)
+	return nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,513 @@
+package lobby
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"projectvelocity/backend/internal/matchmaking"
+	"projectvelocity/backend/internal/shared/types"
+	"projectvelocity/backend/internal/simulation"
+)
+
+// CodeLength is the number of characters in a join code.
+const CodeLength = 6
+
+// codeAlphabet leaves out characters that are easy to misread: 0/O and 1/I.
+const codeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// Limits on lobby settings.
+const (
+	MaxTeamSize    = 4
+	MinMatchLength = time.Minute
+	MaxMatchLength = 20 * time.Minute
+)
+
+// Lobby statuses.
+const (
+	StatusOpen     = "open"
+	StatusStarting = "starting"
+	StatusStarted  = "started"
+)
+
+var (
+	ErrNotFound       = errors.New("lobby: lobby not found")
+	ErrAlreadyInLobby = errors.New("lobby: player is already in a lobby")
+	ErrNotInLobby     = errors.New("lobby: player is not in a lobby")
+	ErrNotHost        = errors.New("lobby: only the host can do that")
+	ErrFull           = errors.New("lobby: no room on that team")
+	ErrStarted        = errors.New("lobby: match already started")
+	ErrBadSettings    = errors.New("lobby: invalid settings")
+	ErrNotReady       = errors.New("lobby: both teams need players or bots")
+	ErrNoServer       = errors.New("lobby: no game server available")
+)
+
+type entry struct {
+	types.Lobby
+	// activeAt is the last time anyone changed the lobby, for Expire.
+	activeAt time.Time
+}
+
+// Manager holds private lobbies in memory, indexed by join code and by member, and
+// writes every change through to its store.
+type Manager struct {
+	mu        sync.Mutex
+	lobbies   map[string]*entry
+	byCode    map[string]string
+	byPlayer  map[string]string
+	byMatch   map[string]string
+	playlists *matchmaking.PlaylistRegistry
+	allocator matchmaking.Allocator
+	seq       uint64
+	now       func() time.Time
+	store     Store
+	// storeErrors counts failed writes to store.
+	storeErrors uint64
+}
+
+// NewManager creates an empty lobby manager backed by a MemoryStore. Settings name
+// playlists from playlists, and started matches get a game server from allocator.
+func NewManager(playlists *matchmaking.PlaylistRegistry, allocator matchmaking.Allocator) *Manager {
+	return &Manager{
+		lobbies:   make(map[string]*entry),
+		byCode:    make(map[string]string),
+		byPlayer:  make(map[string]string),
+		byMatch:   make(map[string]string),
+		playlists: playlists,
+		allocator: allocator,
+		now:       func() time.Time { return time.Now().UTC() },
+		store:     NewMemoryStore(),
+	}
+}
+
+// SetStore replaces the manager's lobbies with those saved in s and makes s the
+// write-through store for later changes. A lobby that was still waiting for its server
+// is reopened, since the allocation's outcome was never saved.
+func (m *Manager) SetStore(s Store) error {
+	records, err := s.Load()
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.store = s
+	clear(m.lobbies)
+	clear(m.byCode)
+	clear(m.byPlayer)
+	clear(m.byMatch)
+	for _, rec := range records {
+		e := &entry{Lobby: rec.Lobby, activeAt: rec.ActiveAt}
+		if e.Status == StatusStarting {
+			e.Status = StatusOpen
+		}
+		m.lobbies[e.LobbyID] = e
+		m.byCode[e.Code] = e.LobbyID
+		for _, mem := range e.Members {
+			m.byPlayer[mem.PlayerID] = e.LobbyID
+		}
+		if e.Assignment != nil {
+			m.byMatch[e.Assignment.MatchID] = e.LobbyID
+		}
+	}
+	return nil
+}
+
+// StoreErrors returns how many writes to the store have failed.
+func (m *Manager) StoreErrors() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.storeErrors
+}
+
+// Create opens a lobby hosted by host, who starts on orange.
+func (m *Manager) Create(host types.LobbyMember, settings types.LobbySettings) (types.Lobby, error) {
+	settings, err := m.validate(settings)
+	if err != nil {
+		return types.Lobby{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.byPlayer[host.PlayerID]; ok {
+		return types.Lobby{}, ErrAlreadyInLobby
+	}
+	code, err := m.newCodeLocked()
+	if err != nil {
+		return types.Lobby{}, err
+	}
+	now := m.now()
+	m.seq++
+	host.Team = "orange"
+	e := &entry{
+		Lobby: types.Lobby{
+			LobbyID:   fmt.Sprintf("lobby_%d_%d", now.UnixNano(), m.seq),
+			Code:      code,
+			HostID:    host.PlayerID,
+			Settings:  settings,
+			Members:   []types.LobbyMember{host},
+			Status:    StatusOpen,
+			CreatedAt: now.Unix(),
+		},
+		activeAt: now,
+	}
+	m.lobbies[e.LobbyID] = e
+	m.byCode[code] = e.LobbyID
+	m.byPlayer[host.PlayerID] = e.LobbyID
+	m.saveLocked(e)
+	return clone(e), nil
+}
+
+// Join adds member to the lobby with code, on whichever team has fewer players. Codes
+// are read out between players, so case and surrounding spaces are ignored.
+func (m *Manager) Join(code string, member types.LobbyMember) (types.Lobby, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id, ok := m.byCode[strings.ToUpper(strings.TrimSpace(code))]
+	if !ok {
+		return types.Lobby{}, ErrNotFound
+	}
+	e := m.lobbies[id]
+	if _, ok := m.byPlayer[member.PlayerID]; ok {
+		return types.Lobby{}, ErrAlreadyInLobby
+	}
+	if e.Status != StatusOpen {
+		return types.Lobby{}, ErrStarted
+	}
+	orange, blue := teamCount(e.Members, "orange"), teamCount(e.Members, "blue")
+	switch {
+	case orange <= blue && orange < e.Settings.TeamSize:
+		member.Team = "orange"
+	case blue < e.Settings.TeamSize:
+		member.Team = "blue"
+	default:
+		return types.Lobby{}, ErrFull
+	}
+	e.Members = append(e.Members, member)
+	e.activeAt = m.now()
+	m.byPlayer[member.PlayerID] = e.LobbyID
+	m.saveLocked(e)
+	return clone(e), nil
+}
+
+// Leave removes playerID from their lobby. A leaving host hands the lobby to the
+// longest-standing member, and the last player out closes it.
+func (m *Manager) Leave(playerID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id, ok := m.byPlayer[playerID]
+	if !ok {
+		return ErrNotInLobby
+	}
+	e := m.lobbies[id]
+	e.Members = slices.DeleteFunc(e.Members, func(mem types.LobbyMember) bool { return mem.PlayerID == playerID })
+	delete(m.byPlayer, playerID)
+	if len(e.Members) == 0 {
+		m.closeLocked(e)
+		return nil
+	}
+	if e.HostID == playerID {
+		e.HostID = e.Members[0].PlayerID
+	}
+	e.activeAt = m.now()
+	m.saveLocked(e)
+	return nil
+}
+
+// Move puts playerID on team. Only the host may move players, and only while the
+// lobby is open.
+func (m *Manager) Move(hostID, playerID, team string) (types.Lobby, error) {
+	if team != "orange" && team != "blue" {
+		return types.Lobby{}, ErrBadSettings
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, err := m.hostedBy(hostID)
+	if err != nil {
+		return types.Lobby{}, err
+	}
+	i := slices.IndexFunc(e.Members, func(mem types.LobbyMember) bool { return mem.PlayerID == playerID })
+	if i < 0 {
+		return types.Lobby{}, ErrNotInLobby
+	}
+	if e.Members[i].Team != team {
+		if teamCount(e.Members, team) >= e.Settings.TeamSize {
+			return types.Lobby{}, ErrFull
+		}
+		e.Members[i].Team = team
+	}
+	e.activeAt = m.now()
+	m.saveLocked(e)
+	return clone(e), nil
+}
+
+// Update replaces the lobby's settings. The current teams must fit the new team size.
+func (m *Manager) Update(hostID string, settings types.LobbySettings) (types.Lobby, error) {
+	settings, err := m.validate(settings)
+	if err != nil {
+		return types.Lobby{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, err := m.hostedBy(hostID)
+	if err != nil {
+		return types.Lobby{}, err
+	}
+	if teamCount(e.Members, "orange") > settings.TeamSize || teamCount(e.Members, "blue") > settings.TeamSize {
+		return types.Lobby{}, ErrFull
+	}
+	e.Settings = settings
+	e.activeAt = m.now()
+	m.saveLocked(e)
+	return clone(e), nil
+}
+
+// Start allocates a game server for exactly the lobby's roster and settings. The
+// lobby is locked against changes while the allocation is in flight and reopens if
+// it fails.
+func (m *Manager) Start(hostID string) (types.Lobby, error) {
+	m.mu.Lock()
+	e, err := m.hostedBy(hostID)
+	if err != nil {
+		m.mu.Unlock()
+		return types.Lobby{}, err
+	}
+	alloc, ok := allocation(e)
+	if !ok {
+		m.mu.Unlock()
+		return types.Lobby{}, ErrNotReady
+	}
+	e.Status = StatusStarting
+	m.mu.Unlock()
+
+	// Allocation may call out to a game server, so it runs without the lock.
+	addr, allocErr := m.allocator.Allocate(alloc)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	e.activeAt = now
+	if _, open := m.lobbies[e.LobbyID]; !open {
+		// Everyone left while the server was being allocated.
+		return types.Lobby{}, ErrNotFound
+	}
+	if allocErr != nil {
+		e.Status = StatusOpen
+		m.saveLocked(e)
+		return types.Lobby{}, fmt.Errorf("%w: %v", ErrNoServer, allocErr)
+	}
+	e.Status = StatusStarted
+	e.Assignment = &types.MatchAssignment{
+		MatchID:        alloc.MatchID,
+		Region:         alloc.Region,
+		Playlist:       alloc.Playlist,
+		Players:        alloc.Players,
+		Teams:          alloc.Teams,
+		BotFill:        alloc.BotFill,
+		Bots:           alloc.Bots,
+		Private:        true,
+		Physics:        alloc.Physics,
+		MatchLengthSec: alloc.MatchLengthSec,
+		ServerAddr:     addr,
+		FoundAtUnix:    now.Unix(),
+	}
+	m.byMatch[alloc.MatchID] = e.LobbyID
+	m.saveLocked(e)
+	return clone(e), nil
+}
+
+// Reopen returns the lobby that started matchID to its open state once the match has
+// ended or its server never came up, so the host can start another. It reports
+// whether matchID belonged to a lobby.
+func (m *Manager) Reopen(matchID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id, ok := m.byMatch[matchID]
+	if !ok {
+		return false
+	}
+	delete(m.byMatch, matchID)
+	if e, ok := m.lobbies[id]; ok && e.Assignment != nil && e.Assignment.MatchID == matchID {
+		e.Status = StatusOpen
+		e.Assignment = nil
+		e.activeAt = m.now()
+		m.saveLocked(e)
+	}
+	return true
+}
+
+// Get returns the lobby playerID belongs to.
+func (m *Manager) Get(playerID string) (types.Lobby, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id, ok := m.byPlayer[playerID]
+	if !ok {
+		return types.Lobby{}, false
+	}
+	return clone(m.lobbies[id]), true
+}
+
+// Expire closes open lobbies nobody has touched for idle and returns how many it closed.
+// Lobbies with a match in progress are kept until the match ends.
+func (m *Manager) Expire(now time.Time, idle time.Duration) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for _, e := range m.lobbies {
+		if e.Status == StatusOpen && now.Sub(e.activeAt) > idle {
+			m.closeLocked(e)
+			n++
+		}
+	}
+	return n
+}
+
+// validate fills in defaults and checks settings against the playlists and physics
+// presets on offer.
+func (m *Manager) validate(s types.LobbySettings) (types.LobbySettings, error) {
+	if s.Playlist == "" {
+		s.Playlist = "casual-1v1"
+	}
+	playlist, ok := m.playlists.Get(s.Playlist)
+	if !ok {
+		return s, fmt.Errorf("%w: unknown playlist %q", ErrBadSettings, s.Playlist)
+	}
+	if s.TeamSize == 0 {
+		s.TeamSize = playlist.TeamSize
+	}
+	if s.TeamSize < 1 || s.TeamSize > MaxTeamSize {
+		return s, fmt.Errorf("%w: team size must be 1 to %d", ErrBadSettings, MaxTeamSize)
+	}
+	if s.Region == "" {
+		s.Region = "us-east"
+	}
+	if s.Physics == "" {
+		s.Physics = simulation.PhysicsStandard
+	}
+	if _, ok := simulation.PhysicsPreset(s.Physics); !ok {
+		return s, fmt.Errorf("%w: unknown physics preset %q", ErrBadSettings, s.Physics)
+	}
+	if length := time.Duration(s.MatchLengthSec) * time.Second; s.MatchLengthSec != 0 && (length < MinMatchLength || length > MaxMatchLength) {
+		return s, fmt.Errorf("%w: match length must be %s to %s", ErrBadSettings, MinMatchLength, MaxMatchLength)
+	}
+	switch s.BotDifficulty {
+	case "":
+		if s.Bots {
+			s.BotDifficulty = matchmaking.BotPro
+		}
+	case matchmaking.BotRookie, matchmaking.BotPro, matchmaking.BotAllStar:
+	default:
+		return s, fmt.Errorf("%w: unknown bot difficulty %q", ErrBadSettings, s.BotDifficulty)
+	}
+	return s, nil
+}
+
+// newCodeLocked draws a join code no live lobby is using.
+func (m *Manager) newCodeLocked() (string, error) {
+	buf := make([]byte, CodeLength)
+	for {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for i, b := range buf {
+			buf[i] = codeAlphabet[int(b)%len(codeAlphabet)]
+		}
+		if _, taken := m.byCode[string(buf)]; !taken {
+			return string(buf), nil
+		}
+	}
+}
+
+// hostedBy returns the open lobby hostID hosts.
+func (m *Manager) hostedBy(hostID string) (*entry, error) {
+	id, ok := m.byPlayer[hostID]
+	if !ok {
+		return nil, ErrNotInLobby
+	}
+	e := m.lobbies[id]
+	if e.HostID != hostID {
+		return nil, ErrNotHost
+	}
+	if e.Status != StatusOpen {
+		return nil, ErrStarted
+	}
+	return e, nil
+}
+
+func (m *Manager) closeLocked(e *entry) {
+	for _, mem := range e.Members {
+		delete(m.byPlayer, mem.PlayerID)
+	}
+	if e.Assignment != nil {
+		delete(m.byMatch, e.Assignment.MatchID)
+	}
+	delete(m.byCode, e.Code)
+	delete(m.lobbies, e.LobbyID)
+	if err := m.store.Delete(e.LobbyID); err != nil {
+		m.storeErrors++
+	}
+}
+
+// saveLocked writes e through to the store. Callers hold m.mu.
+func (m *Manager) saveLocked(e *entry) {
+	if err := m.store.Put(Record{Lobby: clone(e), ActiveAt: e.activeAt}); err != nil {
+		m.storeErrors++
+	}
+}
+
+// allocation builds the match request for the lobby's roster: members on the teams the
+// host chose and, with bots on, a bot in every empty slot. It reports false when a
+// team would be empty.
+func allocation(e *entry) (types.MatchAllocation, bool) {
+	a := types.MatchAllocation{
+		MatchID:        fmt.Sprintf("match_%s_%d", e.Code, time.Now().UTC().UnixNano()),
+		Region:         e.Settings.Region,
+		Playlist:       e.Settings.Playlist,
+		TeamSize:       e.Settings.TeamSize,
+		Teams:          map[string][]string{},
+		Private:        true,
+		Physics:        e.Settings.Physics,
+		MatchLengthSec: e.Settings.MatchLengthSec,
+	}
+	for _, team := range []string{"orange", "blue"} {
+		roster := []string{}
+		for _, mem := range e.Members {
+			if mem.Team == team {
+				roster = append(roster, mem.PlayerID)
+				a.Players = append(a.Players, mem.PlayerID)
+			}
+		}
+		for e.Settings.Bots && len(roster) < e.Settings.TeamSize {
+			roster = append(roster, "bot")
+			a.Bots = append(a.Bots, types.BotSlot{Team: team, Difficulty: e.Settings.BotDifficulty})
+			a.BotFill = true
+		}
+		if len(roster) == 0 {
+			return types.MatchAllocation{}, false
+		}
+		a.Teams[team] = roster
+	}
+	return a, true
+}
+
+func teamCount(members []types.LobbyMember, team string) int {
+	n := 0
+	for _, mem := range members {
+		if mem.Team == team {
+			n++
+		}
+	}
+	return n
+}
+
+func clone(e *entry) types.Lobby {
+	out := e.Lobby
+	out.Members = slices.Clone(e.Members)
+	if e.Assignment != nil {
+		a := *e.Assignment
+		out.Assignment = &a
+	}
+	return out
+}
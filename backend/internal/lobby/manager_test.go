@@ -0,0 +1,206 @@
+package lobby
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"projectvelocity/backend/internal/matchmaking"
+	"projectvelocity/backend/internal/shared/types"
+)
+
+type recordingAllocator struct {
+	got []types.MatchAllocation
+	err error
+}
+
+func (a *recordingAllocator) Allocate(m types.MatchAllocation) (string, error) {
+	a.got = append(a.got, m)
+	return "ws://game:9003/ws", a.err
+}
+
+func newTestManager() (*Manager, *recordingAllocator) {
+	alloc := &recordingAllocator{}
+	return NewManager(matchmaking.DefaultPlaylists(), alloc), alloc
+}
+
+func TestJoinByCodeBalancesTeamsAndCapsThem(t *testing.T) {
+	m, _ := newTestManager()
+	l, err := m.Create(types.LobbyMember{PlayerID: "host"}, types.LobbySettings{Playlist: "casual-2v2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.Code) != CodeLength || strings.ContainsAny(l.Code, "01IO") || l.Settings.TeamSize != 2 {
+		t.Fatalf("expected a readable code and the playlist's team size, got=%+v", l)
+	}
+	if _, err := m.Join("NOPE00", types.LobbyMember{PlayerID: "a"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected unknown code to fail, got=%v", err)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		if l, err = m.Join(l.Code, types.LobbyMember{PlayerID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if teamCount(l.Members, "orange") != 2 || teamCount(l.Members, "blue") != 2 {
+		t.Fatalf("expected joiners spread across teams, got=%+v", l.Members)
+	}
+	if _, err := m.Join(l.Code, types.LobbyMember{PlayerID: "d"}); !errors.Is(err, ErrFull) {
+		t.Fatalf("expected a full lobby to refuse joins, got=%v", err)
+	}
+	if _, err := m.Join(l.Code, types.LobbyMember{PlayerID: "a"}); !errors.Is(err, ErrAlreadyInLobby) {
+		t.Fatalf("expected a member to be refused a second seat, got=%v", err)
+	}
+}
+
+func TestHostMovesPlayersAndStartsWithExactRoster(t *testing.T) {
+	m, alloc := newTestManager()
+	l, _ := m.Create(types.LobbyMember{PlayerID: "host"}, types.LobbySettings{
+		Playlist: "casual-3v3", Physics: "low_gravity", MatchLengthSec: 120, Bots: true, BotDifficulty: matchmaking.BotAllStar,
+	})
+	m.Join(l.Code, types.LobbyMember{PlayerID: "a"})
+	m.Join(l.Code, types.LobbyMember{PlayerID: "b"})
+
+	if _, err := m.Move("a", "b", "orange"); !errors.Is(err, ErrNotHost) {
+		t.Fatalf("expected only the host to move players, got=%v", err)
+	}
+	if _, err := m.Move("host", "a", "orange"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Move("host", "b", "orange"); err != nil {
+		t.Fatal(err)
+	}
+
+	started, err := m.Start("host")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if started.Status != StatusStarted || started.Assignment == nil || !started.Assignment.Private {
+		t.Fatalf("expected a started private match, got=%+v", started)
+	}
+	a := alloc.got[0]
+	if !slices.Equal(a.Teams["orange"], []string{"host", "a", "b"}) || !slices.Equal(a.Teams["blue"], []string{"bot", "bot", "bot"}) {
+		t.Fatalf("expected the host's teams with bots on the empty side, got=%v", a.Teams)
+	}
+	if len(a.Bots) != 3 || a.Bots[0].Difficulty != matchmaking.BotAllStar || a.Physics != "low_gravity" || a.MatchLengthSec != 120 {
+		t.Fatalf("expected the lobby settings in the allocation, got=%+v", a)
+	}
+	if _, err := m.Join(l.Code, types.LobbyMember{PlayerID: "late"}); !errors.Is(err, ErrStarted) {
+		t.Fatalf("expected a started lobby to refuse joins, got=%v", err)
+	}
+
+	if !m.Reopen(a.MatchID) {
+		t.Fatal("expected the match to belong to the lobby")
+	}
+	if got, _ := m.Get("a"); got.Status != StatusOpen || got.Assignment != nil {
+		t.Fatalf("expected the lobby to reopen after its match, got=%+v", got)
+	}
+}
+
+func TestStartNeedsBothTeamsAndReopensWhenNoServer(t *testing.T) {
+	m, alloc := newTestManager()
+	l, _ := m.Create(types.LobbyMember{PlayerID: "host"}, types.LobbySettings{})
+	if _, err := m.Start("host"); !errors.Is(err, ErrNotReady) {
+		t.Fatalf("expected an empty blue team without bots to block the start, got=%v", err)
+	}
+	m.Join(l.Code, types.LobbyMember{PlayerID: "a"})
+	alloc.err = errors.New("fleet full")
+	if _, err := m.Start("host"); !errors.Is(err, ErrNoServer) {
+		t.Fatalf("expected allocation failure to surface, got=%v", err)
+	}
+	if got, _ := m.Get("host"); got.Status != StatusOpen {
+		t.Fatalf("expected the lobby to stay open, got=%s", got.Status)
+	}
+}
+
+func TestSettingsAreValidatedAgainstTheRoster(t *testing.T) {
+	m, _ := newTestManager()
+	if _, err := m.Create(types.LobbyMember{PlayerID: "x"}, types.LobbySettings{Physics: "moon"}); !errors.Is(err, ErrBadSettings) {
+		t.Fatalf("expected unknown physics preset to fail, got=%v", err)
+	}
+	if _, err := m.Create(types.LobbyMember{PlayerID: "x"}, types.LobbySettings{MatchLengthSec: 5}); !errors.Is(err, ErrBadSettings) {
+		t.Fatalf("expected a too-short match to fail, got=%v", err)
+	}
+	l, _ := m.Create(types.LobbyMember{PlayerID: "host"}, types.LobbySettings{TeamSize: 2})
+	m.Join(l.Code, types.LobbyMember{PlayerID: "a"})
+	m.Move("host", "a", "orange")
+	if _, err := m.Update("host", types.LobbySettings{TeamSize: 1}); !errors.Is(err, ErrFull) {
+		t.Fatalf("expected shrinking below a team's size to fail, got=%v", err)
+	}
+}
+
+func TestHostLeavingHandsOverAndIdleLobbiesExpire(t *testing.T) {
+	m, _ := newTestManager()
+	l, _ := m.Create(types.LobbyMember{PlayerID: "host"}, types.LobbySettings{})
+	m.Join(l.Code, types.LobbyMember{PlayerID: "a"})
+	if err := m.Leave("host"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := m.Get("a"); got.HostID != "a" {
+		t.Fatalf("expected the remaining member to host, got=%q", got.HostID)
+	}
+	if n := m.Expire(time.Now().UTC().Add(time.Hour), 30*time.Minute); n != 1 {
+		t.Fatalf("expected the idle lobby to close, closed=%d", n)
+	}
+	if _, err := m.Join(l.Code, types.LobbyMember{PlayerID: "b"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected the closed lobby's code to be freed, got=%v", err)
+	}
+}
+
+func TestJoinIgnoresCodeCaseAndSpaces(t *testing.T) {
+	m, _ := newTestManager()
+	l, err := m.Create(types.LobbyMember{PlayerID: "host"}, types.LobbySettings{Playlist: "casual-2v2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	typed := "  " + strings.ToLower(l.Code) + " \n"
+	if got, err := m.Join(typed, types.LobbyMember{PlayerID: "a"}); err != nil || got.LobbyID != l.LobbyID {
+		t.Fatalf("expected %q to find lobby %s, got=%+v err=%v", typed, l.Code, got, err)
+	}
+}
+
+func TestAnotherManagerResumesLobbiesFromTheStore(t *testing.T) {
+	store := NewMemoryStore()
+	m, _ := newTestManager()
+	if err := m.SetStore(store); err != nil {
+		t.Fatal(err)
+	}
+	started, _ := m.Create(types.LobbyMember{PlayerID: "host"}, types.LobbySettings{Bots: true})
+	if _, err := m.Start("host"); err != nil {
+		t.Fatal(err)
+	}
+	open, _ := m.Create(types.LobbyMember{PlayerID: "h2"}, types.LobbySettings{Playlist: "casual-2v2"})
+	if _, err := m.Join(open.Code, types.LobbyMember{PlayerID: "guest"}); err != nil {
+		t.Fatal(err)
+	}
+	closed, _ := m.Create(types.LobbyMember{PlayerID: "h3"}, types.LobbySettings{})
+	if err := m.Leave("h3"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The holder dies; a fresh manager picks up from the store.
+	next, _ := newTestManager()
+	if err := next.SetStore(store); err != nil {
+		t.Fatal(err)
+	}
+	if l, ok := next.Get("guest"); !ok || l.LobbyID != open.LobbyID || l.HostID != "h2" {
+		t.Fatalf("expected the open lobby back, got=%+v ok=%v", l, ok)
+	}
+	if _, err := next.Join(" "+strings.ToLower(open.Code), types.LobbyMember{PlayerID: "late"}); err != nil {
+		t.Fatalf("expected the restored code to work, got=%v", err)
+	}
+	l, _ := next.Get("host")
+	if l.LobbyID != started.LobbyID || l.Status != StatusStarted || l.Assignment == nil {
+		t.Fatalf("expected the started lobby back with its match, got=%+v", l)
+	}
+	if !next.Reopen(l.Assignment.MatchID) {
+		t.Fatal("expected the restored match to reopen its lobby")
+	}
+	if _, err := next.Join(closed.Code, types.LobbyMember{PlayerID: "x"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected the closed lobby to stay closed, got=%v", err)
+	}
+	if next.StoreErrors() != 0 {
+		t.Fatalf("expected no store errors, got=%d", next.StoreErrors())
+	}
+}
@@ -0,0 +1,64 @@
+package lobby
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"projectvelocity/backend/internal/shared/types"
+)
+
+// Store persists lobbies so they outlive the matchmaker holding them. Manager writes
+// through on every change while holding its lock, so implementations must not call back
+// into it.
+type Store interface {
+	// Load returns every saved lobby.
+	Load() ([]Record, error)
+	// Put saves rec, replacing any earlier record for the same lobby.
+	Put(rec Record) error
+	// Delete forgets a closed lobby; deleting an unknown lobby is not an error.
+	Delete(lobbyID string) error
+	Close() error
+}
+
+// Record is the durable form of a lobby.
+type Record struct {
+	Lobby    types.Lobby `json:"lobby"`
+	ActiveAt time.Time   `json:"active_at"`
+}
+
+// MemoryStore keeps records in process. It is the default store, so lobbies last only as
+// long as the matchmaker does.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]Record
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]Record)}
+}
+
+func (s *MemoryStore) Load() ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Collect(maps.Values(s.records)), nil
+}
+
+func (s *MemoryStore) Put(rec Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[rec.Lobby.LobbyID] = rec
+	return nil
+}
+
+func (s *MemoryStore) Delete(lobbyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, lobbyID)
+	return nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
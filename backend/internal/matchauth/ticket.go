@@ -0,0 +1,84 @@
+package matchauth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"projectvelocity/backend/internal/shared/types"
+)
+
+// DefaultTTL is how long a join ticket stays valid after issue.
+const DefaultTTL = 90 * time.Second
+
+var (
+	ErrMalformed    = errors.New("matchauth: malformed ticket")
+	ErrBadSignature = errors.New("matchauth: bad signature")
+	ErrExpired      = errors.New("matchauth: ticket expired")
+	ErrNotRostered  = errors.New("matchauth: player not in match roster")
+)
+
+// Claims binds a player to a specific match until ExpiresAt.
+type Claims struct {
+	PlayerID    string   `json:"pid"`
+	DisplayName string   `json:"name"`
+	MatchID     string   `json:"mid"`
+	Players     []string `json:"roster"`
+	Region      string   `json:"region,omitempty"`
+	Playlist    string   `json:"playlist,omitempty"`
+	Team        string   `json:"team,omitempty"`
+	// Bots are the bot slots the match was formed with.
+	Bots []types.BotSlot `json:"bots,omitempty"`
+	// Private, Physics and MatchLengthSec carry a lobby match's settings.
+	Private        bool   `json:"private,omitempty"`
+	Physics        string `json:"physics,omitempty"`
+	MatchLengthSec int    `json:"len,omitempty"`
+	ExpiresAt      int64  `json:"exp"`
+}
+
+// Issue signs claims with key and returns a compact "payload.signature" token.
+func Issue(key []byte, c Claims) (string, error) {
+	body, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(body)
+	return payload + "." + sign(key, payload), nil
+}
+
+// Verify checks the signature, expiry and roster membership of token.
+func Verify(key []byte, token string, now time.Time) (Claims, error) {
+	payload, sig, ok := strings.Cut(token, ".")
+	if !ok || payload == "" || sig == "" {
+		return Claims{}, ErrMalformed
+	}
+	if !hmac.Equal([]byte(sig), []byte(sign(key, payload))) {
+		return Claims{}, ErrBadSignature
+	}
+	body, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return Claims{}, ErrMalformed
+	}
+	var c Claims
+	if err := json.Unmarshal(body, &c); err != nil || c.PlayerID == "" || c.MatchID == "" {
+		return Claims{}, ErrMalformed
+	}
+	if now.Unix() >= c.ExpiresAt {
+		return Claims{}, ErrExpired
+	}
+	if !slices.Contains(c.Players, c.PlayerID) {
+		return Claims{}, ErrNotRostered
+	}
+	return c, nil
+}
+
+func sign(key []byte, payload string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,55 @@
+package matchauth
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestIssueVerifyRoundTrip(t *testing.T) {
+	key := []byte("secret")
+	now := time.Now()
+	token, err := Issue(key, Claims{
+		PlayerID:  "p1",
+		MatchID:   "m1",
+		Players:   []string{"p1", "p2"},
+		ExpiresAt: now.Add(time.Minute).Unix(),
+	})
+	if err != nil {
+		t.Fatalf("issue failed: %v", err)
+	}
+	c, err := Verify(key, token, now)
+	if err != nil {
+		t.Fatalf("verify failed: %v", err)
+	}
+	if c.PlayerID != "p1" || c.MatchID != "m1" {
+		t.Fatalf("unexpected claims: %+v", c)
+	}
+}
+
+func TestVerifyRejections(t *testing.T) {
+	key := []byte("secret")
+	now := time.Now()
+	valid := Claims{PlayerID: "p1", MatchID: "m1", Players: []string{"p1"}, ExpiresAt: now.Add(time.Minute).Unix()}
+
+	token, _ := Issue(key, valid)
+	if _, err := Verify([]byte("other"), token, now); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("expected bad signature, got=%v", err)
+	}
+	if _, err := Verify(key, token+"x", now); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("expected tampered signature rejected, got=%v", err)
+	}
+	if _, err := Verify(key, "garbage", now); !errors.Is(err, ErrMalformed) {
+		t.Fatalf("expected malformed, got=%v", err)
+	}
+	if _, err := Verify(key, token, now.Add(2*time.Minute)); !errors.Is(err, ErrExpired) {
+		t.Fatalf("expected expired, got=%v", err)
+	}
+
+	outsider := valid
+	outsider.PlayerID = "intruder"
+	token, _ = Issue(key, outsider)
+	if _, err := Verify(key, token, now); !errors.Is(err, ErrNotRostered) {
+		t.Fatalf("expected not rostered, got=%v", err)
+	}
+}
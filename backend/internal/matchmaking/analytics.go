@@ -0,0 +1,212 @@
+package matchmaking
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+// AnalyticsWindow is how far back wait, spread and bot-fill figures look.
+const AnalyticsWindow = 15 * time.Minute
+
+// maxSamples caps the samples kept per bucket so a busy bucket's window stays cheap.
+const maxSamples = 2000
+
+// BucketStats summarises one bucket's queue and its recent matches.
+type BucketStats struct {
+	Bucket   string `json:"bucket"`
+	Region   string `json:"region"`
+	Playlist string `json:"playlist"`
+	// Tickets and Players count who is searching right now.
+	Tickets int `json:"tickets"`
+	Players int `json:"players"`
+	// Wait percentiles cover tickets from this bucket matched within AnalyticsWindow.
+	WaitP50Sec float64 `json:"wait_p50_sec"`
+	WaitP90Sec float64 `json:"wait_p90_sec"`
+	WaitP99Sec float64 `json:"wait_p99_sec"`
+	// Matches, spread and bot fill cover matches played in this bucket's region within
+	// AnalyticsWindow. Spread is the gap between the best and worst player's MMR.
+	Matches      int     `json:"matches"`
+	MMRSpreadP50 float64 `json:"mmr_spread_p50"`
+	MMRSpreadP90 float64 `json:"mmr_spread_p90"`
+	MMRSpreadMax int     `json:"mmr_spread_max"`
+	BotFillRate  float64 `json:"bot_fill_rate"`
+	// Totals count since start and never reset.
+	MatchesTotal  uint64 `json:"matches_total"`
+	BotFillsTotal uint64 `json:"bot_fills_total"`
+}
+
+type waitSample struct {
+	at   time.Time
+	wait time.Duration
+}
+
+type matchSample struct {
+	at      time.Time
+	spread  int
+	botFill bool
+}
+
+// analytics keeps recent samples per bucket. It is guarded by the QueueManager's lock.
+type analytics struct {
+	waits         map[string][]waitSample
+	matches       map[string][]matchSample
+	matchesTotal  map[string]uint64
+	botFillsTotal map[string]uint64
+}
+
+func newAnalytics() *analytics {
+	return &analytics{
+		waits:         make(map[string][]waitSample),
+		matches:       make(map[string][]matchSample),
+		matchesTotal:  make(map[string]uint64),
+		botFillsTotal: make(map[string]uint64),
+	}
+}
+
+// recordMatch notes a launched match: each ticket's wait counts toward its home
+// bucket, and the spread and bot fill toward the bucket the match was played in.
+func (a *analytics) recordMatch(m *pendingMatch, now time.Time) {
+	lo, hi := math.MaxInt, math.MinInt
+	for _, p := range members(m.tickets) {
+		lo = min(lo, p.MMR)
+		hi = max(hi, p.MMR)
+	}
+	for _, t := range m.tickets {
+		key := bucketKey(t.Region, t.Playlist)
+		a.waits[key] = trimSamples(append(a.waits[key], waitSample{at: now, wait: now.Sub(t.JoinedAt)}), now, func(s waitSample) time.Time { return s.at })
+	}
+	key := bucketKey(m.region, m.playlist.Name)
+	a.matches[key] = trimSamples(append(a.matches[key], matchSample{at: now, spread: hi - lo, botFill: m.botFill}), now, func(s matchSample) time.Time { return s.at })
+	a.matchesTotal[key]++
+	if m.botFill {
+		a.botFillsTotal[key]++
+	}
+}
+
+// trimSamples drops samples older than AnalyticsWindow and keeps at most maxSamples.
+func trimSamples[S any](samples []S, now time.Time, at func(S) time.Time) []S {
+	start := 0
+	for start < len(samples) && now.Sub(at(samples[start])) > AnalyticsWindow {
+		start++
+	}
+	start = max(start, len(samples)-maxSamples)
+	return samples[start:]
+}
+
+// BucketStats reports queue size, wait percentiles, MMR spread and bot-fill rate for
+// every bucket that has searching tickets or recent matches, ordered by bucket key.
+func (q *QueueManager) BucketStats() []BucketStats {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	now := q.now()
+
+	byKey := make(map[string]*BucketStats)
+	get := func(key string) *BucketStats {
+		s, ok := byKey[key]
+		if !ok {
+			region, playlist := splitKey(key)
+			s = &BucketStats{Bucket: key, Region: region, Playlist: playlist}
+			byKey[key] = s
+		}
+		return s
+	}
+	for key, bucket := range q.buckets {
+		for _, t := range bucket {
+			if t.Status == "searching" {
+				s := get(key)
+				s.Tickets++
+				s.Players += t.size()
+			}
+		}
+	}
+	for key, samples := range q.analytics.waits {
+		var waits []float64
+		for _, w := range samples {
+			if now.Sub(w.at) <= AnalyticsWindow {
+				waits = append(waits, w.wait.Seconds())
+			}
+		}
+		if len(waits) == 0 {
+			continue
+		}
+		s := get(key)
+		s.WaitP50Sec = percentile(waits, 0.5)
+		s.WaitP90Sec = percentile(waits, 0.9)
+		s.WaitP99Sec = percentile(waits, 0.99)
+	}
+	for key, samples := range q.analytics.matches {
+		s := get(key)
+		s.MatchesTotal = q.analytics.matchesTotal[key]
+		s.BotFillsTotal = q.analytics.botFillsTotal[key]
+		var spreads []float64
+		bots := 0
+		for _, m := range samples {
+			if now.Sub(m.at) > AnalyticsWindow {
+				continue
+			}
+			spreads = append(spreads, float64(m.spread))
+			s.MMRSpreadMax = max(s.MMRSpreadMax, m.spread)
+			if m.botFill {
+				bots++
+			}
+		}
+		if s.Matches = len(spreads); s.Matches > 0 {
+			s.MMRSpreadP50 = percentile(spreads, 0.5)
+			s.MMRSpreadP90 = percentile(spreads, 0.9)
+			s.BotFillRate = float64(bots) / float64(s.Matches)
+		}
+	}
+
+	out := make([]BucketStats, 0, len(byKey))
+	for _, s := range byKey {
+		out = append(out, *s)
+	}
+	slices.SortFunc(out, func(a, b BucketStats) int {
+		switch {
+		case a.Bucket < b.Bucket:
+			return -1
+		case a.Bucket > b.Bucket:
+			return 1
+		}
+		return 0
+	})
+	return out
+}
+
+// estimateWait guesses how much longer t will search. It takes the median of recent
+// waits in t's bucket that outlasted t's wait so far, and caps it at the point bots
+// would fill the match. It returns zero when there is nothing to go on.
+func (q *QueueManager) estimateWait(t *Ticket, now time.Time) time.Duration {
+	waited := now.Sub(t.JoinedAt)
+	var rest []float64
+	for _, s := range q.analytics.waits[bucketKey(t.Region, t.Playlist)] {
+		if now.Sub(s.at) <= AnalyticsWindow && s.wait > waited {
+			rest = append(rest, (s.wait - waited).Seconds())
+		}
+	}
+	var estimate time.Duration
+	if len(rest) > 0 {
+		estimate = time.Duration(percentile(rest, 0.5) * float64(time.Second))
+	}
+	playlist := q.playlists.lookup(t.Playlist)
+	if playlist.Bots.fills(2*playlist.TeamSize-t.size(), time.Duration(math.MaxInt64)) {
+		untilBots := max(time.Duration(playlist.Bots.WaitMS)*time.Millisecond-waited, time.Second)
+		if estimate == 0 || untilBots < estimate {
+			estimate = untilBots
+		}
+	}
+	return estimate
+}
+
+// estimateSec rounds an estimate up to whole seconds, so any estimate is at least 1.
+func estimateSec(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// percentile returns the nearest-rank p-th percentile of values, which it sorts.
+func percentile(values []float64, p float64) float64 {
+	slices.Sort(values)
+	i := int(math.Ceil(p*float64(len(values)))) - 1
+	return values[max(i, 0)]
+}
@@ -0,0 +1,78 @@
+package matchmaking
+
+import (
+	"testing"
+	"time"
+
+	"projectvelocity/backend/internal/shared/types"
+)
+
+func TestBucketStatsTracksWaitsSpreadAndBotFill(t *testing.T) {
+	q := NewQueueManager("ws://localhost:9003/ws")
+	q.SetReadyCheckTimeout(0)
+	backdate := func(ticketID string, by time.Duration) {
+		q.mu.Lock()
+		q.ticketIndex[ticketID].JoinedAt = time.Now().UTC().Add(-by)
+		q.mu.Unlock()
+	}
+
+	a := q.Join(types.QueueJoinRequest{PlayerID: "p1", Region: "us-east", Playlist: "ranked-1v1", MMR: 1000})
+	b := q.Join(types.QueueJoinRequest{PlayerID: "p2", Region: "us-east", Playlist: "ranked-1v1", MMR: 1100})
+	backdate(a.TicketID, 40*time.Second)
+	backdate(b.TicketID, 20*time.Second)
+	lonely := q.Join(types.QueueJoinRequest{PlayerID: "p3", Region: "us-east", Playlist: "casual-1v1", MMR: 1000})
+	backdate(lonely.TicketID, 5*time.Second)
+	waiting := q.Join(types.QueueJoinRequest{PlayerID: "p4", Region: "eu-west", Playlist: "ranked-1v1", MMR: 1000})
+	q.Process()
+
+	stats := map[string]BucketStats{}
+	for _, s := range q.BucketStats() {
+		stats[s.Bucket] = s
+	}
+	ranked := stats[bucketKey("us-east", "ranked-1v1")]
+	if ranked.Matches != 1 || ranked.MMRSpreadMax != 100 || ranked.BotFillRate != 0 {
+		t.Fatalf("expected one human match with a 100 MMR spread, got=%+v", ranked)
+	}
+	if ranked.WaitP50Sec < 19 || ranked.WaitP50Sec > 21 || ranked.WaitP90Sec < 39 || ranked.WaitP90Sec > 41 {
+		t.Fatalf("expected waits of 20s and 40s, got=%+v", ranked)
+	}
+	casual := stats[bucketKey("us-east", "casual-1v1")]
+	if casual.Matches != 1 || casual.BotFillRate != 1 || casual.BotFillsTotal != 1 {
+		t.Fatalf("expected the casual match filled with a bot, got=%+v", casual)
+	}
+	if eu := stats[bucketKey("eu-west", "ranked-1v1")]; eu.Tickets != 1 || eu.Players != 1 || eu.Matches != 0 {
+		t.Fatalf("expected one searching ticket in eu-west, got=%+v", eu)
+	}
+	if got := q.Poll(waiting.TicketID).EstimatedWaitSec; got != 0 {
+		t.Fatalf("expected no estimate without eu-west history, got=%d", got)
+	}
+}
+
+func TestEstimatedWaitUsesBucketHistory(t *testing.T) {
+	q := NewQueueManager("ws://localhost:9003/ws")
+	q.SetReadyCheckTimeout(0)
+	now := time.Now().UTC()
+	for _, wait := range []time.Duration{10 * time.Second, 30 * time.Second, 60 * time.Second} {
+		q.analytics.recordMatch(&pendingMatch{
+			region:   "us-east",
+			playlist: q.playlists.lookup("ranked-1v1"),
+			tickets:  []*Ticket{{Region: "us-east", Playlist: "ranked-1v1", JoinedAt: now.Add(-wait)}},
+		}, now)
+	}
+
+	join := q.Join(types.QueueJoinRequest{PlayerID: "p1", Region: "us-east", Playlist: "ranked-1v1", MMR: 1000})
+	if join.EstimatedWaitSec < 29 || join.EstimatedWaitSec > 31 {
+		t.Fatalf("expected the median wait on join, got=%d", join.EstimatedWaitSec)
+	}
+	q.mu.Lock()
+	q.ticketIndex[join.TicketID].JoinedAt = now.Add(-40 * time.Second)
+	q.mu.Unlock()
+	if got := q.Poll(join.TicketID).EstimatedWaitSec; got < 19 || got > 21 {
+		t.Fatalf("expected only longer waits to count once 40s in, got=%d", got)
+	}
+
+	casual := q.Join(types.QueueJoinRequest{PlayerID: "p2", Region: "us-east", Playlist: "casual-1v1", MMR: 1000})
+	if casual.EstimatedWaitSec != 4 {
+		t.Fatalf("expected the bot-fill deadline without history, got=%d", casual.EstimatedWaitSec)
+	}
+}
@@ -0,0 +1,232 @@
+package matchmaking
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PriorityWait is the fairness guarantee for old tickets: once a ticket has searched
+// this long it is placed in its best available match, oldest first, before the rest of
+// the pool is optimized, so it can never be traded away for a better overall batch.
+const PriorityWait = 45 * time.Second
+
+// Candidate scoring. Every match is worth matchValue, more than any quality term can
+// add or take away, so the optimizer always prefers more matches and only then better
+// ones. The quality terms are each normalized to about 0..1.
+const (
+	matchValue    = 10.0
+	mmrWeight     = 2.0
+	pingWeight    = 1.0
+	waitWeight    = 1.0
+	waitCreditCap = time.Minute
+)
+
+// searchBudget bounds the branch-and-bound search per playlist and cycle. The first
+// branch explored is the greedy best-score-first pick, so running out only means the
+// answer may not be proven optimal.
+const searchBudget = 20000
+
+// candidate is one match the optimizer could make.
+type candidate struct {
+	region  string
+	group   []*Ticket
+	players int
+	score   float64
+	// idx are the group's positions in the pool, for the search's bookkeeping.
+	idx []int
+}
+
+// optimize forms the best set of full matches it can find in pool, which must be
+// sorted oldest first. Tickets it leaves searching go on to the bot-fill pass.
+func (q *QueueManager) optimize(pool []*Ticket, playlist Playlist, now time.Time) {
+	size := 2 * playlist.TeamSize
+	cands := q.candidates(pool, playlist, now)
+	if len(cands) == 0 {
+		return
+	}
+	used := make([]bool, len(pool))
+	var chosen []*candidate
+	fits := func(c *candidate) bool {
+		for _, i := range c.idx {
+			if used[i] {
+				return false
+			}
+		}
+		return true
+	}
+	take := func(c *candidate, on bool) {
+		for _, i := range c.idx {
+			used[i] = on
+		}
+	}
+
+	// Fairness first: every ticket past PriorityWait gets its best match that is
+	// still possible, oldest ticket first.
+	for i, t := range pool {
+		if used[i] || now.Sub(t.JoinedAt) < PriorityWait {
+			continue
+		}
+		var best *candidate
+		for _, c := range cands {
+			if slices.Contains(c.idx, i) && fits(c) && (best == nil || c.score > best.score) {
+				best = c
+			}
+		}
+		if best != nil {
+			take(best, true)
+			chosen = append(chosen, best)
+		}
+	}
+
+	// Then maximize total score over what is left.
+	var open []*candidate
+	free := 0
+	for _, c := range cands {
+		if fits(c) {
+			open = append(open, c)
+		}
+	}
+	for i, t := range pool {
+		if !used[i] {
+			free += t.size()
+		}
+	}
+	var best, current []*candidate
+	bestScore, nodes := 0.0, 0
+	var search func(next int, score float64, free int)
+	search = func(next int, score float64, free int) {
+		nodes++
+		if score > bestScore {
+			bestScore = score
+			best = slices.Clone(current)
+		}
+		if next == len(open) || nodes > searchBudget {
+			return
+		}
+		// open is sorted best first, so no remaining match scores above open[next].
+		if score+float64(min(len(open)-next, free/size))*open[next].score <= bestScore {
+			return
+		}
+		if c := open[next]; fits(c) {
+			take(c, true)
+			current = append(current, c)
+			search(next+1, score+c.score, free-c.players)
+			current = current[:len(current)-1]
+			take(c, false)
+		}
+		search(next+1, score, free)
+	}
+	search(0, 0, free)
+	chosen = append(chosen, best...)
+
+	// Form matches for the oldest tickets first, in case servers run out.
+	sort.SliceStable(chosen, func(i, j int) bool { return slices.Min(chosen[i].idx) < slices.Min(chosen[j].idx) })
+	for _, c := range chosen {
+		q.formMatch(c.region, playlist, c.group, now)
+	}
+}
+
+// candidates lists full matches around every searching ticket in pool: for each region
+// the ticket will play in, the closest-MMR group plus each run of neighbours in MMR
+// order that includes it. Groups must be splittable into teams. Candidates come back
+// best score first.
+func (q *QueueManager) candidates(pool []*Ticket, playlist Playlist, now time.Time) []*candidate {
+	size := 2 * playlist.TeamSize
+	index := make(map[*Ticket]int, len(pool))
+	for i, t := range pool {
+		index[t] = i
+	}
+	seen := make(map[string]bool)
+	var out []*candidate
+	add := func(region string, group []*Ticket) {
+		if headcount(group) != size {
+			return
+		}
+		ids := make([]int, len(group))
+		for i, t := range group {
+			ids[i] = index[t]
+		}
+		slices.Sort(ids)
+		var key strings.Builder
+		key.WriteString(region)
+		for _, i := range ids {
+			key.WriteString("|" + pool[i].TicketID)
+		}
+		if seen[key.String()] {
+			return
+		}
+		seen[key.String()] = true
+		if _, _, ok := balanceTeams(group, playlist.TeamSize); !ok {
+			return
+		}
+		c := &candidate{region: region, group: slices.Clone(group), players: size, idx: ids}
+		c.score = q.score(c, playlist, now)
+		out = append(out, c)
+	}
+
+	for _, anchor := range pool {
+		if anchor.Status != "searching" {
+			continue
+		}
+		for _, region := range anchor.regionsAt(now) {
+			add(region, q.gather(anchor, pool, region, size, now))
+
+			// Runs of MMR neighbours: the anchor at every position from the top of
+			// the run to the bottom.
+			eligible := []*Ticket{anchor}
+			for _, t := range pool {
+				if t != anchor && t.Status == "searching" && t.playsIn(region, now) &&
+					abs(anchor.MMR-t.MMR) <= q.allowedMMRDiff(anchor, t, now) {
+					eligible = append(eligible, t)
+				}
+			}
+			sort.SliceStable(eligible, func(i, j int) bool { return eligible[i].MMR < eligible[j].MMR })
+			at := slices.Index(eligible, anchor)
+			for start := max(0, at-size+1); start <= at; start++ {
+				var group []*Ticket
+				players := 0
+				for _, t := range eligible[start:] {
+					if players+t.size() <= size {
+						group = append(group, t)
+						players += t.size()
+					}
+					if players == size {
+						break
+					}
+				}
+				if slices.Contains(group, anchor) {
+					add(region, group)
+				}
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].score > out[j].score })
+	return out
+}
+
+// score rates a candidate match: matchValue, plus credit for how long its players
+// waited, minus penalties for uneven teams and for ping to the host region.
+func (q *QueueManager) score(c *candidate, playlist Playlist, now time.Time) float64 {
+	orange, blue, _ := balanceTeams(c.group, playlist.TeamSize)
+	widest := float64(q.mmrWindow.Base + q.mmrWindow.MaxWiden)
+	mmrPenalty := math.Min(teamCost(members(orange), members(blue))/math.Max(widest, 1), 1)
+
+	var waited time.Duration
+	for _, t := range c.group {
+		waited += now.Sub(t.JoinedAt) * time.Duration(t.size())
+	}
+	waitCredit := math.Min(float64(waited)/float64(c.players)/float64(waitCreditCap), 1)
+
+	pingPenalty := 0.0
+	if pings := expectedPings(c.group, c.region); len(pings) > 0 {
+		sum := 0
+		for _, p := range pings {
+			sum += p
+		}
+		pingPenalty = math.Min(float64(sum)/float64(len(pings))/PingCeilingMS, 1)
+	}
+	return matchValue + waitWeight*waitCredit - mmrWeight*mmrPenalty - pingWeight*pingPenalty
+}
@@ -0,0 +1,61 @@
+package matchmaking
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"projectvelocity/backend/internal/shared/types"
+)
+
+func TestBatchMatchingAvoidsStrandingPlayers(t *testing.T) {
+	q := NewQueueManager("ws://localhost:9003/ws")
+	q.SetReadyCheckTimeout(0)
+	now := time.Now().UTC()
+	q.SetClock(func() time.Time { return now })
+
+	// Greedy oldest-first pairs 1000 with its closest partner, 1040, which strands
+	// both 950 and 1090. Pairing 1000-950 and 1040-1090 places everyone.
+	var tickets []string
+	for i, mmr := range []int{1000, 1040, 1090, 950} {
+		resp := q.Join(types.QueueJoinRequest{PlayerID: "p" + strconv.Itoa(i), Region: "us-east", Playlist: "ranked-1v1", MMR: mmr})
+		tickets = append(tickets, resp.TicketID)
+		now = now.Add(time.Millisecond)
+	}
+	q.Process()
+	for i, id := range tickets {
+		if got := q.Poll(id).Status; got != "matched" {
+			t.Fatalf("expected ticket %d matched, got=%s", i, got)
+		}
+	}
+	if q.Poll(tickets[0]).Assignment.MatchID != q.Poll(tickets[3]).Assignment.MatchID {
+		t.Fatalf("expected 1000 paired with 950")
+	}
+}
+
+func TestBatchMatchingPlacesLongWaitersFirst(t *testing.T) {
+	q := NewQueueManager("ws://localhost:9003/ws")
+	q.SetReadyCheckTimeout(0)
+	now := time.Now().UTC()
+	q.SetClock(func() time.Time { return now })
+
+	old := q.Join(types.QueueJoinRequest{PlayerID: "old", Region: "us-east", Playlist: "ranked-1v1", MMR: 1500})
+	now = now.Add(PriorityWait)
+	a := q.Join(types.QueueJoinRequest{PlayerID: "a", Region: "us-east", Playlist: "ranked-1v1", MMR: 1000})
+	b := q.Join(types.QueueJoinRequest{PlayerID: "b", Region: "us-east", Playlist: "ranked-1v1", MMR: 1000})
+	q.Process()
+
+	// a-b is the better match on its own, but the old ticket is owed one first.
+	if got := q.Poll(old.TicketID).Status; got != "matched" {
+		t.Fatalf("expected the long-waiting ticket matched, got=%s", got)
+	}
+	matched := 0
+	for _, id := range []string{a.TicketID, b.TicketID} {
+		if q.Poll(id).Status == "matched" {
+			matched++
+		}
+	}
+	if matched != 1 {
+		t.Fatalf("expected exactly one fresh ticket to partner the old one, got=%d", matched)
+	}
+}
@@ -0,0 +1,250 @@
+package matchmaking
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+const (
+	snapshotFile = "tickets.snapshot.json"
+	walFile      = "tickets.wal"
+	// DefaultCompactEvery is how many log entries FileStore appends before it folds them
+	// into a fresh snapshot.
+	DefaultCompactEvery = 1000
+)
+
+var ErrStoreClosed = errors.New("matchmaking: store closed")
+
+// walEntry is one line of the write-ahead log.
+type walEntry struct {
+	Op       string        `json:"op"` // put|delete
+	Record   *TicketRecord `json:"record,omitempty"`
+	TicketID string        `json:"ticket_id,omitempty"`
+}
+
+// FileStore is a durable Store: each batch is appended to a write-ahead log with one
+// sync, and the log is periodically compacted into a snapshot. On open the snapshot is loaded
+// and the log replayed; a torn final line from a crash is ignored.
+type FileStore struct {
+	mu           sync.Mutex
+	dir          string
+	records      map[string]TicketRecord
+	wal          *os.File
+	entries      int
+	compactEvery int
+}
+
+// OpenFileStore opens or creates a store in dir.
+func OpenFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	s := &FileStore{dir: dir, records: make(map[string]TicketRecord), compactEvery: DefaultCompactEvery}
+	if err := s.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	torn, err := s.replay()
+	if err != nil {
+		return nil, err
+	}
+	wal, err := os.OpenFile(filepath.Join(dir, walFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	s.wal = wal
+	if torn {
+		// Appending after a torn line would hide the new entries from the next replay.
+		if err := s.compact(); err != nil {
+			wal.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// SetCompactEvery changes how many log entries trigger a snapshot.
+func (s *FileStore) SetCompactEvery(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.compactEvery = n
+}
+
+func (s *FileStore) Load() ([]TicketRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Collect(maps.Values(s.records)), nil
+}
+
+func (s *FileStore) Write(changes []Change) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := make([]walEntry, 0, len(changes))
+	for _, c := range changes {
+		switch _, known := s.records[c.TicketID]; {
+		case c.Record != nil:
+			entries = append(entries, walEntry{Op: "put", Record: c.Record})
+		case known:
+			entries = append(entries, walEntry{Op: "delete", TicketID: c.TicketID})
+		}
+	}
+	// The records change only once the log has them, so a failed batch can be retried.
+	if err := s.append(entries); err != nil {
+		return err
+	}
+	for _, c := range changes {
+		if c.Record == nil {
+			delete(s.records, c.TicketID)
+			continue
+		}
+		s.records[c.TicketID] = *c.Record
+	}
+	return s.maybeCompact()
+}
+
+// Close writes a final snapshot so the next open has no log to replay.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.wal == nil {
+		return nil
+	}
+	err := s.compact()
+	if cerr := s.wal.Close(); err == nil {
+		err = cerr
+	}
+	s.wal = nil
+	return err
+}
+
+// append logs entries and syncs them to disk together. Callers hold s.mu.
+func (s *FileStore) append(entries []walEntry) error {
+	if s.wal == nil {
+		return ErrStoreClosed
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	var buf []byte
+	for _, e := range entries {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+	info, err := s.wal.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := s.wal.Write(buf); err != nil {
+		// Cut off a partial batch so entries appended on retry stay replayable.
+		_ = s.wal.Truncate(info.Size())
+		return err
+	}
+	if err := s.wal.Sync(); err != nil {
+		return err
+	}
+	s.entries += len(entries)
+	return nil
+}
+
+// maybeCompact snapshots once the log has grown past compactEvery. Callers hold s.mu.
+func (s *FileStore) maybeCompact() error {
+	if s.compactEvery > 0 && s.entries >= s.compactEvery {
+		return s.compact()
+	}
+	return nil
+}
+
+// compact writes every record to a new snapshot, swaps it in atomically and empties the
+// log. Callers hold s.mu.
+func (s *FileStore) compact() error {
+	body, err := json.Marshal(slices.Collect(maps.Values(s.records)))
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(s.dir, snapshotFile+".tmp")
+	if err := writeSynced(tmp, body); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, snapshotFile)); err != nil {
+		return err
+	}
+	if err := s.wal.Truncate(0); err != nil {
+		return err
+	}
+	s.entries = 0
+	return nil
+}
+
+func (s *FileStore) loadSnapshot() error {
+	body, err := os.ReadFile(filepath.Join(s.dir, snapshotFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var records []TicketRecord
+	if err := json.Unmarshal(body, &records); err != nil {
+		return fmt.Errorf("matchmaking: corrupt snapshot: %w", err)
+	}
+	for _, rec := range records {
+		s.records[rec.TicketID] = rec
+	}
+	return nil
+}
+
+// replay applies the log on top of the snapshot and reports whether it ended in a torn
+// line.
+func (s *FileStore) replay() (bool, error) {
+	f, err := os.Open(filepath.Join(s.dir, walFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var e walEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// Only the last write can be torn; everything before it was synced whole.
+			return true, nil
+		}
+		switch {
+		case e.Op == "put" && e.Record != nil:
+			s.records[e.Record.TicketID] = *e.Record
+		case e.Op == "delete":
+			delete(s.records, e.TicketID)
+		}
+		s.entries++
+	}
+	return false, scanner.Err()
+}
+
+func writeSynced(path string, body []byte) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(body); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
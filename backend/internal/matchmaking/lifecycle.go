@@ -0,0 +1,133 @@
+package matchmaking
+
+import (
+	"context"
+	"time"
+)
+
+// TTLs bound how long tickets linger in each state. A zero field never expires.
+type TTLs struct {
+	// Searching cancels tickets that have waited this long without a match.
+	Searching time.Duration
+	// Assignment forgets matched tickets this long after the match was found.
+	Assignment time.Duration
+	// Cancelled forgets cancelled tickets, which stay pollable until then.
+	Cancelled time.Duration
+}
+
+// DefaultTTLs keeps assignments for the length of a match plus overtime and gives
+// clients a couple of minutes to observe a cancellation.
+func DefaultTTLs() TTLs {
+	return TTLs{
+		Searching:  10 * time.Minute,
+		Assignment: 15 * time.Minute,
+		Cancelled:  2 * time.Minute,
+	}
+}
+
+// QueueStats counts live tickets by status.
+type QueueStats struct {
+	Searching      int
+	AcceptRequired int
+	Matched        int
+	Cancelled      int
+	Cooldowns      int
+	// ExpiredTotal counts tickets cancelled or forgotten by TTL since start.
+	ExpiredTotal uint64
+	// StoreErrors counts failed writes to the persistent store.
+	StoreErrors uint64
+}
+
+// SetTTLs replaces the ticket lifetimes used by Expire.
+func (q *QueueManager) SetTTLs(ttls TTLs) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.ttls = ttls
+}
+
+// Stats reports how many tickets are held in each state.
+func (q *QueueManager) Stats() QueueStats {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	stats := QueueStats{Cooldowns: len(q.cooldowns), ExpiredTotal: q.expired, StoreErrors: q.storeErrors}
+	for _, t := range q.ticketIndex {
+		switch t.Status {
+		case "searching":
+			stats.Searching++
+		case "accept_required":
+			stats.AcceptRequired++
+		case "matched":
+			stats.Matched++
+		case "cancelled":
+			stats.Cancelled++
+		}
+	}
+	return stats
+}
+
+// RunJanitor calls Expire every interval until ctx is done.
+func (q *QueueManager) RunJanitor(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case t := <-ticker.C:
+			q.Expire(t.UTC())
+		}
+	}
+}
+
+// Expire cancels searching tickets that outlived their TTL, forgets matched and
+// cancelled tickets past theirs, and drops cooldowns that no longer matter. Tickets in
+// a ready check are left to its own deadline. It returns how many tickets it touched.
+func (q *QueueManager) Expire(now time.Time) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	n := 0
+	for _, t := range q.ticketIndex {
+		age := now.Sub(t.changedAt)
+		switch t.Status {
+		case "searching":
+			if q.ttls.Searching > 0 && now.Sub(t.JoinedAt) >= q.ttls.Searching {
+				q.leave(t, now)
+				n++
+			}
+		case "matched":
+			if q.ttls.Assignment > 0 && age >= q.ttls.Assignment {
+				q.forget(t)
+				n++
+			}
+		case "cancelled":
+			if q.ttls.Cancelled > 0 && age >= q.ttls.Cancelled {
+				q.forget(t)
+				n++
+			}
+		}
+	}
+	for id, c := range q.cooldowns {
+		if now.After(c.until) && now.Sub(c.lastStrike) > strikeMemory {
+			delete(q.cooldowns, id)
+		}
+	}
+	q.expired += uint64(n)
+	return n
+}
+
+// forget removes every trace of t. Watchers wake and see not_found.
+func (q *QueueManager) forget(t *Ticket) {
+	delete(q.ticketIndex, t.TicketID)
+	delete(q.assignment, t.TicketID)
+	for _, m := range t.Members {
+		if q.playerTickets[m.PlayerID] == t.TicketID {
+			delete(q.playerTickets, m.PlayerID)
+		}
+	}
+	q.queueChange(Change{TicketID: t.TicketID})
+	q.wake(t.TicketID)
+}
@@ -0,0 +1,72 @@
+package matchmaking
+
+import (
+	"testing"
+	"time"
+
+	"projectvelocity/backend/internal/shared/types"
+)
+
+func TestJoinReplacesPlayersExistingTicket(t *testing.T) {
+	q := NewQueueManager("ws://localhost:9003/ws")
+	first := q.Join(types.QueueJoinRequest{PlayerID: "p1", Region: "us-east", Playlist: "ranked-1v1", MMR: 1000})
+	second := q.Join(types.QueueJoinRequest{PlayerID: "p1", Region: "us-east", Playlist: "ranked-2v2", MMR: 1000})
+
+	if got := q.Poll(first.TicketID).Status; got != "cancelled" {
+		t.Fatalf("expected replaced ticket cancelled, got=%s", got)
+	}
+	if got := q.Poll(second.TicketID).Status; got != "searching" {
+		t.Fatalf("expected new ticket searching, got=%s", got)
+	}
+	if stats := q.Stats(); stats.Searching != 1 || stats.Cancelled != 1 {
+		t.Fatalf("expected one live ticket, got=%+v", stats)
+	}
+	q.mu.RLock()
+	queued := len(q.buckets[bucketKey("us-east", "ranked-1v1")])
+	q.mu.RUnlock()
+	if queued != 0 {
+		t.Fatalf("expected replaced ticket out of its bucket, got=%d", queued)
+	}
+}
+
+func TestExpireWalksTicketsThroughTheirTTLs(t *testing.T) {
+	q := NewQueueManager("ws://localhost:9003/ws")
+	q.SetReadyCheckTimeout(0)
+	q.SetTTLs(TTLs{Searching: time.Minute, Assignment: time.Minute, Cancelled: time.Minute})
+
+	a := q.Join(types.QueueJoinRequest{PlayerID: "p1", Region: "us-east", Playlist: "ranked-1v1", MMR: 1000})
+	b := q.Join(types.QueueJoinRequest{PlayerID: "p2", Region: "us-east", Playlist: "ranked-1v1", MMR: 1000})
+	q.Process()
+	lonely := q.Join(types.QueueJoinRequest{PlayerID: "p3", Region: "eu-west", Playlist: "ranked-1v1", MMR: 1000})
+
+	now := time.Now().UTC()
+	if n := q.Expire(now.Add(30 * time.Second)); n != 0 {
+		t.Fatalf("expected nothing expired before TTLs, got=%d", n)
+	}
+	if n := q.Expire(now.Add(2 * time.Minute)); n != 3 {
+		t.Fatalf("expected stale search and both assignments expired, got=%d", n)
+	}
+	if got := q.Poll(lonely.TicketID).Status; got != "cancelled" {
+		t.Fatalf("expected stale search cancelled, got=%s", got)
+	}
+	if got := q.Poll(a.TicketID).Status; got != "not_found" {
+		t.Fatalf("expected old assignment forgotten, got=%s", got)
+	}
+	if got := q.Poll(b.TicketID).Status; got != "not_found" {
+		t.Fatalf("expected old assignment forgotten, got=%s", got)
+	}
+
+	q.Expire(now.Add(5 * time.Minute))
+	if got := q.Poll(lonely.TicketID).Status; got != "not_found" {
+		t.Fatalf("expected cancelled ticket forgotten, got=%s", got)
+	}
+	stats := q.Stats()
+	if stats != (QueueStats{ExpiredTotal: 4}) {
+		t.Fatalf("expected empty queue, got=%+v", stats)
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if len(q.assignment) != 0 || len(q.playerTickets) != 0 {
+		t.Fatalf("expected no leaked state, assignments=%d players=%d", len(q.assignment), len(q.playerTickets))
+	}
+}
@@ -0,0 +1,132 @@
+package matchmaking
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Playlist describes one queue players can join.
+type Playlist struct {
+	Name string `json:"name"`
+	// TeamSize is the number of players per side; a match holds two teams.
+	TeamSize int       `json:"team_size"`
+	Ranked   bool      `json:"ranked"`
+	Bots     BotPolicy `json:"bots"`
+}
+
+// BotPolicy controls whether a playlist fills empty slots with bots. Ranked playlists
+// never do.
+type BotPolicy struct {
+	Allowed bool `json:"allowed"`
+	// WaitMS is how long the oldest ticket waits before bots take the empty slots.
+	WaitMS int64 `json:"wait_ms"`
+	// MaxBots caps bot slots per match; a group that would need more keeps waiting for
+	// players. Zero means every empty slot may be a bot.
+	MaxBots int `json:"max_bots"`
+}
+
+// fills reports whether a group missing empty players may start with bots after waiting.
+func (b BotPolicy) fills(empty int, waited time.Duration) bool {
+	if !b.Allowed || waited < time.Duration(b.WaitMS)*time.Millisecond {
+		return false
+	}
+	return b.MaxBots == 0 || empty <= b.MaxBots
+}
+
+// Bot difficulties, from weakest to strongest.
+const (
+	BotRookie  = "rookie"
+	BotPro     = "pro"
+	BotAllStar = "allstar"
+)
+
+// BotDifficulty picks the bot level for a match whose humans average mmr.
+func BotDifficulty(mmr int) string {
+	switch {
+	case mmr < 850:
+		return BotRookie
+	case mmr < 1250:
+		return BotPro
+	default:
+		return BotAllStar
+	}
+}
+
+// PlaylistRegistry holds the playlists the matchmaker serves.
+type PlaylistRegistry struct {
+	mu        sync.RWMutex
+	playlists map[string]Playlist
+}
+
+// NewPlaylistRegistry creates a registry holding the given playlists.
+func NewPlaylistRegistry(playlists ...Playlist) *PlaylistRegistry {
+	r := &PlaylistRegistry{playlists: make(map[string]Playlist)}
+	for _, p := range playlists {
+		_ = r.Register(p)
+	}
+	return r
+}
+
+// DefaultPlaylists returns the standard ranked 1v1 through 4v4 playlists and the casual
+// 1v1 through 3v3 playlists, which top up short matches with bots.
+func DefaultPlaylists() *PlaylistRegistry {
+	return NewPlaylistRegistry(
+		Playlist{Name: "ranked-1v1", TeamSize: 1, Ranked: true},
+		Playlist{Name: "ranked-2v2", TeamSize: 2, Ranked: true},
+		Playlist{Name: "ranked-3v3", TeamSize: 3, Ranked: true},
+		Playlist{Name: "ranked-4v4", TeamSize: 4, Ranked: true},
+		Playlist{Name: "casual-1v1", TeamSize: 1, Bots: BotPolicy{Allowed: true, WaitMS: 4000, MaxBots: 1}},
+		Playlist{Name: "casual-2v2", TeamSize: 2, Bots: BotPolicy{Allowed: true, WaitMS: 8000, MaxBots: 2}},
+		Playlist{Name: "casual-3v3", TeamSize: 3, Bots: BotPolicy{Allowed: true, WaitMS: 12000, MaxBots: 4}},
+	)
+}
+
+// Register adds or replaces a playlist.
+func (r *PlaylistRegistry) Register(p Playlist) error {
+	if p.Name == "" || p.TeamSize < 1 {
+		return fmt.Errorf("matchmaking: playlist needs a name and a positive team size")
+	}
+	if p.Ranked && p.Bots.Allowed {
+		return fmt.Errorf("matchmaking: ranked playlist %s cannot use bots", p.Name)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.playlists[p.Name] = p
+	return nil
+}
+
+// Get returns the named playlist.
+func (r *PlaylistRegistry) Get(name string) (Playlist, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.playlists[name]
+	return p, ok
+}
+
+// List returns every playlist ordered by team size then name.
+func (r *PlaylistRegistry) List() []Playlist {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]Playlist, 0, len(r.playlists))
+	for _, p := range r.playlists {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].TeamSize != out[j].TeamSize {
+			return out[i].TeamSize < out[j].TeamSize
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// lookup returns the named playlist, treating unknown names as 1v1 so stray tickets
+// still match.
+func (r *PlaylistRegistry) lookup(name string) Playlist {
+	if p, ok := r.Get(name); ok {
+		return p
+	}
+	return Playlist{Name: name, TeamSize: 1}
+}
@@ -4,23 +4,71 @@ import (
 	"context"
 	"fmt"
 	"math"
+	"slices"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"projectvelocity/backend/internal/shared/types"
 )
 
-// Ticket is a queue entry.
+// partySpreadPenalty raises a party's MMR by this share of the gap between its best and
+// worst member, so a strong player cannot be hidden behind weak partners.
+const partySpreadPenalty = 0.25
+
+// Ticket is a queue entry for a solo player or a whole party.
 type Ticket struct {
 	TicketID    string
-	PlayerID    string
+	PlayerID    string // the solo player or party leader
 	DisplayName string
-	MMR         int
-	Region      string
+	MMR         int    // aggregate for parties
+	Region      string // home region: the request's, or the lowest-ping one
 	Playlist    string
 	JoinedAt    time.Time
-	Status      string // searching|matched|cancelled
+	Status      string // searching|accept_required|matched|cancelled
+	PartyID     string
+	// Members always includes PlayerID; everyone in it lands on the same team.
+	Members []types.PartyMember
+	// Pings is the measured round trip to each region in milliseconds, if the client sent any.
+	Pings map[string]int
+	// readyCheck is the match id of the ready check the ticket is waiting on.
+	readyCheck string
+	// version counts status changes so watchers can tell what they have already seen.
+	version uint64
+	// changedAt is when the status last changed; TTLs count from it.
+	changedAt time.Time
+}
+
+// size is the number of players the ticket brings to a match.
+func (t *Ticket) size() int {
+	return len(t.Members)
+}
+
+// partyMMR is the mean member MMR plus a penalty for a wide spread.
+func partyMMR(members []types.PartyMember) int {
+	if len(members) == 0 {
+		return 0
+	}
+	sum, lo, hi := 0, members[0].MMR, members[0].MMR
+	for _, m := range members {
+		sum += m.MMR
+		lo = min(lo, m.MMR)
+		hi = max(hi, m.MMR)
+	}
+	mean := float64(sum) / float64(len(members))
+	return int(math.Round(mean + partySpreadPenalty*float64(hi-lo)))
+}
+
+// Allocator reserves game server capacity for a newly formed match and returns the
+// address clients should connect to.
+type Allocator interface {
+	Allocate(a types.MatchAllocation) (string, error)
+}
+
+// RatingSource supplies the server-side skill value used to place a player in a bucket.
+type RatingSource interface {
+	MMR(playerID, playlist string) int
 }
 
 // QueueManager provides in-memory matchmaking for local and staging usage.
@@ -30,6 +78,33 @@ type QueueManager struct {
 	ticketIndex map[string]*Ticket
 	assignment  map[string]*types.MatchAssignment
 	serverAddr  string
+	allocator   Allocator
+	ratings     RatingSource
+	playlists   *PlaylistRegistry
+
+	readyTimeout time.Duration
+	readyChecks  map[string]*readyCheck
+	cooldowns    map[string]*cooldown
+	// watchers are closed on a ticket's next change; see Watch.
+	watchers map[string]chan struct{}
+	// playerTickets maps every queued player to their newest ticket.
+	playerTickets map[string]string
+	ttls          TTLs
+	expired       uint64
+
+	// store is nil unless SetStore was called. Ticket changes wait in pending, latest
+	// per ticket, until Flush writes them; dirty tells RunStore there is work.
+	store       Store
+	pending     map[string]Change
+	dirty       chan struct{}
+	flushMu     sync.Mutex
+	storeErr    error
+	storeErrors uint64
+
+	analytics *analytics
+	// clock is the time source; the simulator swaps in a virtual one.
+	clock     func() time.Time
+	mmrWindow MMRWindow
 }
 
 func NewQueueManager(serverAddr string) *QueueManager {
@@ -41,7 +116,75 @@ func NewQueueManager(serverAddr string) *QueueManager {
 		ticketIndex: make(map[string]*Ticket),
 		assignment:  make(map[string]*types.MatchAssignment),
 		serverAddr:  serverAddr,
+		playlists:   DefaultPlaylists(),
+
+		readyTimeout: DefaultReadyCheckTimeout,
+		readyChecks:  make(map[string]*readyCheck),
+		cooldowns:    make(map[string]*cooldown),
+		watchers:     make(map[string]chan struct{}),
+
+		playerTickets: make(map[string]string),
+		ttls:          DefaultTTLs(),
+		pending:       make(map[string]Change),
+		dirty:         make(chan struct{}, 1),
+		analytics:     newAnalytics(),
+		clock:         time.Now,
+		mmrWindow:     DefaultMMRWindow(),
+	}
+}
+
+// Playlists returns the registry that defines each playlist's team size.
+func (q *QueueManager) Playlists() *PlaylistRegistry {
+	return q.playlists
+}
+
+// SetAllocator routes new matches through a game server fleet. Without one every
+// match is sent to the static server address.
+func (q *QueueManager) SetAllocator(a Allocator) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.allocator = a
+}
+
+// RollbackMatch returns a match's tickets to their queue after its server reservation
+// was lost. Original join times are kept so the players sort to the front of the bucket.
+func (q *QueueManager) RollbackMatch(matchID string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	requeued := 0
+	for ticketID, a := range q.assignment {
+		if a.MatchID != matchID {
+			continue
+		}
+		delete(q.assignment, ticketID)
+		t, ok := q.ticketIndex[ticketID]
+		if !ok {
+			continue
+		}
+		q.requeue(t)
+		requeued++
 	}
+	return requeued
+}
+
+// serverFor reserves a game server for the match; it reports false when the fleet is full.
+func (q *QueueManager) serverFor(a types.MatchAllocation) (string, bool) {
+	if q.allocator == nil {
+		return q.serverAddr, true
+	}
+	addr, err := q.allocator.Allocate(a)
+	if err != nil {
+		return "", false
+	}
+	return addr, true
+}
+
+// SetRatingSource makes Join use server-side ratings instead of the MMR in the request.
+func (q *QueueManager) SetRatingSource(r RatingSource) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.ratings = r
 }
 
 func bucketKey(region, playlist string) string {
@@ -54,83 +197,228 @@ func bucketKey(region, playlist string) string {
 	return region + "|" + playlist
 }
 
+// lastID keeps nextID strictly increasing when it is called within one clock tick.
+var lastID atomic.Int64
+
 func nextID(prefix string) string {
-	return fmt.Sprintf("%s_%d", prefix, time.Now().UTC().UnixNano())
+	for {
+		last, id := lastID.Load(), time.Now().UTC().UnixNano()
+		if id <= last {
+			id = last + 1
+		}
+		if lastID.CompareAndSwap(last, id) {
+			return fmt.Sprintf("%s_%d", prefix, id)
+		}
+	}
+}
+
+// SetClock replaces the time source the queue stamps and ages tickets with.
+func (q *QueueManager) SetClock(now func() time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.clock = now
 }
 
-// Join adds player to queue.
+// now reads the clock. It only touches fields fixed at setup, so no lock is needed.
+func (q *QueueManager) now() time.Time {
+	return q.clock().UTC()
+}
+
+// Join adds a player or party to queue. With a rating source set, request MMRs are
+// ignored. Callers must check the party fits the playlist's team size (see Fits).
+// Any ticket a member already holds is left first, so each player has one live ticket.
 func (q *QueueManager) Join(req types.QueueJoinRequest) types.QueueJoinResponse {
-	now := time.Now().UTC()
+	now := q.now()
+	q.mu.RLock()
+	ratings := q.ratings
+	q.mu.RUnlock()
+
+	members := req.Members
+	if len(members) == 0 {
+		members = []types.PartyMember{{PlayerID: req.PlayerID, DisplayName: req.DisplayName, MMR: req.MMR}}
+	}
+	members = slices.Clone(members)
+	for i := range members {
+		if ratings != nil {
+			members[i].MMR = ratings.MMR(members[i].PlayerID, req.Playlist)
+		}
+	}
+	region, pings := homeRegion(req)
 	ticket := &Ticket{
 		TicketID:    nextID("t"),
 		PlayerID:    req.PlayerID,
 		DisplayName: req.DisplayName,
-		MMR:         req.MMR,
-		Region:      req.Region,
+		MMR:         partyMMR(members),
+		Region:      region,
 		Playlist:    req.Playlist,
 		JoinedAt:    now,
 		Status:      "searching",
+		PartyID:     req.PartyID,
+		Members:     members,
+		Pings:       pings,
+		version:     1,
+		changedAt:   now,
 	}
-	key := bucketKey(req.Region, req.Playlist)
+	key := bucketKey(region, req.Playlist)
 
 	q.mu.Lock()
 	defer q.mu.Unlock()
+	for _, m := range members {
+		if old, ok := q.ticketIndex[q.playerTickets[m.PlayerID]]; ok {
+			q.leave(old, now)
+		}
+		q.playerTickets[m.PlayerID] = ticket.TicketID
+	}
 	q.buckets[key] = append(q.buckets[key], ticket)
 	q.ticketIndex[ticket.TicketID] = ticket
+	q.save(ticket)
 
-	return types.QueueJoinResponse{TicketID: ticket.TicketID, Status: ticket.Status}
+	return types.QueueJoinResponse{
+		TicketID:         ticket.TicketID,
+		Status:           ticket.Status,
+		EstimatedWaitSec: estimateSec(q.estimateWait(ticket, now)),
+	}
 }
 
-// Leave removes ticket from queue.
-func (q *QueueManager) Leave(ticketID string) bool {
+// Fits reports whether a group of size players can queue for playlist together.
+func (q *QueueManager) Fits(playlist string, size int) bool {
+	p, ok := q.playlists.Get(playlist)
+	return ok && size <= p.TeamSize
+}
+
+// Leave removes ticket from queue on behalf of playerID, who must be on it. For a party
+// this takes every member out. The ticket stays pollable as cancelled; leaving it again
+// reports ErrTicketNotFound.
+func (q *QueueManager) Leave(ticketID, playerID string) error {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
 	t, ok := q.ticketIndex[ticketID]
 	if !ok {
-		return false
+		return ErrTicketNotFound
 	}
+	if !slices.ContainsFunc(t.Members, func(m types.PartyMember) bool { return m.PlayerID == playerID }) {
+		return ErrNotOnTicket
+	}
+	if !q.leave(t, q.now()) {
+		return ErrTicketNotFound
+	}
+	return nil
+}
 
-	key := bucketKey(t.Region, t.Playlist)
-	bucket := q.buckets[key]
-	for i := range bucket {
-		if bucket[i].TicketID == ticketID {
-			bucket = append(bucket[:i], bucket[i+1:]...)
-			break
+// leave cancels t; it reports false when t was already cancelled.
+func (q *QueueManager) leave(t *Ticket, now time.Time) bool {
+	if t.Status == "cancelled" {
+		return false
+	}
+	if rc, ok := q.readyChecks[t.readyCheck]; ok && t.Status == "accept_required" {
+		// Walking away from a found match counts as declining it.
+		offenders := make(map[string]bool, len(t.Members))
+		for _, m := range t.Members {
+			offenders[m.PlayerID] = true
 		}
+		q.cancelReadyCheck(rc, offenders, now)
+		return true
 	}
-	q.buckets[key] = bucket
+
+	q.unbucket(t)
 	t.Status = "cancelled"
-	delete(q.ticketIndex, ticketID)
-	delete(q.assignment, ticketID)
+	delete(q.assignment, t.TicketID)
+	q.touch(t)
 	return true
 }
 
+// unbucket removes t from its bucket if it is still there.
+func (q *QueueManager) unbucket(t *Ticket) {
+	key := bucketKey(t.Region, t.Playlist)
+	q.buckets[key] = slices.DeleteFunc(q.buckets[key], func(b *Ticket) bool { return b == t })
+}
+
 // Poll returns current ticket status and assignment if available.
 func (q *QueueManager) Poll(ticketID string) types.QueuePollResponse {
 	q.mu.RLock()
 	defer q.mu.RUnlock()
+	return q.pollLocked(ticketID, q.now())
+}
 
+func (q *QueueManager) pollLocked(ticketID string, now time.Time) types.QueuePollResponse {
+	t, ok := q.ticketIndex[ticketID]
+	if !ok {
+		return types.QueuePollResponse{TicketID: ticketID, Status: "not_found"}
+	}
+	resp := types.QueuePollResponse{TicketID: ticketID, Status: t.Status, Version: t.version}
 	if a, ok := q.assignment[ticketID]; ok {
 		copyA := *a
-		return types.QueuePollResponse{TicketID: ticketID, Status: "matched", Assignment: &copyA}
+		resp.Status = "matched"
+		resp.Assignment = &copyA
+		return resp
 	}
+	switch t.Status {
+	case "searching":
+		resp.WaitedSec = int(now.Sub(t.JoinedAt).Seconds())
+		resp.EstimatedWaitSec = estimateSec(q.estimateWait(t, now))
+	case "accept_required":
+		if rc, ok := q.readyChecks[t.readyCheck]; ok {
+			resp.ReadyCheck = rc.view()
+		}
+	}
+	return resp
+}
 
-	t, ok := q.ticketIndex[ticketID]
+// Watch returns the ticket's current status and a channel that is closed at its next
+// change. The channel is nil for unknown tickets, which will never change.
+func (q *QueueManager) Watch(ticketID string) (types.QueuePollResponse, <-chan struct{}) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	resp := q.pollLocked(ticketID, q.now())
+	if resp.Status == "not_found" {
+		return resp, nil
+	}
+	ch, ok := q.watchers[ticketID]
 	if !ok {
-		return types.QueuePollResponse{TicketID: ticketID, Status: "not_found"}
+		ch = make(chan struct{})
+		q.watchers[ticketID] = ch
+	}
+	return resp, ch
+}
+
+// WaitForChange long-polls: it returns as soon as the ticket's version differs from
+// since, or with the current status when ctx ends first.
+func (q *QueueManager) WaitForChange(ctx context.Context, ticketID string, since uint64) types.QueuePollResponse {
+	for {
+		resp, changed := q.Watch(ticketID)
+		if resp.Version != since || changed == nil {
+			return resp
+		}
+		select {
+		case <-ctx.Done():
+			return resp
+		case <-changed:
+		}
+	}
+}
+
+// touch records a visible change to t and wakes its watchers. Callers hold q.mu.
+func (q *QueueManager) touch(t *Ticket) {
+	t.version++
+	t.changedAt = q.now()
+	q.save(t)
+	q.wake(t.TicketID)
+}
+
+func (q *QueueManager) wake(ticketID string) {
+	if ch, ok := q.watchers[ticketID]; ok {
+		close(ch)
+		delete(q.watchers, ticketID)
 	}
-	return types.QueuePollResponse{TicketID: ticketID, Status: t.Status}
 }
 
-// Run continuously evaluates queue and creates matches.
-func (q *QueueManager) Run(ctx context.Context, cadence time.Duration, playersPerMatch int) {
+// Run continuously evaluates queue and creates matches. Match size comes from each
+// bucket's playlist.
+func (q *QueueManager) Run(ctx context.Context, cadence time.Duration) {
 	if cadence <= 0 {
 		cadence = time.Second
 	}
-	if playersPerMatch < 2 {
-		playersPerMatch = 2
-	}
 
 	ticker := time.NewTicker(cadence)
 	defer ticker.Stop()
@@ -140,104 +428,208 @@ func (q *QueueManager) Run(ctx context.Context, cadence time.Duration, playersPe
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			q.process(playersPerMatch)
+			q.Process()
 		}
 	}
 }
 
-func (q *QueueManager) process(playersPerMatch int) {
+// Process runs one matchmaking pass over every bucket.
+func (q *QueueManager) Process() {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
-	now := time.Now().UTC()
+	now := q.now()
+	q.expireReadyChecks(now)
+
+	// Tickets wait in their home region's bucket, but a match may draw on every region
+	// of the playlist that its players' pings allow.
+	pools := make(map[string][]*Ticket)
 	for key, bucket := range q.buckets {
-		sort.SliceStable(bucket, func(i, j int) bool {
-			return bucket[i].JoinedAt.Before(bucket[j].JoinedAt)
+		_, name := splitKey(key)
+		pools[name] = append(pools[name], bucket...)
+	}
+	for name, pool := range pools {
+		sort.SliceStable(pool, func(i, j int) bool {
+			return pool[i].JoinedAt.Before(pool[j].JoinedAt)
 		})
+		playlist := q.playlists.lookup(name)
+		matchSize := 2 * playlist.TeamSize
+		q.optimize(pool, playlist, now)
 
-		used := make(map[int]bool)
-		if len(bucket) >= playersPerMatch {
-			for i := 0; i < len(bucket)-1; i++ {
-				if used[i] {
-					continue
-				}
-				a := bucket[i]
-				if a.Status != "searching" {
-					continue
-				}
-
-				best := -1
-				bestDiff := math.MaxInt32
-				for j := i + 1; j < len(bucket); j++ {
-					if used[j] {
-						continue
-					}
-					b := bucket[j]
-					if b.Status != "searching" {
-						continue
-					}
-					diff := abs(a.MMR - b.MMR)
-					threshold := q.allowedMMRDiff(a, b, now)
-					if diff <= threshold && diff < bestDiff {
-						best = j
-						bestDiff = diff
-					}
-				}
-
-				if best == -1 {
-					continue
-				}
-
-				b := bucket[best]
-				used[i] = true
-				used[best] = true
-
-				players := []string{a.PlayerID, b.PlayerID}
-				region, playlist := splitKey(key)
-				assignment := &types.MatchAssignment{
-					TicketID:    a.TicketID,
-					MatchID:     nextID("m"),
-					Region:      region,
-					Playlist:    playlist,
-					Players:     players,
-					BotFill:     false,
-					ServerAddr:  q.serverAddr,
-					FoundAtUnix: now.Unix(),
-				}
-				assignment2 := *assignment
-				assignment2.TicketID = b.TicketID
-
-				a.Status = "matched"
-				b.Status = "matched"
-				q.assignment[a.TicketID] = assignment
-				q.assignment[b.TicketID] = &assignment2
+		// Whoever the optimizer could not place gets a greedy pass, oldest ticket
+		// first, which also starts bot-filled matches once the policy allows.
+		for _, anchor := range pool {
+			if anchor.Status != "searching" {
+				continue
+			}
+			region, group := q.gatherAnyRegion(anchor, pool, matchSize, now)
+			switch {
+			case headcount(group) == matchSize:
+				q.formMatch(region, playlist, group, now)
+			case playlist.Bots.fills(matchSize-headcount(group), now.Sub(anchor.JoinedAt)):
+				q.formMatch(region, playlist, group, now)
 			}
 		}
+	}
 
-		region, playlist := splitKey(key)
+	for key, bucket := range q.buckets {
 		remaining := make([]*Ticket, 0, len(bucket))
-		for i, t := range bucket {
-			if used[i] || t.Status != "searching" {
-				continue
+		for _, t := range bucket {
+			if t.Status == "searching" {
+				remaining = append(remaining, t)
 			}
-			if now.Sub(t.JoinedAt) >= 4*time.Second {
-				t.Status = "matched"
-				q.assignment[t.TicketID] = &types.MatchAssignment{
-					TicketID:    t.TicketID,
-					MatchID:     nextID("m"),
-					Region:      region,
-					Playlist:    playlist,
-					Players:     []string{t.PlayerID, "bot"},
-					BotFill:     true,
-					ServerAddr:  q.serverAddr,
-					FoundAtUnix: now.Unix(),
+		}
+		q.buckets[key] = remaining
+	}
+}
+
+// gatherAnyRegion tries each region the anchor will currently play in, best ping first,
+// and returns the first that yields a full match. Failing that it returns the region
+// with the most players, so bot fill leaves as few empty slots as possible.
+func (q *QueueManager) gatherAnyRegion(anchor *Ticket, pool []*Ticket, size int, now time.Time) (string, []*Ticket) {
+	var bestRegion string
+	var best []*Ticket
+	for _, region := range anchor.regionsAt(now) {
+		group := q.gather(anchor, pool, region, size, now)
+		if headcount(group) == size {
+			return region, group
+		}
+		if best == nil || headcount(group) > headcount(best) {
+			bestRegion, best = region, group
+		}
+	}
+	return bestRegion, best
+}
+
+// gather returns anchor plus searching tickets inside its MMR window that will play in
+// region, closest MMR first, skipping any party that would push the group past size
+// players.
+func (q *QueueManager) gather(anchor *Ticket, pool []*Ticket, region string, size int, now time.Time) []*Ticket {
+	var candidates []*Ticket
+	for _, t := range pool {
+		if t == anchor || t.Status != "searching" || !t.playsIn(region, now) {
+			continue
+		}
+		if abs(anchor.MMR-t.MMR) <= q.allowedMMRDiff(anchor, t, now) {
+			candidates = append(candidates, t)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return abs(anchor.MMR-candidates[i].MMR) < abs(anchor.MMR-candidates[j].MMR)
+	})
+	group := []*Ticket{anchor}
+	players := anchor.size()
+	for _, t := range candidates {
+		if players+t.size() <= size {
+			group = append(group, t)
+			players += t.size()
+		}
+	}
+	return group
+}
+
+// pendingMatch is a formed match that has not been handed to a game server yet.
+type pendingMatch struct {
+	id       string
+	region   string
+	playlist Playlist
+	tickets  []*Ticket
+	teams    map[string][]string
+	players  []string
+	botFill  bool
+	bots     []types.BotSlot
+	pings    map[string]int
+}
+
+// formMatch balances group into teams and fills empty slots with bots pitched at the
+// group's mean MMR. The match then
+// goes through a ready check, or straight to a server when ready checks are disabled.
+// It reports false and leaves the tickets searching when the parties cannot be split
+// into teams or no server is available.
+func (q *QueueManager) formMatch(region string, playlist Playlist, group []*Ticket, now time.Time) bool {
+	orange, blue, ok := balanceTeams(group, playlist.TeamSize)
+	if !ok {
+		return false
+	}
+	teams := map[string][]string{
+		"orange": teamRoster(orange, playlist.TeamSize),
+		"blue":   teamRoster(blue, playlist.TeamSize),
+	}
+	m := &pendingMatch{
+		id:       nextID("m"),
+		region:   region,
+		playlist: playlist,
+		tickets:  group,
+		teams:    teams,
+		players:  append(append([]string{}, teams["orange"]...), teams["blue"]...),
+		botFill:  headcount(group) < 2*playlist.TeamSize,
+		pings:    expectedPings(group, region),
+	}
+	if m.botFill {
+		difficulty := BotDifficulty(int(math.Round(meanMMR(members(group)))))
+		for _, team := range []string{"orange", "blue"} {
+			for _, id := range teams[team] {
+				if id == "bot" {
+					m.bots = append(m.bots, types.BotSlot{Team: team, Difficulty: difficulty})
 				}
-				continue
 			}
-			remaining = append(remaining, t)
 		}
-		q.buckets[key] = remaining
 	}
+	if q.readyTimeout > 0 {
+		q.startReadyCheck(m, now)
+		return true
+	}
+	return q.launch(m, now)
+}
+
+// launch reserves a server for m and assigns every ticket. It reports false, leaving
+// the tickets untouched, when no server is available.
+func (q *QueueManager) launch(m *pendingMatch, now time.Time) bool {
+	serverAddr, ok := q.serverFor(types.MatchAllocation{
+		MatchID:  m.id,
+		Region:   m.region,
+		Playlist: m.playlist.Name,
+		TeamSize: m.playlist.TeamSize,
+		Players:  m.players,
+		Teams:    m.teams,
+		BotFill:  m.botFill,
+		Bots:     m.bots,
+	})
+	if !ok {
+		return false
+	}
+	for _, t := range m.tickets {
+		t.Status = "matched"
+		t.readyCheck = ""
+		q.assignment[t.TicketID] = &types.MatchAssignment{
+			TicketID:    t.TicketID,
+			MatchID:     m.id,
+			Region:      m.region,
+			Playlist:    m.playlist.Name,
+			Players:     m.players,
+			Teams:       m.teams,
+			BotFill:     m.botFill,
+			Bots:        m.bots,
+			Pings:       m.pings,
+			ServerAddr:  serverAddr,
+			FoundAtUnix: now.Unix(),
+		}
+		q.touch(t)
+	}
+	q.analytics.recordMatch(m, now)
+	return true
+}
+
+// teamRoster lists a team's players, padding empty slots with "bot".
+func teamRoster(team []*Ticket, size int) []string {
+	out := make([]string, 0, size)
+	for _, m := range members(team) {
+		out = append(out, m.PlayerID)
+	}
+	for len(out) < size {
+		out = append(out, "bot")
+	}
+	return out
 }
 
 func splitKey(key string) (region, playlist string) {
@@ -249,16 +641,36 @@ func splitKey(key string) (region, playlist string) {
 	return "global", "ranked-1v1"
 }
 
+// MMRWindow is how far apart two tickets' MMRs may be to share a match. It starts at
+// Base and widens by PerSecond for every second the longer-waiting ticket has queued,
+// by at most MaxWiden.
+type MMRWindow struct {
+	Base      int     `json:"base"`
+	PerSecond float64 `json:"per_second"`
+	MaxWiden  int     `json:"max_widen"`
+}
+
+// DefaultMMRWindow starts at 60 and reaches its widest, 710, after about a minute.
+func DefaultMMRWindow() MMRWindow {
+	return MMRWindow{Base: 60, PerSecond: 12, MaxWiden: 650}
+}
+
+// SetMMRWindow replaces the MMR window used when gathering matches.
+func (q *QueueManager) SetMMRWindow(w MMRWindow) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.mmrWindow = w
+}
+
 func (q *QueueManager) allowedMMRDiff(a, b *Ticket, now time.Time) int {
-	base := 60
 	wa := now.Sub(a.JoinedAt).Seconds()
 	wb := now.Sub(b.JoinedAt).Seconds()
 	wait := math.Max(wa, wb)
-	bonus := int(wait * 12)
-	if bonus > 650 {
-		bonus = 650
+	bonus := int(wait * q.mmrWindow.PerSecond)
+	if bonus > q.mmrWindow.MaxWiden {
+		bonus = q.mmrWindow.MaxWiden
 	}
-	return base + bonus
+	return q.mmrWindow.Base + bonus
 }
 
 func abs(v int) int {
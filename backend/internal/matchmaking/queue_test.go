@@ -2,6 +2,8 @@ package matchmaking
 
 import (
 	"context"
+	"errors"
+	"slices"
 	"testing"
 	"time"
 
@@ -10,12 +12,13 @@ import (
 
 func TestQueueMatchesSimilarMMR(t *testing.T) {
 	q := NewQueueManager("ws://localhost:9003/ws")
+	q.SetReadyCheckTimeout(0)
 	a := q.Join(types.QueueJoinRequest{PlayerID: "p1", DisplayName: "A", Region: "us-east", Playlist: "ranked-1v1", MMR: 1200})
 	b := q.Join(types.QueueJoinRequest{PlayerID: "p2", DisplayName: "B", Region: "us-east", Playlist: "ranked-1v1", MMR: 1240})
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	go q.Run(ctx, 10*time.Millisecond, 2)
+	go q.Run(ctx, 10*time.Millisecond)
 	time.Sleep(50 * time.Millisecond)
 
 	ap := q.Poll(a.TicketID)
@@ -33,6 +36,7 @@ func TestQueueMatchesSimilarMMR(t *testing.T) {
 
 func TestQueueWaitExpandsMMRWindow(t *testing.T) {
 	q := NewQueueManager("ws://localhost:9003/ws")
+	q.SetReadyCheckTimeout(0)
 	a := q.Join(types.QueueJoinRequest{PlayerID: "p1", DisplayName: "A", Region: "us-east", Playlist: "ranked-1v1", MMR: 900})
 	b := q.Join(types.QueueJoinRequest{PlayerID: "p2", DisplayName: "B", Region: "us-east", Playlist: "ranked-1v1", MMR: 1500})
 
@@ -47,7 +51,7 @@ func TestQueueWaitExpandsMMRWindow(t *testing.T) {
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	go q.Run(ctx, 10*time.Millisecond, 2)
+	go q.Run(ctx, 10*time.Millisecond)
 	time.Sleep(50 * time.Millisecond)
 
 	ap := q.Poll(a.TicketID)
@@ -58,7 +62,8 @@ func TestQueueWaitExpandsMMRWindow(t *testing.T) {
 
 func TestQueueSoloBotFill(t *testing.T) {
 	q := NewQueueManager("ws://localhost:9003/ws")
-	a := q.Join(types.QueueJoinRequest{PlayerID: "solo", DisplayName: "Solo", Region: "us-east", Playlist: "ranked-1v1", MMR: 1200})
+	q.SetReadyCheckTimeout(0)
+	a := q.Join(types.QueueJoinRequest{PlayerID: "solo", DisplayName: "Solo", Region: "us-east", Playlist: "casual-1v1", MMR: 1200})
 
 	q.mu.Lock()
 	if ta, ok := q.ticketIndex[a.TicketID]; ok {
@@ -68,7 +73,7 @@ func TestQueueSoloBotFill(t *testing.T) {
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	go q.Run(ctx, 10*time.Millisecond, 2)
+	go q.Run(ctx, 10*time.Millisecond)
 	time.Sleep(50 * time.Millisecond)
 
 	ap := q.Poll(a.TicketID)
@@ -81,4 +86,304 @@ func TestQueueSoloBotFill(t *testing.T) {
 	if !ap.Assignment.BotFill {
 		t.Fatal("expected bot_fill=true assignment for solo ticket")
 	}
+	if len(ap.Assignment.Bots) != 1 || ap.Assignment.Bots[0].Difficulty != BotPro {
+		t.Fatalf("expected one pro bot for a 1200 player, got=%+v", ap.Assignment.Bots)
+	}
+}
+
+func TestQueueRankedNeverBotFills(t *testing.T) {
+	q := NewQueueManager("ws://localhost:9003/ws")
+	q.SetReadyCheckTimeout(0)
+	a := q.Join(types.QueueJoinRequest{PlayerID: "solo", Region: "us-east", Playlist: "ranked-1v1", MMR: 1200})
+	q.mu.Lock()
+	q.ticketIndex[a.TicketID].JoinedAt = time.Now().UTC().Add(-5 * time.Minute)
+	q.mu.Unlock()
+
+	q.Process()
+	if got := q.Poll(a.TicketID).Status; got != "searching" {
+		t.Fatalf("expected ranked ticket to keep searching, got=%s", got)
+	}
+	if err := q.Playlists().Register(Playlist{Name: "ranked-bots", TeamSize: 1, Ranked: true, Bots: BotPolicy{Allowed: true}}); err == nil {
+		t.Fatal("expected ranked playlist with bots to be rejected")
+	}
+}
+
+type stubAllocator struct {
+	addr  string
+	full  bool
+	calls []types.MatchAllocation
+}
+
+func (s *stubAllocator) Allocate(a types.MatchAllocation) (string, error) {
+	s.calls = append(s.calls, a)
+	if s.full {
+		return "", errors.New("full")
+	}
+	return s.addr, nil
+}
+
+func TestQueueUsesAllocatorAndRequeuesOnRollback(t *testing.T) {
+	q := NewQueueManager("ws://static/ws")
+	q.SetReadyCheckTimeout(0)
+	alloc := &stubAllocator{addr: "ws://gs-1/ws", full: true}
+	q.SetAllocator(alloc)
+	a := q.Join(types.QueueJoinRequest{PlayerID: "p1", Region: "us-east", Playlist: "ranked-1v1", MMR: 1200})
+	b := q.Join(types.QueueJoinRequest{PlayerID: "p2", Region: "us-east", Playlist: "ranked-1v1", MMR: 1210})
+
+	q.Process()
+	if got := q.Poll(a.TicketID).Status; got != "searching" {
+		t.Fatalf("expected tickets to keep searching while fleet is full, got=%s", got)
+	}
+
+	alloc.full = false
+	q.Process()
+	ap := q.Poll(a.TicketID)
+	if ap.Status != "matched" || ap.Assignment.ServerAddr != "ws://gs-1/ws" {
+		t.Fatalf("expected match on allocated server, got=%+v", ap)
+	}
+	last := alloc.calls[len(alloc.calls)-1]
+	if last.MatchID != ap.Assignment.MatchID || len(last.Players) != 2 || last.Playlist != "ranked-1v1" {
+		t.Fatalf("allocation did not carry match roster: %+v", last)
+	}
+
+	if n := q.RollbackMatch(ap.Assignment.MatchID); n != 2 {
+		t.Fatalf("expected both tickets requeued, got=%d", n)
+	}
+	if got := q.Poll(b.TicketID).Status; got != "searching" {
+		t.Fatalf("expected requeued ticket to be searching, got=%s", got)
+	}
+	q.Process()
+	if got := q.Poll(b.TicketID).Status; got != "matched" {
+		t.Fatalf("expected requeued tickets to match again, got=%s", got)
+	}
+}
+
+type fixedRatings map[string]int
+
+func (f fixedRatings) MMR(playerID, _ string) int { return f[playerID] }
+
+func TestQueueJoinIgnoresClientMMRWithRatingSource(t *testing.T) {
+	q := NewQueueManager("ws://localhost:9003/ws")
+	q.SetReadyCheckTimeout(0)
+	q.SetRatingSource(fixedRatings{"p1": 1480})
+	resp := q.Join(types.QueueJoinRequest{PlayerID: "p1", Region: "us-east", Playlist: "ranked-1v1", MMR: 3000})
+
+	q.mu.RLock()
+	mmr := q.ticketIndex[resp.TicketID].MMR
+	q.mu.RUnlock()
+	if mmr != 1480 {
+		t.Fatalf("expected server-side rating, got=%d", mmr)
+	}
+}
+
+func TestQueueFormsBalancedTeams(t *testing.T) {
+	q := NewQueueManager("ws://localhost:9003/ws")
+	q.SetReadyCheckTimeout(0)
+	ids := map[string]string{}
+	for i, mmr := range []int{1000, 1100, 1200, 1300} {
+		pid := string(rune('a' + i))
+		resp := q.Join(types.QueueJoinRequest{PlayerID: pid, Region: "us-east", Playlist: "ranked-2v2", MMR: mmr})
+		ids[pid] = resp.TicketID
+	}
+	q.mu.Lock()
+	for _, t := range q.ticketIndex {
+		t.JoinedAt = time.Now().UTC().Add(-30 * time.Second)
+	}
+	q.mu.Unlock()
+
+	q.Process()
+	poll := q.Poll(ids["a"])
+	if poll.Status != "matched" || poll.Assignment.BotFill {
+		t.Fatalf("expected a full 2v2 match, got=%+v", poll)
+	}
+	teams := poll.Assignment.Teams
+	if len(teams["orange"]) != 2 || len(teams["blue"]) != 2 {
+		t.Fatalf("expected two players per team, got=%v", teams)
+	}
+	// 1000+1300 vs 1100+1200 is the only split with equal team averages.
+	together := func(x, y string) bool {
+		for _, team := range teams {
+			if slices.Contains(team, x) && slices.Contains(team, y) {
+				return true
+			}
+		}
+		return false
+	}
+	if !together("a", "d") || !together("b", "c") {
+		t.Fatalf("expected balanced split, got=%v", teams)
+	}
+}
+
+func TestQueueTeamPlaylistWaitsThenBotFills(t *testing.T) {
+	q := NewQueueManager("ws://localhost:9003/ws")
+	q.SetReadyCheckTimeout(0)
+	a := q.Join(types.QueueJoinRequest{PlayerID: "a", Region: "us-east", Playlist: "casual-2v2", MMR: 1000})
+	q.Join(types.QueueJoinRequest{PlayerID: "b", Region: "us-east", Playlist: "casual-2v2", MMR: 1010})
+	q.Join(types.QueueJoinRequest{PlayerID: "c", Region: "us-east", Playlist: "casual-2v2", MMR: 1020})
+
+	q.Process()
+	if got := q.Poll(a.TicketID).Status; got != "searching" {
+		t.Fatalf("expected three players to keep waiting for a 2v2, got=%s", got)
+	}
+
+	q.mu.Lock()
+	for _, t := range q.ticketIndex {
+		t.JoinedAt = time.Now().UTC().Add(-10 * time.Second)
+	}
+	q.mu.Unlock()
+	q.Process()
+	poll := q.Poll(a.TicketID)
+	if poll.Status != "matched" || !poll.Assignment.BotFill {
+		t.Fatalf("expected bot-filled 2v2, got=%+v", poll)
+	}
+	bots := 0
+	for _, team := range poll.Assignment.Teams {
+		if len(team) != 2 {
+			t.Fatalf("expected full teams with bot slots, got=%v", poll.Assignment.Teams)
+		}
+		bots += len(slices.DeleteFunc(slices.Clone(team), func(id string) bool { return id != "bot" }))
+	}
+	if bots != 1 {
+		t.Fatalf("expected exactly one bot slot, got=%d", bots)
+	}
+	if len(poll.Assignment.Bots) != 1 || !slices.Contains(poll.Assignment.Teams[poll.Assignment.Bots[0].Team], "bot") {
+		t.Fatalf("expected the bot slot passed on with its team, got=%+v", poll.Assignment.Bots)
+	}
+}
+
+func TestQueueBotCapKeepsShortGroupsWaiting(t *testing.T) {
+	q := NewQueueManager("ws://localhost:9003/ws")
+	q.SetReadyCheckTimeout(0)
+	a := q.Join(types.QueueJoinRequest{PlayerID: "a", Region: "us-east", Playlist: "casual-2v2", MMR: 700})
+	q.mu.Lock()
+	q.ticketIndex[a.TicketID].JoinedAt = time.Now().UTC().Add(-time.Minute)
+	q.mu.Unlock()
+
+	q.Process()
+	if got := q.Poll(a.TicketID).Status; got != "searching" {
+		t.Fatalf("expected a lone player to wait rather than face three bots, got=%s", got)
+	}
+
+	q.Join(types.QueueJoinRequest{PlayerID: "b", Region: "us-east", Playlist: "casual-2v2", MMR: 700})
+	q.Process()
+	poll := q.Poll(a.TicketID)
+	if poll.Status != "matched" || len(poll.Assignment.Bots) != 2 {
+		t.Fatalf("expected two humans plus two bots, got=%+v", poll)
+	}
+	for _, b := range poll.Assignment.Bots {
+		if b.Difficulty != BotRookie {
+			t.Fatalf("expected rookie bots for low MMR, got=%+v", poll.Assignment.Bots)
+		}
+	}
+}
+
+func TestQueuePartyStaysTogetherWithSpreadPenalty(t *testing.T) {
+	q := NewQueueManager("ws://localhost:9003/ws")
+	q.SetReadyCheckTimeout(0)
+	party := q.Join(types.QueueJoinRequest{
+		PlayerID: "lead", Region: "us-east", Playlist: "ranked-2v2", PartyID: "party_1",
+		Members: []types.PartyMember{{PlayerID: "lead", MMR: 1000}, {PlayerID: "mate", MMR: 1400}},
+	})
+	q.mu.RLock()
+	aggregate := q.ticketIndex[party.TicketID].MMR
+	q.mu.RUnlock()
+	if aggregate != 1300 {
+		t.Fatalf("expected mean 1200 plus spread penalty 100, got=%d", aggregate)
+	}
+	if q.Fits("ranked-2v2", 3) || !q.Fits("ranked-2v2", 2) || q.Fits("unknown", 1) {
+		t.Fatal("expected parties to fit only playlists with room")
+	}
+
+	q.Join(types.QueueJoinRequest{PlayerID: "s1", Region: "us-east", Playlist: "ranked-2v2", MMR: 1290})
+	q.Join(types.QueueJoinRequest{PlayerID: "s2", Region: "us-east", Playlist: "ranked-2v2", MMR: 1310})
+	q.Process()
+
+	poll := q.Poll(party.TicketID)
+	if poll.Status != "matched" || len(poll.Assignment.Players) != 4 {
+		t.Fatalf("expected a full 2v2 with the party, got=%+v", poll)
+	}
+	for _, team := range poll.Assignment.Teams {
+		if slices.Contains(team, "lead") != slices.Contains(team, "mate") {
+			t.Fatalf("expected party members on the same team, got=%v", poll.Assignment.Teams)
+		}
+	}
+}
+
+func TestQueueWaitForChangeWakesOnMatchAndLeave(t *testing.T) {
+	q := NewQueueManager("ws://localhost:9003/ws")
+	q.SetReadyCheckTimeout(0)
+	a := q.Join(types.QueueJoinRequest{PlayerID: "p1", Region: "us-east", Playlist: "ranked-1v1", MMR: 1000})
+	first := q.Poll(a.TicketID)
+	if first.Status != "searching" || first.Version == 0 {
+		t.Fatalf("expected versioned searching status, got=%+v", first)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if got := q.WaitForChange(ctx, a.TicketID, first.Version); got.Version != first.Version {
+		t.Fatalf("expected unchanged status after timeout, got=%+v", got)
+	}
+
+	done := make(chan types.QueuePollResponse, 1)
+	go func() {
+		done <- q.WaitForChange(context.Background(), a.TicketID, first.Version)
+	}()
+	q.Join(types.QueueJoinRequest{PlayerID: "p2", Region: "us-east", Playlist: "ranked-1v1", MMR: 1000})
+	q.Process()
+	select {
+	case got := <-done:
+		if got.Status != "matched" || got.Version <= first.Version {
+			t.Fatalf("expected wake on match, got=%+v", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("long poll did not wake on match")
+	}
+
+	c := q.Join(types.QueueJoinRequest{PlayerID: "p3", Region: "us-east", Playlist: "ranked-1v1", MMR: 1000})
+	_, changed := q.Watch(c.TicketID)
+	if err := q.Leave(c.TicketID, "p1"); !errors.Is(err, ErrNotOnTicket) {
+		t.Fatalf("expected a stranger's leave to be refused, got=%v", err)
+	}
+	if got := q.Poll(c.TicketID).Status; got != "searching" {
+		t.Fatalf("expected the ticket to keep searching, got=%s", got)
+	}
+	if err := q.Leave(c.TicketID, "p3"); err != nil {
+		t.Fatalf("leave failed: %v", err)
+	}
+	select {
+	case <-changed:
+	default:
+		t.Fatal("expected leave to close the watch channel")
+	}
+	if got := q.Poll(c.TicketID).Status; got != "cancelled" {
+		t.Fatalf("expected cancelled ticket to stay pollable, got=%s", got)
+	}
+	if err := q.Leave(c.TicketID, "p3"); !errors.Is(err, ErrTicketNotFound) {
+		t.Fatalf("expected second leave to report nothing removed, got=%v", err)
+	}
+	if _, ch := q.Watch("missing"); ch != nil {
+		t.Fatal("expected nil watch channel for unknown ticket")
+	}
+}
+
+func TestMMRWindowWidensOnTheQueueClock(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	q := NewQueueManager("ws://localhost:9003/ws")
+	q.SetReadyCheckTimeout(0)
+	q.SetClock(func() time.Time { return now })
+	q.SetMMRWindow(MMRWindow{Base: 100, PerSecond: 10, MaxWiden: 150})
+
+	a := q.Join(types.QueueJoinRequest{PlayerID: "p1", Region: "us-east", Playlist: "ranked-1v1", MMR: 1000})
+	q.Join(types.QueueJoinRequest{PlayerID: "p2", Region: "us-east", Playlist: "ranked-1v1", MMR: 1240})
+	q.Process()
+	now = now.Add(10 * time.Second)
+	q.Process()
+	if got := q.Poll(a.TicketID).Status; got != "searching" {
+		t.Fatalf("expected a 240 gap to exceed the 200 window at 10s, got=%s", got)
+	}
+	now = now.Add(5 * time.Second)
+	q.Process()
+	if got := q.Poll(a.TicketID); got.Status != "matched" || got.Assignment.FoundAtUnix != now.Unix() {
+		t.Fatalf("expected a match once the window reached its 250 cap, got=%+v", got)
+	}
 }
@@ -0,0 +1,190 @@
+package matchmaking
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"projectvelocity/backend/internal/shared/types"
+)
+
+// DefaultReadyCheckTimeout is how long players have to accept a found match.
+const DefaultReadyCheckTimeout = 15 * time.Second
+
+// cooldownSteps escalate with each missed or declined ready check; the last step repeats.
+var cooldownSteps = []time.Duration{30 * time.Second, 2 * time.Minute, 5 * time.Minute, 15 * time.Minute, 30 * time.Minute}
+
+// strikeMemory is how long a declined ready check keeps counting toward escalation.
+const strikeMemory = 24 * time.Hour
+
+var (
+	ErrTicketNotFound = errors.New("matchmaking: ticket not found")
+	ErrNoReadyCheck   = errors.New("matchmaking: ticket has no pending ready check")
+	ErrNotOnTicket    = errors.New("matchmaking: player is not on this ticket")
+)
+
+type readyCheck struct {
+	*pendingMatch
+	deadline time.Time
+	accepted map[string]bool
+}
+
+type cooldown struct {
+	strikes    int
+	lastStrike time.Time
+	until      time.Time
+}
+
+// SetReadyCheckTimeout changes how long players have to accept. Zero disables ready
+// checks so matches go straight to a server.
+func (q *QueueManager) SetReadyCheckTimeout(d time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.readyTimeout = d
+}
+
+// Cooldown returns when playerID may queue again after declining ready checks. The
+// zero time means no cooldown is active.
+func (q *QueueManager) Cooldown(playerID string, now time.Time) time.Time {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if c, ok := q.cooldowns[playerID]; ok && now.Before(c.until) {
+		return c.until
+	}
+	return time.Time{}
+}
+
+// Accept records playerID's answer to the ready check on ticketID. Declining cancels
+// the match for everyone; the last acceptance hands the match to a game server.
+func (q *QueueManager) Accept(ticketID, playerID string, accept bool) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	t, ok := q.ticketIndex[ticketID]
+	if !ok {
+		return ErrTicketNotFound
+	}
+	rc, ok := q.readyChecks[t.readyCheck]
+	if !ok || t.Status != "accept_required" {
+		return ErrNoReadyCheck
+	}
+	if !slices.ContainsFunc(t.Members, func(m types.PartyMember) bool { return m.PlayerID == playerID }) {
+		return ErrNotOnTicket
+	}
+
+	now := q.now()
+	if !accept {
+		q.cancelReadyCheck(rc, map[string]bool{playerID: true}, now)
+		return nil
+	}
+	rc.accepted[playerID] = true
+	for _, t := range rc.tickets {
+		q.touch(t)
+	}
+	for _, m := range members(rc.tickets) {
+		if !rc.accepted[m.PlayerID] {
+			return nil
+		}
+	}
+	delete(q.readyChecks, rc.id)
+	if !q.launch(rc.pendingMatch, now) {
+		// Nobody is at fault when the fleet is full; everyone goes back unpunished.
+		for _, t := range rc.tickets {
+			q.requeue(t)
+		}
+	}
+	return nil
+}
+
+// startReadyCheck parks m's tickets until every player accepts or the deadline passes.
+func (q *QueueManager) startReadyCheck(m *pendingMatch, now time.Time) {
+	rc := &readyCheck{
+		pendingMatch: m,
+		deadline:     now.Add(q.readyTimeout),
+		accepted:     make(map[string]bool),
+	}
+	q.readyChecks[m.id] = rc
+	for _, t := range m.tickets {
+		t.Status = "accept_required"
+		t.readyCheck = m.id
+		q.touch(t)
+	}
+}
+
+// expireReadyChecks cancels ready checks past their deadline, blaming whoever did not
+// accept.
+func (q *QueueManager) expireReadyChecks(now time.Time) {
+	for _, rc := range q.readyChecks {
+		if now.Before(rc.deadline) {
+			continue
+		}
+		missing := make(map[string]bool)
+		for _, m := range members(rc.tickets) {
+			if !rc.accepted[m.PlayerID] {
+				missing[m.PlayerID] = true
+			}
+		}
+		q.cancelReadyCheck(rc, missing, now)
+	}
+}
+
+// cancelReadyCheck drops the tickets holding an offender, so a party leaves together,
+// and puts every other ticket back in its bucket. Requeued tickets keep their original
+// join time, which sorts them ahead of anyone who queued during the ready check.
+func (q *QueueManager) cancelReadyCheck(rc *readyCheck, offenders map[string]bool, now time.Time) {
+	delete(q.readyChecks, rc.id)
+	for _, t := range rc.tickets {
+		blamed := false
+		for _, m := range t.Members {
+			if offenders[m.PlayerID] {
+				blamed = true
+				q.strike(m.PlayerID, now)
+			}
+		}
+		if blamed {
+			t.Status = "cancelled"
+			t.readyCheck = ""
+			q.touch(t)
+			continue
+		}
+		q.requeue(t)
+	}
+}
+
+// requeue returns a ticket to searching in its bucket with its wait time intact.
+func (q *QueueManager) requeue(t *Ticket) {
+	t.Status = "searching"
+	t.readyCheck = ""
+	key := bucketKey(t.Region, t.Playlist)
+	q.buckets[key] = append(q.buckets[key], t)
+	q.touch(t)
+}
+
+// strike puts playerID on the next cooldown step.
+func (q *QueueManager) strike(playerID string, now time.Time) {
+	c, ok := q.cooldowns[playerID]
+	if !ok || now.Sub(c.lastStrike) > strikeMemory {
+		c = &cooldown{}
+		q.cooldowns[playerID] = c
+	}
+	step := min(c.strikes, len(cooldownSteps)-1)
+	c.strikes++
+	c.lastStrike = now
+	c.until = now.Add(cooldownSteps[step])
+}
+
+func (rc *readyCheck) view() *types.ReadyCheck {
+	v := &types.ReadyCheck{
+		MatchID:    rc.id,
+		Playlist:   rc.playlist.Name,
+		Players:    slices.Clone(rc.players),
+		Accepted:   []string{},
+		DeadlineMS: rc.deadline.UnixMilli(),
+	}
+	for _, m := range members(rc.tickets) {
+		if rc.accepted[m.PlayerID] {
+			v.Accepted = append(v.Accepted, m.PlayerID)
+		}
+	}
+	return v
+}
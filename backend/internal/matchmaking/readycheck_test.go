@@ -0,0 +1,105 @@
+package matchmaking
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"projectvelocity/backend/internal/shared/types"
+)
+
+func joinPair(q *QueueManager) (a, b types.QueueJoinResponse) {
+	a = q.Join(types.QueueJoinRequest{PlayerID: "p1", Region: "us-east", Playlist: "ranked-1v1", MMR: 1200})
+	b = q.Join(types.QueueJoinRequest{PlayerID: "p2", Region: "us-east", Playlist: "ranked-1v1", MMR: 1210})
+	return a, b
+}
+
+func TestReadyCheckLaunchesOnceEveryoneAccepts(t *testing.T) {
+	q := NewQueueManager("ws://localhost:9003/ws")
+	a, b := joinPair(q)
+	q.Process()
+
+	poll := q.Poll(a.TicketID)
+	if poll.Status != "accept_required" || poll.ReadyCheck == nil || len(poll.ReadyCheck.Players) != 2 {
+		t.Fatalf("expected a ready check, got=%+v", poll)
+	}
+	if err := q.Accept(a.TicketID, "p2", true); !errors.Is(err, ErrNotOnTicket) {
+		t.Fatalf("expected accepting for someone else's ticket to fail, got=%v", err)
+	}
+	if err := q.Accept(a.TicketID, "p1", true); err != nil {
+		t.Fatal(err)
+	}
+	if got := q.Poll(a.TicketID).Status; got != "accept_required" {
+		t.Fatalf("expected to wait for the second player, got=%s", got)
+	}
+	if err := q.Accept(b.TicketID, "p2", true); err != nil {
+		t.Fatal(err)
+	}
+	if got := q.Poll(b.TicketID); got.Status != "matched" || got.Assignment == nil {
+		t.Fatalf("expected match once both accepted, got=%+v", got)
+	}
+}
+
+func TestReadyCheckDeclineRequeuesOthersAndEscalatesCooldown(t *testing.T) {
+	q := NewQueueManager("ws://localhost:9003/ws")
+	a, b := joinPair(q)
+	q.mu.Lock()
+	joinedAt := time.Now().UTC().Add(-time.Minute)
+	q.ticketIndex[a.TicketID].JoinedAt = joinedAt
+	q.mu.Unlock()
+	q.Process()
+
+	if err := q.Accept(b.TicketID, "p2", false); err != nil {
+		t.Fatal(err)
+	}
+	if got := q.Poll(b.TicketID).Status; got != "cancelled" {
+		t.Fatalf("expected decliner's ticket cancelled, got=%s", got)
+	}
+	if got := q.Poll(a.TicketID).Status; got != "searching" {
+		t.Fatalf("expected the other player back in queue, got=%s", got)
+	}
+	q.mu.RLock()
+	kept := q.ticketIndex[a.TicketID].JoinedAt.Equal(joinedAt)
+	q.mu.RUnlock()
+	if !kept {
+		t.Fatal("expected requeued ticket to keep its wait time")
+	}
+
+	now := time.Now().UTC()
+	first := q.Cooldown("p2", now)
+	if first.Sub(now) < 25*time.Second {
+		t.Fatalf("expected a first cooldown of about 30s, got=%s", first.Sub(now))
+	}
+	q.mu.Lock()
+	q.strike("p2", now)
+	q.mu.Unlock()
+	if second := q.Cooldown("p2", now); second.Sub(now) < time.Minute {
+		t.Fatalf("expected the cooldown to escalate, got=%s", second.Sub(now))
+	}
+}
+
+func TestReadyCheckTimeoutBlamesOnlyMissingPlayers(t *testing.T) {
+	q := NewQueueManager("ws://localhost:9003/ws")
+	a, b := joinPair(q)
+	q.Process()
+	if err := q.Accept(a.TicketID, "p1", true); err != nil {
+		t.Fatal(err)
+	}
+
+	q.mu.Lock()
+	q.expireReadyChecks(time.Now().UTC().Add(DefaultReadyCheckTimeout + time.Second))
+	q.mu.Unlock()
+
+	if got := q.Poll(a.TicketID).Status; got != "searching" {
+		t.Fatalf("expected accepting player requeued, got=%s", got)
+	}
+	if got := q.Poll(b.TicketID).Status; got != "cancelled" {
+		t.Fatalf("expected silent player cancelled, got=%s", got)
+	}
+	if !q.Cooldown("p1", time.Now().UTC()).IsZero() {
+		t.Fatal("expected no cooldown for the player who accepted")
+	}
+	if q.Cooldown("p2", time.Now().UTC()).IsZero() {
+		t.Fatal("expected a cooldown for the player who timed out")
+	}
+}
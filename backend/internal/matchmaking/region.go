@@ -0,0 +1,115 @@
+package matchmaking
+
+import (
+	"sort"
+	"time"
+
+	"projectvelocity/backend/internal/shared/types"
+)
+
+const (
+	// regionSlackMS is how far above their best ping a player will play from the start.
+	regionSlackMS = 20
+	// regionWidenMSPerSec grows that allowance for every second spent waiting.
+	regionWidenMSPerSec = 4
+	// PingCeilingMS is the worst ping a player is ever matched at, however long they wait.
+	PingCeilingMS = 150
+)
+
+// homeRegion picks the region a request waits in, the lowest-ping one when pings were
+// measured, and returns the usable pings.
+func homeRegion(req types.QueueJoinRequest) (string, map[string]int) {
+	pings := make(map[string]int, len(req.Pings))
+	for region, ping := range req.Pings {
+		if region != "" && ping >= 0 {
+			pings[region] = ping
+		}
+	}
+	region := req.Region
+	if len(pings) > 0 {
+		region = bestRegion(pings)
+	} else {
+		pings = nil
+	}
+	if region == "" {
+		region = "global"
+	}
+	return region, pings
+}
+
+// BucketFor returns the key of the bucket Join will put req in.
+func BucketFor(req types.QueueJoinRequest) string {
+	region, _ := homeRegion(req)
+	return bucketKey(region, req.Playlist)
+}
+
+// BucketPlaylist returns the playlist part of a bucket key.
+func BucketPlaylist(bucket string) string {
+	_, playlist := splitKey(bucket)
+	return playlist
+}
+
+// bestRegion returns the lowest-ping region, breaking ties by name.
+func bestRegion(pings map[string]int) string {
+	best := ""
+	for region, ping := range pings {
+		if best == "" || ping < pings[best] || (ping == pings[best] && region < best) {
+			best = region
+		}
+	}
+	return best
+}
+
+// regionsAt lists the regions t will play in at now, best ping first. Tickets without
+// ping data stay in their home region; the rest widen from their best region toward
+// PingCeilingMS as they wait.
+func (t *Ticket) regionsAt(now time.Time) []string {
+	if len(t.Pings) == 0 {
+		return []string{t.Region}
+	}
+	waited := int(now.Sub(t.JoinedAt).Seconds())
+	limit := min(t.Pings[t.Region]+regionSlackMS+regionWidenMSPerSec*waited, PingCeilingMS)
+	regions := []string{t.Region}
+	for region, ping := range t.Pings {
+		if region != t.Region && ping <= limit {
+			regions = append(regions, region)
+		}
+	}
+	sort.SliceStable(regions[1:], func(i, j int) bool {
+		a, b := regions[1+i], regions[1+j]
+		if t.Pings[a] != t.Pings[b] {
+			return t.Pings[a] < t.Pings[b]
+		}
+		return a < b
+	})
+	return regions
+}
+
+// playsIn reports whether t will currently accept a match hosted in region.
+func (t *Ticket) playsIn(region string, now time.Time) bool {
+	for _, r := range t.regionsAt(now) {
+		if r == region {
+			return true
+		}
+	}
+	return false
+}
+
+// expectedPings maps each player in tickets to their measured ping to region. Players
+// whose tickets carry no ping data are left out.
+func expectedPings(tickets []*Ticket, region string) map[string]int {
+	out := make(map[string]int)
+	for _, t := range tickets {
+		ping, ok := t.Pings[region]
+		if !ok {
+			continue
+		}
+		for _, m := range t.Members {
+			out[m.PlayerID] = ping
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
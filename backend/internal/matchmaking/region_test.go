@@ -0,0 +1,61 @@
+package matchmaking
+
+import (
+	"testing"
+	"time"
+
+	"projectvelocity/backend/internal/shared/types"
+)
+
+func TestRegionSearchWidensWithWait(t *testing.T) {
+	q := NewQueueManager("ws://localhost:9003/ws")
+	q.SetReadyCheckTimeout(0)
+	a := q.Join(types.QueueJoinRequest{PlayerID: "east", Playlist: "ranked-1v1", MMR: 1000,
+		Pings: map[string]int{"us-east": 30, "eu-west": 110}})
+	b := q.Join(types.QueueJoinRequest{PlayerID: "west", Playlist: "ranked-1v1", MMR: 1000,
+		Pings: map[string]int{"eu-west": 25, "us-east": 120}})
+
+	q.Process()
+	if got := q.Poll(a.TicketID).Status; got != "searching" {
+		t.Fatalf("expected fresh tickets to stay in their best regions, got=%s", got)
+	}
+	q.mu.RLock()
+	home := q.ticketIndex[b.TicketID].Region
+	q.mu.RUnlock()
+	if home != "eu-west" {
+		t.Fatalf("expected lowest-ping region as home, got=%s", home)
+	}
+
+	q.mu.Lock()
+	q.ticketIndex[a.TicketID].JoinedAt = time.Now().UTC().Add(-26 * time.Second)
+	q.ticketIndex[b.TicketID].JoinedAt = time.Now().UTC().Add(-25 * time.Second)
+	q.mu.Unlock()
+	q.Process()
+
+	poll := q.Poll(a.TicketID)
+	if poll.Status != "matched" {
+		t.Fatalf("expected widened search to match across regions, got=%+v", poll)
+	}
+	if poll.Assignment.Region != "eu-west" {
+		t.Fatalf("expected the region with the lower mean ping, got=%s", poll.Assignment.Region)
+	}
+	if poll.Assignment.Pings["east"] != 110 || poll.Assignment.Pings["west"] != 25 {
+		t.Fatalf("expected expected pings recorded, got=%v", poll.Assignment.Pings)
+	}
+}
+
+func TestRegionSearchRespectsPingCeiling(t *testing.T) {
+	ticket := &Ticket{
+		Region:   "us-east",
+		JoinedAt: time.Now().UTC().Add(-time.Hour),
+		Pings:    map[string]int{"us-east": 40, "us-west": 90, "ap-south": PingCeilingMS + 1},
+	}
+	got := ticket.regionsAt(time.Now().UTC())
+	if len(got) != 2 || got[0] != "us-east" || got[1] != "us-west" {
+		t.Fatalf("expected regions under the ceiling, best first, got=%v", got)
+	}
+	legacy := &Ticket{Region: "eu-west", JoinedAt: time.Now().UTC().Add(-time.Hour)}
+	if regions := legacy.regionsAt(time.Now().UTC()); len(regions) != 1 || regions[0] != "eu-west" {
+		t.Fatalf("expected tickets without pings to stay home, got=%v", regions)
+	}
+}
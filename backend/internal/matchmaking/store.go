@@ -0,0 +1,298 @@
+package matchmaking
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"projectvelocity/backend/internal/shared/types"
+)
+
+// storeRetry is how long RunStore waits before retrying a batch the store rejected.
+const storeRetry = time.Second
+
+// Store persists tickets and their assignments so a restarted matchmaker resumes with
+// its queues intact. QueueManager collects ticket changes under its lock and hands them
+// over in batches from outside it, so a slow disk never holds up matchmaking.
+type Store interface {
+	// Load returns every saved ticket.
+	Load() ([]TicketRecord, error)
+	// Write applies a batch of changes and returns once all of them are durable.
+	Write(changes []Change) error
+	Close() error
+}
+
+// Change is a ticket's latest state, or its removal when Record is nil.
+type Change struct {
+	TicketID string
+	Record   *TicketRecord
+}
+
+// TicketRecord is the durable form of a Ticket and, once matched, its assignment.
+type TicketRecord struct {
+	TicketID    string                 `json:"ticket_id"`
+	PlayerID    string                 `json:"player_id"`
+	DisplayName string                 `json:"display_name"`
+	MMR         int                    `json:"mmr"`
+	Region      string                 `json:"region"`
+	Playlist    string                 `json:"playlist"`
+	JoinedAt    time.Time              `json:"joined_at"`
+	Status      string                 `json:"status"`
+	PartyID     string                 `json:"party_id,omitempty"`
+	Members     []types.PartyMember    `json:"members"`
+	Pings       map[string]int         `json:"pings_ms,omitempty"`
+	Version     uint64                 `json:"version"`
+	ChangedAt   time.Time              `json:"changed_at"`
+	Assignment  *types.MatchAssignment `json:"assignment,omitempty"`
+}
+
+// Bucket returns the queue bucket the ticket searches in.
+func (r TicketRecord) Bucket() string {
+	return bucketKey(r.Region, r.Playlist)
+}
+
+func (q *QueueManager) record(t *Ticket) TicketRecord {
+	return TicketRecord{
+		TicketID:    t.TicketID,
+		PlayerID:    t.PlayerID,
+		DisplayName: t.DisplayName,
+		MMR:         t.MMR,
+		Region:      t.Region,
+		Playlist:    t.Playlist,
+		JoinedAt:    t.JoinedAt,
+		Status:      t.Status,
+		PartyID:     t.PartyID,
+		Members:     t.Members,
+		Pings:       t.Pings,
+		Version:     t.version,
+		ChangedAt:   t.changedAt,
+		Assignment:  q.assignment[t.TicketID],
+	}
+}
+
+// SetStore restores every ticket saved in s and makes s the store for later changes,
+// which RunStore or Flush write out. Ready checks do not survive a restart, so their
+// tickets go back to searching. Call it before Run.
+func (q *QueueManager) SetStore(s Store) error {
+	records, err := s.Load()
+	if err != nil {
+		return err
+	}
+	slices.SortFunc(records, func(a, b TicketRecord) int { return a.JoinedAt.Compare(b.JoinedAt) })
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.store = s
+	for _, rec := range records {
+		q.restore(rec)
+	}
+	return nil
+}
+
+// Export removes every ticket of bucket and returns them, for handing the bucket to
+// another matchmaker. Ready checks involving those tickets are called off without
+// blame, so the exported tickets and their would-be opponents are all searching.
+//
+// The store keeps the exported tickets, since in a cluster it is shared and the new
+// owner takes over writing them; their unwritten changes are dropped so a late write
+// from here cannot overwrite the new owner's.
+func (q *QueueManager) Export(bucket string) []TicketRecord {
+	q.flushMu.Lock()
+	defer q.flushMu.Unlock()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now()
+	var out []TicketRecord
+	for _, t := range q.ticketIndex {
+		if bucketKey(t.Region, t.Playlist) != bucket {
+			continue
+		}
+		if rc, ok := q.readyChecks[t.readyCheck]; ok {
+			q.cancelReadyCheck(rc, nil, now)
+		}
+		out = append(out, q.record(t))
+	}
+	for _, rec := range out {
+		t := q.ticketIndex[rec.TicketID]
+		q.unbucket(t)
+		q.forget(t)
+		delete(q.pending, rec.TicketID)
+	}
+	return out
+}
+
+// Import adds tickets exported by another matchmaker or loaded from a shared store,
+// keeping their IDs, join times and assignments. A ticket already held at a newer
+// version keeps that version.
+func (q *QueueManager) Import(records []TicketRecord) {
+	slices.SortFunc(records, func(a, b TicketRecord) int { return a.JoinedAt.Compare(b.JoinedAt) })
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, rec := range records {
+		if old, ok := q.ticketIndex[rec.TicketID]; ok {
+			if old.version > rec.Version {
+				continue
+			}
+			q.unbucket(old)
+		}
+		t := q.restore(rec)
+		q.save(t)
+	}
+}
+
+// restore rebuilds a ticket from rec. Callers hold q.mu.
+func (q *QueueManager) restore(rec TicketRecord) *Ticket {
+	t := &Ticket{
+		TicketID:    rec.TicketID,
+		PlayerID:    rec.PlayerID,
+		DisplayName: rec.DisplayName,
+		MMR:         rec.MMR,
+		Region:      rec.Region,
+		Playlist:    rec.Playlist,
+		JoinedAt:    rec.JoinedAt,
+		Status:      rec.Status,
+		PartyID:     rec.PartyID,
+		Members:     rec.Members,
+		Pings:       rec.Pings,
+		version:     rec.Version,
+		changedAt:   rec.ChangedAt,
+	}
+	q.ticketIndex[t.TicketID] = t
+	if rec.Assignment != nil {
+		q.assignment[t.TicketID] = rec.Assignment
+	}
+	if t.Status != "cancelled" {
+		for _, m := range t.Members {
+			q.playerTickets[m.PlayerID] = t.TicketID
+		}
+	}
+	if t.Status == "accept_required" {
+		q.requeue(t)
+	} else if t.Status == "searching" {
+		key := bucketKey(t.Region, t.Playlist)
+		q.buckets[key] = append(q.buckets[key], t)
+	}
+	return t
+}
+
+// save queues t's current state for the store. Callers hold q.mu.
+func (q *QueueManager) save(t *Ticket) {
+	rec := q.record(t)
+	q.queueChange(Change{TicketID: t.TicketID, Record: &rec})
+}
+
+// queueChange replaces any unwritten change to the same ticket, since only the latest
+// state matters, and wakes the writer. Callers hold q.mu.
+func (q *QueueManager) queueChange(c Change) {
+	if q.store == nil {
+		return
+	}
+	q.pending[c.TicketID] = c
+	q.markDirty()
+}
+
+// markDirty wakes RunStore without waiting for it.
+func (q *QueueManager) markDirty() {
+	select {
+	case q.dirty <- struct{}{}:
+	default:
+	}
+}
+
+// RunStore writes queued changes to the store in batches until ctx is cancelled, then
+// writes whatever is left. A rejected batch is retried after storeRetry.
+func (q *QueueManager) RunStore(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			q.Flush()
+			return
+		case <-q.dirty:
+		}
+		if q.Flush() == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			q.Flush()
+			return
+		case <-time.After(storeRetry):
+			q.markDirty()
+		}
+	}
+}
+
+// Flush writes every queued change to the store, with q.mu released while the store
+// works. If the store fails, the batch stays queued for the next flush, and StoreErr
+// reports the failure until a flush succeeds.
+func (q *QueueManager) Flush() error {
+	q.flushMu.Lock()
+	defer q.flushMu.Unlock()
+
+	q.mu.Lock()
+	store, batch := q.store, q.pending
+	q.pending = make(map[string]Change)
+	q.mu.Unlock()
+	if store == nil || len(batch) == 0 {
+		return nil
+	}
+
+	err := store.Write(slices.Collect(maps.Values(batch)))
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.storeErr = err
+	if err != nil {
+		q.storeErrors++
+		for id, c := range batch {
+			if _, newer := q.pending[id]; !newer {
+				q.pending[id] = c
+			}
+		}
+	}
+	return err
+}
+
+// StoreErr returns why the last write to the store failed, or nil once one succeeds.
+// While it is set, recent ticket changes would not survive a restart.
+func (q *QueueManager) StoreErr() error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.storeErr
+}
+
+// MemoryStore keeps records in process, so they survive only restarts of the
+// QueueManager, not of the process.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]TicketRecord
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]TicketRecord)}
+}
+
+func (s *MemoryStore) Load() ([]TicketRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Collect(maps.Values(s.records)), nil
+}
+
+func (s *MemoryStore) Write(changes []Change) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range changes {
+		if c.Record == nil {
+			delete(s.records, c.TicketID)
+			continue
+		}
+		s.records[c.TicketID] = *c.Record
+	}
+	return nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,202 @@
+package matchmaking
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"projectvelocity/backend/internal/shared/types"
+)
+
+func TestFileStoreResumesQueuesAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.SetCompactEvery(3)
+	q := NewQueueManager("ws://localhost:9003/ws")
+	q.SetReadyCheckTimeout(0)
+	if err := q.SetStore(store); err != nil {
+		t.Fatal(err)
+	}
+	a := q.Join(types.QueueJoinRequest{PlayerID: "p1", Region: "us-east", Playlist: "ranked-1v1", MMR: 1000})
+	q.Join(types.QueueJoinRequest{PlayerID: "p2", Region: "us-east", Playlist: "ranked-1v1", MMR: 1000})
+	q.Process()
+	if err := q.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	waiting := q.Join(types.QueueJoinRequest{PlayerID: "p3", Region: "us-east", Playlist: "ranked-2v2", MMR: 1000})
+	gone := q.Join(types.QueueJoinRequest{PlayerID: "p4", Region: "us-east", Playlist: "ranked-2v2", MMR: 1000})
+	q.Leave(gone.TicketID, "p4")
+	if err := q.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, snapshotFile)); err != nil {
+		t.Fatalf("expected compaction to write a snapshot: %v", err)
+	}
+	before := q.Poll(a.TicketID)
+	// Simulate a crash: no Close, and a half-written final log line.
+	wal, err := os.OpenFile(filepath.Join(dir, walFile), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = wal.WriteString(`{"op":"put","record":{"ticket_id":`)
+	wal.Close()
+
+	reopened, err := OpenFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	restarted := NewQueueManager("ws://localhost:9003/ws")
+	restarted.SetReadyCheckTimeout(0)
+	if err := restarted.SetStore(reopened); err != nil {
+		t.Fatal(err)
+	}
+
+	after := restarted.Poll(a.TicketID)
+	if after.Status != "matched" || after.Assignment == nil || after.Assignment.MatchID != before.Assignment.MatchID {
+		t.Fatalf("expected assignment to survive restart, before=%+v after=%+v", before, after)
+	}
+	if got := restarted.Poll(gone.TicketID).Status; got != "cancelled" {
+		t.Fatalf("expected cancelled ticket to survive restart, got=%s", got)
+	}
+	restarted.Join(types.QueueJoinRequest{PlayerID: "p5", Region: "us-east", Playlist: "ranked-2v2", MMR: 1000})
+	restarted.Join(types.QueueJoinRequest{PlayerID: "p6", Region: "us-east", Playlist: "ranked-2v2", MMR: 1000})
+	restarted.Join(types.QueueJoinRequest{PlayerID: "p7", Region: "us-east", Playlist: "ranked-2v2", MMR: 1000})
+	restarted.Process()
+	if got := restarted.Poll(waiting.TicketID).Status; got != "matched" {
+		t.Fatalf("expected restored searching ticket back in its bucket, got=%s", got)
+	}
+}
+
+func TestStoreRequeuesTicketsCaughtInReadyCheck(t *testing.T) {
+	store := NewMemoryStore()
+	q := NewQueueManager("ws://localhost:9003/ws")
+	if err := q.SetStore(store); err != nil {
+		t.Fatal(err)
+	}
+	a := q.Join(types.QueueJoinRequest{PlayerID: "p1", Region: "us-east", Playlist: "ranked-1v1", MMR: 1000})
+	q.Join(types.QueueJoinRequest{PlayerID: "p2", Region: "us-east", Playlist: "ranked-1v1", MMR: 1000})
+	q.Process()
+	if got := q.Poll(a.TicketID).Status; got != "accept_required" {
+		t.Fatalf("expected ready check, got=%s", got)
+	}
+	if err := q.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := NewQueueManager("ws://localhost:9003/ws")
+	if err := restarted.SetStore(store); err != nil {
+		t.Fatal(err)
+	}
+	if got := restarted.Poll(a.TicketID).Status; got != "searching" {
+		t.Fatalf("expected ticket requeued after losing its ready check, got=%s", got)
+	}
+	if err := restarted.Accept(a.TicketID, "p1", true); err != ErrNoReadyCheck {
+		t.Fatalf("expected no ready check after restart, got=%v", err)
+	}
+}
+
+func TestExportHandsBucketToAnotherManager(t *testing.T) {
+	shared := NewMemoryStore()
+	from := NewQueueManager("ws://localhost:9003/ws")
+	to := NewQueueManager("ws://localhost:9003/ws")
+	to.SetReadyCheckTimeout(0)
+	for _, q := range []*QueueManager{from, to} {
+		if err := q.SetStore(shared); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a := from.Join(types.QueueJoinRequest{PlayerID: "p1", Region: "us-east", Playlist: "ranked-1v1", MMR: 1000})
+	b := from.Join(types.QueueJoinRequest{PlayerID: "p2", Region: "us-east", Playlist: "ranked-1v1", MMR: 1000})
+	other := from.Join(types.QueueJoinRequest{PlayerID: "p3", Region: "us-east", Playlist: "ranked-2v2", MMR: 1000})
+	from.Process()
+	if err := from.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	records := from.Export(BucketFor(types.QueueJoinRequest{Region: "us-east", Playlist: "ranked-1v1"}))
+	if len(records) != 2 {
+		t.Fatalf("expected both ranked-1v1 tickets exported, got=%d", len(records))
+	}
+	if got := from.Poll(a.TicketID).Status; got != "not_found" {
+		t.Fatalf("expected exported ticket gone from the sender, got=%s", got)
+	}
+	if got := from.Poll(other.TicketID).Status; got != "searching" {
+		t.Fatalf("expected other buckets untouched, got=%s", got)
+	}
+	if err := from.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if saved, _ := shared.Load(); len(saved) != 3 {
+		t.Fatalf("expected the shared store to keep exported tickets for the new owner, got=%d", len(saved))
+	}
+
+	to.Import(records)
+	to.Process()
+	if got := to.Poll(b.TicketID).Status; got != "matched" {
+		t.Fatalf("expected imported tickets to match on the receiver, got=%s", got)
+	}
+	// A copy loaded later from the store is older than the match and must not undo it.
+	to.Import(records)
+	if got := to.Poll(b.TicketID).Status; got != "matched" {
+		t.Fatalf("expected the newer ticket to win over a stale copy, got=%s", got)
+	}
+}
+
+// gatedStore blocks every Write until release is closed and fails while fail is set.
+type gatedStore struct {
+	*MemoryStore
+	release chan struct{}
+	fail    bool
+}
+
+func (s *gatedStore) Write(changes []Change) error {
+	<-s.release
+	if s.fail {
+		return errors.New("disk full")
+	}
+	return s.MemoryStore.Write(changes)
+}
+
+func TestSlowStoreDoesNotHoldTheQueueAndFailuresAreRetried(t *testing.T) {
+	store := &gatedStore{MemoryStore: NewMemoryStore(), release: make(chan struct{}), fail: true}
+	q := NewQueueManager("ws://localhost:9003/ws")
+	if err := q.SetStore(store); err != nil {
+		t.Fatal(err)
+	}
+	a := q.Join(types.QueueJoinRequest{PlayerID: "p1", Region: "us-east", Playlist: "ranked-1v1", MMR: 1000})
+	flushed := make(chan error)
+	go func() { flushed <- q.Flush() }()
+
+	joined := make(chan struct{})
+	go func() {
+		q.Join(types.QueueJoinRequest{PlayerID: "p2", Region: "us-east", Playlist: "ranked-1v1", MMR: 1000})
+		close(joined)
+	}()
+	select {
+	case <-joined:
+	case <-time.After(time.Second):
+		t.Fatal("expected a join to go through while the store was writing")
+	}
+
+	close(store.release)
+	if err := <-flushed; err == nil || q.StoreErr() == nil || q.Stats().StoreErrors != 1 {
+		t.Fatalf("expected the failed write to be reported, flush=%v StoreErr=%v", err, q.StoreErr())
+	}
+	store.fail = false
+	if err := q.Flush(); err != nil || q.StoreErr() != nil {
+		t.Fatalf("expected the retry to succeed, flush=%v StoreErr=%v", err, q.StoreErr())
+	}
+	records, _ := store.Load()
+	if len(records) != 2 {
+		t.Fatalf("expected the failed batch to be written on retry, got %d records", len(records))
+	}
+	if got := q.Poll(a.TicketID).Status; got != "searching" {
+		t.Fatalf("expected the queue unaffected by the store, got=%s", got)
+	}
+}
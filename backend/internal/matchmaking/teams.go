@@ -0,0 +1,100 @@
+package matchmaking
+
+import (
+	"math"
+
+	"projectvelocity/backend/internal/shared/types"
+)
+
+// spreadWeight is how much within-team MMR spread counts against a split, relative to
+// the difference between team averages.
+const spreadWeight = 0.25
+
+// headcountWeight makes uneven team sizes dominate every MMR consideration; it only
+// comes into play for bot-filled matches.
+const headcountWeight = 10000
+
+// balanceTeams splits the tickets in group between orange and blue without breaking up
+// parties and without exceeding teamSize on either side. Among valid splits it prefers
+// even head counts (orange takes the extra player), then the smallest gap between team
+// average MMR plus a penalty for wide spreads inside each team. Groups are at most two
+// full teams, so trying every split is cheap. ok is false when no split fits.
+func balanceTeams(group []*Ticket, teamSize int) (orange, blue []*Ticket, ok bool) {
+	k := len(group)
+	bestCost := math.Inf(1)
+	bestMask := -1
+	for mask := 0; mask < 1<<k; mask++ {
+		var o, b []*Ticket
+		for i, t := range group {
+			if mask&(1<<i) != 0 {
+				o = append(o, t)
+			} else {
+				b = append(b, t)
+			}
+		}
+		on, bn := headcount(o), headcount(b)
+		if on > teamSize || bn > teamSize || on < bn {
+			continue
+		}
+		cost := float64(headcountWeight*(on-bn)) + teamCost(members(o), members(b))
+		if cost < bestCost {
+			bestCost = cost
+			bestMask = mask
+		}
+	}
+	if bestMask < 0 {
+		return nil, nil, false
+	}
+	for i, t := range group {
+		if bestMask&(1<<i) != 0 {
+			orange = append(orange, t)
+		} else {
+			blue = append(blue, t)
+		}
+	}
+	return orange, blue, true
+}
+
+func headcount(team []*Ticket) int {
+	n := 0
+	for _, t := range team {
+		n += t.size()
+	}
+	return n
+}
+
+func members(team []*Ticket) []types.PartyMember {
+	var out []types.PartyMember
+	for _, t := range team {
+		out = append(out, t.Members...)
+	}
+	return out
+}
+
+func teamCost(orange, blue []types.PartyMember) float64 {
+	cost := spreadWeight * float64(spread(orange)+spread(blue))
+	if len(orange) > 0 && len(blue) > 0 {
+		cost += math.Abs(meanMMR(orange) - meanMMR(blue))
+	}
+	return cost
+}
+
+func meanMMR(team []types.PartyMember) float64 {
+	sum := 0
+	for _, m := range team {
+		sum += m.MMR
+	}
+	return float64(sum) / float64(len(team))
+}
+
+func spread(team []types.PartyMember) int {
+	if len(team) == 0 {
+		return 0
+	}
+	lo, hi := team[0].MMR, team[0].MMR
+	for _, m := range team[1:] {
+		lo = min(lo, m.MMR)
+		hi = max(hi, m.MMR)
+	}
+	return hi - lo
+}
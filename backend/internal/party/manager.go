@@ -0,0 +1,184 @@
+package party
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"projectvelocity/backend/internal/shared/types"
+)
+
+// MaxSize is the largest party; it matches the biggest team any playlist offers.
+const MaxSize = 4
+
+var (
+	ErrAlreadyInParty = errors.New("party: player is already in a party")
+	ErrNotInParty     = errors.New("party: player is not in a party")
+	ErrNotLeader      = errors.New("party: only the leader can do that")
+	ErrNotInvited     = errors.New("party: player was not invited")
+	ErrFull           = errors.New("party: party is full")
+	ErrNotFound       = errors.New("party: party not found")
+)
+
+// Manager holds parties in memory and indexes them by member.
+type Manager struct {
+	mu       sync.Mutex
+	parties  map[string]*types.Party
+	byPlayer map[string]string
+	seq      uint64
+}
+
+// NewManager creates an empty party manager.
+func NewManager() *Manager {
+	return &Manager{
+		parties:  make(map[string]*types.Party),
+		byPlayer: make(map[string]string),
+	}
+}
+
+// Create starts a party led by leader.
+func (m *Manager) Create(leader types.PartyMember) (types.Party, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.byPlayer[leader.PlayerID]; ok {
+		return types.Party{}, ErrAlreadyInParty
+	}
+	m.seq++
+	p := &types.Party{
+		PartyID:   fmt.Sprintf("party_%d_%d", time.Now().UTC().UnixNano(), m.seq),
+		LeaderID:  leader.PlayerID,
+		Members:   []types.PartyMember{leader},
+		Invites:   []string{},
+		CreatedAt: time.Now().UTC().Unix(),
+	}
+	m.parties[p.PartyID] = p
+	m.byPlayer[leader.PlayerID] = p.PartyID
+	return clone(p), nil
+}
+
+// Invite lets the leader invite playerID.
+func (m *Manager) Invite(leaderID, playerID string) (types.Party, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, err := m.ledBy(leaderID)
+	if err != nil {
+		return types.Party{}, err
+	}
+	if len(p.Members)+len(p.Invites) >= MaxSize {
+		return types.Party{}, ErrFull
+	}
+	if _, ok := m.byPlayer[playerID]; ok {
+		return types.Party{}, ErrAlreadyInParty
+	}
+	if !slices.Contains(p.Invites, playerID) {
+		p.Invites = append(p.Invites, playerID)
+	}
+	return clone(p), nil
+}
+
+// Accept adds an invited player to the party. It returns the ticket the party was
+// queued with, which the caller must cancel since the roster changed.
+func (m *Manager) Accept(partyID string, member types.PartyMember) (types.Party, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.parties[partyID]
+	if !ok {
+		return types.Party{}, "", ErrNotFound
+	}
+	if _, ok := m.byPlayer[member.PlayerID]; ok {
+		return types.Party{}, "", ErrAlreadyInParty
+	}
+	i := slices.Index(p.Invites, member.PlayerID)
+	if i < 0 {
+		return types.Party{}, "", ErrNotInvited
+	}
+	p.Invites = slices.Delete(p.Invites, i, i+1)
+	p.Members = append(p.Members, member)
+	m.byPlayer[member.PlayerID] = p.PartyID
+	ticket := p.TicketID
+	p.TicketID = ""
+	return clone(p), ticket, nil
+}
+
+// Leave removes playerID from their party; a leaving leader disbands it. The returned
+// ticket, if any, must be cancelled: one member leaving takes the whole party out of queue.
+func (m *Manager) Leave(playerID string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id, ok := m.byPlayer[playerID]
+	if !ok {
+		return "", ErrNotInParty
+	}
+	p := m.parties[id]
+	if p.LeaderID == playerID {
+		return m.disbandLocked(p), nil
+	}
+	p.Members = slices.DeleteFunc(p.Members, func(mem types.PartyMember) bool { return mem.PlayerID == playerID })
+	delete(m.byPlayer, playerID)
+	ticket := p.TicketID
+	p.TicketID = ""
+	return ticket, nil
+}
+
+// Disband dissolves the leader's party and returns its ticket for cancellation.
+func (m *Manager) Disband(leaderID string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, err := m.ledBy(leaderID)
+	if err != nil {
+		return "", err
+	}
+	return m.disbandLocked(p), nil
+}
+
+// Get returns the party playerID belongs to.
+func (m *Manager) Get(playerID string) (types.Party, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id, ok := m.byPlayer[playerID]
+	if !ok {
+		return types.Party{}, false
+	}
+	return clone(m.parties[id]), true
+}
+
+// SetTicket records the ticket the party is queued with. Only the leader may queue.
+func (m *Manager) SetTicket(leaderID, ticketID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, err := m.ledBy(leaderID)
+	if err != nil {
+		return err
+	}
+	p.TicketID = ticketID
+	return nil
+}
+
+func (m *Manager) ledBy(leaderID string) (*types.Party, error) {
+	id, ok := m.byPlayer[leaderID]
+	if !ok {
+		return nil, ErrNotInParty
+	}
+	p := m.parties[id]
+	if p.LeaderID != leaderID {
+		return nil, ErrNotLeader
+	}
+	return p, nil
+}
+
+func (m *Manager) disbandLocked(p *types.Party) string {
+	for _, mem := range p.Members {
+		delete(m.byPlayer, mem.PlayerID)
+	}
+	delete(m.parties, p.PartyID)
+	return p.TicketID
+}
+
+func clone(p *types.Party) types.Party {
+	out := *p
+	out.Members = slices.Clone(p.Members)
+	out.Invites = slices.Clone(p.Invites)
+	return out
+}
@@ -0,0 +1,76 @@
+package party
+
+import (
+	"errors"
+	"testing"
+
+	"projectvelocity/backend/internal/shared/types"
+)
+
+func TestInviteAcceptAndQueueInvalidation(t *testing.T) {
+	m := NewManager()
+	p, err := m.Create(types.PartyMember{PlayerID: "lead"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := m.Accept(p.PartyID, types.PartyMember{PlayerID: "mate"}); !errors.Is(err, ErrNotInvited) {
+		t.Fatalf("expected uninvited accept to fail, got=%v", err)
+	}
+	if _, err := m.Invite("mate", "lead"); !errors.Is(err, ErrNotInParty) {
+		t.Fatalf("expected non-member invite to fail, got=%v", err)
+	}
+	if _, err := m.Invite("lead", "mate"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetTicket("lead", "t1"); err != nil {
+		t.Fatal(err)
+	}
+	joined, ticket, err := m.Accept(p.PartyID, types.PartyMember{PlayerID: "mate"})
+	if err != nil || len(joined.Members) != 2 || len(joined.Invites) != 0 {
+		t.Fatalf("expected mate to join, party=%+v err=%v", joined, err)
+	}
+	if ticket != "t1" {
+		t.Fatalf("expected roster change to hand back queued ticket, got=%q", ticket)
+	}
+	if err := m.SetTicket("mate", "t2"); !errors.Is(err, ErrNotLeader) {
+		t.Fatalf("expected only leader to queue, got=%v", err)
+	}
+}
+
+func TestLeaveCancelsTicketAndLeaderLeavingDisbands(t *testing.T) {
+	m := NewManager()
+	p, _ := m.Create(types.PartyMember{PlayerID: "lead"})
+	for _, id := range []string{"a", "b"} {
+		m.Invite("lead", id)
+		m.Accept(p.PartyID, types.PartyMember{PlayerID: id})
+	}
+	m.SetTicket("lead", "t1")
+
+	ticket, err := m.Leave("a")
+	if err != nil || ticket != "t1" {
+		t.Fatalf("expected member leaving to cancel the party ticket, ticket=%q err=%v", ticket, err)
+	}
+	if got, _ := m.Get("lead"); len(got.Members) != 2 || got.TicketID != "" {
+		t.Fatalf("expected two members left and no ticket, got=%+v", got)
+	}
+
+	if _, err := m.Leave("lead"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.Get("b"); ok {
+		t.Fatal("expected leader leaving to disband the party")
+	}
+}
+
+func TestPartyIsCappedAtMaxSize(t *testing.T) {
+	m := NewManager()
+	m.Create(types.PartyMember{PlayerID: "lead"})
+	for _, id := range []string{"a", "b", "c"} {
+		if _, err := m.Invite("lead", id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := m.Invite("lead", "d"); !errors.Is(err, ErrFull) {
+		t.Fatalf("expected invites beyond MaxSize to fail, got=%v", err)
+	}
+}
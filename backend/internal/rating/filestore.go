@@ -0,0 +1,211 @@
+package rating
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+const (
+	snapshotFile = "ratings.snapshot.json"
+	walFile      = "ratings.wal"
+	// DefaultCompactEvery is how many log entries FileStore appends before it folds them
+	// into a fresh snapshot.
+	DefaultCompactEvery = 1000
+)
+
+var ErrStoreClosed = errors.New("rating: store closed")
+
+// walEntry is one line of the write-ahead log.
+type walEntry struct {
+	Op     string        `json:"op"` // put
+	Rating *PlayerRating `json:"rating,omitempty"`
+}
+
+// FileStore is a durable Store: every change is appended and synced to a write-ahead
+// log, which is periodically compacted into a snapshot. On open the snapshot is loaded
+// and the log replayed; a torn final line from a crash is ignored. Its files do not
+// clash with the queue's or conduct's, so all of them can share the matchmaker's data
+// directory.
+type FileStore struct {
+	mu           sync.Mutex
+	dir          string
+	ratings      map[string]PlayerRating
+	wal          *os.File
+	entries      int
+	compactEvery int
+}
+
+// OpenFileStore opens or creates a store in dir.
+func OpenFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	s := &FileStore{dir: dir, ratings: make(map[string]PlayerRating), compactEvery: DefaultCompactEvery}
+	if err := s.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	torn, err := s.replay()
+	if err != nil {
+		return nil, err
+	}
+	wal, err := os.OpenFile(filepath.Join(dir, walFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	s.wal = wal
+	if torn {
+		// Appending after a torn line would hide the new entries from the next replay.
+		if err := s.compact(); err != nil {
+			wal.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// SetCompactEvery changes how many log entries trigger a snapshot.
+func (s *FileStore) SetCompactEvery(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.compactEvery = n
+}
+
+func (s *FileStore) Load() ([]PlayerRating, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Collect(maps.Values(s.ratings)), nil
+}
+
+func (s *FileStore) Put(rec PlayerRating) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ratings[key(rec.PlayerID, rec.Playlist)] = rec
+	return s.append(walEntry{Op: "put", Rating: &rec})
+}
+
+// Close writes a final snapshot so the next open has no log to replay.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.wal == nil {
+		return nil
+	}
+	err := s.compact()
+	if cerr := s.wal.Close(); err == nil {
+		err = cerr
+	}
+	s.wal = nil
+	return err
+}
+
+// append logs e and syncs it to disk. Callers hold s.mu.
+func (s *FileStore) append(e walEntry) error {
+	if s.wal == nil {
+		return ErrStoreClosed
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := s.wal.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if err := s.wal.Sync(); err != nil {
+		return err
+	}
+	s.entries++
+	if s.compactEvery > 0 && s.entries >= s.compactEvery {
+		return s.compact()
+	}
+	return nil
+}
+
+// compact writes every rating to a new snapshot, swaps it in atomically and empties the
+// log. Callers hold s.mu.
+func (s *FileStore) compact() error {
+	body, err := json.Marshal(slices.Collect(maps.Values(s.ratings)))
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(s.dir, snapshotFile+".tmp")
+	if err := writeSynced(tmp, body); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, snapshotFile)); err != nil {
+		return err
+	}
+	if err := s.wal.Truncate(0); err != nil {
+		return err
+	}
+	s.entries = 0
+	return nil
+}
+
+func (s *FileStore) loadSnapshot() error {
+	body, err := os.ReadFile(filepath.Join(s.dir, snapshotFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var ratings []PlayerRating
+	if err := json.Unmarshal(body, &ratings); err != nil {
+		return fmt.Errorf("rating: corrupt snapshot: %w", err)
+	}
+	for _, rec := range ratings {
+		s.ratings[key(rec.PlayerID, rec.Playlist)] = rec
+	}
+	return nil
+}
+
+// replay applies the log on top of the snapshot and reports whether it ended in a torn
+// line.
+func (s *FileStore) replay() (bool, error) {
+	f, err := os.Open(filepath.Join(s.dir, walFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var e walEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// Only the last write can be torn; everything before it was synced whole.
+			return true, nil
+		}
+		if e.Op == "put" && e.Rating != nil {
+			s.ratings[key(e.Rating.PlayerID, e.Rating.Playlist)] = *e.Rating
+		}
+		s.entries++
+	}
+	return false, scanner.Err()
+}
+
+func writeSynced(path string, body []byte) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(body); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,110 @@
+package rating
+
+import "math"
+
+// Glicko-2 constants. Ratings are centred on DefaultMean rather than the usual 1500 so
+// they line up with the MMR scale the matchmaker's search windows were tuned for.
+const (
+	DefaultMean       = 1000.0
+	DefaultDeviation  = 350.0
+	DefaultVolatility = 0.06
+	// MinDeviation stops long-time players from becoming immovable.
+	MinDeviation = 30.0
+
+	glickoScale = 173.7178
+	// tau constrains how quickly volatility can change.
+	tau = 0.5
+	// convergence is the tolerance of the volatility root finder.
+	convergence = 0.000001
+)
+
+// Rating is a player's skill estimate: the mean, the uncertainty around it, and how
+// erratic their results have been.
+type Rating struct {
+	Mean       float64 `json:"mean"`
+	Deviation  float64 `json:"deviation"`
+	Volatility float64 `json:"volatility"`
+	Games      int     `json:"games"`
+}
+
+// Default is the rating every player starts from.
+func Default() Rating {
+	return Rating{Mean: DefaultMean, Deviation: DefaultDeviation, Volatility: DefaultVolatility}
+}
+
+// Conservative is a lower-bound skill estimate, useful for leaderboards.
+func (r Rating) Conservative() float64 {
+	return r.Mean - 2*r.Deviation
+}
+
+// outcome is one game within a rating period.
+type outcome struct {
+	opp   Rating
+	score float64 // 1 win, 0.5 draw, 0 loss
+}
+
+// update applies one Glicko-2 rating period to r. Live matches are rated one at a time,
+// but the period form is kept so the implementation can be checked against the paper.
+func update(r Rating, games []outcome) Rating {
+	mu := (r.Mean - DefaultMean) / glickoScale
+	phi := r.Deviation / glickoScale
+
+	var vInv, sum float64
+	for _, game := range games {
+		muJ := (game.opp.Mean - DefaultMean) / glickoScale
+		phiJ := game.opp.Deviation / glickoScale
+		g := 1 / math.Sqrt(1+3*phiJ*phiJ/(math.Pi*math.Pi))
+		e := 1 / (1 + math.Exp(-g*(mu-muJ)))
+		vInv += g * g * e * (1 - e)
+		sum += g * (game.score - e)
+	}
+	v := 1 / vInv
+	delta := v * sum
+
+	sigma := newVolatility(phi, r.Volatility, v, delta)
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	phiNew := 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	muNew := mu + phiNew*phiNew*sum
+
+	return Rating{
+		Mean:       muNew*glickoScale + DefaultMean,
+		Deviation:  math.Max(phiNew*glickoScale, MinDeviation),
+		Volatility: sigma,
+		Games:      r.Games + len(games),
+	}
+}
+
+// newVolatility solves for the post-period volatility with the Illinois method.
+func newVolatility(phi, sigma, v, delta float64) float64 {
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		num := ex * (delta*delta - phi*phi - v - ex)
+		den := 2 * (phi*phi + v + ex) * (phi*phi + v + ex)
+		return num/den - (x-a)/(tau*tau)
+	}
+
+	hi := a
+	var lo float64
+	if delta*delta > phi*phi+v {
+		lo = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*tau) < 0 {
+			k++
+		}
+		lo = a - k*tau
+	}
+	fHi, fLo := f(hi), f(lo)
+	for math.Abs(lo-hi) > convergence {
+		c := hi + (hi-lo)*fHi/(fLo-fHi)
+		fC := f(c)
+		if fC*fLo < 0 {
+			hi, fHi = lo, fLo
+		} else {
+			fHi /= 2
+		}
+		lo, fLo = c, fC
+	}
+	return math.Exp(hi / 2)
+}
@@ -0,0 +1,95 @@
+package rating
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Lookup is one player's rating as served by Handler.
+type Lookup struct {
+	PlayerID string `json:"player_id"`
+	Playlist string `json:"playlist"`
+	Rating   Rating `json:"rating"`
+	MMR      int    `json:"mmr"`
+}
+
+// Handler serves GET ?player_id=&playlist= with the player's rating in s. The playlist
+// defaults to ranked-1v1.
+func Handler(s *Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+			return
+		}
+		playerID := r.URL.Query().Get("player_id")
+		if playerID == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "player_id_required"})
+			return
+		}
+		playlist := r.URL.Query().Get("playlist")
+		if playlist == "" {
+			playlist = "ranked-1v1"
+		}
+		rating := s.Get(playerID, playlist)
+		writeJSON(w, http.StatusOK, Lookup{
+			PlayerID: playerID,
+			Playlist: playlist,
+			Rating:   rating,
+			MMR:      int(math.Round(rating.Mean)),
+		})
+	})
+}
+
+// Remote reads ratings from the matchmaker instance that applies results, so every
+// instance of a cluster places players by the same skill.
+type Remote struct {
+	client *http.Client
+	base   func() (string, bool)
+	local  *Service
+}
+
+// NewRemote reads from the Handler at the URL base returns, e.g.
+// "http://matchmaker-1:9001/v1/ratings". When base reports false, because this instance
+// applies results itself, or the lookup fails, local answers instead.
+func NewRemote(client *http.Client, base func() (string, bool), local *Service) *Remote {
+	if client == nil {
+		client = &http.Client{Timeout: 2 * time.Second}
+	}
+	return &Remote{client: client, base: base, local: local}
+}
+
+func (r *Remote) MMR(playerID, playlist string) int {
+	base, ok := r.base()
+	if !ok {
+		return r.local.MMR(playerID, playlist)
+	}
+	l, err := r.lookup(base, playerID, playlist)
+	if err != nil {
+		return r.local.MMR(playerID, playlist)
+	}
+	return l.MMR
+}
+
+func (r *Remote) lookup(base, playerID, playlist string) (Lookup, error) {
+	var l Lookup
+	resp, err := r.client.Get(base + "?" + url.Values{"player_id": {playerID}, "playlist": {playlist}}.Encode())
+	if err != nil {
+		return l, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return l, fmt.Errorf("rating: lookup returned %d", resp.StatusCode)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&l)
+	return l, err
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
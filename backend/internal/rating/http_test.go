@@ -0,0 +1,74 @@
+package rating
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"projectvelocity/backend/internal/cluster"
+	"projectvelocity/backend/internal/matchmaking"
+	"projectvelocity/backend/internal/shared/types"
+)
+
+// TestResultOnPrimaryMovesMMRForJoinOnAnotherNode runs two matchmaker nodes on one
+// shared store: a is primary and applies results, b owns the bucket a join lands in.
+func TestResultOnPrimaryMovesMMRForJoinOnAnotherNode(t *testing.T) {
+	store := cluster.NewMemoryStore()
+	ratingsA := NewService()
+	mux := http.NewServeMux()
+	mux.Handle("/v1/ratings", Handler(ratingsA))
+	srvA := httptest.NewServer(mux)
+	defer srvA.Close()
+
+	nodeA := cluster.NewNode(store, cluster.Member{ID: "a", Addr: srvA.URL}, 10*time.Second)
+	nodeB := cluster.NewNode(store, cluster.Member{ID: "b", Addr: "http://b"}, 10*time.Second)
+	for _, n := range []*cluster.Node{nodeA, nodeB} {
+		if err := n.Join(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if p, err := nodeA.Primary(); err != nil || p.ID != "a" {
+		t.Fatalf("expected a to be primary, got=%+v err=%v", p, err)
+	}
+
+	queueB := matchmaking.NewQueueManager("ws://localhost:9003/ws")
+	queueB.SetRatingSource(NewRemote(srvA.Client(), func() (string, bool) {
+		p, err := nodeB.Primary()
+		if err != nil || p.ID == nodeB.Self().ID {
+			return "", false
+		}
+		return p.Addr + "/v1/ratings", true
+	}, NewService()))
+	joinOnB := func() int {
+		req := types.QueueJoinRequest{PlayerID: "ace", Region: "us-east", Playlist: "ranked-1v1", MMR: 9999}
+		queueB.Join(req)
+		tickets := queueB.Export(matchmaking.BucketFor(req))
+		if len(tickets) != 1 {
+			t.Fatalf("expected one ticket on b, got=%+v", tickets)
+		}
+		return tickets[0].MMR
+	}
+
+	if got, want := joinOnB(), int(math.Round(DefaultMean)); got != want {
+		t.Fatalf("expected an unrated player at the default, got=%d want=%d", got, want)
+	}
+	ratingsA.Apply(types.MatchResult{
+		MatchID:    "m1",
+		Playlist:   "ranked-1v1",
+		DurationMS: 300000,
+		Winner:     "orange",
+		Players: []types.PlayerMatchStats{
+			{PlayerID: "ace", Team: "orange", TimePlayedMS: 300000},
+			{PlayerID: "rookie", Team: "blue", TimePlayedMS: 300000},
+		},
+	})
+	want := ratingsA.MMR("ace", "ranked-1v1")
+	if want <= int(math.Round(DefaultMean)) {
+		t.Fatalf("expected the win to raise ace's rating on a, got=%d", want)
+	}
+	if got := joinOnB(); got != want {
+		t.Fatalf("expected b to queue ace at the primary's rating, got=%d want=%d", got, want)
+	}
+}
@@ -0,0 +1,167 @@
+package rating
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"projectvelocity/backend/internal/shared/types"
+)
+
+// TestUpdateMatchesGlickmanExample reproduces the worked example from the Glicko-2 paper,
+// shifted from its 1500 centre onto DefaultMean.
+func TestUpdateMatchesGlickmanExample(t *testing.T) {
+	shift := DefaultMean - 1500
+	r := Rating{Mean: 1500 + shift, Deviation: 200, Volatility: 0.06}
+	got := update(r, []outcome{
+		{opp: Rating{Mean: 1400 + shift, Deviation: 30}, score: 1},
+		{opp: Rating{Mean: 1550 + shift, Deviation: 100}, score: 0},
+		{opp: Rating{Mean: 1700 + shift, Deviation: 300}, score: 0},
+	})
+	if math.Abs(got.Mean-(1464.06+shift)) > 0.05 {
+		t.Fatalf("mean: got=%f want=%f", got.Mean, 1464.06+shift)
+	}
+	if math.Abs(got.Deviation-151.52) > 0.05 {
+		t.Fatalf("deviation: got=%f want=151.52", got.Deviation)
+	}
+	if math.Abs(got.Volatility-0.05999) > 0.00001 {
+		t.Fatalf("volatility: got=%f want=0.05999", got.Volatility)
+	}
+}
+
+func TestApplyRatesTeamsAndScalesPartialParticipation(t *testing.T) {
+	s := NewService()
+	res := types.MatchResult{
+		MatchID:    "m1",
+		Playlist:   "ranked-2v2",
+		DurationMS: 300000,
+		Winner:     "orange",
+		Players: []types.PlayerMatchStats{
+			{PlayerID: "a", Team: "orange", TimePlayedMS: 300000},
+			{PlayerID: "b", Team: "orange", TimePlayedMS: 150000},
+			{PlayerID: "c", Team: "blue", TimePlayedMS: 300000},
+			{PlayerID: "d", Team: "blue", TimePlayedMS: 30000, Abandoned: true},
+		},
+	}
+	out := s.Apply(res)
+	if len(out) != 4 {
+		t.Fatalf("expected all four humans rated, got=%d", len(out))
+	}
+	full := out["a"].Mean - DefaultMean
+	half := out["b"].Mean - DefaultMean
+	if full <= 0 || math.Abs(half-full/2) > 0.01 {
+		t.Fatalf("expected half-time teammate to gain half as much: full=%f half=%f", full, half)
+	}
+	loss := update(Default(), []outcome{{opp: compositeOf([]Rating{Default(), Default()}), score: 0}})
+	if math.Abs(out["d"].Mean-loss.Mean) > 1e-9 {
+		t.Fatalf("expected abandoner to take the full loss: got=%f want=%f", out["d"].Mean, loss.Mean)
+	}
+	if out["c"].Mean != DefaultMean || out["c"].Games != 1 {
+		t.Fatalf("expected the abandoner's teammate to get the loss back: %+v", out["c"])
+	}
+	if s.MMR("a", "ranked-2v2") <= int(DefaultMean) || s.MMR("a", "ranked-1v1") != int(DefaultMean) {
+		t.Fatal("expected ratings to be tracked per playlist")
+	}
+}
+
+func TestApplySkipsBotOnlyPrivateAndEmptyMatches(t *testing.T) {
+	s := NewService()
+	out := s.Apply(types.MatchResult{
+		MatchID:  "m1",
+		Playlist: "ranked-1v1",
+		Winner:   "orange",
+		Players: []types.PlayerMatchStats{
+			{PlayerID: "a", Team: "orange"},
+			{PlayerID: "bot_blue", Team: "blue", IsBot: true},
+		},
+	})
+	if out != nil || s.Get("a", "ranked-1v1").Games != 0 {
+		t.Fatal("expected bot matches to be unrated")
+	}
+
+	out = s.Apply(types.MatchResult{
+		MatchID:  "m2",
+		Playlist: "ranked-1v1",
+		Winner:   "orange",
+		Private:  true,
+		Players:  []types.PlayerMatchStats{{PlayerID: "a", Team: "orange"}, {PlayerID: "b", Team: "blue"}},
+	})
+	if out != nil || s.Get("a", "ranked-1v1").Games != 0 {
+		t.Fatal("expected private matches to be unrated")
+	}
+
+	out = s.Apply(types.MatchResult{
+		MatchID:  "m3",
+		Playlist: "ranked-1v1",
+		Winner:   "draw",
+		Players: []types.PlayerMatchStats{
+			{PlayerID: "a", Team: "orange", NoShow: true, Abandoned: true},
+			{PlayerID: "b", Team: "blue", NoShow: true, Abandoned: true},
+		},
+	})
+	if out != nil || s.Get("a", "ranked-1v1") != Default() {
+		t.Fatal("expected a match nobody connected to to be unrated")
+	}
+}
+
+func TestPenalizeLowersMeanInPlaylist(t *testing.T) {
+	s := NewService()
+	got := s.Penalize("a", "ranked-2v2", 25)
+	if got.Mean != DefaultMean-25 || s.MMR("a", "ranked-2v2") != int(DefaultMean)-25 {
+		t.Fatalf("expected a 25 point penalty: %+v", got)
+	}
+	if s.MMR("a", "ranked-1v1") != int(DefaultMean) {
+		t.Fatal("expected the penalty to stay in its playlist")
+	}
+}
+
+func TestFileStoreKeepsRatingsAcrossRestart(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewService()
+	if err := s.SetStore(store); err != nil {
+		t.Fatal(err)
+	}
+	s.Apply(types.MatchResult{
+		MatchID:  "m1",
+		Playlist: "ranked-1v1",
+		Winner:   "orange",
+		Players: []types.PlayerMatchStats{
+			{PlayerID: "a", Team: "orange"},
+			{PlayerID: "b", Team: "blue"},
+		},
+	})
+	s.Penalize("b", "ranked-1v1", 25)
+	want := map[string]Rating{"a": s.Get("a", "ranked-1v1"), "b": s.Get("b", "ranked-1v1")}
+
+	// Simulate a crash: no Close, and a half-written final log line.
+	wal, err := os.OpenFile(filepath.Join(dir, walFile), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = wal.WriteString(`{"op":"put","rating":{"player_id":`)
+	wal.Close()
+	store.wal.Close()
+
+	reopened, err := OpenFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	restored := NewService()
+	if err := restored.SetStore(reopened); err != nil {
+		t.Fatal(err)
+	}
+	for id, r := range want {
+		if got := restored.Get(id, "ranked-1v1"); got != r || got == Default() {
+			t.Fatalf("expected %s's rating to survive a restart: want %+v got %+v", id, r, got)
+		}
+	}
+	if s.StoreErrors() != 0 {
+		t.Fatalf("unexpected store errors: %d", s.StoreErrors())
+	}
+}
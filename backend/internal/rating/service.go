@@ -0,0 +1,197 @@
+package rating
+
+import (
+	"math"
+	"sync"
+
+	"projectvelocity/backend/internal/shared/types"
+)
+
+// Service owns every player's rating per playlist and updates them from match results.
+type Service struct {
+	mu      sync.RWMutex
+	ratings map[string]Rating
+	store   Store
+	// storeErrors counts failed writes to store.
+	storeErrors uint64
+}
+
+// NewService creates an empty rating service backed by a MemoryStore.
+func NewService() *Service {
+	return &Service{ratings: make(map[string]Rating), store: NewMemoryStore()}
+}
+
+// SetStore restores every rating saved in st and makes st the write-through store for
+// later changes.
+func (s *Service) SetStore(st Store) error {
+	saved, err := st.Load()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store = st
+	for _, rec := range saved {
+		s.ratings[key(rec.PlayerID, rec.Playlist)] = rec.Rating
+	}
+	return nil
+}
+
+// StoreErrors returns how many writes to the store have failed.
+func (s *Service) StoreErrors() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.storeErrors
+}
+
+func key(playerID, playlist string) string {
+	return playlist + "|" + playerID
+}
+
+// Get returns the player's rating in playlist, or the default for unrated players.
+func (s *Service) Get(playerID, playlist string) Rating {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if r, ok := s.ratings[key(playerID, playlist)]; ok {
+		return r
+	}
+	return Default()
+}
+
+// MMR is the matchmaking value for the player: their rating mean, rounded.
+func (s *Service) MMR(playerID, playlist string) int {
+	return int(math.Round(s.Get(playerID, playlist).Mean))
+}
+
+// Apply updates the ratings of every human who took part in r. Each player is rated
+// against the opposing team's composite rating, so team games and 1v1 share one path.
+// Changes are scaled by the share of the match the player was on the field; players
+// who abandoned take the full result. Players whose teammate abandoned are given back
+// any rating the result cost them. Private matches, matches without humans on both
+// teams and matches no human connected to are unrated. Apply returns the new ratings
+// keyed by player id.
+func (s *Service) Apply(r types.MatchResult) map[string]Rating {
+	if r.Private || !r.Connected() {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before := make(map[string]Rating)
+	teams := make(map[string][]Rating)
+	deserted := make(map[string]bool)
+	for _, p := range r.Players {
+		if p.IsBot || p.Team == "" {
+			continue
+		}
+		if p.Abandoned {
+			deserted[p.Team] = true
+		}
+		cur, ok := s.ratings[key(p.PlayerID, r.Playlist)]
+		if !ok {
+			cur = Default()
+		}
+		before[p.PlayerID] = cur
+		teams[p.Team] = append(teams[p.Team], cur)
+	}
+	if len(teams["orange"]) == 0 || len(teams["blue"]) == 0 {
+		return nil
+	}
+	composite := map[string]Rating{
+		"orange": compositeOf(teams["orange"]),
+		"blue":   compositeOf(teams["blue"]),
+	}
+
+	out := make(map[string]Rating, len(before))
+	for _, p := range r.Players {
+		cur, ok := before[p.PlayerID]
+		if !ok {
+			continue
+		}
+		weight := participation(p, r.DurationMS)
+		if weight <= 0 {
+			continue
+		}
+		opp := composite["blue"]
+		if p.Team == "blue" {
+			opp = composite["orange"]
+		}
+		next := blend(cur, update(cur, []outcome{{opp: opp, score: scoreFor(p.Team, r.Winner)}}), weight)
+		if deserted[p.Team] && !p.Abandoned && next.Mean < cur.Mean {
+			next.Mean = cur.Mean
+		}
+		s.ratings[key(p.PlayerID, r.Playlist)] = next
+		s.save(p.PlayerID, r.Playlist, next)
+		out[p.PlayerID] = next
+	}
+	return out
+}
+
+// Penalize takes points off playerID's rating mean in playlist and returns the result.
+func (s *Service) Penalize(playerID, playlist string, points float64) Rating {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := key(playerID, playlist)
+	cur, ok := s.ratings[k]
+	if !ok {
+		cur = Default()
+	}
+	cur.Mean -= points
+	s.ratings[k] = cur
+	s.save(playerID, playlist, cur)
+	return cur
+}
+
+// save writes one rating through to the store. Callers hold s.mu.
+func (s *Service) save(playerID, playlist string, r Rating) {
+	if err := s.store.Put(PlayerRating{PlayerID: playerID, Playlist: playlist, Rating: r}); err != nil {
+		s.storeErrors++
+	}
+}
+
+// compositeOf treats a team as one opponent: the mean of means and the root mean
+// square of deviations.
+func compositeOf(members []Rating) Rating {
+	var mean, variance float64
+	for _, m := range members {
+		mean += m.Mean
+		variance += m.Deviation * m.Deviation
+	}
+	n := float64(len(members))
+	return Rating{Mean: mean / n, Deviation: math.Sqrt(variance / n), Volatility: DefaultVolatility}
+}
+
+// participation is the fraction of the match a player should be rated for.
+func participation(p types.PlayerMatchStats, durationMS int64) float64 {
+	if p.Abandoned {
+		return 1
+	}
+	if durationMS <= 0 {
+		return 1
+	}
+	return math.Min(float64(p.TimePlayedMS)/float64(durationMS), 1)
+}
+
+func scoreFor(team, winner string) float64 {
+	switch winner {
+	case team:
+		return 1
+	case "draw":
+		return 0.5
+	default:
+		return 0
+	}
+}
+
+// blend moves from cur toward next by weight, so a partial match is a partial update.
+func blend(cur, next Rating, weight float64) Rating {
+	if weight >= 1 {
+		return next
+	}
+	return Rating{
+		Mean:       cur.Mean + weight*(next.Mean-cur.Mean),
+		Deviation:  cur.Deviation + weight*(next.Deviation-cur.Deviation),
+		Volatility: cur.Volatility + weight*(next.Volatility-cur.Volatility),
+		Games:      next.Games,
+	}
+}
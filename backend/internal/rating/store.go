@@ -0,0 +1,53 @@
+package rating
+
+import (
+	"maps"
+	"slices"
+	"sync"
+)
+
+// Store persists ratings so player skill survives a matchmaker restart. Service writes
+// through on every change while holding its lock, so implementations must not call back
+// into it.
+type Store interface {
+	// Load returns every saved rating.
+	Load() ([]PlayerRating, error)
+	// Put saves rec, replacing any earlier rating for the same player and playlist.
+	Put(rec PlayerRating) error
+	Close() error
+}
+
+// PlayerRating is the durable form of one player's rating in one playlist.
+type PlayerRating struct {
+	PlayerID string `json:"player_id"`
+	Playlist string `json:"playlist"`
+	Rating   Rating `json:"rating"`
+}
+
+// MemoryStore keeps ratings in process. It is the default store, so ratings last only as
+// long as the matchmaker does.
+type MemoryStore struct {
+	mu      sync.Mutex
+	ratings map[string]PlayerRating
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{ratings: make(map[string]PlayerRating)}
+}
+
+func (s *MemoryStore) Load() ([]PlayerRating, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Collect(maps.Values(s.ratings)), nil
+}
+
+func (s *MemoryStore) Put(rec PlayerRating) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ratings[key(rec.PlayerID, rec.Playlist)] = rec
+	return nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
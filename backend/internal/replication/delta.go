@@ -0,0 +1,166 @@
+package replication
+
+import (
+	"sort"
+
+	"projectvelocity/backend/internal/shared/types"
+)
+
+// Diff returns the changes required to turn base into cur.
+func Diff(base, cur types.MatchState) types.StateDelta {
+	delta := types.StateDelta{
+		BaseTick: base.Tick,
+		Tick:     cur.Tick,
+	}
+
+	for id, car := range cur.Cars {
+		prev, ok := base.Cars[id]
+		if !ok {
+			if delta.AddedCars == nil {
+				delta.AddedCars = make(map[string]types.CarState)
+			}
+			delta.AddedCars[id] = car
+			continue
+		}
+		cd, changed := diffCar(prev, car)
+		if !changed {
+			continue
+		}
+		if delta.Cars == nil {
+			delta.Cars = make(map[string]types.CarDelta)
+		}
+		delta.Cars[id] = cd
+	}
+	for id := range base.Cars {
+		if _, ok := cur.Cars[id]; !ok {
+			delta.RemovedCars = append(delta.RemovedCars, id)
+		}
+	}
+	sort.Strings(delta.RemovedCars)
+
+	if base.Ball != cur.Ball {
+		ball := cur.Ball
+		delta.Ball = &ball
+	}
+	if base.Score != cur.Score {
+		score := cur.Score
+		delta.Score = &score
+	}
+	if len(cur.Events) > 0 {
+		delta.Events = make([]types.GameplayEvent, len(cur.Events))
+		copy(delta.Events, cur.Events)
+	}
+	return delta
+}
+
+// Apply reconstructs the state described by delta on top of base.
+func Apply(base types.MatchState, delta types.StateDelta) types.MatchState {
+	out := base
+	out.Tick = delta.Tick
+	out.Cars = make(map[string]types.CarState, len(base.Cars)+len(delta.AddedCars))
+	for id, car := range base.Cars {
+		out.Cars[id] = car
+	}
+	for _, id := range delta.RemovedCars {
+		delete(out.Cars, id)
+	}
+	for id, cd := range delta.Cars {
+		car, ok := out.Cars[id]
+		if !ok {
+			continue
+		}
+		out.Cars[id] = applyCar(car, cd)
+	}
+	for id, car := range delta.AddedCars {
+		out.Cars[id] = car
+	}
+	if delta.Ball != nil {
+		out.Ball = *delta.Ball
+	}
+	if delta.Score != nil {
+		out.Score = *delta.Score
+	}
+	out.Events = make([]types.GameplayEvent, len(delta.Events))
+	copy(out.Events, delta.Events)
+	return out
+}
+
+func diffCar(prev, cur types.CarState) (types.CarDelta, bool) {
+	var d types.CarDelta
+	changed := false
+	if prev.DisplayName != cur.DisplayName {
+		d.DisplayName = &cur.DisplayName
+		changed = true
+	}
+	if prev.Team != cur.Team {
+		d.Team = &cur.Team
+		changed = true
+	}
+	if prev.IsBot != cur.IsBot {
+		d.IsBot = &cur.IsBot
+		changed = true
+	}
+	if prev.Autopilot != cur.Autopilot {
+		d.Autopilot = &cur.Autopilot
+		changed = true
+	}
+	if prev.Position != cur.Position {
+		d.Position = &cur.Position
+		changed = true
+	}
+	if prev.Velocity != cur.Velocity {
+		d.Velocity = &cur.Velocity
+		changed = true
+	}
+	if prev.Rotation != cur.Rotation {
+		d.Rotation = &cur.Rotation
+		changed = true
+	}
+	if prev.Boost != cur.Boost {
+		d.Boost = &cur.Boost
+		changed = true
+	}
+	if prev.IsGrounded != cur.IsGrounded {
+		d.IsGrounded = &cur.IsGrounded
+		changed = true
+	}
+	if prev.LastInput != cur.LastInput {
+		d.LastInput = &cur.LastInput
+		changed = true
+	}
+	return d, changed
+}
+
+func applyCar(car types.CarState, d types.CarDelta) types.CarState {
+	if d.DisplayName != nil {
+		car.DisplayName = *d.DisplayName
+	}
+	if d.Team != nil {
+		car.Team = *d.Team
+	}
+	if d.IsBot != nil {
+		car.IsBot = *d.IsBot
+	}
+	if d.Autopilot != nil {
+		car.Autopilot = *d.Autopilot
+	}
+	if d.Position != nil {
+		car.Position = *d.Position
+	}
+	if d.Velocity != nil {
+		car.Velocity = *d.Velocity
+	}
+	if d.Rotation != nil {
+		car.Rotation = *d.Rotation
+	}
+	if d.Boost != nil {
+		car.Boost = *d.Boost
+	}
+	if d.IsGrounded != nil {
+		car.IsGrounded = *d.IsGrounded
+	}
+	if d.LastInput != nil {
+		car.LastInput = *d.LastInput
+	}
+	return car
+}
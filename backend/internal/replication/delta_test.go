@@ -0,0 +1,90 @@
+package replication
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"projectvelocity/backend/internal/shared/types"
+	"projectvelocity/backend/internal/simulation"
+)
+
+func TestDiffApplyRoundTrip(t *testing.T) {
+	w := simulation.NewWorld("m1", 10*time.Second, []simulation.PlayerSpawn{
+		{PlayerID: "p1", DisplayName: "p1", Team: "orange"},
+		{PlayerID: "p2", DisplayName: "p2", Team: "blue"},
+	})
+	base := w.Snapshot()
+
+	w.ApplyInput(types.CarInput{PlayerID: "p1", Throttle: 1, Boost: true})
+	for range 30 {
+		w.Tick(1.0 / 120.0)
+	}
+	w.RemovePlayer("p2")
+	w.EnsurePlayer("p3", "p3")
+	cur := w.Snapshot()
+
+	delta := Diff(base, cur)
+	if delta.BaseTick != base.Tick || delta.Tick != cur.Tick {
+		t.Fatalf("unexpected ticks base=%d tick=%d", delta.BaseTick, delta.Tick)
+	}
+	if _, ok := delta.AddedCars["p3"]; !ok {
+		t.Fatal("expected p3 in added cars")
+	}
+	if len(delta.RemovedCars) != 1 || delta.RemovedCars[0] != "p2" {
+		t.Fatalf("expected p2 removed, got=%v", delta.RemovedCars)
+	}
+
+	got := Apply(base, delta)
+	if !reflect.DeepEqual(got.Cars, cur.Cars) {
+		t.Fatalf("cars mismatch after apply:\n got=%+v\nwant=%+v", got.Cars, cur.Cars)
+	}
+	if got.Ball != cur.Ball || got.Score != cur.Score || got.Tick != cur.Tick {
+		t.Fatal("ball, score or tick mismatch after apply")
+	}
+}
+
+func TestDiffOmitsUnchangedFields(t *testing.T) {
+	w := simulation.NewWorld("m2", 10*time.Second, []simulation.PlayerSpawn{
+		{PlayerID: "p1", DisplayName: "p1", Team: "orange"},
+	})
+	w.Tick(1.0 / 120.0)
+	base := w.Snapshot()
+	w.ApplyInput(types.CarInput{PlayerID: "p1", Throttle: 1})
+	w.Tick(1.0 / 120.0)
+	cur := w.Snapshot()
+
+	delta := Diff(base, cur)
+	cd, ok := delta.Cars["p1"]
+	if !ok {
+		t.Fatal("expected p1 delta")
+	}
+	if cd.DisplayName != nil || cd.Team != nil || cd.IsBot != nil {
+		t.Fatalf("expected static fields omitted, got=%+v", cd)
+	}
+	if cd.Position == nil {
+		t.Fatal("expected position change")
+	}
+
+	full, _ := json.Marshal(cur)
+	partial, _ := json.Marshal(delta)
+	if len(partial) >= len(full) {
+		t.Fatalf("expected delta smaller than snapshot: delta=%d full=%d", len(partial), len(full))
+	}
+}
+
+func TestHistoryEvictsOldBaselines(t *testing.T) {
+	h := NewHistory(4)
+	for tick := uint64(1); tick <= 6; tick++ {
+		h.Push(types.MatchState{Tick: tick})
+	}
+	if _, ok := h.Get(2); ok {
+		t.Fatal("expected tick 2 to be evicted")
+	}
+	for _, tick := range []uint64{3, 4, 5, 6} {
+		if s, ok := h.Get(tick); !ok || s.Tick != tick {
+			t.Fatalf("expected tick %d retained", tick)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package replication
+
+import (
+	"sync"
+
+	"projectvelocity/backend/internal/shared/types"
+)
+
+// DefaultHistorySize keeps roughly two seconds of 60Hz snapshots.
+const DefaultHistorySize = 128
+
+// History is a fixed-size ring of recently replicated snapshots used as delta baselines.
+type History struct {
+	mu    sync.RWMutex
+	slots []types.MatchState
+	next  int
+	count int
+}
+
+// NewHistory creates a snapshot ring holding up to size entries.
+func NewHistory(size int) *History {
+	if size <= 0 {
+		size = DefaultHistorySize
+	}
+	return &History{slots: make([]types.MatchState, size)}
+}
+
+// Push records a snapshot, evicting the oldest one once the ring is full.
+func (h *History) Push(state types.MatchState) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.slots[h.next] = state
+	h.next = (h.next + 1) % len(h.slots)
+	if h.count < len(h.slots) {
+		h.count++
+	}
+}
+
+// Get returns the snapshot recorded for tick, if it is still retained.
+func (h *History) Get(tick uint64) (types.MatchState, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for i := 0; i < h.count; i++ {
+		idx := (h.next - 1 - i + len(h.slots)) % len(h.slots)
+		s := h.slots[idx]
+		if s.Tick == tick {
+			return s, true
+		}
+		if s.Tick < tick {
+			break
+		}
+	}
+	return types.MatchState{}, false
+}
@@ -0,0 +1,158 @@
+package replication
+
+import (
+	"sync"
+	"time"
+)
+
+// PacerConfig tunes per-client replication rate adaptation.
+type PacerConfig struct {
+	MaxHz int
+	MinHz int
+	// HighRTT halves the rate ceiling; VeryHighRTT drops it to MinHz.
+	HighRTT     time.Duration
+	VeryHighRTT time.Duration
+	// SlowConsumerAfter is how long a send queue may stay full before the client is dropped.
+	SlowConsumerAfter time.Duration
+}
+
+// DefaultPacerConfig matches the 60Hz replication loop.
+func DefaultPacerConfig() PacerConfig {
+	return PacerConfig{
+		MaxHz:             60,
+		MinHz:             10,
+		HighRTT:           150 * time.Millisecond,
+		VeryHighRTT:       300 * time.Millisecond,
+		SlowConsumerAfter: 5 * time.Second,
+	}
+}
+
+// PacerStats is a point-in-time view of a client's replication health.
+type PacerStats struct {
+	RateHz     int
+	RTT        time.Duration
+	QueueDepth int
+	Sent       uint64
+	Dropped    uint64
+}
+
+// Pacer decides when a client is due another frame and tracks delivery health.
+// Rate follows additive-increase/multiplicative-decrease on send queue pressure,
+// capped by a ceiling derived from the measured round-trip time.
+type Pacer struct {
+	mu          sync.Mutex
+	cfg         PacerConfig
+	hz          int
+	nextSend    time.Time
+	rtt         time.Duration
+	queueDepth  int
+	sent        uint64
+	dropped     uint64
+	fullSince   time.Time
+	dropRunning bool
+}
+
+// NewPacer creates a pacer starting at the configured maximum rate.
+func NewPacer(cfg PacerConfig) *Pacer {
+	if cfg.MaxHz <= 0 {
+		cfg.MaxHz = 60
+	}
+	if cfg.MinHz <= 0 || cfg.MinHz > cfg.MaxHz {
+		cfg.MinHz = cfg.MaxHz
+	}
+	return &Pacer{cfg: cfg, hz: cfg.MaxHz}
+}
+
+// ObserveRTT records a round-trip measurement and clamps the rate to its ceiling.
+func (p *Pacer) ObserveRTT(rtt time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rtt = rtt
+	if ceiling := p.ceiling(); p.hz > ceiling {
+		p.hz = ceiling
+	}
+}
+
+// ObserveQueue adapts the rate to the depth of the client's outbound queue.
+func (p *Pacer) ObserveQueue(depth, capacity int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.queueDepth = depth
+	if capacity <= 0 {
+		return
+	}
+	fill := float64(depth) / float64(capacity)
+	switch {
+	case fill >= 0.5:
+		p.hz /= 2
+	case fill >= 0.25:
+		p.hz -= p.cfg.MaxHz / 12
+	case fill < 0.1:
+		p.hz++
+	}
+	if p.hz < p.cfg.MinHz {
+		p.hz = p.cfg.MinHz
+	}
+	if ceiling := p.ceiling(); p.hz > ceiling {
+		p.hz = ceiling
+	}
+}
+
+// Ready reports whether the client is due a frame at now and schedules the next one.
+func (p *Pacer) Ready(now time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if now.Before(p.nextSend) {
+		return false
+	}
+	interval := time.Second / time.Duration(p.hz)
+	// Allow a little slack so a 60Hz ticker does not skip every other frame on jitter.
+	p.nextSend = now.Add(interval - interval/8)
+	return true
+}
+
+// Delivered records a frame that was accepted by the client's send queue.
+func (p *Pacer) Delivered() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sent++
+	p.dropRunning = false
+}
+
+// Dropped records a frame discarded because the send queue was full and reports
+// whether the queue has stayed full long enough to treat the client as a slow consumer.
+func (p *Pacer) Dropped(now time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.dropped++
+	p.hz = p.cfg.MinHz
+	if !p.dropRunning {
+		p.dropRunning = true
+		p.fullSince = now
+	}
+	return p.cfg.SlowConsumerAfter > 0 && now.Sub(p.fullSince) >= p.cfg.SlowConsumerAfter
+}
+
+// Stats returns the current pacing state.
+func (p *Pacer) Stats() PacerStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return PacerStats{
+		RateHz:     p.hz,
+		RTT:        p.rtt,
+		QueueDepth: p.queueDepth,
+		Sent:       p.sent,
+		Dropped:    p.dropped,
+	}
+}
+
+func (p *Pacer) ceiling() int {
+	switch {
+	case p.cfg.VeryHighRTT > 0 && p.rtt >= p.cfg.VeryHighRTT:
+		return p.cfg.MinHz
+	case p.cfg.HighRTT > 0 && p.rtt >= p.cfg.HighRTT:
+		return max(p.cfg.MaxHz/2, p.cfg.MinHz)
+	default:
+		return p.cfg.MaxHz
+	}
+}
@@ -0,0 +1,70 @@
+package replication
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPacerBacksOffUnderQueuePressure(t *testing.T) {
+	p := NewPacer(DefaultPacerConfig())
+	p.ObserveQueue(40, 64)
+	if got := p.Stats().RateHz; got != 30 {
+		t.Fatalf("expected rate halved to 30, got=%d", got)
+	}
+	for range 100 {
+		p.ObserveQueue(0, 64)
+	}
+	if got := p.Stats().RateHz; got != 60 {
+		t.Fatalf("expected rate to recover to 60, got=%d", got)
+	}
+}
+
+func TestPacerCapsRateOnHighRTT(t *testing.T) {
+	p := NewPacer(DefaultPacerConfig())
+	p.ObserveRTT(200 * time.Millisecond)
+	for range 100 {
+		p.ObserveQueue(0, 64)
+	}
+	if got := p.Stats().RateHz; got != 30 {
+		t.Fatalf("expected rate capped at 30, got=%d", got)
+	}
+	p.ObserveRTT(400 * time.Millisecond)
+	if got := p.Stats().RateHz; got != 10 {
+		t.Fatalf("expected rate capped at min, got=%d", got)
+	}
+}
+
+func TestPacerReadyHonoursRate(t *testing.T) {
+	p := NewPacer(PacerConfig{MaxHz: 10, MinHz: 10})
+	start := time.Now()
+	sent := 0
+	for i := range 60 {
+		if p.Ready(start.Add(time.Duration(i) * time.Second / 60)) {
+			sent++
+		}
+	}
+	if sent < 9 || sent > 11 {
+		t.Fatalf("expected ~10 frames in one second at 10Hz, got=%d", sent)
+	}
+}
+
+func TestPacerFlagsPersistentlySlowConsumer(t *testing.T) {
+	cfg := DefaultPacerConfig()
+	cfg.SlowConsumerAfter = time.Second
+	p := NewPacer(cfg)
+	start := time.Now()
+
+	if p.Dropped(start) {
+		t.Fatal("single drop should not flag slow consumer")
+	}
+	p.Delivered()
+	if p.Dropped(start.Add(2 * time.Second)) {
+		t.Fatal("drop run should reset after a delivery")
+	}
+	if !p.Dropped(start.Add(3 * time.Second)) {
+		t.Fatal("expected slow consumer after queue stayed full")
+	}
+	if got := p.Stats().Dropped; got != 3 {
+		t.Fatalf("expected 3 dropped frames, got=%d", got)
+	}
+}
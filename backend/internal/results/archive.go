@@ -0,0 +1,124 @@
+package results
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"projectvelocity/backend/internal/shared/types"
+)
+
+const archiveFile = "results.log"
+
+var ErrArchiveClosed = errors.New("results: archive closed")
+
+// Archive persists recorded results so a resubmission after a matchmaker restart is
+// still recognised as a duplicate and history survives. Store writes to it while holding
+// its lock, so implementations must not call back into the store.
+type Archive interface {
+	// Load returns every archived result.
+	Load() ([]types.MatchResult, error)
+	// Put archives r. Results are never rewritten, so Put is only called once per match.
+	Put(r types.MatchResult) error
+	Close() error
+}
+
+// FileArchive is a durable Archive: results are appended to a log in dir and synced
+// before Put returns. A torn final line from a crash is cut off on open. Its file does
+// not clash with the queue's or conduct's, so all of them can share the matchmaker's
+// data directory.
+type FileArchive struct {
+	mu      sync.Mutex
+	f       *os.File
+	results []types.MatchResult
+}
+
+// OpenFileArchive opens or creates an archive in dir.
+func OpenFileArchive(dir string) (*FileArchive, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, archiveFile), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	a := &FileArchive{f: f}
+	good, err := a.replay()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	// Appending after a torn line would hide the new entries from the next replay.
+	if err := f.Truncate(good); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(good, 0); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *FileArchive) Load() ([]types.MatchResult, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]types.MatchResult(nil), a.results...), nil
+}
+
+func (a *FileArchive) Put(r types.MatchResult) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.f == nil {
+		return ErrArchiveClosed
+	}
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := a.f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if err := a.f.Sync(); err != nil {
+		return err
+	}
+	a.results = append(a.results, r)
+	return nil
+}
+
+func (a *FileArchive) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.f == nil {
+		return nil
+	}
+	err := a.f.Close()
+	a.f = nil
+	return err
+}
+
+// replay reads every whole line and returns the offset just past the last one.
+func (a *FileArchive) replay() (int64, error) {
+	reader := bufio.NewReader(a.f)
+	var good int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// Whatever follows the last newline is a write the crash cut short.
+			return good, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		var r types.MatchResult
+		if err := json.Unmarshal(line, &r); err != nil {
+			return good, nil
+		}
+		a.results = append(a.results, r)
+		good += int64(len(line))
+	}
+}
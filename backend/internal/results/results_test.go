@@ -0,0 +1,110 @@
+package results
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"projectvelocity/backend/internal/shared/types"
+)
+
+func sampleResult(matchID string, endedAt int64) types.MatchResult {
+	return types.MatchResult{
+		MatchID:     matchID,
+		EndedAt:     endedAt,
+		OrangeScore: 2,
+		BlueScore:   1,
+		Winner:      "orange",
+		Players: []types.PlayerMatchStats{
+			{PlayerID: "p1", Team: "orange", Goals: 2},
+			{PlayerID: "p2", Team: "blue", Goals: 1},
+			{PlayerID: "bot_blue", Team: "blue", IsBot: true},
+		},
+	}
+}
+
+func TestRecordIsIdempotentAndNotifiesOnce(t *testing.T) {
+	s := NewStore()
+	notified := 0
+	s.OnRecorded(func(types.MatchResult) { notified++ })
+
+	r := sampleResult("m1", 100)
+	if created, err := s.Record(r); err != nil || !created {
+		t.Fatalf("expected first record to be stored, created=%v err=%v", created, err)
+	}
+	if created, err := s.Record(sampleResult("m1", 100)); err != nil || created {
+		t.Fatalf("expected identical resubmission to be a no-op, created=%v err=%v", created, err)
+	}
+	changed := sampleResult("m1", 100)
+	changed.BlueScore = 5
+	if _, err := s.Record(changed); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected conflicting record to be rejected, got=%v", err)
+	}
+	if notified != 1 {
+		t.Fatalf("expected exactly one notification, got=%d", notified)
+	}
+	if _, err := s.Record(types.MatchResult{MatchID: "m2"}); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected record without players to be invalid, got=%v", err)
+	}
+}
+
+func TestHistoryNewestFirstAndSkipsBots(t *testing.T) {
+	s := NewStore()
+	for i, id := range []string{"m1", "m2", "m3"} {
+		if _, err := s.Record(sampleResult(id, int64(100+i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := s.History("p1", 2)
+	if len(h) != 2 || h[0].MatchID != "m3" || h[1].MatchID != "m2" {
+		t.Fatalf("unexpected history: %+v", h)
+	}
+	if len(s.History("bot_blue", 0)) != 0 {
+		t.Fatal("expected bots to have no match history")
+	}
+}
+
+func TestArchivedResultIsStillADuplicateAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	a, err := OpenFileArchive(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewStore()
+	if err := s.SetArchive(a); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Record(sampleResult("m1", 100)); err != nil {
+		t.Fatal(err)
+	}
+	a.Close()
+	// A crash mid-append leaves a torn line behind.
+	f, err := os.OpenFile(filepath.Join(dir, archiveFile), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"match_id":"m2","pla`)
+	f.Close()
+
+	a, err = OpenFileArchive(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	restarted := NewStore()
+	if err := restarted.SetArchive(a); err != nil {
+		t.Fatal(err)
+	}
+	notified := 0
+	restarted.OnRecorded(func(types.MatchResult) { notified++ })
+	if created, err := restarted.Record(sampleResult("m1", 100)); err != nil || created {
+		t.Fatalf("expected the retried result to be a duplicate, created=%v err=%v", created, err)
+	}
+	if created, err := restarted.Record(sampleResult("m2", 200)); err != nil || !created {
+		t.Fatalf("expected the torn result to be recordable, created=%v err=%v", created, err)
+	}
+	if notified != 1 || len(restarted.History("p1", 0)) != 2 {
+		t.Fatalf("expected one notification and both matches in history, notified=%d history=%d", notified, len(restarted.History("p1", 0)))
+	}
+}
@@ -0,0 +1,128 @@
+package results
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+
+	"projectvelocity/backend/internal/shared/types"
+)
+
+var (
+	ErrInvalid  = errors.New("results: match_id and players are required")
+	ErrConflict = errors.New("results: a different result is already recorded for this match")
+)
+
+// Store keeps final match records keyed by match id and indexes them per player for
+// match history. Recording is idempotent: a resubmitted identical record is a no-op.
+type Store struct {
+	mu        sync.RWMutex
+	byMatch   map[string]types.MatchResult
+	byPlayer  map[string][]string
+	listeners []func(types.MatchResult)
+	archive   Archive
+}
+
+// NewStore creates an empty in-memory result store.
+func NewStore() *Store {
+	return &Store{
+		byMatch:  make(map[string]types.MatchResult),
+		byPlayer: make(map[string][]string),
+	}
+}
+
+// SetArchive restores every result saved in a, without notifying listeners since their
+// effects were applied when the result was first recorded, and makes a the write-through
+// archive for later results. Results already held are kept as they are.
+func (s *Store) SetArchive(a Archive) error {
+	saved, err := a.Load()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.archive = a
+	for _, r := range saved {
+		if _, ok := s.byMatch[r.MatchID]; !ok {
+			s.indexLocked(r)
+		}
+	}
+	return nil
+}
+
+// OnRecorded registers fn to run once for every newly recorded result, e.g. to update
+// ratings. Listeners run synchronously after the store lock is released.
+func (s *Store) OnRecorded(fn func(types.MatchResult)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+// Record stores r and reports whether it was new. Resubmitting the same record returns
+// false; a differing record for a known match returns ErrConflict. If the archive cannot
+// save r, Record returns its error and forgets r, so the submitter can retry.
+func (s *Store) Record(r types.MatchResult) (bool, error) {
+	if r.MatchID == "" || len(r.Players) == 0 {
+		return false, ErrInvalid
+	}
+
+	s.mu.Lock()
+	if prev, ok := s.byMatch[r.MatchID]; ok {
+		s.mu.Unlock()
+		if reflect.DeepEqual(prev, r) {
+			return false, nil
+		}
+		return false, ErrConflict
+	}
+	if s.archive != nil {
+		if err := s.archive.Put(r); err != nil {
+			s.mu.Unlock()
+			return false, err
+		}
+	}
+	s.indexLocked(r)
+	listeners := s.listeners
+	s.mu.Unlock()
+
+	for _, fn := range listeners {
+		fn(r)
+	}
+	return true, nil
+}
+
+// indexLocked adds r to the match and player indexes. Callers hold s.mu.
+func (s *Store) indexLocked(r types.MatchResult) {
+	s.byMatch[r.MatchID] = r
+	for _, p := range r.Players {
+		if p.IsBot {
+			continue
+		}
+		s.byPlayer[p.PlayerID] = append(s.byPlayer[p.PlayerID], r.MatchID)
+	}
+}
+
+// Get returns the recorded result for matchID.
+func (s *Store) Get(matchID string) (types.MatchResult, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r, ok := s.byMatch[matchID]
+	return r, ok
+}
+
+// History returns up to limit of the player's matches, most recently ended first.
+// A non-positive limit returns every match.
+func (s *Store) History(playerID string, limit int) []types.MatchResult {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ids := s.byPlayer[playerID]
+	out := make([]types.MatchResult, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, s.byMatch[id])
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].EndedAt > out[j].EndedAt })
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
@@ -0,0 +1,178 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"sync"
+	"time"
+)
+
+// DefaultGrace is how long a dropped player's car is held for a resume.
+const DefaultGrace = 30 * time.Second
+
+type entry struct {
+	token        string
+	disconnected bool
+	gen          uint64
+	timer        *time.Timer
+}
+
+// Manager issues reconnect tokens and holds dropped players for a grace period
+// before handing them to the expire callback for removal.
+type Manager struct {
+	mu       sync.Mutex
+	grace    time.Duration
+	expire   func(playerID string)
+	byToken  map[string]string
+	byPlayer map[string]*entry
+}
+
+// NewManager creates a manager that calls expire once a player's grace period lapses.
+func NewManager(grace time.Duration, expire func(playerID string)) *Manager {
+	if grace <= 0 {
+		grace = DefaultGrace
+	}
+	return &Manager{
+		grace:    grace,
+		expire:   expire,
+		byToken:  make(map[string]string),
+		byPlayer: make(map[string]*entry),
+	}
+}
+
+// Grace returns the configured hold duration.
+func (m *Manager) Grace() time.Duration {
+	return m.grace
+}
+
+// Issue marks playerID connected and returns a fresh reconnect token, revoking any previous one.
+func (m *Manager) Issue(playerID string) (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.byPlayer[playerID]
+	if !ok {
+		e = &entry{}
+		m.byPlayer[playerID] = e
+	}
+	if e.token != "" {
+		delete(m.byToken, e.token)
+	}
+	m.stopLocked(e)
+	e.token = token
+	m.byToken[token] = playerID
+	return token, nil
+}
+
+// Disconnected starts the grace period for playerID. It reports false when the player
+// has no session, in which case the caller should remove the player immediately.
+func (m *Manager) Disconnected(playerID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.byPlayer[playerID]
+	if !ok {
+		return false
+	}
+	m.stopLocked(e)
+	e.disconnected = true
+	gen := e.gen
+	e.timer = time.AfterFunc(m.grace, func() { m.lapse(playerID, gen) })
+	return true
+}
+
+// Lookup reports which player a reconnect token belongs to without redeeming it, so a
+// caller can validate a resume before committing to it.
+func (m *Manager) Lookup(token string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	playerID, ok := m.byToken[token]
+	return playerID, ok
+}
+
+// Resume redeems a reconnect token, cancelling any pending grace expiry. The token is
+// consumed; callers should Issue a new one for the resumed connection.
+func (m *Manager) Resume(token string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	playerID, ok := m.byToken[token]
+	if !ok {
+		return "", false
+	}
+	delete(m.byToken, token)
+	e := m.byPlayer[playerID]
+	e.token = ""
+	m.stopLocked(e)
+	return playerID, true
+}
+
+// Reclaim cancels the grace period for a player re-entering through another path,
+// such as a fresh join ticket. It reports whether the player was being held.
+func (m *Manager) Reclaim(playerID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.byPlayer[playerID]
+	if !ok || !e.disconnected {
+		return false
+	}
+	m.stopLocked(e)
+	return true
+}
+
+// Held reports whether playerID is currently inside its grace period.
+func (m *Manager) Held(playerID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.byPlayer[playerID]
+	return ok && e.disconnected
+}
+
+// Forget drops all session state for playerID without invoking the expire callback.
+func (m *Manager) Forget(playerID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.byPlayer[playerID]
+	if !ok {
+		return
+	}
+	m.stopLocked(e)
+	delete(m.byToken, e.token)
+	delete(m.byPlayer, playerID)
+}
+
+func (m *Manager) lapse(playerID string, gen uint64) {
+	m.mu.Lock()
+	e, ok := m.byPlayer[playerID]
+	if !ok || !e.disconnected || e.gen != gen {
+		m.mu.Unlock()
+		return
+	}
+	delete(m.byToken, e.token)
+	delete(m.byPlayer, playerID)
+	m.mu.Unlock()
+
+	if m.expire != nil {
+		m.expire(playerID)
+	}
+}
+
+// stopLocked cancels a pending expiry; bumping gen makes an already-fired timer a no-op.
+func (m *Manager) stopLocked(e *entry) {
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+	e.disconnected = false
+	e.gen++
+}
+
+func randomToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
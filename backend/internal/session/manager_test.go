@@ -0,0 +1,98 @@
+package session
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type expiries struct {
+	mu  sync.Mutex
+	ids []string
+}
+
+func (e *expiries) record(id string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.ids = append(e.ids, id)
+}
+
+func (e *expiries) list() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.ids...)
+}
+
+func TestGraceExpiryRemovesPlayer(t *testing.T) {
+	var exp expiries
+	m := NewManager(20*time.Millisecond, exp.record)
+	if _, err := m.Issue("p1"); err != nil {
+		t.Fatalf("issue failed: %v", err)
+	}
+	if !m.Disconnected("p1") {
+		t.Fatal("expected p1 to enter grace")
+	}
+	if !m.Held("p1") {
+		t.Fatal("expected p1 held during grace")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if got := exp.list(); len(got) != 1 || got[0] != "p1" {
+		t.Fatalf("expected p1 expired, got=%v", got)
+	}
+	if m.Held("p1") {
+		t.Fatal("expected p1 released after expiry")
+	}
+}
+
+func TestResumeWithinGraceKeepsPlayer(t *testing.T) {
+	var exp expiries
+	m := NewManager(30*time.Millisecond, exp.record)
+	token, _ := m.Issue("p1")
+	m.Disconnected("p1")
+
+	playerID, ok := m.Resume(token)
+	if !ok || playerID != "p1" {
+		t.Fatalf("expected resume for p1, got=%q ok=%v", playerID, ok)
+	}
+	if _, ok := m.Resume(token); ok {
+		t.Fatal("expected reconnect token to be single-use")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if got := exp.list(); len(got) != 0 {
+		t.Fatalf("expected no expiry after resume, got=%v", got)
+	}
+}
+
+func TestLookupLeavesGraceRunning(t *testing.T) {
+	var exp expiries
+	m := NewManager(20*time.Millisecond, exp.record)
+	token, _ := m.Issue("p1")
+	m.Disconnected("p1")
+
+	if playerID, ok := m.Lookup(token); !ok || playerID != "p1" {
+		t.Fatalf("expected lookup to find p1, got=%q ok=%v", playerID, ok)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if got := exp.list(); len(got) != 1 || got[0] != "p1" {
+		t.Fatalf("expected an unredeemed lookup to leave the expiry in place, got=%v", got)
+	}
+}
+
+func TestIssueRevokesPreviousToken(t *testing.T) {
+	m := NewManager(time.Second, nil)
+	first, _ := m.Issue("p1")
+	second, _ := m.Issue("p1")
+	if _, ok := m.Resume(first); ok {
+		t.Fatal("expected first token revoked")
+	}
+	if _, ok := m.Resume(second); !ok {
+		t.Fatal("expected latest token valid")
+	}
+}
+
+func TestDisconnectedWithoutSession(t *testing.T) {
+	m := NewManager(time.Second, nil)
+	if m.Disconnected("ghost") {
+		t.Fatal("expected unknown player to be removed immediately")
+	}
+}
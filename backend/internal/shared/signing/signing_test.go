@@ -0,0 +1,24 @@
+package signing
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSignVerify(t *testing.T) {
+	key := []byte("k")
+	body := []byte(`{"match_id":"m1"}`)
+	sig := Sign(key, body)
+	if err := Verify(key, body, sig); err != nil {
+		t.Fatalf("expected valid signature, got=%v", err)
+	}
+	if err := Verify(key, []byte(`{"match_id":"m2"}`), sig); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("expected tampered body to fail, got=%v", err)
+	}
+	if err := Verify([]byte("other"), body, sig); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("expected wrong key to fail, got=%v", err)
+	}
+	if err := Verify(key, body, ""); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("expected a missing signature to fail, got=%v", err)
+	}
+}
@@ -34,6 +34,7 @@ type CarState struct {
 	DisplayName string   `json:"display_name"`
 	Team        string   `json:"team"` // orange|blue
 	IsBot       bool     `json:"is_bot"`
+	Autopilot   bool     `json:"autopilot,omitempty"` // bot-driven while its player reconnects
 	Position    Vec3     `json:"position"`
 	Velocity    Vec3     `json:"velocity"`
 	Rotation    Rotator  `json:"rotation"`
@@ -56,6 +57,15 @@ type ScoreState struct {
 	TimeRemainingMS int `json:"time_remaining_ms"`
 }
 
+// Physics holds the simulation constants a physics preset changes.
+type Physics struct {
+	Preset          string  `json:"preset"`
+	Gravity         float64 `json:"gravity"`
+	BoostAccel      float64 `json:"boost_accel"`
+	BallRestitution float64 `json:"ball_restitution"`
+	BallMaxSpeed    float64 `json:"ball_max_speed"`
+}
+
 // MatchState is replicated to all clients.
 type MatchState struct {
 	MatchID   string              `json:"match_id"`
@@ -67,28 +77,88 @@ type MatchState struct {
 	Events    []GameplayEvent     `json:"events"`
 }
 
+// CarDelta carries only the car fields that changed since a baseline snapshot.
+type CarDelta struct {
+	DisplayName *string   `json:"display_name,omitempty"`
+	Team        *string   `json:"team,omitempty"`
+	IsBot       *bool     `json:"is_bot,omitempty"`
+	Autopilot   *bool     `json:"autopilot,omitempty"`
+	Position    *Vec3     `json:"position,omitempty"`
+	Velocity    *Vec3     `json:"velocity,omitempty"`
+	Rotation    *Rotator  `json:"rotation,omitempty"`
+	Boost       *float64  `json:"boost,omitempty"`
+	IsGrounded  *bool     `json:"is_grounded,omitempty"`
+	LastInput   *CarInput `json:"last_input,omitempty"`
+}
+
+// StateDelta describes the difference between an acknowledged baseline tick and Tick.
+type StateDelta struct {
+	BaseTick    uint64              `json:"base_tick"`
+	Tick        uint64              `json:"tick"`
+	Cars        map[string]CarDelta `json:"cars,omitempty"`
+	AddedCars   map[string]CarState `json:"added_cars,omitempty"`
+	RemovedCars []string            `json:"removed_cars,omitempty"`
+	Ball        *BallState          `json:"ball,omitempty"`
+	Score       *ScoreState         `json:"score,omitempty"`
+	Events      []GameplayEvent     `json:"events,omitempty"`
+}
+
 // GameplayEvent tracks state changes worth UI/audio feedback.
 type GameplayEvent struct {
-	Type       string `json:"type"` // goal|save|shot_on_goal|demo|kickoff
+	Type       string `json:"type"` // goal|save|shot_on_goal|demo|kickoff|forfeit
 	PlayerID   string `json:"player_id,omitempty"`
 	Team       string `json:"team,omitempty"`
 	OccurredMS int64  `json:"occurred_ms"`
 }
 
+// ClockSync carries NTP-style timestamps for one ping/pong exchange.
+// Client times are on the client clock and server times on the server clock.
+type ClockSync struct {
+	ClientSendMS int64 `json:"client_send_ms"`
+	ServerRecvMS int64 `json:"server_recv_ms,omitempty"`
+	ServerSendMS int64 `json:"server_send_ms,omitempty"`
+	ClientRecvMS int64 `json:"client_recv_ms,omitempty"`
+	// RTTMS and OffsetMS echo the server's smoothed estimates for this client.
+	RTTMS    int64 `json:"rtt_ms,omitempty"`
+	OffsetMS int64 `json:"offset_ms,omitempty"`
+}
+
 // ClientEnvelope is sent from client to server.
 type ClientEnvelope struct {
-	Type  string    `json:"type"` // hello|input|ping
-	Input *CarInput `json:"input,omitempty"`
+	Type    string     `json:"type"` // hello|input|ping|sync|ack|forfeit
+	Input   *CarInput  `json:"input,omitempty"`
+	AckTick uint64     `json:"ack_tick,omitempty"`
+	Sync    *ClockSync `json:"sync,omitempty"`
+	// Accept is the sender's forfeit vote: true to concede, false to play on.
+	Accept bool `json:"accept,omitempty"`
+}
+
+// ForfeitVote is a team's vote to concede a match after a teammate abandoned it. It
+// passes once every voter accepts and fails on the first refusal or at the deadline.
+type ForfeitVote struct {
+	Team       string   `json:"team"`
+	Voters     []string `json:"voters"`
+	Accepted   []string `json:"accepted"`
+	Status     string   `json:"status"` // open|passed|failed
+	DeadlineMS int64    `json:"deadline_ms"`
 }
 
 // ServerEnvelope is sent from server to client.
 type ServerEnvelope struct {
-	Type     string      `json:"type"` // welcome|state|pong|error
+	Type     string      `json:"type"` // welcome|state|delta|pong|error|forfeit_vote
 	Tick     uint64      `json:"tick,omitempty"`
 	State    *MatchState `json:"state,omitempty"`
+	Delta    *StateDelta `json:"delta,omitempty"`
 	ServerMS int64       `json:"server_ms,omitempty"`
+	Sync     *ClockSync  `json:"sync,omitempty"`
 	Message  string      `json:"message,omitempty"`
-	AckSeq   uint64      `json:"ack_seq,omitempty"`
+	// Physics is sent with the welcome so client prediction matches the match's preset.
+	Physics *Physics `json:"physics,omitempty"`
+	// Forfeit is the state of the recipient's team's forfeit vote.
+	Forfeit *ForfeitVote `json:"forfeit,omitempty"`
+	// ReconnectToken lets the client resume its car after a dropped connection.
+	ReconnectToken string `json:"reconnect_token,omitempty"`
+	AckSeq         uint64 `json:"ack_seq,omitempty"`
 }
 
 // QueueJoinRequest requests matchmaking entry.
@@ -98,44 +168,263 @@ type QueueJoinRequest struct {
 	Region      string `json:"region"`
 	Playlist    string `json:"playlist"`
 	MMR         int    `json:"mmr"`
+	// PartyID and Members are set when a party leader queues the whole party.
+	PartyID string        `json:"party_id,omitempty"`
+	Members []PartyMember `json:"members,omitempty"`
+	// Pings maps region to the client's measured ping in milliseconds. When present,
+	// the best region replaces Region and the search widens to the others over time.
+	Pings map[string]int `json:"pings_ms,omitempty"`
+}
+
+// PartyMember is one player in a party.
+type PartyMember struct {
+	PlayerID    string `json:"player_id"`
+	DisplayName string `json:"display_name"`
+	MMR         int    `json:"mmr,omitempty"`
+}
+
+// Party is a group of players who queue together and always share a team.
+type Party struct {
+	PartyID  string        `json:"party_id"`
+	LeaderID string        `json:"leader_id"`
+	Members  []PartyMember `json:"members"`
+	Invites  []string      `json:"invites"`
+	// TicketID is the party's live matchmaking ticket, if it is queued.
+	TicketID  string `json:"ticket_id,omitempty"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// LobbySettings configure a private lobby's match.
+type LobbySettings struct {
+	Playlist string `json:"playlist"`
+	TeamSize int    `json:"team_size"`
+	Region   string `json:"region"`
+	Physics  string `json:"physics"`
+	// MatchLengthSec is the match clock; zero uses the game server's default.
+	MatchLengthSec int `json:"match_length_sec,omitempty"`
+	// Bots fill the empty slots on both teams when the match starts.
+	Bots          bool   `json:"bots"`
+	BotDifficulty string `json:"bot_difficulty,omitempty"` // rookie|pro|allstar
+}
+
+// LobbyMember is one player in a lobby and the team the host put them on.
+type LobbyMember struct {
+	PlayerID    string `json:"player_id"`
+	DisplayName string `json:"display_name"`
+	Team        string `json:"team"` // orange|blue
+}
+
+// Lobby is a private match being set up. Players join with Code; the host picks
+// teams and settings and starts the match.
+type Lobby struct {
+	LobbyID   string        `json:"lobby_id"`
+	Code      string        `json:"code"`
+	HostID    string        `json:"host_id"`
+	Settings  LobbySettings `json:"settings"`
+	Members   []LobbyMember `json:"members"`
+	Status    string        `json:"status"` // open|starting|started
+	CreatedAt int64         `json:"created_at"`
+	// Assignment is the allocated match once the lobby has started.
+	Assignment *MatchAssignment `json:"assignment,omitempty"`
 }
 
 // QueueJoinResponse returns a ticket for polling.
 type QueueJoinResponse struct {
 	TicketID string `json:"ticket_id"`
 	Status   string `json:"status"`
+	// EstimatedWaitSec is a guess from recent matches in the bucket; omitted when unknown.
+	EstimatedWaitSec int `json:"estimated_wait_sec,omitempty"`
 }
 
 // MatchAssignment is returned once a ticket is matched.
 type MatchAssignment struct {
-	TicketID    string   `json:"ticket_id"`
-	MatchID     string   `json:"match_id"`
-	Region      string   `json:"region"`
-	Playlist    string   `json:"playlist"`
-	Players     []string `json:"players"`
-	BotFill     bool     `json:"bot_fill"`
-	ServerAddr  string   `json:"server_addr"`
-	FoundAtUnix int64    `json:"found_at_unix"`
+	TicketID string   `json:"ticket_id"`
+	MatchID  string   `json:"match_id"`
+	Region   string   `json:"region"`
+	Playlist string   `json:"playlist"`
+	Players  []string `json:"players"`
+	// Teams maps orange/blue to their players; empty slots are filled by "bot".
+	Teams   map[string][]string `json:"teams,omitempty"`
+	BotFill bool                `json:"bot_fill"`
+	Bots    []BotSlot           `json:"bots,omitempty"`
+	// Private, Physics and MatchLengthSec are set for lobby matches; see MatchAllocation.
+	Private        bool   `json:"private,omitempty"`
+	Physics        string `json:"physics,omitempty"`
+	MatchLengthSec int    `json:"match_length_sec,omitempty"`
+	// Pings is each player's measured ping to Region in milliseconds, where known.
+	Pings       map[string]int `json:"pings_ms,omitempty"`
+	ServerAddr  string         `json:"server_addr"`
+	FoundAtUnix int64          `json:"found_at_unix"`
+	// JoinTicket is minted by the gateway for the polling player and presented to the game server.
+	JoinTicket string `json:"join_ticket,omitempty"`
 }
 
 // QueuePollResponse represents current matchmaking status.
 type QueuePollResponse struct {
 	TicketID   string           `json:"ticket_id"`
-	Status     string           `json:"status"` // searching|matched|not_found
+	Status     string           `json:"status"` // searching|accept_required|matched|cancelled|not_found
 	Assignment *MatchAssignment `json:"assignment,omitempty"`
+	ReadyCheck *ReadyCheck      `json:"ready_check,omitempty"`
+	// Version increases with every status change; long-poll callers pass it back as since.
+	Version   uint64 `json:"version"`
+	WaitedSec int    `json:"waited_sec,omitempty"`
+	// EstimatedWaitSec is the expected remaining wait while searching; omitted when unknown.
+	EstimatedWaitSec int `json:"estimated_wait_sec,omitempty"`
+}
+
+// ReadyCheck is a found match waiting for every player to accept before a server is
+// allocated.
+type ReadyCheck struct {
+	MatchID    string   `json:"match_id"`
+	Playlist   string   `json:"playlist"`
+	Players    []string `json:"players"`
+	Accepted   []string `json:"accepted"`
+	DeadlineMS int64    `json:"deadline_ms"` // unix ms
+}
+
+// QueueLeaveRequest takes a ticket out of the queue on behalf of one of its players.
+type QueueLeaveRequest struct {
+	TicketID string `json:"ticket_id"`
+	PlayerID string `json:"player_id"`
+}
+
+// QueueAcceptRequest answers a ready check for one player on a ticket.
+type QueueAcceptRequest struct {
+	TicketID string `json:"ticket_id"`
+	PlayerID string `json:"player_id"`
+	Accept   bool   `json:"accept"`
+}
+
+// GameServerRegistration announces a game server process to the matchmaker fleet registry.
+type GameServerRegistration struct {
+	ServerID   string `json:"server_id"`
+	Region     string `json:"region"`
+	PublicAddr string `json:"public_addr"` // websocket URL handed to clients
+	ControlURL string `json:"control_url"` // HTTP base used for allocation calls
+	Capacity   int    `json:"capacity"`
+}
+
+// GameServerHeartbeat reports liveness and the matches a game server currently hosts.
+type GameServerHeartbeat struct {
+	ServerID string   `json:"server_id"`
+	Matches  []string `json:"matches"`
+}
+
+// GameServerStatus is the fleet registry's view of one game server.
+type GameServerStatus struct {
+	GameServerRegistration
+	Reserved        int   `json:"reserved"`
+	Hosted          int   `json:"hosted"`
+	LastHeartbeatAt int64 `json:"last_heartbeat_at"`
+}
+
+// MatchAllocation asks a game server to host a match for a fixed roster.
+type MatchAllocation struct {
+	MatchID  string              `json:"match_id"`
+	Region   string              `json:"region"`
+	Playlist string              `json:"playlist"`
+	TeamSize int                 `json:"team_size"`
+	Players  []string            `json:"players"`
+	Teams    map[string][]string `json:"teams,omitempty"`
+	BotFill  bool                `json:"bot_fill"`
+	Bots     []BotSlot           `json:"bots,omitempty"`
+	// Private marks a lobby match, which never affects ratings. Physics names a
+	// simulation preset and MatchLengthSec overrides the server's match length; both
+	// fall back to the server defaults when empty.
+	Private        bool   `json:"private,omitempty"`
+	Physics        string `json:"physics,omitempty"`
+	MatchLengthSec int    `json:"match_length_sec,omitempty"`
+}
+
+// BotSlot asks the game server to spawn one bot on a team.
+type BotSlot struct {
+	Team       string `json:"team"`
+	Difficulty string `json:"difficulty"` // rookie|pro|allstar
+}
+
+// PlayerMatchStats is one participant's line in a final match record.
+type PlayerMatchStats struct {
+	PlayerID     string `json:"player_id"`
+	DisplayName  string `json:"display_name"`
+	Team         string `json:"team"`
+	IsBot        bool   `json:"is_bot"`
+	Goals        int    `json:"goals"`
+	Shots        int    `json:"shots"`
+	Touches      int    `json:"touches"`
+	TimePlayedMS int64  `json:"time_played_ms"`
+	Abandoned    bool   `json:"abandoned"` // left before the end and never came back
+	// NoShow marks a rostered player who never connected. They count as abandoned only
+	// when another human did connect; a match nobody joined is a server-side failure.
+	NoShow bool `json:"no_show,omitempty"`
+}
+
+// MatchResult is the final record a game server reports when a match ends.
+type MatchResult struct {
+	MatchID     string `json:"match_id"`
+	Region      string `json:"region,omitempty"`
+	Playlist    string `json:"playlist,omitempty"`
+	ServerID    string `json:"server_id,omitempty"`
+	StartedAt   int64  `json:"started_at"` // unix ms
+	EndedAt     int64  `json:"ended_at"`   // unix ms
+	DurationMS  int64  `json:"duration_ms"`
+	OrangeScore int    `json:"orange_score"`
+	BlueScore   int    `json:"blue_score"`
+	Winner      string `json:"winner"` // orange|blue|draw
+	Forfeit     bool   `json:"forfeit"`
+	ForfeitTeam string `json:"forfeit_team,omitempty"`
+	// Private is set for lobby matches, which are recorded but never rated.
+	Private bool               `json:"private,omitempty"`
+	Players []PlayerMatchStats `json:"players"`
+}
+
+// Connected reports whether any human had a car in the match. A match nobody joined
+// failed on the allocation or server side and says nothing about its players.
+func (r MatchResult) Connected() bool {
+	for _, p := range r.Players {
+		if !p.IsBot && !p.NoShow {
+			return true
+		}
+	}
+	return false
 }
 
-// GuestAuthRequest requests a guest player token.
+// GuestAuthRequest requests a guest player token. With a DeviceID the device's account
+// is signed back in, so the player keeps the same id across sessions.
 type GuestAuthRequest struct {
 	DisplayName string `json:"display_name"`
+	DeviceID    string `json:"device_id,omitempty"`
 }
 
-// GuestAuthResponse returns signed auth details.
-type GuestAuthResponse struct {
+// AccountAuthRequest registers, signs in or upgrades to an account with a username and
+// password. DeviceID, when set, binds the device to the account.
+type AccountAuthRequest struct {
+	Username    string `json:"username"`
+	Password    string `json:"password"`
+	DisplayName string `json:"display_name,omitempty"`
+	DeviceID    string `json:"device_id,omitempty"`
+}
+
+// AuthResponse returns a session token and the account it signs in.
+type AuthResponse struct {
 	PlayerID    string `json:"player_id"`
 	DisplayName string `json:"display_name"`
-	Token       string `json:"token"`
-	ExpiresAt   int64  `json:"expires_at"`
+	Username    string `json:"username,omitempty"`
+	Guest       bool   `json:"guest"`
+	CreatedAt   int64  `json:"created_at"` // unix seconds
+	Token       string `json:"token,omitempty"`
+	ExpiresAt   int64  `json:"expires_at,omitempty"`
+}
+
+// StreamTokenRequest asks for a token to open one ticket's event stream with.
+type StreamTokenRequest struct {
+	TicketID string `json:"ticket_id"`
+}
+
+// StreamTokenResponse is a short-lived token that only opens the named ticket's event
+// stream, so the session token never has to go in a URL.
+type StreamTokenResponse struct {
+	StreamToken string `json:"stream_token"`
+	ExpiresAt   int64  `json:"expires_at"` // unix seconds
 }
 
 // TelemetryEvent represents a gameplay/platform event.
@@ -0,0 +1,131 @@
+package simulation
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"projectvelocity/backend/internal/shared/types"
+)
+
+// Bot difficulties, matching the levels matchmaking assigns.
+const (
+	BotRookie  = "rookie"
+	BotPro     = "pro"
+	BotAllStar = "allstar"
+)
+
+// botSkill tunes how sharply a bot drives.
+type botSkill struct {
+	// steerNormalization is the heading error, in degrees, that gets full steering lock.
+	steerNormalization float64
+	throttle           float64
+	// boostMinDist is how far from the ball a lined-up bot must be before it boosts.
+	boostMinDist float64
+	jumps        bool
+}
+
+var botSkills = map[string]botSkill{
+	BotRookie:  {steerNormalization: 60, throttle: 0.75, boostMinDist: math.Inf(1)},
+	BotPro:     {steerNormalization: BotSteerNormalization, throttle: 1, boostMinDist: 600, jumps: true},
+	BotAllStar: {steerNormalization: 20, throttle: 1, boostMinDist: 250, jumps: true},
+}
+
+// botSkillFor returns the tuning for level, treating unknown levels as BotPro.
+func botSkillFor(level string) botSkill {
+	if s, ok := botSkills[level]; ok {
+		return s
+	}
+	return botSkills[BotPro]
+}
+
+// SetBots makes the world's bots match slots: each team gets exactly as many bots as it
+// has slots, at the slots' difficulties. Existing bots are kept where possible.
+func (w *World) SetBots(slots []types.BotSlot) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	want := map[string][]string{}
+	for _, s := range slots {
+		team := s.Team
+		if team != "blue" {
+			team = "orange"
+		}
+		want[team] = append(want[team], s.Difficulty)
+	}
+	have := map[string][]string{}
+	for id, c := range w.state.Cars {
+		if c.IsBot {
+			have[c.Team] = append(have[c.Team], id)
+		}
+	}
+	for _, team := range []string{"orange", "blue"} {
+		ids := have[team]
+		sort.Strings(ids)
+		levels := want[team]
+		for i, id := range ids {
+			if i >= len(levels) {
+				w.removeBotLocked(id)
+				continue
+			}
+			w.botLevel[id] = levels[i]
+		}
+		for _, level := range levels[min(len(ids), len(levels)):] {
+			w.addBotLocked(team, level)
+		}
+	}
+}
+
+// addBotLocked spawns a bot at team's next kickoff slot. Callers hold w.mu.
+func (w *World) addBotLocked(team, level string) string {
+	count := 0
+	for _, c := range w.state.Cars {
+		if c.Team == team {
+			count++
+		}
+	}
+
+	botID := "bot_" + team + strconv.Itoa(count) + "_" + time.Now().UTC().Format("150405.000000000")
+	pos := types.Vec3{X: -2048, Y: kickoffSlotOffset(count), Z: CarRadius}
+	yaw := 0.0
+	if team == "blue" {
+		pos = types.Vec3{X: 2048, Y: kickoffSlotOffset(count), Z: CarRadius}
+		yaw = 180.0
+	}
+	w.state.Cars[botID] = types.CarState{
+		PlayerID:    botID,
+		DisplayName: "Velocity Bot",
+		Team:        team,
+		IsBot:       true,
+		Position:    pos,
+		Rotation:    types.Rotator{Yaw: yaw},
+		Boost:       100,
+		IsGrounded:  true,
+	}
+	w.jump[botID] = &jumpContext{}
+	w.botLevel[botID] = level
+	w.statsFor(w.state.Cars[botID])
+	w.state.Events = append(w.state.Events, types.GameplayEvent{
+		Type:       "player_join",
+		PlayerID:   botID,
+		Team:       team,
+		OccurredMS: time.Now().UTC().UnixMilli(),
+	})
+	return botID
+}
+
+// removeBotLocked despawns one bot. Callers hold w.mu.
+func (w *World) removeBotLocked(id string) {
+	c := w.state.Cars[id]
+	delete(w.state.Cars, id)
+	delete(w.input, id)
+	delete(w.jump, id)
+	delete(w.botLevel, id)
+	w.state.Events = append(w.state.Events, types.GameplayEvent{
+		Type:       "player_leave",
+		PlayerID:   id,
+		Team:       c.Team,
+		OccurredMS: time.Now().UTC().UnixMilli(),
+	})
+}
@@ -0,0 +1,60 @@
+package simulation
+
+import (
+	"sort"
+	"time"
+
+	"projectvelocity/backend/internal/shared/types"
+)
+
+// PhysicsStandard is the preset every public match plays with.
+const PhysicsStandard = "standard"
+
+// physicsPresets are the tunings private lobbies can pick. Anything they do not list
+// keeps the package constants.
+var physicsPresets = map[string]types.Physics{
+	PhysicsStandard: {Preset: PhysicsStandard, Gravity: Gravity, BoostAccel: BoostAccel, BallRestitution: BallRestitution, BallMaxSpeed: BallMaxSpeed},
+	"low_gravity":   {Preset: "low_gravity", Gravity: Gravity / 3, BoostAccel: BoostAccel, BallRestitution: 0.75, BallMaxSpeed: BallMaxSpeed},
+	"hyper":         {Preset: "hyper", Gravity: Gravity, BoostAccel: BoostAccel * 1.5, BallRestitution: BallRestitution, BallMaxSpeed: BallMaxSpeed * 1.5},
+	"bouncy":        {Preset: "bouncy", Gravity: Gravity, BoostAccel: BoostAccel, BallRestitution: 0.95, BallMaxSpeed: BallMaxSpeed},
+}
+
+// PhysicsPreset returns the named preset; "" is the standard preset.
+func PhysicsPreset(name string) (types.Physics, bool) {
+	if name == "" {
+		name = PhysicsStandard
+	}
+	p, ok := physicsPresets[name]
+	return p, ok
+}
+
+// PhysicsPresets lists the preset names in order.
+func PhysicsPresets() []string {
+	out := make([]string, 0, len(physicsPresets))
+	for name := range physicsPresets {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// SetPhysics switches the world to a preset.
+func (w *World) SetPhysics(p types.Physics) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.physics = p
+}
+
+// Physics returns the constants the world is simulating with.
+func (w *World) Physics() types.Physics {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.physics
+}
+
+// SetTimeRemaining resets the match clock, for matches configured with their own length.
+func (w *World) SetTimeRemaining(d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.state.Score.TimeRemainingMS = int(d.Milliseconds())
+}
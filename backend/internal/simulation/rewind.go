@@ -0,0 +1,73 @@
+package simulation
+
+import "projectvelocity/backend/internal/shared/types"
+
+// maxRewindSec bounds how far back a late input may replay its car. It covers the
+// game server's input plausibility window at any realistic latency.
+const maxRewindSec = 1.0
+
+// carFrame is a human car's state just before one tick, kept so a late input can be
+// replayed from the tick it was meant for.
+type carFrame struct {
+	car  types.CarState
+	jump jumpContext
+	dt   float64
+}
+
+// ApplyInputAt stores a client input that was stamped ageMS ago in server time and
+// replays the player's car from that moment with it, so the car responds when the
+// player pressed rather than when the packet arrived. Only the car's own movement is
+// replayed; ball contacts resolved since then stand.
+func (w *World) ApplyInputAt(in types.CarInput, ageMS int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	in = clampInput(in)
+	w.input[in.PlayerID] = in
+
+	car, ok := w.state.Cars[in.PlayerID]
+	frames := w.history[in.PlayerID]
+	if !ok || car.IsBot || car.Autopilot || ageMS <= 0 || len(frames) == 0 {
+		return
+	}
+	// Rewind to the first tick that ran after the stamp, rounding to the nearest tick.
+	age := float64(ageMS) / 1000
+	start, elapsed := len(frames), 0.0
+	for start > 0 && elapsed+frames[start-1].dt/2 <= age {
+		start--
+		elapsed += frames[start].dt
+	}
+	if start == len(frames) {
+		return
+	}
+
+	car, jc := frames[start].car, frames[start].jump
+	prev := car.LastInput
+	for i := start; i < len(frames); i++ {
+		frames[i].car, frames[i].jump = car, jc
+		updateCar(&car, in, prev, &jc, w.physics, frames[i].dt)
+		car.LastInput, prev = in, in
+		clampCarBounds(&car)
+	}
+	w.state.Cars[in.PlayerID] = car
+	*w.jump[in.PlayerID] = jc
+}
+
+// recordFrame remembers a human car's state before this tick moves it and drops frames
+// older than maxRewindSec. Callers hold w.mu.
+func (w *World) recordFrame(car types.CarState, jc *jumpContext, dt float64) {
+	if car.IsBot || car.Autopilot {
+		delete(w.history, car.PlayerID)
+		return
+	}
+	frames := append(w.history[car.PlayerID], carFrame{car: car, jump: *jc, dt: dt})
+	total := 0.0
+	for _, f := range frames {
+		total += f.dt
+	}
+	drop := 0
+	for drop < len(frames) && total > maxRewindSec {
+		total -= frames[drop].dt
+		drop++
+	}
+	w.history[car.PlayerID] = frames[drop:]
+}
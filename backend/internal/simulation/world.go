@@ -2,6 +2,7 @@ package simulation
 
 import (
 	"math"
+	"sort"
 	"sync"
 	"time"
 
@@ -72,12 +73,22 @@ type World struct {
 	input          map[string]types.CarInput
 	jump           map[string]*jumpContext
 	lastShotByTeam map[string]int64
+	// stats outlive cars so players who left still appear in the final record.
+	stats map[string]*types.PlayerMatchStats
+	// lastTouch is the car that most recently hit the ball, credited for shots and goals.
+	lastTouch string
+	// botLevel holds each bot's difficulty; cars missing from it drive at BotPro.
+	botLevel map[string]string
+	physics  types.Physics
+	// history holds each human car's recent pre-tick states for ApplyInputAt.
+	history map[string][]carFrame
 }
 
 // NewWorld creates a world with kickoff positions.
 func NewWorld(matchID string, duration time.Duration, players []PlayerSpawn) *World {
 	cars := make(map[string]types.CarState, len(players))
 	jump := make(map[string]*jumpContext, len(players))
+	stats := make(map[string]*types.PlayerMatchStats, len(players))
 
 	teamSlots := map[string]int{
 		"orange": 0,
@@ -113,6 +124,7 @@ func NewWorld(matchID string, duration time.Duration, players []PlayerSpawn) *Wo
 			IsGrounded:  true,
 		}
 		jump[p.PlayerID] = &jumpContext{}
+		stats[p.PlayerID] = &types.PlayerMatchStats{PlayerID: p.PlayerID, DisplayName: p.DisplayName, Team: team}
 	}
 
 	now := time.Now().UTC()
@@ -143,15 +155,17 @@ func NewWorld(matchID string, duration time.Duration, players []PlayerSpawn) *Wo
 			"orange": 0,
 			"blue":   0,
 		},
+		stats:    stats,
+		botLevel: make(map[string]string),
+		physics:  physicsPresets[PhysicsStandard],
+		history:  make(map[string][]carFrame),
 	}
 	return w
 }
 
 // ApplyInput stores latest client input for the player.
 func (w *World) ApplyInput(in types.CarInput) {
-	w.mu.Lock()
-	defer w.mu.Unlock()
-	w.input[in.PlayerID] = clampInput(in)
+	w.ApplyInputAt(in, 0)
 }
 
 // Tick advances the world simulation by dt seconds.
@@ -168,6 +182,11 @@ func (w *World) Tick(dt float64) {
 		if deltaMS < 1 {
 			deltaMS = 1
 		}
+		for _, car := range w.state.Cars {
+			if !car.IsBot && !car.Autopilot {
+				w.statsFor(car).TimePlayedMS += int64(deltaMS)
+			}
+		}
 		w.state.Score.TimeRemainingMS -= deltaMS
 		if w.state.Score.TimeRemainingMS < 0 {
 			w.state.Score.TimeRemainingMS = 0
@@ -182,15 +201,19 @@ func (w *World) Tick(dt float64) {
 			jc = &jumpContext{}
 			w.jump[id] = jc
 		}
-		updateCar(&car, in, prev, jc, dt)
+		w.recordFrame(car, jc, dt)
+		updateCar(&car, in, prev, jc, w.physics, dt)
 		car.LastInput = in
 		clampCarBounds(&car)
 		w.state.Cars[id] = car
 	}
 
-	updateBall(&w.state.Ball, dt)
-	clampBallBounds(&w.state.Ball)
-	resolveCarBallCollisions(&w.state)
+	updateBall(&w.state.Ball, w.physics, dt)
+	clampBallBounds(&w.state.Ball, w.physics)
+	if toucher := resolveCarBallCollisions(&w.state); toucher != "" {
+		w.lastTouch = toucher
+		w.statsFor(w.state.Cars[toucher]).Touches++
+	}
 	w.detectShotOnGoal()
 	w.detectGoalAndResetIfNeeded()
 }
@@ -214,8 +237,61 @@ func (w *World) Snapshot() types.MatchState {
 	return out
 }
 
+// Finished reports whether the match clock has run out.
+func (w *World) Finished() bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.state.Score.TimeRemainingMS <= 0
+}
+
+// Stats returns every participant's stats ordered by team then player id, including
+// players who have since left.
+func (w *World) Stats() []types.PlayerMatchStats {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	out := make([]types.PlayerMatchStats, 0, len(w.stats))
+	for _, st := range w.stats {
+		out = append(out, *st)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Team != out[j].Team {
+			return out[i].Team < out[j].Team
+		}
+		return out[i].PlayerID < out[j].PlayerID
+	})
+	return out
+}
+
+// statsFor returns the stats line for car, creating it on first sight. Callers hold w.mu.
+func (w *World) statsFor(car types.CarState) *types.PlayerMatchStats {
+	st, ok := w.stats[car.PlayerID]
+	if !ok {
+		st = &types.PlayerMatchStats{PlayerID: car.PlayerID}
+		w.stats[car.PlayerID] = st
+	}
+	st.DisplayName = car.DisplayName
+	st.Team = car.Team
+	st.IsBot = car.IsBot
+	return st
+}
+
+// creditTeamTouch returns the last toucher if they play for team, else "".
+func (w *World) creditTeamTouch(team string) string {
+	car, ok := w.state.Cars[w.lastTouch]
+	if !ok || car.Team != team {
+		return ""
+	}
+	return w.lastTouch
+}
+
 // EnsurePlayer inserts a player if not present and returns the assigned team.
 func (w *World) EnsurePlayer(playerID, displayName string) string {
+	return w.EnsurePlayerOnTeam(playerID, displayName, "")
+}
+
+// EnsurePlayerOnTeam is EnsurePlayer with a preassigned team from matchmaking. An empty
+// team balances by head count; existing cars keep their team.
+func (w *World) EnsurePlayerOnTeam(playerID, displayName, preferred string) string {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
@@ -224,6 +300,7 @@ func (w *World) EnsurePlayer(playerID, displayName string) string {
 			c.DisplayName = displayName
 		}
 		c.IsBot = false
+		c.Autopilot = false
 		w.state.Cars[playerID] = c
 		if _, ok := w.jump[playerID]; !ok {
 			w.jump[playerID] = &jumpContext{}
@@ -248,6 +325,9 @@ func (w *World) EnsurePlayer(playerID, displayName string) string {
 	if orangeCount > blueCount {
 		team = "blue"
 	}
+	if preferred == "orange" || preferred == "blue" {
+		team = preferred
+	}
 
 	teamSlot := orangeCount
 	if team == "blue" {
@@ -272,6 +352,7 @@ func (w *World) EnsurePlayer(playerID, displayName string) string {
 		IsGrounded:  true,
 	}
 	w.jump[playerID] = &jumpContext{}
+	w.statsFor(w.state.Cars[playerID]).Abandoned = false
 
 	w.state.Events = append(w.state.Events, types.GameplayEvent{
 		Type:       "player_join",
@@ -336,39 +417,24 @@ func (w *World) EnsureBotOpponent(playerID string) string {
 			return id
 		}
 	}
+	return w.addBotLocked(opp, BotPro)
+}
 
-	oppCount := 0
-	for _, c := range w.state.Cars {
-		if c.Team == opp {
-			oppCount++
-		}
-	}
-
-	botID := "bot_" + opp + "_" + time.Now().UTC().Format("150405.000000000")
-	pos := types.Vec3{X: -2048, Y: kickoffSlotOffset(oppCount), Z: CarRadius}
-	yaw := 0.0
-	if opp == "blue" {
-		pos = types.Vec3{X: 2048, Y: kickoffSlotOffset(oppCount), Z: CarRadius}
-		yaw = 180.0
+// SetAutopilot hands a human car to the bot controller while its player is away.
+// It reports false if the player has no car.
+func (w *World) SetAutopilot(playerID string, on bool) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	c, ok := w.state.Cars[playerID]
+	if !ok || c.IsBot {
+		return false
 	}
-	w.state.Cars[botID] = types.CarState{
-		PlayerID:    botID,
-		DisplayName: "Velocity Bot",
-		Team:        opp,
-		IsBot:       true,
-		Position:    pos,
-		Rotation:    types.Rotator{Yaw: yaw},
-		Boost:       100,
-		IsGrounded:  true,
+	c.Autopilot = on
+	w.state.Cars[playerID] = c
+	if !on {
+		delete(w.input, playerID)
 	}
-	w.jump[botID] = &jumpContext{}
-	w.state.Events = append(w.state.Events, types.GameplayEvent{
-		Type:       "player_join",
-		PlayerID:   botID,
-		Team:       opp,
-		OccurredMS: time.Now().UTC().UnixMilli(),
-	})
-	return botID
+	return true
 }
 
 // RemoveAllBots removes every bot, used when enough humans are available.
@@ -377,15 +443,7 @@ func (w *World) RemoveAllBots() {
 	defer w.mu.Unlock()
 	for id, c := range w.state.Cars {
 		if c.IsBot {
-			delete(w.state.Cars, id)
-			delete(w.input, id)
-			delete(w.jump, id)
-			w.state.Events = append(w.state.Events, types.GameplayEvent{
-				Type:       "player_leave",
-				PlayerID:   id,
-				Team:       c.Team,
-				OccurredMS: time.Now().UTC().UnixMilli(),
-			})
+			w.removeBotLocked(id)
 		}
 	}
 }
@@ -398,9 +456,13 @@ func (w *World) RemovePlayer(playerID string) {
 	if !ok {
 		return
 	}
+	if !c.IsBot && w.state.Score.TimeRemainingMS > 0 {
+		w.statsFor(c).Abandoned = true
+	}
 	delete(w.state.Cars, playerID)
 	delete(w.input, playerID)
 	delete(w.jump, playerID)
+	delete(w.history, playerID)
 	w.state.Events = append(w.state.Events, types.GameplayEvent{
 		Type:       "player_leave",
 		PlayerID:   playerID,
@@ -409,13 +471,26 @@ func (w *World) RemovePlayer(playerID string) {
 	})
 }
 
+// Forfeit ends the match at once on team's concession: the clock stops and a forfeit
+// event tells clients why.
+func (w *World) Forfeit(team string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.state.Score.TimeRemainingMS = 0
+	w.state.Events = append(w.state.Events, types.GameplayEvent{
+		Type:       "forfeit",
+		Team:       team,
+		OccurredMS: time.Now().UTC().UnixMilli(),
+	})
+}
+
 func (w *World) computeBotInputs() {
 	now := time.Now().UTC().UnixMilli()
 	for id, car := range w.state.Cars {
-		if !car.IsBot {
+		if !car.IsBot && !car.Autopilot {
 			continue
 		}
-		in := botInputFor(car, w.state.Ball)
+		in := botInputFor(car, w.state.Ball, botSkillFor(w.botLevel[id]))
 		in.PlayerID = id
 		in.Sequence = w.state.Tick
 		in.ClientMS = now
@@ -423,7 +498,7 @@ func (w *World) computeBotInputs() {
 	}
 }
 
-func botInputFor(car types.CarState, ball types.BallState) types.CarInput {
+func botInputFor(car types.CarState, ball types.BallState, skill botSkill) types.CarInput {
 	dx := ball.Position.X - car.Position.X
 	dy := ball.Position.Y - car.Position.Y
 	dz := ball.Position.Z - car.Position.Z
@@ -431,15 +506,15 @@ func botInputFor(car types.CarState, ball types.BallState) types.CarInput {
 
 	targetYaw := math.Atan2(dy, dx) * 180 / math.Pi
 	delta := normalizeSignedDeg(targetYaw - car.Rotation.Yaw)
-	steer := clamp(delta/BotSteerNormalization, -1, 1)
+	steer := clamp(delta/skill.steerNormalization, -1, 1)
 
-	throttle := 1.0
+	throttle := skill.throttle
 	if math.Abs(delta) > 120 {
 		throttle = -0.25
 	}
-	boost := math.Abs(delta) < 12 && dist2D > 600 && car.Boost > 15
+	boost := math.Abs(delta) < 12 && dist2D > skill.boostMinDist && car.Boost > 15
 	handbrake := math.Abs(delta) > 75
-	jump := car.IsGrounded && dist2D < 250 && dz > 110
+	jump := skill.jumps && car.IsGrounded && dist2D < 250 && dz > 110
 
 	return types.CarInput{
 		Throttle:  throttle,
@@ -456,8 +531,13 @@ func (w *World) detectShotOnGoal() {
 
 	if b.Position.X > ArenaLength*0.35 && b.Velocity.X > 200 && math.Abs(b.Position.Y) <= GoalWidth*0.7 {
 		if now-w.lastShotByTeam["orange"] >= 700 {
+			shooter := w.creditTeamTouch("orange")
+			if shooter != "" {
+				w.stats[shooter].Shots++
+			}
 			w.state.Events = append(w.state.Events, types.GameplayEvent{
 				Type:       "shot_on_goal",
+				PlayerID:   shooter,
 				Team:       "orange",
 				OccurredMS: now,
 			})
@@ -466,8 +546,13 @@ func (w *World) detectShotOnGoal() {
 	}
 	if b.Position.X < -ArenaLength*0.35 && b.Velocity.X < -200 && math.Abs(b.Position.Y) <= GoalWidth*0.7 {
 		if now-w.lastShotByTeam["blue"] >= 700 {
+			shooter := w.creditTeamTouch("blue")
+			if shooter != "" {
+				w.stats[shooter].Shots++
+			}
 			w.state.Events = append(w.state.Events, types.GameplayEvent{
 				Type:       "shot_on_goal",
+				PlayerID:   shooter,
 				Team:       "blue",
 				OccurredMS: now,
 			})
@@ -487,13 +572,21 @@ func (w *World) detectGoalAndResetIfNeeded() {
 	now := time.Now().UTC().UnixMilli()
 	if b.Position.X >= ArenaLength/2 {
 		w.state.Score.Orange++
-		w.state.Events = append(w.state.Events, types.GameplayEvent{Type: "goal", Team: "orange", OccurredMS: now})
+		scorer := w.creditTeamTouch("orange")
+		if scorer != "" {
+			w.stats[scorer].Goals++
+		}
+		w.state.Events = append(w.state.Events, types.GameplayEvent{Type: "goal", PlayerID: scorer, Team: "orange", OccurredMS: now})
 		w.resetKickoff("orange")
 		return
 	}
 	if b.Position.X <= -ArenaLength/2 {
 		w.state.Score.Blue++
-		w.state.Events = append(w.state.Events, types.GameplayEvent{Type: "goal", Team: "blue", OccurredMS: now})
+		scorer := w.creditTeamTouch("blue")
+		if scorer != "" {
+			w.stats[scorer].Goals++
+		}
+		w.state.Events = append(w.state.Events, types.GameplayEvent{Type: "goal", PlayerID: scorer, Team: "blue", OccurredMS: now})
 		w.resetKickoff("blue")
 	}
 }
@@ -501,6 +594,9 @@ func (w *World) detectGoalAndResetIfNeeded() {
 func (w *World) resetKickoff(scoringTeam string) {
 	w.state.Ball.Position = types.Vec3{X: 0, Y: 0, Z: BallRadius + 20}
 	w.state.Ball.Velocity = types.Vec3{}
+	w.lastTouch = ""
+	// Replaying a car across the reset would drag it back to where the goal was scored.
+	clear(w.history)
 
 	teamSlots := map[string]int{
 		"orange": 0,
@@ -545,7 +641,7 @@ func clampInput(in types.CarInput) types.CarInput {
 	return in
 }
 
-func updateCar(car *types.CarState, in types.CarInput, prev types.CarInput, jc *jumpContext, dt float64) {
+func updateCar(car *types.CarState, in types.CarInput, prev types.CarInput, jc *jumpContext, phys types.Physics, dt float64) {
 	yawRad := car.Rotation.Yaw * math.Pi / 180.0
 
 	speed2D := math.Hypot(car.Velocity.X, car.Velocity.Y)
@@ -583,7 +679,7 @@ func updateCar(car *types.CarState, in types.CarInput, prev types.CarInput, jc *
 
 	usingBoost := in.Boost && car.Boost > 0
 	if usingBoost && in.Throttle > 0 {
-		forwardSpeed += BoostAccel * dt
+		forwardSpeed += phys.BoostAccel * dt
 		car.Boost -= 34.0 * dt
 		if car.Boost < 0 {
 			car.Boost = 0
@@ -665,7 +761,7 @@ func updateCar(car *types.CarState, in types.CarInput, prev types.CarInput, jc *
 		}
 	}
 
-	car.Velocity.Z += Gravity * dt
+	car.Velocity.Z += phys.Gravity * dt
 	if car.IsGrounded {
 		car.Velocity.X *= GroundFriction
 		car.Velocity.Y *= GroundFriction
@@ -714,8 +810,8 @@ func clampCarBounds(car *types.CarState) {
 	}
 }
 
-func updateBall(ball *types.BallState, dt float64) {
-	ball.Velocity.Z += Gravity * dt
+func updateBall(ball *types.BallState, phys types.Physics, dt float64) {
+	ball.Velocity.Z += phys.Gravity * dt
 	ball.Position.X += ball.Velocity.X * dt
 	ball.Position.Y += ball.Velocity.Y * dt
 	ball.Position.Z += ball.Velocity.Z * dt
@@ -730,25 +826,25 @@ func updateBall(ball *types.BallState, dt float64) {
 	ball.Velocity.Z *= 0.9994
 
 	speed := math.Sqrt(ball.Velocity.X*ball.Velocity.X + ball.Velocity.Y*ball.Velocity.Y + ball.Velocity.Z*ball.Velocity.Z)
-	if speed > BallMaxSpeed && speed > 0 {
-		scale := BallMaxSpeed / speed
+	if speed > phys.BallMaxSpeed && speed > 0 {
+		scale := phys.BallMaxSpeed / speed
 		ball.Velocity.X *= scale
 		ball.Velocity.Y *= scale
 		ball.Velocity.Z *= scale
 	}
 }
 
-func clampBallBounds(ball *types.BallState) {
+func clampBallBounds(ball *types.BallState, phys types.Physics) {
 	halfL := ArenaLength / 2
 	halfW := ArenaWidth / 2
 
 	if ball.Position.Z < ball.Radius {
 		ball.Position.Z = ball.Radius
-		ball.Velocity.Z = -ball.Velocity.Z * BallRestitution
+		ball.Velocity.Z = -ball.Velocity.Z * phys.BallRestitution
 	}
 	if ball.Position.Z > ArenaHeight-ball.Radius {
 		ball.Position.Z = ArenaHeight - ball.Radius
-		ball.Velocity.Z = -ball.Velocity.Z * BallRestitution
+		ball.Velocity.Z = -ball.Velocity.Z * phys.BallRestitution
 	}
 
 	inGoalY := math.Abs(ball.Position.Y) <= GoalWidth/2
@@ -775,7 +871,10 @@ func clampBallBounds(ball *types.BallState) {
 	}
 }
 
-func resolveCarBallCollisions(state *types.MatchState) {
+// resolveCarBallCollisions bounces the ball off overlapping cars and returns the id of
+// a car that hit it this tick, or "" when nothing did.
+func resolveCarBallCollisions(state *types.MatchState) string {
+	toucher := ""
 	for id, car := range state.Cars {
 		dx := state.Ball.Position.X - car.Position.X
 		dy := state.Ball.Position.Y - car.Position.Y
@@ -812,7 +911,9 @@ func resolveCarBallCollisions(state *types.MatchState) {
 		car.Position.Z -= nz * overlap * 0.15
 
 		state.Cars[id] = car
+		toucher = id
 	}
+	return toucher
 }
 
 func normalizeDeg(d float64) float64 {
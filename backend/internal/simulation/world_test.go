@@ -101,6 +101,44 @@ func TestBotLifecycleForSingleHuman(t *testing.T) {
 	}
 }
 
+func TestSetBotsReconcilesTeamsAndDifficulty(t *testing.T) {
+	w := NewWorld("m9", 10*time.Second, nil)
+	w.EnsurePlayer("p1", "Pilot1")
+	w.SetBots([]types.BotSlot{
+		{Team: "orange", Difficulty: BotRookie},
+		{Team: "blue", Difficulty: BotAllStar},
+		{Team: "blue", Difficulty: BotAllStar},
+	})
+	bots := func() map[string]int {
+		out := map[string]int{}
+		for _, c := range w.Snapshot().Cars {
+			if c.IsBot {
+				out[c.Team]++
+			}
+		}
+		return out
+	}
+	if got := bots(); got["orange"] != 1 || got["blue"] != 2 {
+		t.Fatalf("expected 1 orange and 2 blue bots, got=%v", got)
+	}
+
+	w.SetBots([]types.BotSlot{{Team: "blue", Difficulty: BotRookie}})
+	if got := bots(); got["orange"] != 0 || got["blue"] != 1 {
+		t.Fatalf("expected surplus bots removed, got=%v", got)
+	}
+	for id, level := range w.botLevel {
+		if level != BotRookie {
+			t.Fatalf("expected remaining bot %s retuned to rookie, got=%s", id, level)
+		}
+	}
+
+	rookie := botInputFor(types.CarState{}, types.BallState{Position: types.Vec3{X: 3000}}, botSkillFor(BotRookie))
+	allstar := botInputFor(types.CarState{Boost: 100}, types.BallState{Position: types.Vec3{X: 3000}}, botSkillFor(BotAllStar))
+	if rookie.Boost || rookie.Throttle >= allstar.Throttle || !allstar.Boost {
+		t.Fatalf("expected rookie to drive slower than all-star, rookie=%+v allstar=%+v", rookie, allstar)
+	}
+}
+
 func TestRemovePlayer(t *testing.T) {
 	w := NewWorld("m6", 10*time.Second, nil)
 	w.EnsurePlayer("p1", "Pilot1")
@@ -110,6 +148,27 @@ func TestRemovePlayer(t *testing.T) {
 	}
 }
 
+func TestForfeitEndsMatchWithoutAbandoningRemainingPlayers(t *testing.T) {
+	w := NewWorld("m-forfeit", 10*time.Second, []PlayerSpawn{
+		{PlayerID: "p1", DisplayName: "p1", Team: "orange"},
+		{PlayerID: "p2", DisplayName: "p2", Team: "blue"},
+	})
+	w.Forfeit("orange")
+	if !w.Finished() {
+		t.Fatal("expected a forfeit to end the match")
+	}
+	events := w.Snapshot().Events
+	if len(events) == 0 || events[len(events)-1].Type != "forfeit" || events[len(events)-1].Team != "orange" {
+		t.Fatalf("expected a forfeit event, got=%+v", events)
+	}
+	w.RemovePlayer("p1")
+	for _, st := range w.Stats() {
+		if st.Abandoned {
+			t.Fatalf("expected leaving after the forfeit not to count as abandoning: %+v", st)
+		}
+	}
+}
+
 func TestForwardAccelerationIsResponsive(t *testing.T) {
 	w := NewWorld("m7", 10*time.Second, []PlayerSpawn{{PlayerID: "p1", DisplayName: "p1", Team: "orange"}})
 	w.ApplyInput(types.CarInput{PlayerID: "p1", Throttle: 1})
@@ -167,3 +226,123 @@ func TestJumpAndDoubleJumpWork(t *testing.T) {
 		t.Fatalf("expected double jump to increase vertical speed, before=%f after=%f", velBeforeSecond, afterSecond.Velocity.Z)
 	}
 }
+
+func TestAutopilotDrivesHeldCarWithoutCountingAsBot(t *testing.T) {
+	w := NewWorld("m10", 10*time.Second, []PlayerSpawn{{PlayerID: "p1", DisplayName: "p1", Team: "orange"}})
+	if !w.SetAutopilot("p1", true) {
+		t.Fatal("expected autopilot to engage for existing car")
+	}
+	for range 60 {
+		w.Tick(1.0 / 120.0)
+	}
+	car := w.Snapshot().Cars["p1"]
+	if math.Hypot(car.Velocity.X, car.Velocity.Y) < 100 {
+		t.Fatalf("expected autopilot to drive the car, velocity=%+v", car.Velocity)
+	}
+	if w.HumanCount() != 1 {
+		t.Fatalf("expected held car to count as human, got=%d", w.HumanCount())
+	}
+
+	w.EnsurePlayer("p1", "p1")
+	if w.Snapshot().Cars["p1"].Autopilot {
+		t.Fatal("expected rejoin to release autopilot")
+	}
+}
+
+func TestStatsCreditScorerAndKeepLeavers(t *testing.T) {
+	w := NewWorld("m11", 10*time.Second, []PlayerSpawn{
+		{PlayerID: "p1", DisplayName: "p1", Team: "orange"},
+		{PlayerID: "p2", DisplayName: "p2", Team: "blue"},
+	})
+	w.mu.Lock()
+	w.lastTouch = "p1"
+	w.state.Ball.Position = types.Vec3{X: ArenaLength/2 + 5, Y: 0, Z: 100}
+	w.mu.Unlock()
+
+	w.Tick(1.0 / 120.0)
+	w.RemovePlayer("p2")
+
+	stats := w.Stats()
+	if len(stats) != 2 {
+		t.Fatalf("expected stats for both players, got=%+v", stats)
+	}
+	// Ordered by team: blue before orange.
+	blue, orange := stats[0], stats[1]
+	if orange.PlayerID != "p1" || orange.Goals != 1 || orange.TimePlayedMS <= 0 {
+		t.Fatalf("expected p1 credited with the goal, got=%+v", orange)
+	}
+	if blue.PlayerID != "p2" || !blue.Abandoned {
+		t.Fatalf("expected p2 marked abandoned, got=%+v", blue)
+	}
+
+	w.EnsurePlayer("p2", "p2")
+	for _, st := range w.Stats() {
+		if st.PlayerID == "p2" && st.Abandoned {
+			t.Fatal("expected rejoin to clear abandoned flag")
+		}
+	}
+}
+
+func TestLowGravityPresetSlowsTheBallsFall(t *testing.T) {
+	fall := func(preset string) float64 {
+		w := NewWorld("m-phys", 10*time.Second, nil)
+		if preset != "" {
+			p, ok := PhysicsPreset(preset)
+			if !ok {
+				t.Fatalf("expected preset %q to exist", preset)
+			}
+			w.SetPhysics(p)
+		}
+		w.mu.Lock()
+		w.state.Ball.Position.Z = 1500
+		w.mu.Unlock()
+		for range 60 {
+			w.Tick(1.0 / 120.0)
+		}
+		return 1500 - w.Snapshot().Ball.Position.Z
+	}
+	standard, low := fall(""), fall("low_gravity")
+	if low <= 0 || low >= standard/2 {
+		t.Fatalf("expected low gravity to fall well short of standard, standard=%f low=%f", standard, low)
+	}
+	if _, ok := PhysicsPreset("moon"); ok {
+		t.Fatal("expected unknown preset to be rejected")
+	}
+}
+
+func TestLateInputIsReplayedFromItsStampedTick(t *testing.T) {
+	const dt = 1.0 / 120.0
+	spawn := []PlayerSpawn{{PlayerID: "p1", DisplayName: "p1", Team: "orange"}}
+	throttle := types.CarInput{PlayerID: "p1", Throttle: 1}
+
+	// The reference world receives the input on time, 20 ticks before the end.
+	onTime := NewWorld("m-ref", 10*time.Second, spawn)
+	for i := range 30 {
+		if i == 10 {
+			onTime.ApplyInput(throttle)
+		}
+		onTime.Tick(dt)
+	}
+
+	// The same input stamped 20 ticks ago arrives only now.
+	late := NewWorld("m-late", 10*time.Second, spawn)
+	for range 30 {
+		late.Tick(dt)
+	}
+	late.ApplyInputAt(throttle, int64(math.Round(20*dt*1000)))
+
+	want, got := onTime.Snapshot().Cars["p1"], late.Snapshot().Cars["p1"]
+	if math.Abs(want.Position.X-got.Position.X) > 1e-6 || math.Abs(want.Velocity.X-got.Velocity.X) > 1e-6 {
+		t.Fatalf("expected the late input to land at its stamp: want pos=%v vel=%v got pos=%v vel=%v",
+			want.Position, want.Velocity, got.Position, got.Velocity)
+	}
+
+	uncompensated := NewWorld("m-now", 10*time.Second, spawn)
+	for range 30 {
+		uncompensated.Tick(dt)
+	}
+	uncompensated.ApplyInput(throttle)
+	if still := uncompensated.Snapshot().Cars["p1"]; still.Position.X != -2048 || want.Position.X <= -2048 {
+		t.Fatalf("expected an unstamped input to wait for the next tick, pos=%v", still.Position)
+	}
+}
@@ -0,0 +1,108 @@
+package tournament
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// drawElimination lays out a single or double elimination bracket for the seeded
+// teams. The bracket is padded to a power of two with byes, which go to the top
+// seeds, and the seeds are placed so the top two can only meet in the final.
+//
+// A double elimination losers' bracket alternates two kinds of round: one where its
+// survivors play each other, and one where they meet the teams just knocked out of the
+// winners' bracket. Its champion meets the winners' champion in the grand final, which
+// is played again if the losers' side wins the first one.
+func (t *Tournament) drawElimination(double bool) {
+	size := 1
+	for size < len(t.Teams) {
+		size *= 2
+	}
+	rounds := bits.Len(uint(size)) - 1
+
+	seeds := seedOrder(size)
+	for r := 1; r <= rounds; r++ {
+		n := size >> r
+		for i := range n {
+			s := &Series{SeriesID: winnersID(r, i), Bracket: "winners", Round: r, Status: SeriesPending, Games: []Game{}}
+			if r == 1 {
+				s.Home, s.Away = t.seeded(seeds[2*i]), t.seeded(seeds[2*i+1])
+			} else {
+				s.feeders = 2
+			}
+			if r < rounds {
+				s.WinnerTo, s.winnerSide = winnersID(r+1, i/2), i%2
+			} else if double {
+				s.WinnerTo, s.winnerSide = "GF", 0
+			}
+			if double {
+				switch {
+				case rounds == 1:
+					s.LoserTo, s.loserSide = "GF", 1
+				case r == 1:
+					s.LoserTo, s.loserSide = losersID(1, i/2), i%2
+				default:
+					// Drop-ins land in reverse order so teams do not meet again at once.
+					s.LoserTo, s.loserSide = losersID(2*(r-1), n-1-i), 1
+				}
+			}
+			t.Series = append(t.Series, s)
+		}
+	}
+
+	if double {
+		last := 2 * (rounds - 1)
+		for j := 1; j <= last; j++ {
+			n := size >> (j/2 + 2)
+			if j%2 == 0 {
+				n = size >> (j/2 + 1)
+			}
+			for i := range n {
+				s := &Series{SeriesID: losersID(j, i), Bracket: "losers", Round: j, Status: SeriesPending, Games: []Game{}, feeders: 2}
+				switch {
+				case j == last:
+					s.WinnerTo, s.winnerSide = "GF", 1
+				case j%2 == 1:
+					s.WinnerTo, s.winnerSide = losersID(j+1, i), 0
+				default:
+					s.WinnerTo, s.winnerSide = losersID(j+1, i/2), i%2
+				}
+				t.Series = append(t.Series, s)
+			}
+		}
+		t.Series = append(t.Series, &Series{SeriesID: "GF", Bracket: "grand_final", Round: 1, Status: SeriesPending, Games: []Game{}, feeders: 2})
+	}
+
+	// Every series exists now, so first-round byes can move their teams on.
+	for _, s := range t.Series {
+		if s.Round == 1 && s.Bracket == "winners" {
+			t.settle(s)
+		}
+	}
+}
+
+// seeded returns the id of the team with seed, or "" for a bye.
+func (t *Tournament) seeded(seed int) string {
+	if seed > len(t.Teams) {
+		return ""
+	}
+	return t.Teams[seed-1].TeamID
+}
+
+// seedOrder lists seeds in bracket order for a bracket of size teams: 1, 8, 4, 5, 2,
+// 7, 3, 6 for eight. Adjacent pairs meet in the first round.
+func seedOrder(size int) []int {
+	order := []int{1}
+	for len(order) < size {
+		next := make([]int, 0, 2*len(order))
+		for _, seed := range order {
+			next = append(next, seed, 2*len(order)+1-seed)
+		}
+		order = next
+	}
+	return order
+}
+
+func winnersID(round, slot int) string { return fmt.Sprintf("W%d-%d", round, slot) }
+
+func losersID(round, slot int) string { return fmt.Sprintf("L%d-%d", round, slot) }
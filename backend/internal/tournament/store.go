@@ -0,0 +1,66 @@
+package tournament
+
+import (
+	"maps"
+	"slices"
+	"sync"
+
+	"projectvelocity/backend/internal/shared/types"
+)
+
+// Store persists tournaments so they outlive the matchmaker running them. Manager
+// writes through on every change while holding its lock, so implementations must not
+// call back into it.
+type Store interface {
+	// Load returns every saved tournament.
+	Load() ([]Record, error)
+	// Put saves rec, replacing any earlier record for the same tournament.
+	Put(rec Record) error
+	Close() error
+}
+
+// Record is the durable form of a tournament: its bracket state plus the links between
+// series that the bracket view leaves out.
+type Record struct {
+	Tournament Tournament `json:"tournament"`
+	// Series holds each series' links, in the order of Tournament.Series.
+	Series []SeriesLinks `json:"series"`
+	// Seq is the manager's ID counter, so restored IDs are never handed out again.
+	Seq uint64 `json:"seq"`
+}
+
+// SeriesLinks is where a series sends its teams and how many it still waits for.
+type SeriesLinks struct {
+	WinnerSide int                    `json:"winner_side"`
+	LoserSide  int                    `json:"loser_side"`
+	Feeders    int                    `json:"feeders"`
+	Assignment *types.MatchAssignment `json:"assignment,omitempty"`
+}
+
+// MemoryStore keeps records in process. It is the default store, so tournaments last
+// only as long as the matchmaker does.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]Record
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]Record)}
+}
+
+func (s *MemoryStore) Load() ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Collect(maps.Values(s.records)), nil
+}
+
+func (s *MemoryStore) Put(rec Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[rec.Tournament.TournamentID] = rec
+	return nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
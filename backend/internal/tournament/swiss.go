@@ -0,0 +1,161 @@
+package tournament
+
+import (
+	"fmt"
+	"math/bits"
+	"slices"
+	"sort"
+)
+
+// swissRounds is the configured round count, or by default enough rounds for one team
+// to finish unbeaten.
+func (t *Tournament) swissRounds() int {
+	if t.Config.SwissRounds > 0 {
+		return t.Config.SwissRounds
+	}
+	return bits.Len(uint(len(t.Teams) - 1))
+}
+
+// startSwissRound pairs the next round. The first round sets the top half of the seeds
+// against the bottom half; later rounds pair teams on the same record, best first,
+// avoiding rematches where possible. With an odd field the lowest-ranked team that has
+// not yet had a bye sits out and is given the win.
+func (t *Tournament) startSwissRound() {
+	t.Round++
+	t.Standings = t.swissStandings()
+	// Standings start out in seed order, so the first round ranks by seed.
+	order := make([]string, 0, len(t.Standings))
+	for _, s := range t.Standings {
+		order = append(order, s.TeamID)
+	}
+
+	played := make(map[[2]string]bool)
+	hadBye := make(map[string]bool)
+	for _, s := range t.Series {
+		played[[2]string{s.Home, s.Away}] = true
+		played[[2]string{s.Away, s.Home}] = true
+		if s.Away == "" {
+			hadBye[s.Home] = true
+		}
+	}
+
+	slot := 0
+	add := func(home, away string) {
+		s := &Series{SeriesID: fmt.Sprintf("S%d-%d", t.Round, slot), Bracket: "swiss", Round: t.Round, Home: home, Away: away, Status: SeriesReady, Games: []Game{}}
+		slot++
+		if away == "" {
+			s.Winner, s.Status = home, SeriesDone
+		}
+		t.Series = append(t.Series, s)
+	}
+
+	if len(order)%2 == 1 {
+		bye := len(order) - 1
+		for i := len(order) - 1; i >= 0; i-- {
+			if !hadBye[order[i]] {
+				bye = i
+				break
+			}
+		}
+		add(order[bye], "")
+		order = slices.Delete(order, bye, bye+1)
+	}
+	if t.Round == 1 {
+		half := len(order) / 2
+		folded := make([]string, 0, len(order))
+		for i := range half {
+			folded = append(folded, order[i], order[i+half])
+		}
+		order = folded
+	}
+	pairs, ok := pairUp(order, played)
+	if !ok {
+		// Every pairing repeats a meeting; take the best-ranked one regardless.
+		pairs, _ = pairUp(order, nil)
+	}
+	for _, p := range pairs {
+		add(p[0], p[1])
+	}
+}
+
+// pairUp pairs the teams in order, each with the best-ranked team left that it has not
+// played, backtracking when that strands someone. It reports false when no pairing
+// avoids a rematch.
+func pairUp(order []string, played map[[2]string]bool) ([][2]string, bool) {
+	if len(order) == 0 {
+		return nil, true
+	}
+	home := order[0]
+	for j := 1; j < len(order); j++ {
+		if played[[2]string{home, order[j]}] {
+			continue
+		}
+		rest := slices.Delete(slices.Clone(order[1:]), j-1, j)
+		if pairs, ok := pairUp(rest, played); ok {
+			return append([][2]string{{home, order[j]}}, pairs...), true
+		}
+	}
+	return nil, false
+}
+
+// swissSeriesDone starts the next round once the current one is over, or ends the
+// tournament after the last round with the top of the standings as champion.
+func (t *Tournament) swissSeriesDone() {
+	t.Standings = t.swissStandings()
+	for _, s := range t.Series {
+		if s.Round == t.Round && s.Status != SeriesDone {
+			return
+		}
+	}
+	if t.Round < t.swissRounds() {
+		t.startSwissRound()
+		return
+	}
+	t.Status, t.Champion = StatusFinished, t.Standings[0].TeamID
+}
+
+// swissStandings ranks teams by series wins, then Buchholz, then game difference, then
+// seed.
+func (t *Tournament) swissStandings() []Standing {
+	byTeam := make(map[string]*Standing, len(t.Teams))
+	seed := make(map[string]int, len(t.Teams))
+	for _, team := range t.Teams {
+		byTeam[team.TeamID] = &Standing{TeamID: team.TeamID}
+		seed[team.TeamID] = team.Seed
+	}
+	opponents := make(map[string][]string)
+	for _, s := range t.Series {
+		if s.Status != SeriesDone {
+			continue
+		}
+		byTeam[s.Winner].Wins++
+		if s.Loser == "" {
+			continue
+		}
+		byTeam[s.Loser].Losses++
+		byTeam[s.Home].GameDiff += s.HomeWins - s.AwayWins
+		byTeam[s.Away].GameDiff += s.AwayWins - s.HomeWins
+		opponents[s.Home] = append(opponents[s.Home], s.Away)
+		opponents[s.Away] = append(opponents[s.Away], s.Home)
+	}
+	out := make([]Standing, 0, len(byTeam))
+	for id, st := range byTeam {
+		for _, opp := range opponents[id] {
+			st.Buchholz += byTeam[opp].Wins
+		}
+		out = append(out, *st)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		switch {
+		case a.Wins != b.Wins:
+			return a.Wins > b.Wins
+		case a.Buchholz != b.Buchholz:
+			return a.Buchholz > b.Buchholz
+		case a.GameDiff != b.GameDiff:
+			return a.GameDiff > b.GameDiff
+		}
+		return seed[a.TeamID] < seed[b.TeamID]
+	})
+	return out
+}
@@ -0,0 +1,730 @@
+package tournament
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"projectvelocity/backend/internal/matchmaking"
+	"projectvelocity/backend/internal/shared/types"
+	"projectvelocity/backend/internal/simulation"
+)
+
+// Formats a tournament can be played in.
+const (
+	SingleElimination = "single_elimination"
+	DoubleElimination = "double_elimination"
+	Swiss             = "swiss"
+)
+
+// Tournament statuses.
+const (
+	StatusRegistration = "registration"
+	StatusRunning      = "running"
+	StatusFinished     = "finished"
+)
+
+// Series statuses. A pending series is waiting for earlier series to decide its teams;
+// a ready one is waiting for a game server.
+const (
+	SeriesPending = "pending"
+	SeriesReady   = "ready"
+	SeriesLive    = "live"
+	SeriesDone    = "done"
+)
+
+// Game statuses. A drawn game is replayed, so it never decides anything.
+const (
+	GameAllocating = "allocating"
+	GameLive       = "live"
+	GameDone       = "done"
+	GameDrawn      = "drawn"
+)
+
+// MaxBestOf caps series length.
+const MaxBestOf = 7
+
+var (
+	ErrNotFound          = errors.New("tournament: tournament not found")
+	ErrBadConfig         = errors.New("tournament: invalid configuration")
+	ErrNotOrganizer      = errors.New("tournament: only the organizer can do that")
+	ErrClosed            = errors.New("tournament: registration is closed")
+	ErrFull              = errors.New("tournament: tournament is full")
+	ErrTeamSize          = errors.New("tournament: team has the wrong number of players")
+	ErrAlreadyRegistered = errors.New("tournament: player is already registered")
+	ErrTooFewTeams       = errors.New("tournament: at least two teams are needed")
+	ErrNoMatch           = errors.New("tournament: no tournament match to play")
+)
+
+// Config describes a tournament. Matches are private, so they never touch ratings;
+// Playlist names the ratings used for seeding and is passed to the game server.
+type Config struct {
+	Name     string `json:"name"`
+	Format   string `json:"format"`
+	Playlist string `json:"playlist"`
+	TeamSize int    `json:"team_size"`
+	// BestOf is the number of games a series can run to; the first team to win a
+	// majority takes it.
+	BestOf   int `json:"best_of"`
+	MaxTeams int `json:"max_teams,omitempty"`
+	// SwissRounds defaults to enough rounds to leave one unbeaten team.
+	SwissRounds    int    `json:"swiss_rounds,omitempty"`
+	Region         string `json:"region"`
+	Physics        string `json:"physics,omitempty"`
+	MatchLengthSec int    `json:"match_length_sec,omitempty"`
+}
+
+// Team is one registered entry. Seed is assigned when the tournament starts, by
+// Rating, the mean MMR of the team's players.
+type Team struct {
+	TeamID    string   `json:"team_id"`
+	Name      string   `json:"name"`
+	CaptainID string   `json:"captain_id"`
+	Players   []string `json:"players"`
+	Rating    int      `json:"rating"`
+	Seed      int      `json:"seed,omitempty"`
+}
+
+// Game is one match of a series.
+type Game struct {
+	Number     int    `json:"number"`
+	MatchID    string `json:"match_id"`
+	Status     string `json:"status"`
+	ServerAddr string `json:"server_addr,omitempty"`
+	Winner     string `json:"winner,omitempty"` // team id
+	HomeScore  int    `json:"home_score"`
+	AwayScore  int    `json:"away_score"`
+}
+
+// Series is a best-of-N between two teams. Home plays orange. Teams left empty are
+// still to be decided, or byes once the series is done. WinnerTo and LoserTo name the
+// series the teams move on to, for drawing the bracket.
+type Series struct {
+	SeriesID string `json:"series_id"`
+	Bracket  string `json:"bracket"` // winners|losers|grand_final|swiss
+	Round    int    `json:"round"`
+	Home     string `json:"home,omitempty"`
+	Away     string `json:"away,omitempty"`
+	HomeWins int    `json:"home_wins"`
+	AwayWins int    `json:"away_wins"`
+	Winner   string `json:"winner,omitempty"`
+	Loser    string `json:"loser,omitempty"`
+	Status   string `json:"status"`
+	Games    []Game `json:"games"`
+	WinnerTo string `json:"winner_to,omitempty"`
+	LoserTo  string `json:"loser_to,omitempty"`
+
+	winnerSide, loserSide int
+	// feeders counts the earlier series that have still to send a team here.
+	feeders int
+	// assignment is the live game's allocation, handed to its players.
+	assignment *types.MatchAssignment
+}
+
+// Standing is a team's Swiss record. Buchholz, the sum of its opponents' wins, breaks
+// ties between teams on the same record.
+type Standing struct {
+	TeamID   string `json:"team_id"`
+	Wins     int    `json:"wins"`
+	Losses   int    `json:"losses"`
+	GameDiff int    `json:"game_diff"`
+	Buchholz int    `json:"buchholz"`
+}
+
+// Tournament is the full bracket state.
+type Tournament struct {
+	TournamentID string     `json:"tournament_id"`
+	OrganizerID  string     `json:"organizer_id"`
+	Config       Config     `json:"config"`
+	Status       string     `json:"status"`
+	Teams        []Team     `json:"teams"`
+	Series       []*Series  `json:"series"`
+	Standings    []Standing `json:"standings,omitempty"`
+	// Round is the Swiss round in play.
+	Round     int    `json:"round,omitempty"`
+	Champion  string `json:"champion,omitempty"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// Summary is a tournament's listing entry.
+type Summary struct {
+	TournamentID string `json:"tournament_id"`
+	Name         string `json:"name"`
+	Format       string `json:"format"`
+	Status       string `json:"status"`
+	Teams        int    `json:"teams"`
+	MaxTeams     int    `json:"max_teams,omitempty"`
+	Champion     string `json:"champion,omitempty"`
+}
+
+// Ratings supplies the MMR teams are seeded by.
+type Ratings interface {
+	MMR(playerID, playlist string) int
+}
+
+type matchRef struct {
+	tournamentID, seriesID string
+}
+
+// launch is a game waiting for its server.
+type launch struct {
+	ref   matchRef
+	alloc types.MatchAllocation
+}
+
+// Manager runs tournaments in memory and writes every change through to its store.
+// Games are allocated as private matches and their results come back through Record.
+type Manager struct {
+	mu          sync.Mutex
+	tournaments map[string]*Tournament
+	byMatch     map[string]matchRef
+	playlists   *matchmaking.PlaylistRegistry
+	ratings     Ratings
+	allocator   matchmaking.Allocator
+	seq         uint64
+	now         func() time.Time
+	store       Store
+	// storeErrors counts failed writes to store.
+	storeErrors uint64
+}
+
+// NewManager creates an empty tournament manager backed by a MemoryStore.
+func NewManager(playlists *matchmaking.PlaylistRegistry, ratings Ratings, allocator matchmaking.Allocator) *Manager {
+	return &Manager{
+		tournaments: make(map[string]*Tournament),
+		byMatch:     make(map[string]matchRef),
+		playlists:   playlists,
+		ratings:     ratings,
+		allocator:   allocator,
+		now:         func() time.Time { return time.Now().UTC() },
+		store:       NewMemoryStore(),
+	}
+}
+
+// SetStore replaces the manager's tournaments with those saved in s and makes s the
+// write-through store for later changes. A game still waiting for its server is put
+// back in line, since the allocation's outcome was never saved; Retry launches it again.
+func (m *Manager) SetStore(s Store) error {
+	records, err := s.Load()
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.store = s
+	clear(m.tournaments)
+	clear(m.byMatch)
+	for _, rec := range records {
+		t := clone(&rec.Tournament)
+		for i, s := range t.Series {
+			if i < len(rec.Series) {
+				l := rec.Series[i]
+				s.winnerSide, s.loserSide, s.feeders, s.assignment = l.WinnerSide, l.LoserSide, l.Feeders, l.Assignment
+			}
+			if s.Status != SeriesLive {
+				continue
+			}
+			for _, g := range slices.Clone(s.Games) {
+				switch g.Status {
+				case GameAllocating:
+					s.dropGame(g.MatchID)
+				case GameLive:
+					m.byMatch[g.MatchID] = matchRef{t.TournamentID, s.SeriesID}
+				}
+			}
+		}
+		m.tournaments[t.TournamentID] = &t
+		m.seq = max(m.seq, rec.Seq)
+	}
+	return nil
+}
+
+// StoreErrors returns how many writes to the store have failed.
+func (m *Manager) StoreErrors() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.storeErrors
+}
+
+// Create opens a tournament for registration.
+func (m *Manager) Create(organizerID string, cfg Config) (Tournament, error) {
+	cfg, err := m.validate(cfg)
+	if err != nil {
+		return Tournament{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	m.seq++
+	t := &Tournament{
+		TournamentID: fmt.Sprintf("tourn_%d_%d", now.UnixNano(), m.seq),
+		OrganizerID:  organizerID,
+		Config:       cfg,
+		Status:       StatusRegistration,
+		Teams:        []Team{},
+		Series:       []*Series{},
+		CreatedAt:    now.Unix(),
+	}
+	m.tournaments[t.TournamentID] = t
+	m.saveLocked(t)
+	return clone(t), nil
+}
+
+// Register enters a team captained by captainID, who must be one of its players.
+func (m *Manager) Register(tournamentID, captainID, name string, players []string) (Tournament, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tournaments[tournamentID]
+	if !ok {
+		return Tournament{}, ErrNotFound
+	}
+	if t.Status != StatusRegistration {
+		return Tournament{}, ErrClosed
+	}
+	if t.Config.MaxTeams > 0 && len(t.Teams) >= t.Config.MaxTeams {
+		return Tournament{}, ErrFull
+	}
+	players = slices.Compact(slices.Sorted(slices.Values(players)))
+	if len(players) != t.Config.TeamSize || !slices.Contains(players, captainID) {
+		return Tournament{}, ErrTeamSize
+	}
+	for _, team := range t.Teams {
+		for _, id := range players {
+			if slices.Contains(team.Players, id) {
+				return Tournament{}, fmt.Errorf("%w: %s", ErrAlreadyRegistered, id)
+			}
+		}
+	}
+	if name == "" {
+		name = fmt.Sprintf("Team %d", len(t.Teams)+1)
+	}
+	m.seq++
+	t.Teams = append(t.Teams, Team{
+		TeamID:    fmt.Sprintf("team_%d", m.seq),
+		Name:      name,
+		CaptainID: captainID,
+		Players:   players,
+	})
+	m.saveLocked(t)
+	return clone(t), nil
+}
+
+// Withdraw removes the team captainID captains while registration is open.
+func (m *Manager) Withdraw(tournamentID, captainID string) (Tournament, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tournaments[tournamentID]
+	if !ok {
+		return Tournament{}, ErrNotFound
+	}
+	if t.Status != StatusRegistration {
+		return Tournament{}, ErrClosed
+	}
+	t.Teams = slices.DeleteFunc(t.Teams, func(team Team) bool { return team.CaptainID == captainID })
+	m.saveLocked(t)
+	return clone(t), nil
+}
+
+// Start closes registration, seeds the teams by rating, draws the bracket and
+// launches every series that can be played.
+func (m *Manager) Start(tournamentID, organizerID string) (Tournament, error) {
+	m.mu.Lock()
+	t, ok := m.tournaments[tournamentID]
+	switch {
+	case !ok:
+		m.mu.Unlock()
+		return Tournament{}, ErrNotFound
+	case t.OrganizerID != organizerID:
+		m.mu.Unlock()
+		return Tournament{}, ErrNotOrganizer
+	case t.Status != StatusRegistration:
+		m.mu.Unlock()
+		return Tournament{}, ErrClosed
+	case len(t.Teams) < 2:
+		m.mu.Unlock()
+		return Tournament{}, ErrTooFewTeams
+	}
+	m.seed(t)
+	t.Status = StatusRunning
+	switch t.Config.Format {
+	case Swiss:
+		t.startSwissRound()
+	case DoubleElimination:
+		t.drawElimination(true)
+	default:
+		t.drawElimination(false)
+	}
+	m.saveLocked(t)
+	launches := m.pendingLocked()
+	m.mu.Unlock()
+
+	m.launch(launches)
+	return m.Get(tournamentID)
+}
+
+// Record applies a game result. Draws are replayed; a decided game counts toward its
+// series, and a decided series moves its teams on through the bracket. It reports
+// whether the match belonged to a tournament.
+func (m *Manager) Record(r types.MatchResult) bool {
+	m.mu.Lock()
+	ref, ok := m.byMatch[r.MatchID]
+	if !ok {
+		m.mu.Unlock()
+		return false
+	}
+	delete(m.byMatch, r.MatchID)
+	t := m.tournaments[ref.tournamentID]
+	s := t.series(ref.seriesID)
+	if s != nil && s.Status == SeriesLive {
+		if g := s.game(r.MatchID); g != nil && g.Status != GameDone && g.Status != GameDrawn {
+			g.HomeScore, g.AwayScore = r.OrangeScore, r.BlueScore
+			switch r.Winner {
+			case "orange":
+				g.Status, g.Winner = GameDone, s.Home
+				s.HomeWins++
+			case "blue":
+				g.Status, g.Winner = GameDone, s.Away
+				s.AwayWins++
+			default:
+				g.Status = GameDrawn
+			}
+			s.assignment = nil
+			s.Status = SeriesReady
+			if need := t.Config.BestOf/2 + 1; s.HomeWins >= need || s.AwayWins >= need {
+				winner, loser := s.Home, s.Away
+				if s.AwayWins > s.HomeWins {
+					winner, loser = s.Away, s.Home
+				}
+				t.finish(s, winner, loser)
+			}
+		}
+	}
+	m.saveLocked(t)
+	launches := m.pendingLocked()
+	m.mu.Unlock()
+
+	m.launch(launches)
+	return true
+}
+
+// Rollback puts a game whose server never came up back in line for another server.
+// It reports whether the match belonged to a tournament.
+func (m *Manager) Rollback(matchID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ref, ok := m.byMatch[matchID]
+	if !ok {
+		return false
+	}
+	delete(m.byMatch, matchID)
+	t := m.tournaments[ref.tournamentID]
+	if s := t.series(ref.seriesID); s != nil && s.Status == SeriesLive {
+		s.dropGame(matchID)
+	}
+	m.saveLocked(t)
+	return true
+}
+
+// Retry allocates servers for series still waiting on one.
+func (m *Manager) Retry() {
+	m.mu.Lock()
+	launches := m.pendingLocked()
+	m.mu.Unlock()
+	m.launch(launches)
+}
+
+// Get returns a tournament's bracket state.
+func (m *Manager) Get(tournamentID string) (Tournament, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tournaments[tournamentID]
+	if !ok {
+		return Tournament{}, ErrNotFound
+	}
+	return clone(t), nil
+}
+
+// List summarises every tournament, newest first.
+func (m *Manager) List() []Summary {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]Summary, 0, len(m.tournaments))
+	for _, t := range m.tournaments {
+		out = append(out, Summary{
+			TournamentID: t.TournamentID,
+			Name:         t.Config.Name,
+			Format:       t.Config.Format,
+			Status:       t.Status,
+			Teams:        len(t.Teams),
+			MaxTeams:     t.Config.MaxTeams,
+			Champion:     t.Champion,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].TournamentID > out[j].TournamentID })
+	return out
+}
+
+// Assignment returns the live tournament game playerID should join.
+func (m *Manager) Assignment(playerID string) (types.MatchAssignment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range m.tournaments {
+		if t.Status != StatusRunning {
+			continue
+		}
+		for _, s := range t.Series {
+			if s.assignment != nil && slices.Contains(s.assignment.Players, playerID) {
+				return *s.assignment, nil
+			}
+		}
+	}
+	return types.MatchAssignment{}, ErrNoMatch
+}
+
+func (m *Manager) validate(cfg Config) (Config, error) {
+	switch cfg.Format {
+	case "":
+		cfg.Format = SingleElimination
+	case SingleElimination, DoubleElimination, Swiss:
+	default:
+		return cfg, fmt.Errorf("%w: unknown format %q", ErrBadConfig, cfg.Format)
+	}
+	if cfg.Name == "" {
+		return cfg, fmt.Errorf("%w: name required", ErrBadConfig)
+	}
+	if cfg.Playlist == "" {
+		cfg.Playlist = "ranked-1v1"
+	}
+	playlist, ok := m.playlists.Get(cfg.Playlist)
+	if !ok {
+		return cfg, fmt.Errorf("%w: unknown playlist %q", ErrBadConfig, cfg.Playlist)
+	}
+	if cfg.TeamSize == 0 {
+		cfg.TeamSize = playlist.TeamSize
+	}
+	if cfg.TeamSize < 1 || cfg.TeamSize > 4 {
+		return cfg, fmt.Errorf("%w: team size must be 1 to 4", ErrBadConfig)
+	}
+	if cfg.BestOf == 0 {
+		cfg.BestOf = 1
+	}
+	if cfg.BestOf < 1 || cfg.BestOf > MaxBestOf || cfg.BestOf%2 == 0 {
+		return cfg, fmt.Errorf("%w: best of must be odd and at most %d", ErrBadConfig, MaxBestOf)
+	}
+	if cfg.MaxTeams < 0 || cfg.SwissRounds < 0 || cfg.MatchLengthSec < 0 {
+		return cfg, fmt.Errorf("%w: negative limit", ErrBadConfig)
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east"
+	}
+	if _, ok := simulation.PhysicsPreset(cfg.Physics); !ok {
+		return cfg, fmt.Errorf("%w: unknown physics preset %q", ErrBadConfig, cfg.Physics)
+	}
+	return cfg, nil
+}
+
+// seed rates every team by its players' mean MMR and orders the teams best first;
+// teams on the same rating keep their registration order.
+func (m *Manager) seed(t *Tournament) {
+	for i := range t.Teams {
+		sum := 0
+		for _, id := range t.Teams[i].Players {
+			sum += m.ratings.MMR(id, t.Config.Playlist)
+		}
+		t.Teams[i].Rating = sum / len(t.Teams[i].Players)
+	}
+	sort.SliceStable(t.Teams, func(i, j int) bool { return t.Teams[i].Rating > t.Teams[j].Rating })
+	for i := range t.Teams {
+		t.Teams[i].Seed = i + 1
+	}
+}
+
+// pendingLocked opens a game on every ready series and returns the allocations to make.
+func (m *Manager) pendingLocked() []launch {
+	var out []launch
+	for _, t := range m.tournaments {
+		if t.Status != StatusRunning {
+			continue
+		}
+		opened := len(out)
+		for _, s := range t.Series {
+			if s.Status != SeriesReady {
+				continue
+			}
+			m.seq++
+			matchID := fmt.Sprintf("match_%s_%s_%d", t.TournamentID, s.SeriesID, m.seq)
+			s.Games = append(s.Games, Game{Number: len(s.Games) + 1, MatchID: matchID, Status: GameAllocating})
+			s.Status = SeriesLive
+			ref := matchRef{t.TournamentID, s.SeriesID}
+			m.byMatch[matchID] = ref
+			out = append(out, launch{ref: ref, alloc: t.allocation(s, matchID)})
+		}
+		if len(out) > opened {
+			m.saveLocked(t)
+		}
+	}
+	return out
+}
+
+// launch allocates servers outside the lock. A series whose allocation fails goes back
+// to ready for Retry.
+func (m *Manager) launch(launches []launch) {
+	for _, l := range launches {
+		addr, err := m.allocator.Allocate(l.alloc)
+
+		m.mu.Lock()
+		t := m.tournaments[l.ref.tournamentID]
+		s := t.series(l.ref.seriesID)
+		g := s.game(l.alloc.MatchID)
+		switch {
+		case g == nil || g.Status != GameAllocating:
+		case err != nil:
+			delete(m.byMatch, l.alloc.MatchID)
+			s.dropGame(l.alloc.MatchID)
+		default:
+			g.Status, g.ServerAddr = GameLive, addr
+			a := l.alloc
+			s.assignment = &types.MatchAssignment{
+				MatchID:        a.MatchID,
+				Region:         a.Region,
+				Playlist:       a.Playlist,
+				Players:        a.Players,
+				Teams:          a.Teams,
+				Private:        true,
+				Physics:        a.Physics,
+				MatchLengthSec: a.MatchLengthSec,
+				ServerAddr:     addr,
+				FoundAtUnix:    m.now().Unix(),
+			}
+		}
+		m.saveLocked(t)
+		m.mu.Unlock()
+	}
+}
+
+// saveLocked writes t through to the store. Callers hold m.mu.
+func (m *Manager) saveLocked(t *Tournament) {
+	rec := Record{Tournament: clone(t), Series: make([]SeriesLinks, len(t.Series)), Seq: m.seq}
+	for i, s := range t.Series {
+		rec.Series[i] = SeriesLinks{WinnerSide: s.winnerSide, LoserSide: s.loserSide, Feeders: s.feeders, Assignment: s.assignment}
+	}
+	if err := m.store.Put(rec); err != nil {
+		m.storeErrors++
+	}
+}
+
+// allocation asks for a private match between the series' teams, home on orange.
+func (t *Tournament) allocation(s *Series, matchID string) types.MatchAllocation {
+	home, away := t.team(s.Home), t.team(s.Away)
+	return types.MatchAllocation{
+		MatchID:        matchID,
+		Region:         t.Config.Region,
+		Playlist:       t.Config.Playlist,
+		TeamSize:       t.Config.TeamSize,
+		Players:        append(slices.Clone(home.Players), away.Players...),
+		Teams:          map[string][]string{"orange": slices.Clone(home.Players), "blue": slices.Clone(away.Players)},
+		Private:        true,
+		Physics:        t.Config.Physics,
+		MatchLengthSec: t.Config.MatchLengthSec,
+	}
+}
+
+// finish decides s and moves its teams on.
+func (t *Tournament) finish(s *Series, winner, loser string) {
+	s.Winner, s.Loser, s.Status = winner, loser, SeriesDone
+	s.assignment = nil
+	switch {
+	case s.Bracket == "swiss":
+		t.swissSeriesDone()
+	case s.WinnerTo != "":
+		t.feed(s.WinnerTo, s.winnerSide, winner)
+		if s.LoserTo != "" {
+			t.feed(s.LoserTo, s.loserSide, loser)
+		}
+	case s.Bracket == "grand_final" && s.Round == 1 && winner == s.Away && loser != "":
+		// The losers' bracket champion handed the unbeaten team its first loss, so the
+		// final is played again.
+		t.Series = append(t.Series, &Series{SeriesID: "GF2", Bracket: "grand_final", Round: 2, Home: s.Home, Away: s.Away, Status: SeriesReady, Games: []Game{}})
+	default:
+		t.Status, t.Champion = StatusFinished, winner
+	}
+}
+
+// feed sends team into a slot of series id. Once both slots are known the series is
+// ready, or a walkover if one side is a bye.
+func (t *Tournament) feed(id string, side int, team string) {
+	s := t.series(id)
+	if side == 0 {
+		s.Home = team
+	} else {
+		s.Away = team
+	}
+	s.feeders--
+	if s.feeders == 0 {
+		t.settle(s)
+	}
+}
+
+// settle readies a series whose teams are known, or passes a lone team straight
+// through.
+func (t *Tournament) settle(s *Series) {
+	if s.Home != "" && s.Away != "" {
+		s.Status = SeriesReady
+		return
+	}
+	t.finish(s, s.Home+s.Away, "")
+}
+
+func (t *Tournament) series(id string) *Series {
+	for _, s := range t.Series {
+		if s.SeriesID == id {
+			return s
+		}
+	}
+	return nil
+}
+
+func (t *Tournament) team(id string) Team {
+	for _, team := range t.Teams {
+		if team.TeamID == id {
+			return team
+		}
+	}
+	return Team{}
+}
+
+func (s *Series) game(matchID string) *Game {
+	for i := range s.Games {
+		if s.Games[i].MatchID == matchID {
+			return &s.Games[i]
+		}
+	}
+	return nil
+}
+
+// dropGame forgets a game that never got a server and readies the series again.
+func (s *Series) dropGame(matchID string) {
+	s.Games = slices.DeleteFunc(s.Games, func(g Game) bool { return g.MatchID == matchID })
+	s.assignment = nil
+	s.Status = SeriesReady
+}
+
+func clone(t *Tournament) Tournament {
+	out := *t
+	out.Teams = slices.Clone(t.Teams)
+	for i := range out.Teams {
+		out.Teams[i].Players = slices.Clone(t.Teams[i].Players)
+	}
+	out.Series = make([]*Series, len(t.Series))
+	for i, s := range t.Series {
+		c := *s
+		c.Games = slices.Clone(s.Games)
+		c.assignment = nil
+		out.Series[i] = &c
+	}
+	out.Standings = slices.Clone(t.Standings)
+	return out
+}
@@ -0,0 +1,273 @@
+package tournament
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"projectvelocity/backend/internal/matchmaking"
+	"projectvelocity/backend/internal/shared/types"
+)
+
+type fakeAllocator struct {
+	err error
+}
+
+func (a *fakeAllocator) Allocate(types.MatchAllocation) (string, error) {
+	return "ws://game:9003/ws", a.err
+}
+
+type fakeRatings map[string]int
+
+func (r fakeRatings) MMR(playerID, _ string) int { return r[playerID] }
+
+// newTournament registers one solo team per rating, in the order given.
+func newTournament(t *testing.T, format string, bestOf int, mmrs ...int) (*Manager, *fakeAllocator, string) {
+	t.Helper()
+	ratings := fakeRatings{}
+	alloc := &fakeAllocator{}
+	m := NewManager(matchmaking.DefaultPlaylists(), ratings, alloc)
+	tour, err := m.Create("org", Config{Name: "cup", Format: format, BestOf: bestOf})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, mmr := range mmrs {
+		id := fmt.Sprintf("p%d", i)
+		ratings[id] = mmr
+		if _, err := m.Register(tour.TournamentID, id, id, []string{id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return m, alloc, tour.TournamentID
+}
+
+// playRound reports a result for every live game, the winner picked by pick.
+func playRound(t *testing.T, m *Manager, id string, pick func(s *Series) string) int {
+	t.Helper()
+	tour, _ := m.Get(id)
+	played := 0
+	for _, s := range tour.Series {
+		if s.Status != SeriesLive {
+			continue
+		}
+		g := s.Games[len(s.Games)-1]
+		r := types.MatchResult{MatchID: g.MatchID, Private: true, Winner: pick(s)}
+		if !m.Record(r) {
+			t.Fatalf("expected match %s to belong to the tournament", g.MatchID)
+		}
+		played++
+	}
+	return played
+}
+
+func homeWins(*Series) string { return "orange" }
+
+func seedOf(tour Tournament, teamID string) int {
+	i := slices.IndexFunc(tour.Teams, func(team Team) bool { return team.TeamID == teamID })
+	return tour.Teams[i].Seed
+}
+
+func TestSingleEliminationSeedsByRatingAndGivesByesToTopSeeds(t *testing.T) {
+	m, _, id := newTournament(t, SingleElimination, 1, 1000, 1400, 900, 1200, 1100)
+	tour, err := m.Start(id, "org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tour.Teams[0].Rating != 1400 || tour.Teams[0].Seed != 1 {
+		t.Fatalf("expected the best-rated team seeded first, got=%+v", tour.Teams[0])
+	}
+	live := 0
+	for _, s := range tour.Series {
+		if s.Round == 1 && s.Status == SeriesLive {
+			live++
+			if seeds := []int{seedOf(tour, s.Home), seedOf(tour, s.Away)}; !slices.Equal(seeds, []int{4, 5}) {
+				t.Fatalf("expected only seeds 4 and 5 to play in round one, got=%v", seeds)
+			}
+		}
+	}
+	if live != 1 {
+		t.Fatalf("expected three byes and one first-round series, live=%d", live)
+	}
+
+	for playRound(t, m, id, homeWins) > 0 {
+	}
+	tour, _ = m.Get(id)
+	if tour.Status != StatusFinished || seedOf(tour, tour.Champion) != 1 {
+		t.Fatalf("expected the top seed to win when favourites always win, got=%s champion=%s", tour.Status, tour.Champion)
+	}
+}
+
+func TestBestOfThreeReplaysDrawsAndNeedsTwoWins(t *testing.T) {
+	m, _, id := newTournament(t, SingleElimination, 3, 1000, 900)
+	if _, err := m.Start(id, "org"); err != nil {
+		t.Fatal(err)
+	}
+	for _, winner := range []string{"draw", "blue", "orange"} {
+		playRound(t, m, id, func(*Series) string { return winner })
+	}
+	tour, _ := m.Get(id)
+	s := tour.Series[0]
+	if s.HomeWins != 1 || s.AwayWins != 1 || len(s.Games) != 4 || s.Status != SeriesLive {
+		t.Fatalf("expected a drawn game to be replayed and the series to go on at 1-1, got=%+v", s)
+	}
+	playRound(t, m, id, func(*Series) string { return "blue" })
+	tour, _ = m.Get(id)
+	if tour.Champion != tour.Series[0].Away || tour.Series[0].AwayWins != 2 {
+		t.Fatalf("expected the away team to take the series 2-1, got=%+v", tour.Series[0])
+	}
+}
+
+func TestDoubleEliminationResetsTheGrandFinal(t *testing.T) {
+	m, _, id := newTournament(t, DoubleElimination, 1, 1400, 1300, 1200, 1100)
+	tour, _ := m.Start(id, "org")
+	top := tour.Teams[0].TeamID
+
+	// The top seed loses once, in the winners' final, then fights back through the
+	// losers' bracket and wins both grand finals.
+	for playRound(t, m, id, func(s *Series) string {
+		switch {
+		case s.Bracket == "winners" && s.Round == 2:
+			if s.Home == top {
+				return "blue"
+			}
+			return "orange"
+		case s.Home == top:
+			return "orange"
+		case s.Away == top:
+			return "blue"
+		}
+		return "orange"
+	}) > 0 {
+	}
+	tour, _ = m.Get(id)
+	if tour.Status != StatusFinished || tour.Champion != top {
+		t.Fatalf("expected the top seed to win from the losers' bracket, got=%s champion=%s", tour.Status, tour.Champion)
+	}
+	if reset := tour.Series[len(tour.Series)-1]; reset.SeriesID != "GF2" || reset.Winner != top {
+		t.Fatalf("expected a grand final reset, got=%+v", reset)
+	}
+	losses := map[string]int{}
+	for _, s := range tour.Series {
+		losses[s.Loser]++
+	}
+	if runnerUp := tour.Series[len(tour.Series)-1].Home; losses[top] != 1 || losses[runnerUp] != 2 {
+		t.Fatalf("expected the champion to drop one series and the runner-up two, got=%v", losses)
+	}
+}
+
+func TestSwissPairsByRecordWithoutRematches(t *testing.T) {
+	m, _, id := newTournament(t, Swiss, 1, 1400, 1300, 1200, 1100, 1000)
+	tour, _ := m.Start(id, "org")
+	if got := len(tour.Series); got != 3 || tour.Series[0].Away != "" || seedOf(tour, tour.Series[0].Home) != 5 {
+		t.Fatalf("expected the bottom seed to take the first-round bye, got=%+v", tour.Series[0])
+	}
+
+	for round := 1; round <= 3; round++ {
+		if playRound(t, m, id, homeWins) == 0 {
+			t.Fatalf("expected games in round %d", round)
+		}
+	}
+	tour, _ = m.Get(id)
+	if tour.Status != StatusFinished || tour.Round != 3 {
+		t.Fatalf("expected three Swiss rounds for five teams, got=%s round=%d", tour.Status, tour.Round)
+	}
+	met := map[[2]string]bool{}
+	for _, s := range tour.Series {
+		if s.Away == "" {
+			continue
+		}
+		if met[[2]string{s.Home, s.Away}] || met[[2]string{s.Away, s.Home}] {
+			t.Fatalf("expected no rematches, %s and %s met twice", s.Home, s.Away)
+		}
+		met[[2]string{s.Home, s.Away}] = true
+	}
+	if tour.Standings[0].TeamID != tour.Champion || tour.Standings[0].Wins != 3 {
+		t.Fatalf("expected an unbeaten champion on top of the standings, got=%+v", tour.Standings[0])
+	}
+}
+
+func TestRegistrationAndAllocationRetry(t *testing.T) {
+	m, alloc, id := newTournament(t, SingleElimination, 1, 1000)
+	if _, err := m.Register(id, "p0", "again", []string{"p0"}); !errors.Is(err, ErrAlreadyRegistered) {
+		t.Fatalf("expected a player to be entered once, got=%v", err)
+	}
+	if _, err := m.Register(id, "x", "wrong", []string{"y"}); !errors.Is(err, ErrTeamSize) {
+		t.Fatalf("expected the captain to be on the team, got=%v", err)
+	}
+	if _, err := m.Start(id, "org"); !errors.Is(err, ErrTooFewTeams) {
+		t.Fatalf("expected one team to be too few, got=%v", err)
+	}
+	m.Register(id, "p1", "", []string{"p1"})
+	if _, err := m.Start(id, "p1"); !errors.Is(err, ErrNotOrganizer) {
+		t.Fatalf("expected only the organizer to start, got=%v", err)
+	}
+
+	alloc.err = errors.New("fleet full")
+	tour, _ := m.Start(id, "org")
+	if s := tour.Series[0]; s.Status != SeriesReady || len(s.Games) != 0 {
+		t.Fatalf("expected the series to wait for a server, got=%+v", s)
+	}
+	alloc.err = nil
+	m.Retry()
+	a, err := m.Assignment("p1")
+	if err != nil || !a.Private || !slices.Contains(a.Teams["blue"], "p1") {
+		t.Fatalf("expected p1 to get a private match once a server frees up, got=%+v err=%v", a, err)
+	}
+	if !m.Rollback(a.MatchID) {
+		t.Fatal("expected the rolled back match to belong to the tournament")
+	}
+	if _, err := m.Assignment("p1"); !errors.Is(err, ErrNoMatch) {
+		t.Fatalf("expected the rolled back game to be withdrawn, got=%v", err)
+	}
+}
+
+func TestAnotherManagerResumesTheBracketFromTheStore(t *testing.T) {
+	store := NewMemoryStore()
+	ratings := fakeRatings{"p0": 1400, "p1": 1300, "p2": 1200, "p3": 1100}
+	m := NewManager(matchmaking.DefaultPlaylists(), ratings, &fakeAllocator{})
+	if err := m.SetStore(store); err != nil {
+		t.Fatal(err)
+	}
+	tour, _ := m.Create("org", Config{Name: "cup", Format: DoubleElimination})
+	id := tour.TournamentID
+	for _, p := range []string{"p0", "p1", "p2", "p3"} {
+		if _, err := m.Register(id, p, p, []string{p}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.Start(id, "org")
+	if played := playRound(t, m, id, homeWins); played != 2 {
+		t.Fatalf("expected the first round to be played, got=%d", played)
+	}
+
+	// The manager dies; another loads what it saved, as the shared store would hand it
+	// over: through JSON.
+	saved, _ := store.Load()
+	moved := NewMemoryStore()
+	for _, rec := range saved {
+		buf, _ := json.Marshal(rec)
+		var back Record
+		if err := json.Unmarshal(buf, &back); err != nil {
+			t.Fatal(err)
+		}
+		moved.Put(back)
+	}
+	next := NewManager(matchmaking.DefaultPlaylists(), ratings, &fakeAllocator{})
+	if err := next.SetStore(moved); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := next.Assignment("p0"); err != nil {
+		t.Fatalf("expected the live winners' final to be handed out again, got=%v", err)
+	}
+	for playRound(t, next, id, homeWins) > 0 {
+	}
+	tour, _ = next.Get(id)
+	if tour.Status != StatusFinished || tour.Champion != tour.Teams[0].TeamID {
+		t.Fatalf("expected the restored bracket to play out, got=%s champion=%s", tour.Status, tour.Champion)
+	}
+	if next.StoreErrors() != 0 {
+		t.Fatalf("expected no store errors, got=%d", next.StoreErrors())
+	}
+}
@@ -3,6 +3,9 @@ import * as THREE from "https://cdn.jsdelivr.net/npm/three@0.167.1/build/three.m
 const SIM_SCALE = 0.01;
 const HUD_EVENT_TIMEOUT_MS = 1200;
 const INPUT_SEND_HZ = 60;
+const BASELINE_HISTORY = 64;
+const RECONNECT_ATTEMPTS = 6;
+const CLOSE_SUPERSEDED = 4002;
 const OFFLINE_TICK_HZ = 120;
 const ARENA_LENGTH_UU = 8192;
 const ARENA_WIDTH_UU = 10240;
@@ -15,16 +18,13 @@ const MAX_CAR_SPEED = 2300;
 const MAX_DRIVE_SPEED = 1410;
 const THROTTLE_ACCEL = 1600;
 const BRAKE_ACCEL = 3500;
-const BOOST_ACCEL = 991.666;
 const TURN_RATE = 3.4;
-const GRAVITY = -650;
 const JUMP_VELOCITY = 292;
 const JUMP_HOLD_ACCEL = 1460;
 const JUMP_HOLD_MAX = 0.2;
 const STICKY_FORCE = 325;
 const STICKY_TIME = 3 / 120;
 const DOUBLE_JUMP_MAX = 1.25;
-const BALL_RESTITUTION = 0.6;
 const WALL_RESTITUTION = 0.78;
 const CAR_BALL_ELASTICITY = 0.94;
 const GROUND_FRICTION = 0.9965;
@@ -35,7 +35,14 @@ const HANDBRAKE_TURN_BOOST = 1.35;
 const AIR_RESISTANCE = 0.9992;
 const AIR_THROTTLE_ACCEL = 66.667;
 const AIR_REVERSE_ACCEL = 33.334;
-const BALL_MAX_SPEED = 6000;
+// Constants a physics preset can change; the server sends the match's values with
+// the welcome so prediction agrees with the simulation.
+const physics = {
+  gravity: -650,
+  boostAccel: 991.666,
+  ballRestitution: 0.6,
+  ballMaxSpeed: 6000,
+};
 
 const canvas = document.getElementById("game-canvas");
 const menu = document.getElementById("menu");
@@ -61,12 +68,19 @@ const state = {
   displayName: "Pilot",
   ticketID: "",
   ws: null,
+  serverAddr: "",
+  reconnectToken: "",
+  forfeitVote: null,
   matchID: "",
   localCarID: "",
   pingSentAt: 0,
   pingMS: null,
+  clockOffsetMS: null,
+  snapshotAgeMS: 0,
   seq: 1,
   connected: false,
+  baselines: new Map(),
+  ackTick: 0,
   cars: new Map(),
   localCarState: null,
   ballVisual: null,
@@ -414,6 +428,9 @@ function bindInputHandlers() {
     if (isControlKey(e.code)) {
       e.preventDefault();
     }
+    if ((e.code === "KeyY" || e.code === "KeyN") && !e.repeat) {
+      sendForfeitVote(e.code === "KeyY");
+    }
   });
 
   window.addEventListener("keyup", (e) => {
@@ -461,6 +478,7 @@ function startNetworkLoops() {
     const envelope = {
       type: "input",
       input,
+      ack_tick: state.ackTick,
     };
     state.ws.send(JSON.stringify(envelope));
   }, Math.round(1000 / INPUT_SEND_HZ));
@@ -470,7 +488,7 @@ function startNetworkLoops() {
       return;
     }
     state.pingSentAt = performance.now();
-    state.ws.send(JSON.stringify({ type: "ping" }));
+    state.ws.send(JSON.stringify({ type: "ping", sync: { client_send_ms: Date.now() } }));
   }, 2000);
 }
 
@@ -502,6 +520,22 @@ function buildInputPayload() {
   return payload;
 }
 
+// deviceID is this browser's stable id. Signing in with it brings back the same guest
+// account, and with it the player's ratings and history.
+function deviceID() {
+  const key = "velocity.device_id";
+  try {
+    let id = localStorage.getItem(key);
+    if (!id) {
+      id = crypto.randomUUID();
+      localStorage.setItem(key, id);
+    }
+    return id;
+  } catch {
+    return undefined;
+  }
+}
+
 async function startOnlineMatchFlow() {
   if (startOnlineBtn.disabled || startOfflineBtn.disabled) {
     return;
@@ -519,7 +553,7 @@ async function startOnlineMatchFlow() {
     setStatus("Authenticating...");
     const auth = await requestJSON(`${state.gatewayURL}/v1/auth/guest`, {
       method: "POST",
-      body: JSON.stringify({ display_name: state.displayName }),
+      body: JSON.stringify({ display_name: state.displayName, device_id: deviceID() }),
     });
 
     state.token = auth.token;
@@ -530,7 +564,7 @@ async function startOnlineMatchFlow() {
     const join = await requestJSON(`${state.gatewayURL}/v1/matchmaking/join`, {
       method: "POST",
       headers: authHeaders(),
-      body: JSON.stringify({ region: "us-east", playlist: "ranked-1v1", mmr: 1200 }),
+      body: JSON.stringify({ region: "us-east", playlist: "casual-1v1" }),
     });
     state.ticketID = join.ticket_id;
 
@@ -545,7 +579,7 @@ async function startOnlineMatchFlow() {
     window.focus();
     canvas.focus();
 
-    await connectWebSocket(assignment.server_addr);
+    await connectWebSocket(assignment.server_addr, assignment.join_ticket);
   } catch (err) {
     console.error(err);
     setStatus(`Online unavailable (${err.message || "failed"}). Starting offline...`);
@@ -578,32 +612,139 @@ function clearLiveState() {
     scene.remove(visual);
   }
   state.cars.clear();
+  state.baselines.clear();
+  state.ackTick = 0;
+  state.clockOffsetMS = null;
+  state.snapshotAgeMS = 0;
   state.localCarState = null;
   state.lastEventSig = "";
   eventEl.textContent = "";
   playersEl.textContent = "0";
 }
 
+const MATCHMAKING_TIMEOUT_MS = 60000;
+const QUEUE_STREAM_EVENTS = ["searching", "wait_update", "accept_required", "matched", "cancelled", "not_found"];
+
 async function pollForAssignment(ticketID) {
-  for (let i = 0; i < 60; i += 1) {
-    const res = await requestJSON(`${state.gatewayURL}/v1/matchmaking/poll?ticket_id=${encodeURIComponent(ticketID)}`, {
+  // Prefer the push stream; long-poll when EventSource is missing or the stream drops.
+  if (typeof EventSource === "function") {
+    try {
+      return await streamAssignment(ticketID);
+    } catch (err) {
+      if (err.fatal) {
+        throw err;
+      }
+      console.warn("matchmaking stream unavailable, falling back to long-poll", err);
+    }
+  }
+  return longPollAssignment(ticketID);
+}
+
+async function streamAssignment(ticketID) {
+  // EventSource cannot send headers, so trade the session token for a short-lived one
+  // that only opens this ticket's stream and is safe to put in the URL.
+  const { stream_token: streamToken } = await requestJSON(`${state.gatewayURL}/v1/matchmaking/stream-token`, {
+    method: "POST",
+    headers: authHeaders(),
+    body: JSON.stringify({ ticket_id: ticketID }),
+  });
+  return new Promise((resolve, reject) => {
+    const params = new URLSearchParams({ ticket_id: ticketID, stream_token: streamToken });
+    const source = new EventSource(`${state.gatewayURL}/v1/matchmaking/stream?${params}`);
+    let done = false;
+    const finish = (assignment, err) => {
+      if (done) {
+        return;
+      }
+      done = true;
+      clearTimeout(timer);
+      source.close();
+      if (err) {
+        reject(err);
+      } else {
+        resolve(assignment);
+      }
+    };
+    const timer = setTimeout(() => finish(null, fatalError("matchmaking timeout")), MATCHMAKING_TIMEOUT_MS);
+
+    const onUpdate = (ev) => {
+      applyQueueUpdate(ticketID, JSON.parse(ev.data)).then(
+        (assignment) => assignment && finish(assignment),
+        (err) => finish(null, err),
+      );
+    };
+    for (const name of QUEUE_STREAM_EVENTS) {
+      source.addEventListener(name, onUpdate);
+    }
+    source.addEventListener("error", (ev) => {
+      // Events the gateway sends carry data; a bare error means the connection dropped.
+      if (ev.data) {
+        finish(null, fatalError(JSON.parse(ev.data).error || "matchmaking failed"));
+        return;
+      }
+      finish(null, new Error("matchmaking stream closed"));
+    });
+  });
+}
+
+async function longPollAssignment(ticketID) {
+  const deadline = Date.now() + MATCHMAKING_TIMEOUT_MS;
+  let since = 0;
+  while (Date.now() < deadline) {
+    const params = new URLSearchParams({ ticket_id: ticketID, since: String(since), wait_ms: "5000" });
+    const res = await requestJSON(`${state.gatewayURL}/v1/matchmaking/poll?${params}`, {
       method: "GET",
       headers: authHeaders(),
     });
-
-    if (res.status === "matched" && res.assignment) {
-      return res.assignment;
+    const assignment = await applyQueueUpdate(ticketID, res);
+    if (assignment) {
+      return assignment;
     }
-    setStatus(`Queueing... ${Math.min(i + 1, 60)}s`);
-    await sleep(1000);
+    since = res.version || 0;
   }
   throw new Error("matchmaking timeout");
 }
 
-function connectWebSocket(rawServerAddr) {
+// applyQueueUpdate reacts to one ticket status and returns the assignment once matched.
+async function applyQueueUpdate(ticketID, res) {
+  if (res.status === "matched" && res.assignment) {
+    return res.assignment;
+  }
+  if (res.status === "accept_required" && res.ready_check) {
+    // Pressing play is the player's consent, so accept the ready check on their behalf.
+    if (!res.ready_check.accepted.includes(state.playerID)) {
+      await requestJSON(`${state.gatewayURL}/v1/matchmaking/accept`, {
+        method: "POST",
+        headers: authHeaders(),
+        body: JSON.stringify({ ticket_id: ticketID, accept: true }),
+      });
+    }
+    setStatus("Match found, waiting for other players to accept...");
+    return null;
+  }
+  if (res.status === "not_found" || res.status === "cancelled") {
+    throw fatalError("match cancelled");
+  }
+  const estimate = res.estimated_wait_sec ? ` (about ${res.estimated_wait_sec}s left)` : "";
+  setStatus(`Queueing... ${res.waited_sec || 0}s${estimate}`);
+  return null;
+}
+
+function fatalError(message) {
+  const err = new Error(message);
+  err.fatal = true;
+  return err;
+}
+
+function connectWebSocket(rawServerAddr, joinTicket) {
+  state.serverAddr = rawServerAddr;
+  return openGameSocket(`ticket=${encodeURIComponent(joinTicket)}`);
+}
+
+function openGameSocket(query) {
   return new Promise((resolve, reject) => {
-    const wsURL = resolveWebSocketURL(rawServerAddr);
-    const url = `${wsURL}?player_id=${encodeURIComponent(state.playerID)}&display_name=${encodeURIComponent(state.displayName)}`;
+    const wsURL = resolveWebSocketURL(state.serverAddr);
+    const url = `${wsURL}?${query}`;
 
     const ws = new WebSocket(url);
     let opened = false;
@@ -622,11 +763,10 @@ function connectWebSocket(rawServerAddr) {
       }
     };
 
-    ws.onclose = () => {
+    ws.onclose = (evt) => {
       state.connected = false;
-      if (state.mode === "online") {
-        setStatus("Disconnected");
-        menu.style.display = "block";
+      if (opened && state.mode === "online") {
+        handleSocketClosed(evt);
       }
     };
 
@@ -641,6 +781,35 @@ function connectWebSocket(rawServerAddr) {
   });
 }
 
+async function handleSocketClosed(evt) {
+  const token = state.reconnectToken;
+  if (!token || evt.code === CLOSE_SUPERSEDED) {
+    setStatus("Disconnected");
+    menu.style.display = "block";
+    return;
+  }
+
+  // The server holds our car for a grace period; resume with the same car if we can.
+  for (let attempt = 0; attempt < RECONNECT_ATTEMPTS; attempt += 1) {
+    setStatus(`Reconnecting... (${attempt + 1}/${RECONNECT_ATTEMPTS})`);
+    await sleep(Math.min(500 * 2 ** attempt, 4000));
+    if (state.mode !== "online") {
+      return;
+    }
+    state.baselines.clear();
+    state.ackTick = 0;
+    try {
+      await openGameSocket(`resume=${encodeURIComponent(token)}&match_id=${encodeURIComponent(state.matchID)}`);
+      return;
+    } catch (_err) {
+      // Retry until attempts run out.
+    }
+  }
+  state.reconnectToken = "";
+  setStatus("Disconnected");
+  menu.style.display = "block";
+}
+
 function setStartButtonsBusy(busy) {
   startOnlineBtn.disabled = busy;
   startOfflineBtn.disabled = busy;
@@ -657,6 +826,7 @@ function closeOnlineSocket() {
   }
   state.ws = null;
   state.connected = false;
+  state.reconnectToken = "";
 }
 
 function stopOfflineMode() {
@@ -892,7 +1062,7 @@ function updateOfflineCar(car, input, ctx, dt) {
 
   const usingBoost = input.boost && car.boost > 0 && input.throttle > 0;
   if (usingBoost) {
-    forwardSpeed += BOOST_ACCEL * dt;
+    forwardSpeed += physics.boostAccel * dt;
     car.boost = Math.max(0, car.boost - 34 * dt);
   } else {
     car.boost = Math.min(100, car.boost + 8 * dt);
@@ -962,7 +1132,7 @@ function updateOfflineCar(car, input, ctx, dt) {
     }
   }
 
-  car.velocity.z += GRAVITY * dt;
+  car.velocity.z += physics.gravity * dt;
   if (car.is_grounded) {
     car.velocity.x *= GROUND_FRICTION;
     car.velocity.y *= GROUND_FRICTION;
@@ -1023,7 +1193,7 @@ function clampOfflineCarBounds(car) {
 }
 
 function updateOfflineBall(ball, dt) {
-  ball.velocity.z += GRAVITY * dt;
+  ball.velocity.z += physics.gravity * dt;
   ball.position.x += ball.velocity.x * dt;
   ball.position.y += ball.velocity.y * dt;
   ball.position.z += ball.velocity.z * dt;
@@ -1038,8 +1208,8 @@ function updateOfflineBall(ball, dt) {
   ball.velocity.z *= 0.9994;
 
   const speed = Math.hypot(ball.velocity.x, ball.velocity.y, ball.velocity.z);
-  if (speed > BALL_MAX_SPEED && speed > 0) {
-    const s = BALL_MAX_SPEED / speed;
+  if (speed > physics.ballMaxSpeed && speed > 0) {
+    const s = physics.ballMaxSpeed / speed;
     ball.velocity.x *= s;
     ball.velocity.y *= s;
     ball.velocity.z *= s;
@@ -1051,11 +1221,11 @@ function clampOfflineBallBounds(ball) {
   const halfW = ARENA_WIDTH_UU / 2;
   if (ball.position.z < BALL_RADIUS_UU) {
     ball.position.z = BALL_RADIUS_UU;
-    ball.velocity.z = -ball.velocity.z * BALL_RESTITUTION;
+    ball.velocity.z = -ball.velocity.z * physics.ballRestitution;
   }
   if (ball.position.z > ARENA_HEIGHT_UU - BALL_RADIUS_UU) {
     ball.position.z = ARENA_HEIGHT_UU - BALL_RADIUS_UU;
-    ball.velocity.z = -ball.velocity.z * BALL_RESTITUTION;
+    ball.velocity.z = -ball.velocity.z * physics.ballRestitution;
   }
 
   const inGoalY = Math.abs(ball.position.y) <= GOAL_WIDTH_UU / 2;
@@ -1118,15 +1288,47 @@ function resolveOfflineCarBallCollision(car, ball) {
 function handleServerEnvelope(envelope) {
   switch (envelope.type) {
     case "welcome":
+      if (envelope.reconnect_token) {
+        state.reconnectToken = envelope.reconnect_token;
+      }
+      if (envelope.physics) {
+        physics.gravity = envelope.physics.gravity;
+        physics.boostAccel = envelope.physics.boost_accel;
+        physics.ballRestitution = envelope.physics.ball_restitution;
+        physics.ballMaxSpeed = envelope.physics.ball_max_speed;
+      }
+    // falls through
     case "state":
       if (envelope.state) {
+        updateSnapshotAge(envelope.server_ms);
+        rememberBaseline(envelope.state);
         applyMatchState(envelope.state);
       }
       break;
+    case "delta":
+      if (envelope.delta) {
+        const base = state.baselines.get(envelope.delta.base_tick);
+        if (!base) {
+          // Baseline already discarded; keep acking the newest tick so the server resends a full snapshot.
+          break;
+        }
+        updateSnapshotAge(envelope.server_ms);
+        const next = applyStateDelta(base, envelope.delta);
+        rememberBaseline(next);
+        applyMatchState(next);
+      }
+      break;
     case "pong":
-      if (state.pingSentAt > 0) {
+      if (envelope.sync) {
+        completeClockSync(envelope.sync);
+      } else if (state.pingSentAt > 0) {
         state.pingMS = Math.round(performance.now() - state.pingSentAt);
-        pingEl.textContent = `${state.pingMS}`;
+      }
+      pingEl.textContent = `${state.pingMS ?? "--"}`;
+      break;
+    case "forfeit_vote":
+      if (envelope.forfeit) {
+        showForfeitVote(envelope.forfeit);
       }
       break;
     case "error":
@@ -1137,6 +1339,92 @@ function handleServerEnvelope(envelope) {
   }
 }
 
+// sendForfeitVote answers the team's forfeit vote; accepting with no vote open starts one.
+// The server only allows it once a teammate has abandoned the match.
+function sendForfeitVote(accept) {
+  if (state.mode !== "online" || !state.ws || state.ws.readyState !== WebSocket.OPEN) {
+    return;
+  }
+  if (!accept && !state.forfeitVote) {
+    return;
+  }
+  state.ws.send(JSON.stringify({ type: "forfeit", accept }));
+}
+
+function showForfeitVote(vote) {
+  state.forfeitVote = vote.status === "open" ? vote : null;
+  if (vote.status === "passed") {
+    setStatus("Your team forfeited the match.");
+    return;
+  }
+  if (vote.status === "failed") {
+    setStatus("Forfeit vote failed.");
+    return;
+  }
+  const secs = Math.max(Math.ceil((vote.deadline_ms - Date.now()) / 1000), 0);
+  setStatus(`Forfeit vote ${vote.accepted.length}/${vote.voters.length} (Y to forfeit, N to play on, ${secs}s)`);
+}
+
+function completeClockSync(sync) {
+  const clientRecvMS = Date.now();
+  if (state.ws && state.ws.readyState === WebSocket.OPEN) {
+    state.ws.send(JSON.stringify({ type: "sync", sync: { ...sync, client_recv_ms: clientRecvMS } }));
+  }
+  // Prefer the server's smoothed estimates once it has some; fall back to this exchange.
+  if (sync.rtt_ms > 0) {
+    state.pingMS = sync.rtt_ms;
+    state.clockOffsetMS = sync.offset_ms ?? 0;
+    return;
+  }
+  const rtt = (clientRecvMS - sync.client_send_ms) - (sync.server_send_ms - sync.server_recv_ms);
+  const offset = ((sync.server_recv_ms - sync.client_send_ms) + (sync.server_send_ms - clientRecvMS)) / 2;
+  state.pingMS = Math.max(Math.round(rtt), 0);
+  state.clockOffsetMS = Math.round(offset);
+}
+
+function updateSnapshotAge(serverMS) {
+  if (!serverMS || state.clockOffsetMS === null) {
+    state.snapshotAgeMS = 0;
+    return;
+  }
+  const age = Date.now() + state.clockOffsetMS - serverMS;
+  state.snapshotAgeMS = clamp(age, 0, 150);
+}
+
+function rememberBaseline(matchState) {
+  state.baselines.set(matchState.tick, matchState);
+  if (matchState.tick > state.ackTick) {
+    state.ackTick = matchState.tick;
+  }
+  while (state.baselines.size > BASELINE_HISTORY) {
+    const oldest = state.baselines.keys().next().value;
+    state.baselines.delete(oldest);
+  }
+}
+
+function applyStateDelta(base, delta) {
+  const cars = { ...(base.cars || {}) };
+  for (const id of delta.removed_cars || []) {
+    delete cars[id];
+  }
+  for (const [id, changes] of Object.entries(delta.cars || {})) {
+    if (cars[id]) {
+      cars[id] = { ...cars[id], ...changes };
+    }
+  }
+  for (const [id, car] of Object.entries(delta.added_cars || {})) {
+    cars[id] = car;
+  }
+  return {
+    ...base,
+    tick: delta.tick,
+    cars,
+    ball: delta.ball || base.ball,
+    score: delta.score || base.score,
+    events: delta.events || [],
+  };
+}
+
 function applyMatchState(matchState) {
   scoreOrangeEl.textContent = String(matchState.score.orange ?? 0);
   scoreBlueEl.textContent = String(matchState.score.blue ?? 0);
@@ -1156,7 +1444,7 @@ function applyMatchState(matchState) {
 
     const leadSeconds = carID === state.localCarID
       ? Math.min(((state.pingMS ?? 28) / 1000) * 0.7, 0.07)
-      : 0.02;
+      : Math.max(state.snapshotAgeMS / 1000, 0.02);
     const predictedPos = {
       x: carState.position.x + carState.velocity.x * leadSeconds,
       y: carState.position.y + carState.velocity.y * leadSeconds,
@@ -1186,7 +1474,14 @@ function applyMatchState(matchState) {
     if (!state.ballVisual.userData.targetPos) {
       state.ballVisual.userData.targetPos = new THREE.Vector3();
     }
-    state.ballVisual.userData.targetPos.copy(toScenePos(matchState.ball.position));
+    const ballLead = state.snapshotAgeMS / 1000;
+    const ballPos = matchState.ball.position;
+    const ballVel = matchState.ball.velocity || { x: 0, y: 0, z: 0 };
+    state.ballVisual.userData.targetPos.copy(toScenePos({
+      x: ballPos.x + ballVel.x * ballLead,
+      y: ballPos.y + ballVel.y * ballLead,
+      z: Math.max(ballPos.z + ballVel.z * ballLead, BALL_RADIUS_UU),
+    }));
   }
 
   if (Array.isArray(matchState.events) && matchState.events.length > 0) {
@@ -1215,6 +1510,9 @@ function labelForEvent(ev) {
   if (ev.type === "player_join") {
     return "PLAYER JOINED";
   }
+  if (ev.type === "forfeit") {
+    return `${(ev.team || "").toUpperCase()} FORFEITS`;
+  }
   return ev.type.replaceAll("_", " ").toUpperCase();
 }
 
@@ -13,7 +13,7 @@ trap cleanup EXIT INT TERM
 PIDS=""
 
 cd "$ROOT_DIR/backend"
-GAME_ADDR=:9003 MATCH_DURATION_SEC=300 go run ./cmd/gameserver > /tmp/velocity_gameserver.log 2>&1 &
+GAME_ADDR=:9003 MATCH_DURATION_SEC=300 MATCHMAKER_HTTP=http://localhost:9001 go run ./cmd/gameserver > /tmp/velocity_gameserver.log 2>&1 &
 PIDS+=" $!"
 MATCHMAKER_ADDR=:9001 GAME_WS_ADDR=ws://localhost:9003/ws go run ./cmd/matchmaker > /tmp/velocity_matchmaker.log 2>&1 &
 PIDS+=" $!"